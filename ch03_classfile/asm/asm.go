@@ -0,0 +1,322 @@
+package asm
+
+import (
+	"bufio"
+	"fmt"
+	"jvmgo/ch03_classfile/classfile"
+	"strconv"
+	"strings"
+)
+
+// 汇编源码的格式与 Jasmin 基本相同，每行一条指令或伪指令，";" 之后到行尾是注释，例如：
+//
+// .source Hello.java
+// .class public Hello
+// .super java/lang/Object
+// .field public static final X I = 42
+//
+// .method public static main([Ljava/lang/String;)V
+// .limit stack 2
+// .line 3
+// getstatic java/lang/System/out Ljava/io/PrintStream;
+// ldc "hello"
+// invokevirtual java/io/PrintStream/println(Ljava/lang/String;)V
+// return
+// .end method
+//
+// 支持的伪指令：
+// .version 主版本号 [次版本号]，默认为 49 0，此时不需要 StackMapTable，汇编器也不会生成 StackMapTable
+// .source 源文件名
+// .class/.interface 访问标志... 类名，类会自动加上 ACC_SUPER，接口会自动加上 ACC_ABSTRACT
+// .super 超类名，默认为 java/lang/Object
+// .implements 接口名
+// .field 访问标志... 字段名 描述符 [= 常量值]
+// .method 访问标志... 方法名描述符，直到 .end method 为止
+// .throws 异常类名
+// .limit stack/locals 数值，不指定时由 InstructionList.Commit() 计算
+// .line 行号，对应下一条指令
+// .catch 异常类名|all from 标签 to 标签 using 标签
+//
+// 标签写作 "名称:"，指向下一条指令，在 .end method 之前定义的标签指向字节码末尾，只能用于 .catch 的 to
+// 指令的操作数写法：
+// 字段：getstatic 类名/字段名 描述符
+// 方法：invokevirtual 类名/方法名描述符，接口方法在前面加上 InterfaceMethod，invokeinterface 的 count 可以省略
+// 常量：ldc 123、ldc 1.5、ldc "字符串"、ldc java/lang/String（类常量），ldc2_w 的整数为 long，小数为 double，
+// 也可以用后缀 L、F、D 明确指定类型
+// 类：new、anewarray、checkcast、instanceof 后面是类名，multianewarray 后面是数组描述符和维数
+// 局部变量：iload 4，在指令前加上 wide 强制使用 wide 前缀，索引超过 255 时自动使用 wide 前缀
+// switch：tableswitch 最小值，之后每行一个标签；lookupswitch 之后每行一个 "值 : 标签"，
+// 最后一行都是 "default : 标签"
+// invokedynamic 需要 BootstrapMethods 属性，目前不支持
+
+type assembler struct {
+	name   string // 源文件名，只用于错误信息
+	lineNo int    // 当前行号
+
+	majorVersion uint16
+	minorVersion uint16
+	sourceFile   string
+	accessFlags  uint16
+	className    string
+	superName    string
+	interfaces   []string
+	cf           *classfile.ClassFile // 遇到第一个字段或方法时才创建，在此之前 .super 等伪指令可以按任意顺序出现
+
+	method *methodAssembler // 正在汇编的方法，不在方法中时为 nil
+}
+
+var accessFlagNames = map[string]uint16{
+	"public": 0x0001, "private": 0x0002, "protected": 0x0004, "static": 0x0008,
+	"final": 0x0010, "super": 0x0020, "synchronized": 0x0020, "volatile": 0x0040,
+	"bridge": 0x0040, "transient": 0x0080, "varargs": 0x0080, "native": 0x0100,
+	"interface": 0x0200, "abstract": 0x0400, "strict": 0x0800, "strictfp": 0x0800,
+	"synthetic": 0x1000, "annotation": 0x2000, "enum": 0x4000,
+}
+
+// 把汇编源码汇编为 ClassFile，name 是源文件名，只用于错误信息
+// 出错时返回的错误以 "文件名:行号: " 开头
+func Assemble(name, source string) (cf *classfile.ClassFile, err error) {
+	self := &assembler{name: name, majorVersion: 49}
+	defer func() {
+		if r := recover(); r != nil {
+			cf = nil
+			err = fmt.Errorf("%s:%d: %v", self.name, self.lineNo, r)
+		}
+	}()
+
+	scanner := bufio.NewScanner(strings.NewReader(source))
+	for scanner.Scan() {
+		self.lineNo++
+		tokens := tokenize(scanner.Text())
+		if len(tokens) > 0 {
+			self.statement(tokens)
+		}
+	}
+	if self.method != nil {
+		panic("missing .end method")
+	}
+	return self.classFile(), nil
+}
+
+// 把一行源码切分为单词，双引号中的字符串作为一个单词（保留引号），冒号总是作为单独的单词
+// 与 Jasmin 相同，只有出现在单词开头的 ";" 才表示注释，描述符中的 ";" 不受影响
+func tokenize(line string) []string {
+	var tokens []string
+	for i := 0; i < len(line); {
+		switch c := line[i]; {
+		case c == ';':
+			return tokens
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '"':
+			j := i + 1
+			for j < len(line) && line[j] != '"' {
+				if line[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(line) {
+				panic("unterminated string")
+			}
+			tokens = append(tokens, line[i:j+1])
+			i = j + 1
+		case c == ':':
+			tokens = append(tokens, ":")
+			i++
+		default:
+			j := i
+			for j < len(line) && !strings.ContainsRune(" \t\r:\"", rune(line[j])) {
+				j++
+			}
+			tokens = append(tokens, line[i:j])
+			i = j
+		}
+	}
+	return tokens
+}
+
+func (self *assembler) statement(tokens []string) {
+	if self.method != nil {
+		if tokens[0] == ".end" {
+			expectTokens(tokens, 2)
+			if tokens[1] != "method" {
+				panic("unexpected .end " + tokens[1])
+			}
+			self.method.finish()
+			self.method = nil
+			return
+		}
+		self.method.statement(tokens)
+		return
+	}
+
+	switch tokens[0] {
+	case ".version":
+		if len(tokens) != 2 && len(tokens) != 3 {
+			panic(".version expects a major and an optional minor version")
+		}
+		self.majorVersion = uint16(parseInt(tokens[1], 0, 0xFFFF))
+		if len(tokens) == 3 {
+			self.minorVersion = uint16(parseInt(tokens[2], 0, 0xFFFF))
+		}
+		if self.cf != nil {
+			self.cf.SetVersion(self.majorVersion, self.minorVersion)
+		}
+	case ".source":
+		expectTokens(tokens, 2)
+		self.sourceFile = tokens[1]
+		if self.cf != nil {
+			self.cf.SetSourceFile(self.sourceFile)
+		}
+	case ".class", ".interface":
+		if self.className != "" {
+			panic("duplicate " + tokens[0])
+		}
+		if len(tokens) < 2 {
+			panic(tokens[0] + " expects a class name")
+		}
+		self.accessFlags = parseAccessFlags(tokens[1 : len(tokens)-1])
+		if tokens[0] == ".interface" {
+			self.accessFlags |= classfile.ACC_INTERFACE | classfile.ACC_ABSTRACT
+		} else {
+			self.accessFlags |= classfile.ACC_SUPER
+		}
+		self.className = tokens[len(tokens)-1]
+	case ".super":
+		expectTokens(tokens, 2)
+		if self.cf != nil {
+			panic(".super must come before fields and methods")
+		}
+		self.superName = tokens[1]
+	case ".implements":
+		expectTokens(tokens, 2)
+		if self.cf != nil {
+			self.cf.AddInterface(tokens[1])
+		} else {
+			self.interfaces = append(self.interfaces, tokens[1])
+		}
+	case ".field":
+		self.field(tokens[1:])
+	case ".method":
+		if len(tokens) < 2 {
+			panic(".method expects a name and a descriptor")
+		}
+		nameAndDescriptor := tokens[len(tokens)-1]
+		i := strings.IndexByte(nameAndDescriptor, '(')
+		if i <= 0 {
+			panic("invalid method name and descriptor: " + nameAndDescriptor)
+		}
+		cf := self.classFile()
+		method := cf.AddMethod(parseAccessFlags(tokens[1:len(tokens)-1]), nameAndDescriptor[:i], nameAndDescriptor[i:])
+		self.method = newMethodAssembler(self, cf, method)
+	default:
+		panic("unexpected " + tokens[0] + " outside of a method")
+	}
+}
+
+// 返回正在生成的 ClassFile，第一次调用时根据之前的伪指令创建
+func (self *assembler) classFile() *classfile.ClassFile {
+	if self.cf != nil {
+		return self.cf
+	}
+	if self.className == "" {
+		panic("missing .class or .interface")
+	}
+	if self.superName == "" && self.className != "java/lang/Object" {
+		self.superName = "java/lang/Object"
+	}
+	self.cf = classfile.NewClassFile(self.majorVersion, self.minorVersion, self.accessFlags, self.className, self.superName)
+	for _, name := range self.interfaces {
+		self.cf.AddInterface(name)
+	}
+	if self.sourceFile != "" {
+		self.cf.SetSourceFile(self.sourceFile)
+	}
+	return self.cf
+}
+
+// .field 访问标志... 字段名 描述符 [= 常量值]
+func (self *assembler) field(tokens []string) {
+	var value string
+	hasValue := false
+	for i, token := range tokens {
+		if token == "=" {
+			if i != len(tokens)-2 {
+				panic("invalid field initializer")
+			}
+			value, hasValue = tokens[i+1], true
+			tokens = tokens[:i]
+			break
+		}
+	}
+	if len(tokens) < 2 {
+		panic(".field expects a name and a descriptor")
+	}
+	cf := self.classFile()
+	name, descriptor := tokens[len(tokens)-2], tokens[len(tokens)-1]
+	field := cf.AddField(parseAccessFlags(tokens[:len(tokens)-2]), name, descriptor)
+	if hasValue {
+		cf.SetConstantValue(field, fieldConstant(cf, descriptor, value))
+	}
+}
+
+// 根据字段描述符把常量值加入常量池
+func fieldConstant(cf *classfile.ClassFile, descriptor, value string) uint16 {
+	switch descriptor {
+	case "I", "S", "C", "B", "Z":
+		return cf.AddInteger(int32(parseInt(value, -1<<31, 1<<31-1)))
+	case "J":
+		return cf.AddLong(parseInt(strings.TrimRight(value, "lL"), -1<<63, 1<<63-1))
+	case "F":
+		return cf.AddFloat(float32(parseFloat(strings.TrimRight(value, "fF"), 32)))
+	case "D":
+		return cf.AddDouble(parseFloat(strings.TrimRight(value, "dD"), 64))
+	case "Ljava/lang/String;":
+		return cf.AddString(parseString(value))
+	}
+	panic("field of type " + descriptor + " cannot have a constant value")
+}
+
+func parseAccessFlags(words []string) uint16 {
+	var flags uint16
+	for _, word := range words {
+		flag, ok := accessFlagNames[word]
+		if !ok {
+			panic("unknown access flag: " + word)
+		}
+		flags |= flag
+	}
+	return flags
+}
+
+func expectTokens(tokens []string, n int) {
+	if len(tokens) != n {
+		panic(fmt.Sprintf("%s expects %d operand(s), got %d", tokens[0], n-1, len(tokens)-1))
+	}
+}
+
+func parseInt(s string, min, max int64) int64 {
+	val, err := strconv.ParseInt(s, 0, 64)
+	if err != nil || val < min || val > max {
+		panic(fmt.Sprintf("invalid number %s, expected %d to %d", s, min, max))
+	}
+	return val
+}
+
+func parseFloat(s string, bitSize int) float64 {
+	val, err := strconv.ParseFloat(s, bitSize)
+	if err != nil {
+		panic("invalid number " + s)
+	}
+	return val
+}
+
+// 双引号中的字符串使用 Go 的转义规则，例如 \n、\t、\" 和 \u0001
+func parseString(s string) string {
+	str, err := strconv.Unquote(s)
+	if err != nil || !strings.HasPrefix(s, `"`) {
+		panic("invalid string " + s)
+	}
+	return str
+}
@@ -0,0 +1,109 @@
+package asm
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"jvmgo/ch03_classfile/classfile"
+	"jvmgo/ch03_classfile/javap"
+)
+
+var update = flag.Bool("update", false, "重新生成 testdata 中的 .txt 文件")
+
+// testdata 中的每个 .j 文件是一个测试用例，同名的 .txt 文件是汇编结果写出后重新解析，
+// 由 javap -c -p 输出的期望结果
+func TestAssembleFixtures(t *testing.T) {
+	for _, file := range fixtures(t) {
+		name := strings.TrimSuffix(filepath.Base(file), ".j")
+		t.Run(name, func(t *testing.T) {
+			cf := assembleFixture(t, file)
+			for _, e := range cf.CheckFormat() {
+				t.Errorf("CheckFormat: %v", e)
+			}
+
+			var buf bytes.Buffer
+			if err := javap.Write(&buf, cf, nil, javap.Options{Code: true, Private: true}); err != nil {
+				t.Fatal(err)
+			}
+			golden := strings.TrimSuffix(file, ".j") + ".txt"
+			if *update {
+				if err := ioutil.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != string(want) {
+				t.Errorf("output differs from %s:\n%s", golden, got)
+			}
+		})
+	}
+}
+
+// 除了不支持的 invokedynamic 和不能出现在 class 文件中的保留操作码，每个操作码至少出现在一个测试用例中
+func TestFixturesCoverAllOpcodes(t *testing.T) {
+	covered := map[uint8]bool{}
+	for _, file := range fixtures(t) {
+		cf := assembleFixture(t, file)
+		for _, method := range cf.Methods() {
+			list, err := classfile.NewInstructionList(cf, method)
+			if err != nil {
+				t.Fatalf("%s: %s: %v", file, method.Name(), err)
+			}
+			for insn := list.First(); insn != nil; insn = insn.Next() {
+				covered[insn.Opcode()] = true
+				if insn.IsWide() {
+					covered[classfile.OP_wide] = true
+				}
+			}
+		}
+	}
+	for op := 0; op < 256; op++ {
+		switch name := classfile.OpcodeName(uint8(op)); uint8(op) {
+		case classfile.OP_invokedynamic, classfile.OP_breakpoint, classfile.OP_impdep1, classfile.OP_impdep2:
+		default:
+			if name != "" && !covered[uint8(op)] {
+				t.Errorf("no fixture covers %s", name)
+			}
+		}
+	}
+}
+
+func fixtures(t *testing.T) []string {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.j"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no fixtures in testdata")
+	}
+	return files
+}
+
+// 汇编测试用例，写出后重新解析，得到与从文件读入的类相同的 ClassFile
+func assembleFixture(t *testing.T, file string) *classfile.ClassFile {
+	source, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err := Assemble(filepath.Base(file), string(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := classfile.Serialize(cf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err = classfile.Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cf
+}
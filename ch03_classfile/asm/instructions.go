@@ -0,0 +1,489 @@
+package asm
+
+import (
+	"fmt"
+	"jvmgo/ch03_classfile/classfile"
+	"strconv"
+	"strings"
+)
+
+// methodAssembler 汇编 .method 和 .end method 之间的内容
+// 标签可以在定义之前引用，所以跳转指令和 switch 先以 nil 为目标加入 InstructionList，到 .end method 时再统一设置
+type methodAssembler struct {
+	owner  *assembler // 用于在记录跳转时取得当前行号
+	cf     *classfile.ClassFile
+	method *classfile.MemberInfo
+	list   *classfile.InstructionList
+
+	labels        map[string]*label
+	pendingLabels []*label // 已定义但还没有遇到下一条指令的标签
+	pendingLine   int      // .line 指定的行号，对应下一条指令，-1 表示没有
+	wide          bool     // 上一行是单独的 wide 前缀
+
+	branches []*branchFixup
+	switches []*switchFixup
+	catches  []*catchDirective
+	sw       *switchFixup // 正在读取跳转表的 switch 指令
+}
+
+type label struct {
+	name    string
+	insn    *classfile.Instruction // 标签之后的第一条指令，为 nil 表示字节码末尾
+	defined bool
+}
+
+type branchFixup struct {
+	insn   *classfile.Instruction
+	label  string
+	lineNo int
+}
+
+type switchFixup struct {
+	insn         *classfile.Instruction
+	opcode       uint8
+	low          int32
+	keys         []int32
+	labels       []string
+	defaultLabel string
+	lineNo       int
+}
+
+type catchDirective struct {
+	catchType uint16
+	from      string
+	to        string
+	using     string
+	lineNo    int
+}
+
+// 助记符到操作码的映射，由 classfile.OpcodeName() 生成
+var opcodes = map[string]uint8{}
+
+func init() {
+	for op := 0; op < 256; op++ {
+		if name := classfile.OpcodeName(uint8(op)); name != "" {
+			opcodes[name] = uint8(op)
+		}
+	}
+}
+
+// newarray 的 atype 操作数
+var arrayTypes = map[string]uint8{
+	"boolean": 4, "char": 5, "float": 6, "double": 7,
+	"byte": 8, "short": 9, "int": 10, "long": 11,
+}
+
+func newMethodAssembler(owner *assembler, cf *classfile.ClassFile, method *classfile.MemberInfo) *methodAssembler {
+	return &methodAssembler{
+		owner:       owner,
+		cf:          cf,
+		method:      method,
+		list:        cf.NewCode(method),
+		labels:      map[string]*label{},
+		pendingLine: -1,
+	}
+}
+
+func (self *methodAssembler) statement(tokens []string) {
+	if self.sw != nil {
+		self.switchCase(tokens)
+		return
+	}
+	// "名称:" 定义标签，同一行的后面还可以有指令
+	if len(tokens) >= 2 && tokens[1] == ":" {
+		self.defineLabel(tokens[0])
+		if len(tokens) > 2 {
+			self.statement(tokens[2:])
+		}
+		return
+	}
+	if strings.HasPrefix(tokens[0], ".") {
+		self.directive(tokens)
+		return
+	}
+	if tokens[0] == "wide" {
+		if len(tokens) == 1 {
+			self.wide = true
+			return
+		}
+		self.instruction(tokens[1:], true)
+		return
+	}
+	wide := self.wide
+	self.wide = false
+	self.instruction(tokens, wide)
+}
+
+func (self *methodAssembler) directive(tokens []string) {
+	switch tokens[0] {
+	case ".limit":
+		expectTokens(tokens, 3)
+		value := uint16(parseInt(tokens[2], 0, 0xFFFF))
+		switch tokens[1] {
+		case "stack":
+			self.list.SetMaxStack(value)
+		case "locals":
+			self.list.SetMaxLocals(value)
+		default:
+			panic("unknown .limit " + tokens[1])
+		}
+	case ".line":
+		expectTokens(tokens, 2)
+		self.pendingLine = int(parseInt(tokens[1], 0, 0xFFFF))
+	case ".throws":
+		expectTokens(tokens, 2)
+		self.cf.AddThrows(self.method, tokens[1])
+	case ".catch":
+		// .catch 类名|all from 标签 to 标签 using 标签
+		expectTokens(tokens, 8)
+		if tokens[2] != "from" || tokens[4] != "to" || tokens[6] != "using" {
+			panic(".catch expects: .catch <class>|all from <label> to <label> using <label>")
+		}
+		catch := &catchDirective{from: tokens[3], to: tokens[5], using: tokens[7], lineNo: self.owner.lineNo}
+		if tokens[1] != "all" {
+			catch.catchType = self.cf.AddClass(tokens[1])
+		}
+		self.catches = append(self.catches, catch)
+	default:
+		panic("unexpected " + tokens[0] + " in a method")
+	}
+}
+
+func (self *methodAssembler) lookupLabel(name string) *label {
+	l := self.labels[name]
+	if l == nil {
+		l = &label{name: name}
+		self.labels[name] = l
+	}
+	return l
+}
+
+func (self *methodAssembler) defineLabel(name string) {
+	l := self.lookupLabel(name)
+	if l.defined {
+		panic("duplicate label " + name)
+	}
+	l.defined = true
+	self.pendingLabels = append(self.pendingLabels, l)
+}
+
+// 把指令追加到 InstructionList，并绑定之前定义的标签和 .line 行号
+func (self *methodAssembler) emit(insn *classfile.Instruction) {
+	self.list.Append(insn)
+	for _, l := range self.pendingLabels {
+		l.insn = insn
+	}
+	self.pendingLabels = nil
+	if self.pendingLine >= 0 {
+		self.list.AddLineNumber(insn, uint16(self.pendingLine))
+		self.pendingLine = -1
+	}
+}
+
+func (self *methodAssembler) instruction(tokens []string, wide bool) {
+	op, ok := opcodes[tokens[0]]
+	if !ok {
+		panic("unknown instruction " + tokens[0])
+	}
+	if wide && !isLocalInstruction(op) && op != classfile.OP_iinc {
+		panic("wide cannot be applied to " + tokens[0])
+	}
+
+	switch {
+	case isBranchInstruction(op):
+		expectTokens(tokens, 2)
+		insn := classfile.NewBranchInstruction(op, nil)
+		self.branches = append(self.branches, &branchFixup{insn: insn, label: tokens[1], lineNo: self.owner.lineNo})
+		self.emit(insn)
+	case isLocalInstruction(op):
+		expectTokens(tokens, 2)
+		index := uint16(parseInt(tokens[1], 0, 0xFFFF))
+		if wide {
+			self.emit(classfile.NewInstruction(op, uint8(index>>8), uint8(index)))
+		} else {
+			self.emit(classfile.NewLocalInstruction(op, index))
+		}
+	case op == classfile.OP_iinc:
+		expectTokens(tokens, 3)
+		index := parseInt(tokens[1], 0, 0xFFFF)
+		value := parseInt(tokens[2], -0x8000, 0x7FFF)
+		if wide || index > 0xFF || value < -0x80 || value > 0x7F {
+			self.emit(classfile.NewInstruction(op, uint8(index>>8), uint8(index), uint8(value>>8), uint8(value)))
+		} else {
+			self.emit(classfile.NewInstruction(op, uint8(index), uint8(value)))
+		}
+	case op == classfile.OP_bipush:
+		expectTokens(tokens, 2)
+		self.emit(classfile.NewInstruction(op, uint8(parseInt(tokens[1], -0x80, 0x7F))))
+	case op == classfile.OP_sipush:
+		expectTokens(tokens, 2)
+		value := parseInt(tokens[1], -0x8000, 0x7FFF)
+		self.emit(classfile.NewInstruction(op, uint8(value>>8), uint8(value)))
+	case op == classfile.OP_newarray:
+		expectTokens(tokens, 2)
+		atype, ok := arrayTypes[tokens[1]]
+		if !ok {
+			panic("invalid array type " + tokens[1])
+		}
+		self.emit(classfile.NewInstruction(op, atype))
+	case op == classfile.OP_ldc || op == classfile.OP_ldc_w || op == classfile.OP_ldc2_w:
+		expectTokens(tokens, 2)
+		self.ldc(op, tokens[1])
+	case op >= classfile.OP_getstatic && op <= classfile.OP_putfield:
+		expectTokens(tokens, 3)
+		className, name := splitMemberName(tokens[1])
+		self.emit(classfile.NewCpInstruction(op, self.cf.AddFieldref(className, name, tokens[2])))
+	case op >= classfile.OP_invokevirtual && op <= classfile.OP_invokeinterface:
+		self.invoke(op, tokens)
+	case op == classfile.OP_invokedynamic:
+		panic("invokedynamic is not supported")
+	case op == classfile.OP_new || op == classfile.OP_anewarray ||
+		op == classfile.OP_checkcast || op == classfile.OP_instanceof:
+		expectTokens(tokens, 2)
+		self.emit(classfile.NewCpInstruction(op, self.cf.AddClass(tokens[1])))
+	case op == classfile.OP_multianewarray:
+		expectTokens(tokens, 3)
+		index := self.cf.AddClass(tokens[1])
+		dimensions := uint8(parseInt(tokens[2], 1, 0xFF))
+		self.emit(classfile.NewInstruction(op, uint8(index>>8), uint8(index), dimensions))
+	case op == classfile.OP_tableswitch:
+		expectTokens(tokens, 2)
+		low := int32(parseInt(tokens[1], -1<<31, 1<<31-1))
+		self.sw = &switchFixup{opcode: op, low: low, lineNo: self.owner.lineNo}
+	case op == classfile.OP_lookupswitch:
+		expectTokens(tokens, 1)
+		self.sw = &switchFixup{opcode: op, lineNo: self.owner.lineNo}
+	case op == classfile.OP_wide:
+		panic("wide must be followed by an instruction")
+	default:
+		expectTokens(tokens, 1)
+		self.emit(classfile.NewInstruction(op))
+	}
+}
+
+func isBranchInstruction(op uint8) bool {
+	return (op >= classfile.OP_ifeq && op <= classfile.OP_jsr) ||
+		op == classfile.OP_ifnull || op == classfile.OP_ifnonnull ||
+		op == classfile.OP_goto_w || op == classfile.OP_jsr_w
+}
+
+// 显式给出局部变量索引的 load、store 和 ret 指令
+func isLocalInstruction(op uint8) bool {
+	return (op >= classfile.OP_iload && op <= classfile.OP_aload) ||
+		(op >= classfile.OP_istore && op <= classfile.OP_astore) ||
+		op == classfile.OP_ret
+}
+
+// ldc 的常量超过 255 项时自动改用 ldc_w
+func (self *methodAssembler) ldc(op uint8, value string) {
+	var index uint16
+	wideValue := false
+	switch kind, text := constantKind(value, op == classfile.OP_ldc2_w); kind {
+	case "int":
+		index = self.cf.AddInteger(int32(parseInt(text, -1<<31, 1<<31-1)))
+	case "long":
+		index = self.cf.AddLong(parseInt(text, -1<<63, 1<<63-1))
+		wideValue = true
+	case "float":
+		index = self.cf.AddFloat(float32(parseFloat(text, 32)))
+	case "double":
+		index = self.cf.AddDouble(parseFloat(text, 64))
+		wideValue = true
+	case "string":
+		index = self.cf.AddString(parseString(text))
+	default:
+		index = self.cf.AddClass(text)
+	}
+	if wideValue != (op == classfile.OP_ldc2_w) {
+		if wideValue {
+			panic("long and double constants need ldc2_w: " + value)
+		}
+		panic("ldc2_w expects a long or double constant: " + value)
+	}
+	if op == classfile.OP_ldc && index > 0xFF {
+		op = classfile.OP_ldc_w
+	}
+	self.emit(classfile.NewCpInstruction(op, index))
+}
+
+// 判断 ldc 操作数的类型，返回类型和去掉后缀的文本
+// 以数字、符号或小数点开头的是数值，带引号的是字符串，其余的是类名
+func constantKind(value string, wide bool) (string, string) {
+	if strings.HasPrefix(value, `"`) {
+		return "string", value
+	}
+	switch strings.TrimLeft(value, "+-") {
+	case "NaN", "Infinity":
+		if wide {
+			return "double", value
+		}
+		return "float", value
+	}
+	if value == "" || !strings.ContainsRune("0123456789+-.", rune(value[0])) {
+		return "class", value
+	}
+	unsigned := strings.TrimLeft(value, "+-")
+	if strings.HasPrefix(unsigned, "0x") || strings.HasPrefix(unsigned, "0X") {
+		// 十六进制数中的 d、f 是数字，只有 L 后缀有意义
+		if strings.HasSuffix(value, "L") || strings.HasSuffix(value, "l") {
+			return "long", value[:len(value)-1]
+		}
+		if wide {
+			return "long", value
+		}
+		return "int", value
+	}
+	switch value[len(value)-1] {
+	case 'L', 'l':
+		return "long", value[:len(value)-1]
+	case 'F', 'f':
+		return "float", value[:len(value)-1]
+	case 'D', 'd':
+		return "double", value[:len(value)-1]
+	}
+	if _, err := strconv.ParseInt(value, 0, 64); err == nil {
+		if wide {
+			return "long", value
+		}
+		return "int", value
+	}
+	if wide {
+		return "double", value
+	}
+	return "float", value
+}
+
+// invokevirtual 类名/方法名描述符，接口方法在前面加上 InterfaceMethod，invokeinterface 后面可以跟 count
+func (self *methodAssembler) invoke(op uint8, tokens []string) {
+	operands := tokens[1:]
+	isInterface := op == classfile.OP_invokeinterface
+	if len(operands) > 0 && operands[0] == "InterfaceMethod" {
+		isInterface = true
+		operands = operands[1:]
+	}
+	if len(operands) == 0 || len(operands) > 2 || (len(operands) == 2 && op != classfile.OP_invokeinterface) {
+		panic(tokens[0] + " expects a method reference")
+	}
+	i := strings.IndexByte(operands[0], '(')
+	if i < 0 {
+		panic("invalid method reference " + operands[0])
+	}
+	className, name := splitMemberName(operands[0][:i])
+	descriptor := operands[0][i:]
+	var index uint16
+	if isInterface {
+		index = self.cf.AddInterfaceMethodref(className, name, descriptor)
+	} else {
+		index = self.cf.AddMethodref(className, name, descriptor)
+	}
+	if len(operands) == 2 {
+		count := uint8(parseInt(operands[1], 1, 0xFF))
+		self.emit(classfile.NewInstruction(op, uint8(index>>8), uint8(index), count, 0))
+		return
+	}
+	self.emit(classfile.NewCpInstruction(op, index))
+}
+
+// 把 "java/lang/System/out" 切分为类名和成员名
+func splitMemberName(s string) (string, string) {
+	i := strings.LastIndexByte(s, '/')
+	if i <= 0 || i == len(s)-1 {
+		panic("invalid member reference " + s)
+	}
+	return s[:i], s[i+1:]
+}
+
+// 读取 switch 的跳转表，tableswitch 每行一个标签，lookupswitch 每行一个 "值 : 标签"，以 "default : 标签" 结束
+func (self *methodAssembler) switchCase(tokens []string) {
+	sw := self.sw
+	if tokens[0] == "default" {
+		if len(tokens) != 3 || tokens[1] != ":" {
+			panic("expected default : <label>")
+		}
+		sw.defaultLabel = tokens[2]
+		self.sw = nil
+		targets := make([]*classfile.Instruction, len(sw.labels))
+		if sw.opcode == classfile.OP_tableswitch {
+			sw.insn = classfile.NewTableSwitchInstruction(sw.low, nil, targets)
+		} else {
+			sw.insn = classfile.NewLookupSwitchInstruction(sw.keys, nil, targets)
+		}
+		self.switches = append(self.switches, sw)
+		self.emit(sw.insn)
+		return
+	}
+	if sw.opcode == classfile.OP_tableswitch {
+		if len(tokens) != 1 {
+			panic("expected a tableswitch label")
+		}
+		sw.labels = append(sw.labels, tokens[0])
+		return
+	}
+	if len(tokens) != 3 || tokens[1] != ":" {
+		panic("expected <key> : <label>")
+	}
+	key := int32(parseInt(tokens[0], -1<<31, 1<<31-1))
+	if n := len(sw.keys); n > 0 && key <= sw.keys[n-1] {
+		panic("lookupswitch keys must be sorted and unique")
+	}
+	sw.keys = append(sw.keys, key)
+	sw.labels = append(sw.labels, tokens[2])
+}
+
+// 设置所有跳转目标并写回 Code 属性，引用的标签必须已经定义
+// 标签出错时把当前行号改为引用标签的那一行，这样错误信息指向出错的指令而不是 .end method
+func (self *methodAssembler) finish() {
+	if self.sw != nil {
+		self.owner.lineNo = self.sw.lineNo
+		panic("missing default in the switch")
+	}
+	if self.wide {
+		panic("wide must be followed by an instruction")
+	}
+	for _, fixup := range self.branches {
+		fixup.insn.SetTarget(self.target(fixup.label, fixup.lineNo))
+	}
+	for _, sw := range self.switches {
+		targets := make([]*classfile.Instruction, len(sw.labels))
+		for i, name := range sw.labels {
+			targets[i] = self.target(name, sw.lineNo)
+		}
+		sw.insn.SetSwitchTargets(self.target(sw.defaultLabel, sw.lineNo), targets)
+	}
+	for _, catch := range self.catches {
+		start := self.resolve(catch.from, catch.lineNo)
+		end := self.resolve(catch.to, catch.lineNo)
+		handler := self.target(catch.using, catch.lineNo)
+		if start == nil {
+			self.owner.lineNo = catch.lineNo
+			panic("empty .catch range")
+		}
+		self.list.AddExceptionHandler(start, end, handler, catch.catchType)
+	}
+	if self.list.First() == nil {
+		panic("method " + self.method.Name() + " has no instructions")
+	}
+	if err := self.list.Commit(); err != nil {
+		panic(fmt.Sprintf("method %s%s: %v", self.method.Name(), self.method.Descriptor(), err))
+	}
+}
+
+// 返回标签指向的指令，nil 表示字节码末尾
+func (self *methodAssembler) resolve(name string, lineNo int) *classfile.Instruction {
+	l := self.labels[name]
+	if l == nil || !l.defined {
+		self.owner.lineNo = lineNo
+		panic("undefined label " + name)
+	}
+	return l.insn
+}
+
+// 跳转目标必须是一条指令，不能是字节码末尾
+func (self *methodAssembler) target(name string, lineNo int) *classfile.Instruction {
+	insn := self.resolve(name, lineNo)
+	if insn == nil {
+		self.owner.lineNo = lineNo
+		panic("label " + name + " does not precede an instruction")
+	}
+	return insn
+}
@@ -0,0 +1,328 @@
+package classdeps
+
+import (
+	"encoding/binary"
+	"fmt"
+	"jvmgo/ch03_classfile/classfile"
+	"jvmgo/ch03_classfile/descriptor"
+	"sort"
+)
+
+// classdeps 找出每个类依赖的其它类，类似 jdeps，并可以汇总到包和 jar（或目录）的层次，用于检查分层规则和循环依赖
+//
+// 依赖来自 class 文件中所有引用类型的地方：
+// - 常量池中的 CONSTANT_Class（父类、接口、new、checkcast、catch 的异常类型等都通过它引用），
+//   以及 NameAndType 和 MethodType 中的描述符（字段和方法引用、invokedynamic 的参数和返回类型）
+// - 字段和方法的描述符
+// - 类、字段和方法的 Signature 属性中的泛型类型
+// - 注解（包括参数注解、类型注解和 AnnotationDefault）的类型，以及注解中的枚举和 Class 类型的值
+// 数组类型按元素类型计算，基本类型和类自身不算依赖
+
+// 返回 cf 依赖的类，内部形式的类名，按名称排序
+// Signature 和注解属性格式错误时只跳过这个属性，错误记录在 skipped 中，其余的依赖照常返回；
+// 常量池索引无效、字段或方法描述符格式错误时返回 err
+func Dependencies(cf *classfile.ClassFile) (deps []string, skipped []error, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			deps, skipped = nil, nil
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	c := &collector{cp: cf.ConstantPool(), names: map[string]bool{}}
+	c.constantPool()
+	c.attributes("class", cf.Attributes(), c.classSignature)
+	for _, field := range cf.Fileds() {
+		c.fieldDescriptor(field.Descriptor())
+		c.attributes("field "+field.Name(), field.Attributes(), c.fieldSignature)
+	}
+	for _, method := range cf.Methods() {
+		location := "method " + method.Name() + method.Descriptor()
+		c.methodDescriptor(method.Descriptor())
+		c.attributes(location, method.Attributes(), c.methodSignature)
+		if code := method.CodeAttribute(); code != nil {
+			c.attributes(location+" Code", code.Attributes(), nil)
+		}
+	}
+
+	delete(c.names, cf.ClassName())
+	deps = make([]string, 0, len(c.names))
+	for name := range c.names {
+		deps = append(deps, name)
+	}
+	sort.Strings(deps)
+	return deps, c.skipped, nil
+}
+
+type collector struct {
+	cp      classfile.ConstantPool
+	names   map[string]bool
+	skipped []error // 格式错误而被跳过的属性
+}
+
+// 出错时以 panic 的形式抛出，由 Dependencies() 转换为 error
+func (self *collector) check(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (self *collector) utf8(index uint16) string {
+	s, err := self.cp.GetUtf8(index)
+	self.check(err)
+	return s
+}
+
+// 只有 NameAndType 和 MethodType 的描述符需要单独解析，成员引用的类通过 CONSTANT_Class 得到
+// Utf8 常量不一定是类型，不能直接当作描述符解析
+func (self *collector) constantPool() {
+	self.check(self.cp.Walk(func(index uint16, c classfile.ConstantInfo) error {
+		switch c := c.(type) {
+		case *classfile.ConstantClassInfo:
+			self.className(self.utf8(c.NameIndex()))
+		case *classfile.ConstantNameAndTypeInfo:
+			self.descriptor(self.utf8(c.DescriptorIndex()))
+		case *classfile.ConstantMethodTypeInfo:
+			self.methodDescriptor(self.utf8(c.DescriptorIndex()))
+		}
+		return nil
+	}))
+}
+
+// CONSTANT_Class 中的数组类使用字段描述符，例如 "[Ljava/lang/String;"
+func (self *collector) className(name string) {
+	if len(name) > 0 && name[0] == '[' {
+		self.fieldDescriptor(name)
+	} else {
+		self.names[name] = true
+	}
+}
+
+// NameAndType 中的描述符可能是字段描述符或方法描述符
+func (self *collector) descriptor(desc string) {
+	if len(desc) > 0 && desc[0] == '(' {
+		self.methodDescriptor(desc)
+	} else {
+		self.fieldDescriptor(desc)
+	}
+}
+
+func (self *collector) fieldDescriptor(desc string) {
+	t, err := descriptor.ParseFieldDescriptor(desc)
+	self.check(err)
+	self.addType(t)
+}
+
+func (self *collector) methodDescriptor(desc string) {
+	m, err := descriptor.ParseMethodDescriptor(desc)
+	self.check(err)
+	self.addMethodType(m)
+}
+
+// AnnotationDefault 中 Class 类型的值使用返回值描述符，可能是 "V"
+func (self *collector) returnDescriptor(desc string) {
+	if desc != "V" {
+		self.fieldDescriptor(desc)
+	}
+}
+
+func (self *collector) classSignature(signature string) {
+	c, err := descriptor.ParseClassSignature(signature)
+	self.check(err)
+	self.addTypeParameters(c.TypeParameters())
+	self.addType(c.Superclass())
+	for _, t := range c.Interfaces() {
+		self.addType(t)
+	}
+}
+
+func (self *collector) fieldSignature(signature string) {
+	t, err := descriptor.ParseFieldSignature(signature)
+	self.check(err)
+	self.addType(t)
+}
+
+func (self *collector) methodSignature(signature string) {
+	m, err := descriptor.ParseMethodSignature(signature)
+	self.check(err)
+	self.addMethodType(m)
+}
+
+// 递归收集类型中出现的所有类，包括外部类、类型实参和数组的元素类型
+func (self *collector) addType(t descriptor.Type) {
+	switch t := t.(type) {
+	case *descriptor.ClassType:
+		for ; t != nil; t = t.Outer() {
+			self.names[t.Name()] = true
+			for _, arg := range t.TypeArguments() {
+				if arg.Type() != nil {
+					self.addType(arg.Type())
+				}
+			}
+		}
+	case *descriptor.ArrayType:
+		self.addType(t.ElementType())
+	}
+}
+
+func (self *collector) addMethodType(m *descriptor.MethodType) {
+	self.addTypeParameters(m.TypeParameters())
+	for _, t := range m.ParameterTypes() {
+		self.addType(t)
+	}
+	self.addType(m.ReturnType())
+	for _, t := range m.ExceptionTypes() {
+		self.addType(t)
+	}
+}
+
+func (self *collector) addTypeParameters(params []*descriptor.TypeParameter) {
+	for _, param := range params {
+		if param.ClassBound() != nil {
+			self.addType(param.ClassBound())
+		}
+		for _, t := range param.InterfaceBounds() {
+			self.addType(t)
+		}
+	}
+}
+
+// classfile 包没有解析 Signature 和注解相关的属性，这里按照 JVM 规范 4.7.9、4.7.16 ~ 4.7.22 节解码
+// signature 为 nil 表示该位置不会出现 Signature 属性（Code 属性中的属性）
+func (self *collector) attributes(location string, attributes []classfile.AttributeInfo, signature func(string)) {
+	for _, attrInfo := range attributes {
+		if attr, ok := attrInfo.(*classfile.UnparsedAttribute); ok {
+			self.attribute(location, attr, signature)
+		}
+	}
+}
+
+// 属性中的依赖先收集到单独的集合中，整个属性解码成功后才合并，出错时跳过整个属性并记录错误
+func (self *collector) attribute(location string, attr *classfile.UnparsedAttribute, signature func(string)) {
+	names := self.names
+	self.names = map[string]bool{}
+	defer func() {
+		attrNames := self.names
+		self.names = names
+		if r := recover(); r != nil {
+			self.skipped = append(self.skipped, fmt.Errorf("%s %s: %v", location, attr.Name(), r))
+			return
+		}
+		for name := range attrNames {
+			names[name] = true
+		}
+	}()
+
+	r := &attrReader{name: attr.Name(), info: attr.Info()}
+	switch attr.Name() {
+	case "Signature":
+		if signature != nil {
+			signature(self.utf8(r.u2()))
+		}
+	case "RuntimeVisibleAnnotations", "RuntimeInvisibleAnnotations":
+		self.annotations(r)
+	case "RuntimeVisibleParameterAnnotations", "RuntimeInvisibleParameterAnnotations":
+		for n := r.u1(); n > 0; n-- {
+			self.annotations(r)
+		}
+	case "RuntimeVisibleTypeAnnotations", "RuntimeInvisibleTypeAnnotations":
+		for n := r.u2(); n > 0; n-- {
+			r.skipTypeAnnotationTarget()
+			self.annotation(r)
+		}
+	case "AnnotationDefault":
+		self.elementValue(r)
+	}
+}
+
+func (self *collector) annotations(r *attrReader) {
+	for n := r.u2(); n > 0; n-- {
+		self.annotation(r)
+	}
+}
+
+//	annotation {
+//	    u2 type_index;
+//	    u2 num_element_value_pairs;
+//	    { u2 element_name_index; element_value value; } element_value_pairs[num_element_value_pairs];
+//	}
+func (self *collector) annotation(r *attrReader) {
+	self.fieldDescriptor(self.utf8(r.u2()))
+	for n := r.u2(); n > 0; n-- {
+		r.u2()
+		self.elementValue(r)
+	}
+}
+
+// element_value 以 tag 开头，tag 决定其余部分的结构
+func (self *collector) elementValue(r *attrReader) {
+	switch tag := r.u1(); tag {
+	case 'B', 'C', 'D', 'F', 'I', 'J', 'S', 'Z', 's':
+		r.u2() // const_value_index
+	case 'e':
+		self.fieldDescriptor(self.utf8(r.u2())) // type_name_index
+		r.u2()                                  // const_name_index
+	case 'c':
+		self.returnDescriptor(self.utf8(r.u2())) // class_info_index
+	case '@':
+		self.annotation(r)
+	case '[':
+		for n := r.u2(); n > 0; n-- {
+			self.elementValue(r)
+		}
+	default:
+		panic(fmt.Errorf("invalid element_value tag %q in %s attribute", tag, r.name))
+	}
+}
+
+// 按顺序读取未解析属性的内容，越界时 panic
+type attrReader struct {
+	name string
+	info []byte
+	pos  int
+}
+
+func (self *attrReader) u1() uint8 {
+	self.need(1)
+	v := self.info[self.pos]
+	self.pos++
+	return v
+}
+
+func (self *attrReader) u2() uint16 {
+	self.need(2)
+	v := binary.BigEndian.Uint16(self.info[self.pos:])
+	self.pos += 2
+	return v
+}
+
+func (self *attrReader) skip(n int) {
+	self.need(n)
+	self.pos += n
+}
+
+func (self *attrReader) need(n int) {
+	if self.pos+n > len(self.info) {
+		panic(fmt.Errorf("truncated %s attribute", self.name))
+	}
+}
+
+// 跳过 type_annotation 中 target_type、target_info 和 type_path 部分，target_info 的长度由 target_type 决定（JVM 规范表 4.7.20-A、4.7.20-B）
+func (self *attrReader) skipTypeAnnotationTarget() {
+	switch targetType := self.u1(); targetType {
+	case 0x00, 0x01, 0x16: // type_parameter_target、formal_parameter_target
+		self.skip(1)
+	case 0x10, 0x17, 0x42, 0x43, 0x44, 0x45, 0x46: // supertype_target、throws_target、catch_target、offset_target
+		self.skip(2)
+	case 0x11, 0x12: // type_parameter_bound_target
+		self.skip(2)
+	case 0x13, 0x14, 0x15: // empty_target
+	case 0x40, 0x41: // localvar_target
+		self.skip(6 * int(self.u2()))
+	case 0x47, 0x48, 0x49, 0x4A, 0x4B: // type_argument_target
+		self.skip(3)
+	default:
+		panic(fmt.Errorf("invalid target_type 0x%02x in %s attribute", targetType, self.name))
+	}
+	self.skip(2 * int(self.u1())) // type_path
+}
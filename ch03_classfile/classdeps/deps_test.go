@@ -0,0 +1,63 @@
+package classdeps
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"jvmgo/ch03_classfile/classfile"
+)
+
+// 在类的末尾写入 attributes 的 class 文件，每个属性由名称和内容组成
+// NewClassFile 生成的类没有类属性，attributes_count 是最后两个字节
+func classWithAttributes(t *testing.T, cf *classfile.ClassFile, attrs ...[2]string) *classfile.ClassFile {
+	names := make([]uint16, len(attrs))
+	for i, attr := range attrs {
+		names[i] = cf.AddUtf8(attr[0])
+	}
+	data, err := classfile.Serialize(cf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = binary.BigEndian.AppendUint16(data[:len(data)-2], uint16(len(attrs)))
+	for i, attr := range attrs {
+		data = binary.BigEndian.AppendUint16(data, names[i])
+		data = binary.BigEndian.AppendUint32(data, uint32(len(attr[1])))
+		data = append(data, attr[1]...)
+	}
+	cf, err = classfile.Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cf
+}
+
+func u2(v uint16) string {
+	return string(binary.BigEndian.AppendUint16(nil, v))
+}
+
+// 格式错误的 Signature 属性和被截断的注解属性被跳过，其余的依赖照常返回
+func TestDependenciesSkipsBadAttributes(t *testing.T) {
+	cf := classfile.NewClassFile(52, 0, classfile.ACC_PUBLIC|classfile.ACC_SUPER, "p/A", "p/Base")
+	cf.AddMethod(classfile.ACC_PUBLIC|classfile.ACC_ABSTRACT, "m", "(Lp/Arg;)Lp/Ret;")
+	badSignature := cf.AddUtf8("Lp/Base<")
+	annotation := cf.AddUtf8("Lp/Ann;")
+	partial := cf.AddUtf8("Lp/Partial;")
+	cf = classWithAttributes(t, cf,
+		[2]string{"Signature", u2(badSignature)},
+		[2]string{"RuntimeVisibleAnnotations", u2(1) + u2(annotation) + u2(0)},
+		[2]string{"RuntimeInvisibleAnnotations", u2(2) + u2(partial) + u2(0)},
+	)
+
+	deps, skipped, err := Dependencies(cf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"p/Ann", "p/Arg", "p/Base", "p/Ret"}
+	if !reflect.DeepEqual(deps, want) {
+		t.Errorf("Dependencies() = %v, want %v", deps, want)
+	}
+	if len(skipped) != 2 {
+		t.Errorf("got %d skipped attributes, want 2: %v", len(skipped), skipped)
+	}
+}
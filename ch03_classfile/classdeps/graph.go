@@ -0,0 +1,258 @@
+package classdeps
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"jvmgo/ch03_classfile/classfile"
+	"jvmgo/ch03_classfile/descriptor"
+	"sort"
+	"strings"
+)
+
+// 依赖图的层次
+type Level string
+
+const (
+	ClassLevel   Level = "class"
+	PackageLevel Level = "package"
+	ArchiveLevel Level = "archive" // 类所在的 jar 或目录，与 jdeps 一样目录也算作 archive
+)
+
+// 不在被分析的类中的依赖（例如 JDK 中的类）在 ArchiveLevel 上归到这个节点，与 jdeps 一样
+const NotFound = "not found"
+
+// Analyzer 收集一组类及其依赖，再按照不同的层次生成依赖图
+type Analyzer struct {
+	classes  map[string]*classDeps // 以内部形式的类名为键
+	warnings []error
+}
+
+type classDeps struct {
+	source string // 类所在的 jar 或目录
+	deps   []string
+}
+
+func NewAnalyzer() *Analyzer {
+	return &Analyzer{classes: map[string]*classDeps{}}
+}
+
+// 添加 source 中的一个类，同名的类已经添加过时忽略，与 classpath 中靠前的类优先一致；module-info 不是类，也被忽略
+func (self *Analyzer) AddClass(source string, cf *classfile.ClassFile) error {
+	if classfile.ClassAccessFlags(cf.AccessFlags()).IsModule() {
+		return nil
+	}
+	name := cf.ClassName()
+	if self.classes[name] != nil {
+		return nil
+	}
+	deps, skipped, err := Dependencies(cf)
+	if err != nil {
+		return fmt.Errorf("%s: %v", descriptor.JavaName(name), err)
+	}
+	for _, e := range skipped {
+		self.warnings = append(self.warnings, fmt.Errorf("%s: %v", descriptor.JavaName(name), e))
+	}
+	self.classes[name] = &classDeps{source: source, deps: deps}
+	return nil
+}
+
+// 添加类时因为格式错误而跳过的属性，这些属性中的依赖没有计入依赖图
+func (self *Analyzer) Warnings() []error {
+	return self.warnings
+}
+
+// 按照 level 生成依赖图，节点名是 Java 形式的类名、包名或者 jar（目录）的路径
+// external 为 false 时只保留被分析的类之间的依赖，否则也包括对其它类的依赖；同一个节点内部的依赖不计入
+func (self *Analyzer) Graph(level Level, external bool) *Graph {
+	g := NewGraph()
+	for name, c := range self.classes {
+		from := self.node(level, name)
+		g.AddNode(from)
+		for _, dep := range c.deps {
+			if self.classes[dep] == nil && !external {
+				continue
+			}
+			if to := self.node(level, dep); to != from {
+				g.AddEdge(from, to)
+			}
+		}
+	}
+	return g
+}
+
+func (self *Analyzer) node(level Level, className string) string {
+	switch level {
+	case PackageLevel:
+		return packageName(className)
+	case ArchiveLevel:
+		if c := self.classes[className]; c != nil {
+			return c.source
+		}
+		return NotFound
+	default:
+		return descriptor.JavaName(className)
+	}
+}
+
+// 无名包与 jdeps 一样显示为 <unnamed>
+func packageName(className string) string {
+	if i := strings.LastIndex(className, "/"); i >= 0 {
+		return descriptor.JavaName(className[:i])
+	}
+	return "<unnamed>"
+}
+
+// Graph 是有向的依赖图
+type Graph struct {
+	edges map[string]map[string]bool // 节点 -> 它依赖的节点
+}
+
+func NewGraph() *Graph {
+	return &Graph{edges: map[string]map[string]bool{}}
+}
+
+func (self *Graph) AddNode(node string) {
+	if self.edges[node] == nil {
+		self.edges[node] = map[string]bool{}
+	}
+}
+
+func (self *Graph) AddEdge(from, to string) {
+	self.AddNode(from)
+	self.AddNode(to)
+	self.edges[from][to] = true
+}
+
+// 返回所有节点，按名称排序
+func (self *Graph) Nodes() []string {
+	nodes := make([]string, 0, len(self.edges))
+	for node := range self.edges {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// 返回 node 直接依赖的节点，按名称排序
+func (self *Graph) Dependencies(node string) []string {
+	deps := make([]string, 0, len(self.edges[node]))
+	for dep := range self.edges[node] {
+		deps = append(deps, dep)
+	}
+	sort.Strings(deps)
+	return deps
+}
+
+// 返回图中所有的循环依赖，每个循环是一个包含多个节点的强连通分量，分量内的节点按名称排序，分量之间按第一个节点排序
+// 使用 Tarjan 算法，节点按名称顺序访问，保证结果稳定
+func (self *Graph) Cycles() [][]string {
+	t := &tarjan{g: self, index: map[string]int{}, lowlink: map[string]int{}, onStack: map[string]bool{}}
+	for _, node := range self.Nodes() {
+		if _, visited := t.index[node]; !visited {
+			t.visit(node)
+		}
+	}
+	sort.Slice(t.cycles, func(i, j int) bool {
+		return t.cycles[i][0] < t.cycles[j][0]
+	})
+	return t.cycles
+}
+
+type tarjan struct {
+	g       *Graph
+	next    int
+	index   map[string]int
+	lowlink map[string]int
+	stack   []string
+	onStack map[string]bool
+	cycles  [][]string
+}
+
+func (self *tarjan) visit(node string) {
+	self.index[node] = self.next
+	self.lowlink[node] = self.next
+	self.next++
+	self.stack = append(self.stack, node)
+	self.onStack[node] = true
+
+	for _, dep := range self.g.Dependencies(node) {
+		if _, visited := self.index[dep]; !visited {
+			self.visit(dep)
+			if self.lowlink[dep] < self.lowlink[node] {
+				self.lowlink[node] = self.lowlink[dep]
+			}
+		} else if self.onStack[dep] && self.index[dep] < self.lowlink[node] {
+			self.lowlink[node] = self.index[dep]
+		}
+	}
+
+	if self.lowlink[node] != self.index[node] {
+		return
+	}
+	var component []string
+	for {
+		top := self.stack[len(self.stack)-1]
+		self.stack = self.stack[:len(self.stack)-1]
+		self.onStack[top] = false
+		component = append(component, top)
+		if top == node {
+			break
+		}
+	}
+	if len(component) > 1 {
+		sort.Strings(component)
+		self.cycles = append(self.cycles, component)
+	}
+}
+
+// 以 Graphviz DOT 格式写出依赖图，循环依赖中的边用红色标出
+func (self *Graph) WriteDOT(w io.Writer) error {
+	inCycle := map[string]int{} // 节点 -> 所在循环的编号
+	for i, cycle := range self.Cycles() {
+		for _, node := range cycle {
+			inCycle[node] = i + 1
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("digraph \"dependencies\" {\n")
+	for _, node := range self.Nodes() {
+		deps := self.Dependencies(node)
+		if len(deps) == 0 {
+			fmt.Fprintf(&b, "    %q;\n", node)
+		}
+		for _, dep := range deps {
+			fmt.Fprintf(&b, "    %q -> %q", node, dep)
+			if inCycle[node] != 0 && inCycle[node] == inCycle[dep] {
+				b.WriteString(" [color=red]")
+			}
+			b.WriteString(";\n")
+		}
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// JSON 格式的依赖图，dependencies 包含所有节点，没有依赖的节点对应空数组
+type graphJSON struct {
+	Level        Level               `json:"level,omitempty"`
+	Dependencies map[string][]string `json:"dependencies"`
+	Cycles       [][]string          `json:"cycles"`
+}
+
+// 以缩进格式写出 JSON，level 为空时不输出 level 键
+func (self *Graph) WriteJSON(w io.Writer, level Level) error {
+	g := &graphJSON{Level: level, Dependencies: map[string][]string{}, Cycles: self.Cycles()}
+	for _, node := range self.Nodes() {
+		g.Dependencies[node] = self.Dependencies(node)
+	}
+	if g.Cycles == nil {
+		g.Cycles = [][]string{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(g)
+}
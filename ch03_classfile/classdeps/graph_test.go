@@ -0,0 +1,96 @@
+package classdeps
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"jvmgo/ch03_classfile/classfile"
+)
+
+// a.jar 中 p/A -> q/B -> p/C，lib 目录中 r/D -> p/A；p/A 还依赖 java/lang/Object 和 java/lang/String
+func newTestAnalyzer(t *testing.T) *Analyzer {
+	classes := []struct {
+		source, name, super string
+		field               string
+	}{
+		{"a.jar", "p/A", "java/lang/Object", "Lq/B;"},
+		{"a.jar", "q/B", "java/lang/Object", "Lp/C;"},
+		{"a.jar", "p/C", "java/lang/Object", "Ljava/lang/String;"},
+		{"lib", "r/D", "p/A", "I"},
+		{"lib", "p/A", "java/lang/Object", "Lr/D;"}, // 同名的类以先添加的为准
+	}
+	analyzer := NewAnalyzer()
+	for _, c := range classes {
+		cf := classfile.NewClassFile(52, 0, classfile.ACC_PUBLIC|classfile.ACC_SUPER, c.name, c.super)
+		cf.AddField(classfile.ACC_PRIVATE, "f", c.field)
+		if err := analyzer.AddClass(c.source, cf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return analyzer
+}
+
+func TestGraphLevels(t *testing.T) {
+	analyzer := newTestAnalyzer(t)
+	tests := []struct {
+		level    Level
+		external bool
+		want     map[string][]string
+	}{
+		{ClassLevel, false, map[string][]string{
+			"p.A": {"q.B"}, "q.B": {"p.C"}, "p.C": {}, "r.D": {"p.A"},
+		}},
+		{ClassLevel, true, map[string][]string{
+			"p.A": {"java.lang.Object", "q.B"}, "q.B": {"java.lang.Object", "p.C"},
+			"p.C": {"java.lang.Object", "java.lang.String"}, "r.D": {"p.A"},
+			"java.lang.Object": {}, "java.lang.String": {},
+		}},
+		{PackageLevel, false, map[string][]string{"p": {"q"}, "q": {"p"}, "r": {"p"}}},
+		{ArchiveLevel, true, map[string][]string{"a.jar": {NotFound}, "lib": {"a.jar"}, NotFound: {}}},
+	}
+	for _, test := range tests {
+		g := analyzer.Graph(test.level, test.external)
+		got := map[string][]string{}
+		for _, node := range g.Nodes() {
+			got[node] = g.Dependencies(node)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s level, external %v: got %v, want %v", test.level, test.external, got, test.want)
+		}
+	}
+}
+
+func TestGraphCycles(t *testing.T) {
+	g := NewGraph()
+	for _, edge := range [][2]string{{"a", "b"}, {"b", "c"}, {"c", "a"}, {"c", "d"}, {"e", "f"}, {"f", "e"}, {"g", "g"}} {
+		g.AddEdge(edge[0], edge[1])
+	}
+	want := [][]string{{"a", "b", "c"}, {"e", "f"}} // 只依赖自己的节点不算循环
+	if cycles := g.Cycles(); !reflect.DeepEqual(cycles, want) {
+		t.Errorf("Cycles() = %v, want %v", cycles, want)
+	}
+
+	// 包 p 和 q 相互依赖
+	if cycles := newTestAnalyzer(t).Graph(PackageLevel, false).Cycles(); !reflect.DeepEqual(cycles, [][]string{{"p", "q"}}) {
+		t.Errorf("package cycles = %v, want [[p q]]", cycles)
+	}
+}
+
+func TestWriteDOT(t *testing.T) {
+	var buf bytes.Buffer
+	if err := newTestAnalyzer(t).Graph(PackageLevel, false).WriteDOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		`digraph "dependencies" {`,
+		`    "p" -> "q" [color=red];`,
+		`    "q" -> "p" [color=red];`,
+		`    "r" -> "p";`,
+		`}`,
+	}, "\n") + "\n"
+	if buf.String() != want {
+		t.Errorf("WriteDOT() =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
@@ -0,0 +1,587 @@
+package classdiff
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"jvmgo/ch03_classfile/classfile"
+	"jvmgo/ch03_classfile/descriptor"
+)
+
+// classdiff 比较同一个类（或同一组类）的两个版本，找出 API 层面的变化，并按照 JLS 第 13 章（二进制兼容性）
+// 判断每处变化是否会破坏依赖旧版本编译的二进制代码，例如删除 public 方法、把方法改为 final、把类改为 abstract
+//
+// 只有 API 才参与比较：public 的类，以及其中 public 和 protected 的字段和方法，编译器生成的 synthetic 成员除外
+// 非 public 的类和 private、包访问权限的成员对其它包中的代码不可见，修改它们不会影响兼容性
+
+// 变化的种类
+type ChangeKind string
+
+const (
+	Added   ChangeKind = "added"
+	Removed ChangeKind = "removed"
+	Changed ChangeKind = "changed"
+)
+
+// Change 描述 API 的一处变化
+// member 为空表示类本身的变化，否则是 Java 形式的成员，例如 "method void foo(int)"、"field java.lang.String name"
+type Change struct {
+	className string // Java 形式的类名，例如 java.util.List
+	member    string
+	kind      ChangeKind
+	detail    string // 对变化的说明，Added 和 Removed 通常为空
+	breaking  bool   // 是否破坏二进制兼容性
+}
+
+// getter 方法
+func (self *Change) ClassName() string {
+	return self.className
+}
+func (self *Change) Member() string {
+	return self.member
+}
+func (self *Change) Kind() ChangeKind {
+	return self.kind
+}
+func (self *Change) Detail() string {
+	return self.detail
+}
+func (self *Change) IsBreaking() bool {
+	return self.breaking
+}
+
+// 例如 "java.util.Foo: method void bar(int): removed (breaking)"
+func (self *Change) String() string {
+	s := self.className
+	if self.member != "" {
+		s += ": " + self.member
+	}
+	s += ": " + string(self.kind)
+	if self.detail != "" {
+		s += ", " + self.detail
+	}
+	if self.breaking {
+		s += " (breaking)"
+	}
+	return s
+}
+
+// 比较两组类，例如同一个 jar 的两个版本，按类名匹配新旧版本
+// 删除 public 类是不兼容的变化，新增的 public 类只报告一条 Added，不再列出其成员
+// 结果按类名排序，同一个类中依次是类本身、字段和方法的变化；常量池索引无效等问题会导致返回错误
+func Compare(oldClasses, newClasses []*classfile.ClassFile) (changes []*Change, err error) {
+	defer handleError(&changes, &err)
+	d := newDiffer(oldClasses, newClasses)
+	names := map[string]bool{}
+	for name := range d.oldClasses {
+		names[name] = true
+	}
+	for name := range d.newClasses {
+		names[name] = true
+	}
+	sortedNames := make([]string, 0, len(names))
+	for name := range names {
+		sortedNames = append(sortedNames, name)
+	}
+	sort.Strings(sortedNames)
+
+	for _, name := range sortedNames {
+		oldClass, newClass := d.oldClasses[name], d.newClasses[name]
+		switch {
+		case newClass == nil:
+			if isPublic(oldClass.AccessFlags()) {
+				d.add(name, "", Removed, "", true)
+			}
+		case oldClass == nil:
+			if isPublic(newClass.AccessFlags()) {
+				d.add(name, "", Added, "", false)
+			}
+		default:
+			d.compareClass(oldClass, newClass)
+		}
+	}
+	return d.changes, nil
+}
+
+// 比较同一个类的两个版本，不要求类名相同；父类和接口的层次只能从这两个 class 文件中得到
+func CompareClasses(oldClass, newClass *classfile.ClassFile) (changes []*Change, err error) {
+	defer handleError(&changes, &err)
+	d := newDiffer([]*classfile.ClassFile{oldClass}, []*classfile.ClassFile{newClass})
+	d.compareClass(oldClass, newClass)
+	return d.changes, nil
+}
+
+// 读取常量池出错时会 panic，在这里转换为 error
+func handleError(changes *[]*Change, err *error) {
+	if r := recover(); r != nil {
+		*changes = nil
+		*err = fmt.Errorf("%v", r)
+	}
+}
+
+type differ struct {
+	oldClasses map[string]*classfile.ClassFile // 以内部形式的类名为键
+	newClasses map[string]*classfile.ClassFile
+	changes    []*Change
+}
+
+// module-info 不是类，不参与比较
+func newDiffer(oldClasses, newClasses []*classfile.ClassFile) *differ {
+	d := &differ{oldClasses: map[string]*classfile.ClassFile{}, newClasses: map[string]*classfile.ClassFile{}}
+	for _, cf := range oldClasses {
+		if !classfile.ClassAccessFlags(cf.AccessFlags()).IsModule() {
+			d.oldClasses[cf.ClassName()] = cf
+		}
+	}
+	for _, cf := range newClasses {
+		if !classfile.ClassAccessFlags(cf.AccessFlags()).IsModule() {
+			d.newClasses[cf.ClassName()] = cf
+		}
+	}
+	return d
+}
+
+func (self *differ) add(className, member string, kind ChangeKind, detail string, breaking bool) {
+	self.changes = append(self.changes, &Change{
+		className: descriptor.JavaName(className),
+		member:    member,
+		kind:      kind,
+		detail:    detail,
+		breaking:  breaking,
+	})
+}
+
+func isPublic(accessFlags uint16) bool {
+	return accessFlags&classfile.ACC_PUBLIC != 0
+}
+
+// 类、字段和方法的可见范围：private < 包访问权限 < protected < public
+func accessLevel(accessFlags uint16) int {
+	switch {
+	case accessFlags&classfile.ACC_PUBLIC != 0:
+		return 3
+	case accessFlags&classfile.ACC_PROTECTED != 0:
+		return 2
+	case accessFlags&classfile.ACC_PRIVATE != 0:
+		return 0
+	}
+	return 1
+}
+
+var accessLevelNames = []string{"private", "package-private", "protected", "public"}
+
+// JLS 13.4.1 ~ 13.4.4 和 13.5.1 ~ 13.5.2：
+// 类不再是 public、由非 abstract 改为 abstract、由非 final 改为 final、在类和接口之间转换，
+// 以及从父类和接口的集合中删除任何一个类型，都会破坏兼容性
+func (self *differ) compareClass(oldClass, newClass *classfile.ClassFile) {
+	name := newClass.ClassName()
+	oldFlags := classfile.ClassAccessFlags(oldClass.AccessFlags())
+	newFlags := classfile.ClassAccessFlags(newClass.AccessFlags())
+	switch {
+	case !oldFlags.IsPublic() && !newFlags.IsPublic():
+		return
+	case !oldFlags.IsPublic():
+		self.add(name, "", Added, "class is now public", false)
+		return
+	case !newFlags.IsPublic():
+		self.add(name, "", Changed, "class is no longer public", true)
+		return
+	}
+
+	switch {
+	case oldFlags.IsInterface() && !newFlags.IsInterface():
+		self.add(name, "", Changed, "interface changed to class", true)
+	case !oldFlags.IsInterface() && newFlags.IsInterface():
+		self.add(name, "", Changed, "class changed to interface", true)
+	case !oldFlags.IsInterface():
+		if !oldFlags.IsAbstract() && newFlags.IsAbstract() {
+			self.add(name, "", Changed, "class is now abstract", true)
+		} else if oldFlags.IsAbstract() && !newFlags.IsAbstract() {
+			self.add(name, "", Changed, "class is no longer abstract", false)
+		}
+		if !oldFlags.IsFinal() && newFlags.IsFinal() {
+			self.add(name, "", Changed, "class is now final", true)
+		} else if oldFlags.IsFinal() && !newFlags.IsFinal() {
+			self.add(name, "", Changed, "class is no longer final", false)
+		}
+	}
+
+	self.compareSupertypes(oldClass, newClass)
+	inFinalClass := oldFlags.IsFinal() && newFlags.IsFinal()
+	self.compareFields(name, oldClass, newClass)
+	self.compareMethods(name, oldClass, newClass, inFinalClass)
+}
+
+// 父类的变化本身不一定破坏兼容性，只要原来的父类和接口仍然在新版本的类型层次中即可（JLS 13.4.4）
+// 类型层次只能沿着参与比较的类向上展开，不在其中的类（例如 JDK 中的类）视为层次的终点
+func (self *differ) compareSupertypes(oldClass, newClass *classfile.ClassFile) {
+	name := newClass.ClassName()
+	if oldSuper, newSuper := oldClass.SuperClassName(), newClass.SuperClassName(); oldSuper != newSuper {
+		self.add(name, "", Changed, fmt.Sprintf("superclass changed from %s to %s",
+			descriptor.JavaName(oldSuper), descriptor.JavaName(newSuper)), false)
+	}
+	oldInterfaces := map[string]bool{}
+	for _, iface := range oldClass.InterfaceNames() {
+		oldInterfaces[iface] = true
+	}
+	for _, iface := range newClass.InterfaceNames() {
+		if !oldInterfaces[iface] {
+			self.add(name, "", Changed, "added interface "+descriptor.JavaName(iface), false)
+		}
+	}
+
+	oldSupertypes := supertypes(oldClass, self.oldClasses)
+	newSupertypes := supertypes(newClass, self.newClasses)
+	for _, supertype := range oldSupertypes {
+		if !contains(newSupertypes, supertype) {
+			self.add(name, "", Changed, "no longer a subtype of "+descriptor.JavaName(supertype), true)
+		}
+	}
+}
+
+// 返回类的所有父类和接口（不含 java/lang/Object），按照广度优先的顺序排列
+func supertypes(cf *classfile.ClassFile, classes map[string]*classfile.ClassFile) []string {
+	var result []string
+	queue := []*classfile.ClassFile{cf}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		names := current.InterfaceNames()
+		if superName := current.SuperClassName(); superName != "" {
+			names = append([]string{superName}, names...)
+		}
+		for _, name := range names {
+			if name == "java/lang/Object" || contains(result, name) {
+				continue
+			}
+			result = append(result, name)
+			if super := classes[name]; super != nil {
+				queue = append(queue, super)
+			}
+		}
+	}
+	return result
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// 返回类中属于 API 的成员，键为 "名称:描述符"，同时按原来的顺序返回键
+func apiMembers(members []*classfile.MemberInfo, inInterface bool) ([]string, map[string]*classfile.MemberInfo) {
+	var keys []string
+	byKey := map[string]*classfile.MemberInfo{}
+	for _, member := range members {
+		flags := member.AccessFlags()
+		if flags&classfile.ACC_SYNTHETIC != 0 || member.Name() == "<clinit>" {
+			continue
+		}
+		// Java 9 之前接口中的成员都是 public，之后的 private 接口方法不属于 API
+		if accessLevel(flags) < 2 && !(inInterface && flags&classfile.ACC_PRIVATE == 0) {
+			continue
+		}
+		key := member.Name() + ":" + member.Descriptor()
+		keys = append(keys, key)
+		byKey[key] = member
+	}
+	return keys, byKey
+}
+
+// 与旧成员同名的新成员，用于把 "删除 + 新增" 合并为一处类型变化，找不到或者有多个时返回 nil
+// 方法还要求参数相同，即只有返回值不同
+func findRetyped(old *classfile.MemberInfo, keys []string, byKey map[string]*classfile.MemberInfo, isMethod bool) *classfile.MemberInfo {
+	var found *classfile.MemberInfo
+	for _, key := range keys {
+		member := byKey[key]
+		if member.Name() != old.Name() {
+			continue
+		}
+		if isMethod && parameters(member.Descriptor()) != parameters(old.Descriptor()) {
+			continue
+		}
+		if found != nil {
+			return nil
+		}
+		found = member
+	}
+	return found
+}
+
+// 方法描述符中的参数部分，例如 "(ILjava/lang/String;)V" 返回 "(ILjava/lang/String;)"
+func parameters(desc string) string {
+	return desc[:strings.IndexByte(desc, ')')+1]
+}
+
+// JLS 13.4.8 ~ 13.4.10：删除字段、缩小访问权限、改为 final、在 static 和实例字段之间转换都会破坏兼容性；
+// 修改常量的值不会导致链接错误，但依赖旧版本编译的代码中内联的仍然是旧值，所以也报告出来
+func (self *differ) compareFields(className string, oldClass, newClass *classfile.ClassFile) {
+	oldInterface := classfile.ClassAccessFlags(oldClass.AccessFlags()).IsInterface()
+	newInterface := classfile.ClassAccessFlags(newClass.AccessFlags()).IsInterface()
+	oldKeys, oldFields := apiMembers(oldClass.Fileds(), oldInterface)
+	newKeys, newFields := apiMembers(newClass.Fileds(), newInterface)
+	retyped := map[string]bool{}
+
+	for _, key := range oldKeys {
+		oldField := oldFields[key]
+		newField := newFields[key]
+		if newField == nil {
+			newField = findField(newClass, oldField.Name(), oldField.Descriptor())
+		}
+		if newField == nil {
+			if owner, inherited := self.findInherited(newClass, oldField, false); inherited != nil {
+				self.add(className, fieldString(oldField), Changed, "now inherited from "+descriptor.JavaName(owner.ClassName()), false)
+				self.compareField(className, oldClass, owner, oldField, inherited)
+				continue
+			}
+			if other := findRetyped(oldField, newKeys, newFields, false); other != nil && oldFields[other.Name()+":"+other.Descriptor()] == nil {
+				retyped[other.Name()+":"+other.Descriptor()] = true
+				self.add(className, fieldString(oldField), Changed,
+					"type changed to "+javaType(other.Descriptor()), true)
+			} else {
+				self.add(className, fieldString(oldField), Removed, "", true)
+			}
+			continue
+		}
+		self.compareField(className, oldClass, newClass, oldField, newField)
+	}
+	for _, key := range newKeys {
+		if oldFields[key] == nil && !retyped[key] {
+			self.add(className, fieldString(newFields[key]), Added, "", false)
+		}
+	}
+}
+
+func (self *differ) compareField(className string, oldClass, newClass *classfile.ClassFile, oldField, newField *classfile.MemberInfo) {
+	member := fieldString(oldField)
+	oldFlags := classfile.FieldAccessFlags(oldField.AccessFlags())
+	newFlags := classfile.FieldAccessFlags(newField.AccessFlags())
+	self.compareAccess(className, member, uint16(oldFlags), uint16(newFlags))
+	switch {
+	case !oldFlags.IsFinal() && newFlags.IsFinal():
+		self.add(className, member, Changed, "field is now final", true)
+	case oldFlags.IsFinal() && !newFlags.IsFinal():
+		self.add(className, member, Changed, "field is no longer final", false)
+	}
+	if oldFlags.IsStatic() != newFlags.IsStatic() {
+		self.add(className, member, Changed, staticChange(newFlags.IsStatic()), true)
+	}
+	oldValue := constantValue(oldClass, oldField)
+	newValue := constantValue(newClass, newField)
+	if oldValue != newValue {
+		self.add(className, member, Changed, fmt.Sprintf("constant value changed from %s to %s", oldValue, newValue), false)
+	}
+}
+
+// JLS 13.4.12 ~ 13.4.22：删除方法、修改返回值类型、缩小访问权限、由非 abstract 改为 abstract、
+// 在 static 和实例方法之间转换，以及把可以被覆盖的实例方法改为 final，都会破坏兼容性
+// 修改 throws 子句、native、synchronized 不影响二进制兼容性
+func (self *differ) compareMethods(className string, oldClass, newClass *classfile.ClassFile, inFinalClass bool) {
+	oldInterface := classfile.ClassAccessFlags(oldClass.AccessFlags()).IsInterface()
+	newInterface := classfile.ClassAccessFlags(newClass.AccessFlags()).IsInterface()
+	oldKeys, oldMethods := apiMembers(oldClass.Methods(), oldInterface)
+	newKeys, newMethods := apiMembers(newClass.Methods(), newInterface)
+	retyped := map[string]bool{}
+
+	for _, key := range oldKeys {
+		oldMethod := oldMethods[key]
+		newMethod := newMethods[key]
+		if newMethod == nil {
+			newMethod = findMethod(newClass, oldMethod.Name(), oldMethod.Descriptor())
+		}
+		if newMethod == nil {
+			if owner, inherited := self.findInherited(newClass, oldMethod, true); inherited != nil {
+				self.add(className, methodString(className, oldMethod), Changed, "now inherited from "+descriptor.JavaName(owner.ClassName()), false)
+				self.compareMethod(className, oldClass, owner, oldMethod, inherited, inFinalClass)
+				continue
+			}
+			if other := findRetyped(oldMethod, newKeys, newMethods, true); other != nil && oldMethods[other.Name()+":"+other.Descriptor()] == nil {
+				retyped[other.Name()+":"+other.Descriptor()] = true
+				self.add(className, methodString(className, oldMethod), Changed,
+					"return type changed to "+javaReturnType(other.Descriptor()), true)
+			} else {
+				self.add(className, methodString(className, oldMethod), Removed, "", true)
+			}
+			continue
+		}
+		self.compareMethod(className, oldClass, newClass, oldMethod, newMethod, inFinalClass)
+	}
+	for _, key := range newKeys {
+		if oldMethods[key] != nil || retyped[key] {
+			continue
+		}
+		newMethod := newMethods[key]
+		detail := ""
+		// 新增抽象方法不会导致链接错误，但已有的子类或实现类无法再通过编译，调用时还会抛出 AbstractMethodError
+		if classfile.MethodAccessFlags(newMethod.AccessFlags()).IsAbstract() {
+			detail = "abstract, existing subclasses and implementations must be updated"
+		}
+		self.add(className, methodString(className, newMethod), Added, detail, false)
+	}
+}
+
+func (self *differ) compareMethod(className string, oldClass, newClass *classfile.ClassFile,
+	oldMethod, newMethod *classfile.MemberInfo, inFinalClass bool) {
+	member := methodString(className, oldMethod)
+	oldFlags := classfile.MethodAccessFlags(oldMethod.AccessFlags())
+	newFlags := classfile.MethodAccessFlags(newMethod.AccessFlags())
+	self.compareAccess(className, member, uint16(oldFlags), uint16(newFlags))
+	switch {
+	case !oldFlags.IsAbstract() && newFlags.IsAbstract():
+		self.add(className, member, Changed, "method is now abstract", true)
+	case oldFlags.IsAbstract() && !newFlags.IsAbstract():
+		self.add(className, member, Changed, "method is no longer abstract", false)
+	}
+	// 类方法不能被覆盖，final 类中的方法也没有子类可以覆盖，改为 final 都不影响兼容性（JLS 13.4.17）
+	switch {
+	case !oldFlags.IsFinal() && newFlags.IsFinal():
+		self.add(className, member, Changed, "method is now final", !newFlags.IsStatic() && !inFinalClass)
+	case oldFlags.IsFinal() && !newFlags.IsFinal():
+		self.add(className, member, Changed, "method is no longer final", false)
+	}
+	if oldFlags.IsStatic() != newFlags.IsStatic() {
+		self.add(className, member, Changed, staticChange(newFlags.IsStatic()), true)
+	}
+	if oldThrows, newThrows := throwsClause(oldClass, oldMethod), throwsClause(newClass, newMethod); oldThrows != newThrows {
+		self.add(className, member, Changed, fmt.Sprintf("throws clause changed from [%s] to [%s]", oldThrows, newThrows), false)
+	}
+}
+
+// 访问权限缩小会破坏兼容性，扩大则不会（JLS 13.4.7）
+func (self *differ) compareAccess(className, member string, oldFlags, newFlags uint16) {
+	oldLevel, newLevel := accessLevel(oldFlags), accessLevel(newFlags)
+	if oldLevel != newLevel {
+		self.add(className, member, Changed, fmt.Sprintf("access changed from %s to %s",
+			accessLevelNames[oldLevel], accessLevelNames[newLevel]), newLevel < oldLevel)
+	}
+}
+
+func staticChange(nowStatic bool) string {
+	if nowStatic {
+		return "changed from instance to static"
+	}
+	return "changed from static to instance"
+}
+
+// 从类中删除的成员如果仍然能从新版本的父类或接口继承，引用它的代码在解析时会找到继承的成员（JVM 规范 5.4.3.2 和 5.4.3.3 节），
+// 这时比较继承的成员，返回声明它的类；与 supertypes() 相同，只在参与比较的类中查找，private 成员和构造方法不能继承
+func (self *differ) findInherited(cf *classfile.ClassFile, old *classfile.MemberInfo, isMethod bool) (*classfile.ClassFile, *classfile.MemberInfo) {
+	if isMethod && old.Name() == "<init>" {
+		return nil, nil
+	}
+	for _, name := range supertypes(cf, self.newClasses) {
+		super := self.newClasses[name]
+		if super == nil {
+			continue
+		}
+		var member *classfile.MemberInfo
+		if isMethod {
+			member = findMethod(super, old.Name(), old.Descriptor())
+		} else {
+			member = findField(super, old.Name(), old.Descriptor())
+		}
+		if member != nil && member.AccessFlags()&classfile.ACC_PRIVATE == 0 {
+			return super, member
+		}
+	}
+	return nil, nil
+}
+
+// 旧版本 API 中的成员在新版本中可能不再属于 API（例如改为了 private），此时仍然要找到它才能报告访问权限的变化
+func findField(cf *classfile.ClassFile, name, desc string) *classfile.MemberInfo {
+	return findMember(cf.Fileds(), name, desc)
+}
+func findMethod(cf *classfile.ClassFile, name, desc string) *classfile.MemberInfo {
+	return findMember(cf.Methods(), name, desc)
+}
+func findMember(members []*classfile.MemberInfo, name, desc string) *classfile.MemberInfo {
+	for _, member := range members {
+		if member.Name() == name && member.Descriptor() == desc {
+			return member
+		}
+	}
+	return nil
+}
+
+// 字段的 ConstantValue，没有时返回空字符串
+func constantValue(cf *classfile.ClassFile, field *classfile.MemberInfo) string {
+	attr := field.ConstantValueAttribute()
+	if attr == nil {
+		return ""
+	}
+	c, err := cf.ConstantPool().GetConstantInfo(attr.ConstantValueIndex())
+	if err != nil {
+		return err.Error()
+	}
+	switch c := c.(type) {
+	case *classfile.ConstantIntegerInfo:
+		return fmt.Sprint(c.Value())
+	case *classfile.ConstantLongInfo:
+		return fmt.Sprint(c.Value())
+	case *classfile.ConstantFloatInfo:
+		return fmt.Sprint(c.Value())
+	case *classfile.ConstantDoubleInfo:
+		return fmt.Sprint(c.Value())
+	case *classfile.ConstantStringInfo:
+		return fmt.Sprintf("%q", c.String())
+	}
+	return ""
+}
+
+// 方法声明抛出的异常，以 ", " 分隔
+func throwsClause(cf *classfile.ClassFile, method *classfile.MemberInfo) string {
+	attr := method.ExceptionsAttribute()
+	if attr == nil {
+		return ""
+	}
+	var names []string
+	for _, index := range attr.ExceptionIndexTable() {
+		name, err := cf.ConstantPool().GetClassName(index)
+		if err != nil {
+			name = fmt.Sprintf("#%d", index)
+		}
+		names = append(names, descriptor.JavaName(name))
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// 例如 "field int count"
+func fieldString(field *classfile.MemberInfo) string {
+	return "field " + javaType(field.Descriptor()) + " " + field.Name()
+}
+
+// 例如 "method void foo(int)"，构造方法为 "constructor Foo(int)"
+func methodString(className string, method *classfile.MemberInfo) string {
+	methodType, err := descriptor.ParseMethodDescriptor(method.Descriptor())
+	if err != nil {
+		return "method " + method.Name() + method.Descriptor()
+	}
+	if method.Name() == "<init>" {
+		params := make([]string, len(methodType.ParameterTypes()))
+		for i, param := range methodType.ParameterTypes() {
+			params[i] = param.String()
+		}
+		return "constructor " + descriptor.JavaName(className) + "(" + strings.Join(params, ", ") + ")"
+	}
+	return "method " + methodType.Declaration(method.Name())
+}
+
+func javaType(desc string) string {
+	if t, err := descriptor.ParseFieldDescriptor(desc); err == nil {
+		return t.String()
+	}
+	return desc
+}
+
+func javaReturnType(desc string) string {
+	if methodType, err := descriptor.ParseMethodDescriptor(desc); err == nil {
+		return methodType.ReturnType().String()
+	}
+	return desc
+}
@@ -0,0 +1,77 @@
+package classdiff
+
+import (
+	"testing"
+
+	"jvmgo/ch03_classfile/classfile"
+)
+
+func publicClass(name, superName string) *classfile.ClassFile {
+	return classfile.NewClassFile(52, 0, classfile.ACC_PUBLIC|classfile.ACC_SUPER, name, superName)
+}
+
+// 方法和字段移动到新增的父类中，子类仍然继承它们，不是不兼容的变化
+func TestCompareMemberMovedToSuperclass(t *testing.T) {
+	oldClass := publicClass("p/A", "java/lang/Object")
+	oldClass.AddMethod(classfile.ACC_PUBLIC, "m", "()V")
+	oldClass.AddField(classfile.ACC_PUBLIC, "f", "I")
+
+	base := publicClass("p/Base", "java/lang/Object")
+	base.AddMethod(classfile.ACC_PUBLIC, "m", "()V")
+	base.AddField(classfile.ACC_PUBLIC, "f", "I")
+	newClass := publicClass("p/A", "p/Base")
+
+	changes, err := Compare([]*classfile.ClassFile{oldClass}, []*classfile.ClassFile{base, newClass})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{
+		"p.A: changed, superclass changed from java.lang.Object to p.Base": true,
+		"p.A: field int f: changed, now inherited from p.Base":             true,
+		"p.A: method void m(): changed, now inherited from p.Base":         true,
+		"p.Base: added": true,
+	}
+	for _, change := range changes {
+		if change.IsBreaking() {
+			t.Errorf("unexpected breaking change: %v", change)
+		}
+		if !want[change.String()] {
+			t.Errorf("unexpected change: %v", change)
+		}
+		delete(want, change.String())
+	}
+	for change := range want {
+		t.Errorf("missing change: %s", change)
+	}
+}
+
+// 继承的成员访问权限更小，或者找不到继承的成员时，仍然是不兼容的变化
+func TestCompareMemberNotInherited(t *testing.T) {
+	oldClass := publicClass("p/A", "java/lang/Object")
+	oldClass.AddMethod(classfile.ACC_PUBLIC, "m", "()V")
+	oldClass.AddMethod(classfile.ACC_PUBLIC, "n", "()V")
+
+	base := publicClass("p/Base", "java/lang/Object")
+	base.AddMethod(classfile.ACC_PROTECTED, "m", "()V")
+	base.AddMethod(classfile.ACC_PRIVATE, "n", "()V")
+	newClass := publicClass("p/A", "p/Base")
+
+	changes, err := Compare([]*classfile.ClassFile{oldClass}, []*classfile.ClassFile{base, newClass})
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaking := map[string]bool{}
+	for _, change := range changes {
+		if change.IsBreaking() {
+			breaking[change.String()] = true
+		}
+	}
+	for _, want := range []string{
+		"p.A: method void m(): changed, access changed from public to protected (breaking)",
+		"p.A: method void n(): removed (breaking)",
+	} {
+		if !breaking[want] {
+			t.Errorf("missing breaking change %q in %v", want, changes)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package classfile
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 类、字段、方法和内部类的访问标志，见 JVM 规范 4.1、4.5、4.6 和 4.7.6 节
+// 同一个位在不同的上下文中有不同的含义，例如 0x0020 对类是 ACC_SUPER，对方法是 ACC_SYNCHRONIZED，
+// 所以按上下文分为 ClassAccessFlags、FieldAccessFlags、MethodAccessFlags 和 InnerClassAccessFlags 四种类型，
+// 从 AccessFlags() 得到的 uint16 直接转换即可，例如 ClassAccessFlags(cf.AccessFlags()).IsInterface()
+//
+// 规范中没有定义的位必须忽略，String() 和 Names() 不会输出它们，Validate() 也不检查它们
+const (
+	ACC_PUBLIC       = 0x0001
+	ACC_PRIVATE      = 0x0002
+	ACC_PROTECTED    = 0x0004
+	ACC_STATIC       = 0x0008
+	ACC_FINAL        = 0x0010
+	ACC_SUPER        = 0x0020 // 类：invokespecial 使用新的语义
+	ACC_SYNCHRONIZED = 0x0020 // 方法
+	ACC_VOLATILE     = 0x0040 // 字段
+	ACC_BRIDGE       = 0x0040 // 方法：编译器生成的桥接方法
+	ACC_TRANSIENT    = 0x0080 // 字段
+	ACC_VARARGS      = 0x0080 // 方法：可变参数
+	ACC_NATIVE       = 0x0100
+	ACC_INTERFACE    = 0x0200
+	ACC_ABSTRACT     = 0x0400
+	ACC_STRICT       = 0x0800 // 方法：strictfp
+	ACC_ANNOTATION   = 0x2000
+	ACC_ENUM         = 0x4000
+	ACC_MODULE       = 0x8000 // 类：module-info
+	// ACC_SYNTHETIC 定义在 module_descriptor.go 中，对所有上下文都是 0x1000
+)
+
+type ClassAccessFlags uint16
+type FieldAccessFlags uint16
+type MethodAccessFlags uint16
+type InnerClassAccessFlags uint16
+
+type accessFlagName struct {
+	flag uint16
+	name string
+}
+
+// 各上下文中有意义的标志，按位从低到高排列，与 javap 的输出顺序相同
+var (
+	classFlagNames = []accessFlagName{{ACC_PUBLIC, "ACC_PUBLIC"}, {ACC_FINAL, "ACC_FINAL"}, {ACC_SUPER, "ACC_SUPER"},
+		{ACC_INTERFACE, "ACC_INTERFACE"}, {ACC_ABSTRACT, "ACC_ABSTRACT"}, {ACC_SYNTHETIC, "ACC_SYNTHETIC"},
+		{ACC_ANNOTATION, "ACC_ANNOTATION"}, {ACC_ENUM, "ACC_ENUM"}, {ACC_MODULE, "ACC_MODULE"}}
+	fieldFlagNames = []accessFlagName{{ACC_PUBLIC, "ACC_PUBLIC"}, {ACC_PRIVATE, "ACC_PRIVATE"}, {ACC_PROTECTED, "ACC_PROTECTED"},
+		{ACC_STATIC, "ACC_STATIC"}, {ACC_FINAL, "ACC_FINAL"}, {ACC_VOLATILE, "ACC_VOLATILE"},
+		{ACC_TRANSIENT, "ACC_TRANSIENT"}, {ACC_SYNTHETIC, "ACC_SYNTHETIC"}, {ACC_ENUM, "ACC_ENUM"}}
+	methodFlagNames = []accessFlagName{{ACC_PUBLIC, "ACC_PUBLIC"}, {ACC_PRIVATE, "ACC_PRIVATE"}, {ACC_PROTECTED, "ACC_PROTECTED"},
+		{ACC_STATIC, "ACC_STATIC"}, {ACC_FINAL, "ACC_FINAL"}, {ACC_SYNCHRONIZED, "ACC_SYNCHRONIZED"},
+		{ACC_BRIDGE, "ACC_BRIDGE"}, {ACC_VARARGS, "ACC_VARARGS"}, {ACC_NATIVE, "ACC_NATIVE"},
+		{ACC_ABSTRACT, "ACC_ABSTRACT"}, {ACC_STRICT, "ACC_STRICT"}, {ACC_SYNTHETIC, "ACC_SYNTHETIC"}}
+	innerClassFlagNames = []accessFlagName{{ACC_PUBLIC, "ACC_PUBLIC"}, {ACC_PRIVATE, "ACC_PRIVATE"},
+		{ACC_PROTECTED, "ACC_PROTECTED"}, {ACC_STATIC, "ACC_STATIC"}, {ACC_FINAL, "ACC_FINAL"},
+		{ACC_INTERFACE, "ACC_INTERFACE"}, {ACC_ABSTRACT, "ACC_ABSTRACT"}, {ACC_SYNTHETIC, "ACC_SYNTHETIC"},
+		{ACC_ANNOTATION, "ACC_ANNOTATION"}, {ACC_ENUM, "ACC_ENUM"}}
+)
+
+func accessFlagNames(flags uint16, names []accessFlagName) []string {
+	result := []string{}
+	for _, n := range names {
+		if flags&n.flag != 0 {
+			result = append(result, n.name)
+		}
+	}
+	return result
+}
+
+// 设置了多于一个的 public、private、protected
+func hasConflictingAccess(flags uint16) bool {
+	n := 0
+	for _, flag := range []uint16{ACC_PUBLIC, ACC_PRIVATE, ACC_PROTECTED} {
+		if flags&flag != 0 {
+			n++
+		}
+	}
+	return n > 1
+}
+
+func (self ClassAccessFlags) IsPublic() bool {
+	return self&ACC_PUBLIC != 0
+}
+func (self ClassAccessFlags) IsFinal() bool {
+	return self&ACC_FINAL != 0
+}
+func (self ClassAccessFlags) IsSuper() bool {
+	return self&ACC_SUPER != 0
+}
+func (self ClassAccessFlags) IsInterface() bool {
+	return self&ACC_INTERFACE != 0
+}
+func (self ClassAccessFlags) IsAbstract() bool {
+	return self&ACC_ABSTRACT != 0
+}
+func (self ClassAccessFlags) IsSynthetic() bool {
+	return self&ACC_SYNTHETIC != 0
+}
+func (self ClassAccessFlags) IsAnnotation() bool {
+	return self&ACC_ANNOTATION != 0
+}
+func (self ClassAccessFlags) IsEnum() bool {
+	return self&ACC_ENUM != 0
+}
+func (self ClassAccessFlags) IsModule() bool {
+	return self&ACC_MODULE != 0
+}
+
+// 标志名列表，例如 [ACC_PUBLIC ACC_SUPER]
+func (self ClassAccessFlags) Names() []string {
+	return accessFlagNames(uint16(self), classFlagNames)
+}
+
+// 以逗号分隔的标志名，例如 "ACC_PUBLIC, ACC_SUPER"
+func (self ClassAccessFlags) String() string {
+	return strings.Join(self.Names(), ", ")
+}
+
+// 按照 JVM 规范 4.1 节检查类的访问标志，majorVersion 决定 ACC_MODULE 等标志是否有效
+// 与 HotSpot 相同，接口的 ACC_SUPER 和 ACC_ENUM 只对 49（Java 5）及以上版本检查
+func (self ClassAccessFlags) Validate(majorVersion uint16) error {
+	if majorVersion >= 53 && self.IsModule() {
+		if self&^ACC_MODULE&(ACC_PUBLIC|ACC_FINAL|ACC_SUPER|ACC_INTERFACE|ACC_ABSTRACT|ACC_SYNTHETIC|ACC_ANNOTATION|ACC_ENUM) != 0 {
+			return errors.New("ACC_MODULE must not be combined with other flags")
+		}
+		return nil
+	}
+	if self.IsInterface() {
+		switch {
+		case !self.IsAbstract():
+			return errors.New("interface must be abstract")
+		case self.IsFinal():
+			return errors.New("interface must not be final")
+		case majorVersion >= 49 && self.IsSuper():
+			return errors.New("interface must not have ACC_SUPER")
+		case majorVersion >= 49 && self.IsEnum():
+			return errors.New("interface must not be an enum")
+		}
+		return nil
+	}
+	switch {
+	case self.IsAnnotation():
+		return errors.New("annotation type must be an interface")
+	case self.IsFinal() && self.IsAbstract():
+		return errors.New("class must not be both final and abstract")
+	}
+	return nil
+}
+
+func (self FieldAccessFlags) IsPublic() bool {
+	return self&ACC_PUBLIC != 0
+}
+func (self FieldAccessFlags) IsPrivate() bool {
+	return self&ACC_PRIVATE != 0
+}
+func (self FieldAccessFlags) IsProtected() bool {
+	return self&ACC_PROTECTED != 0
+}
+func (self FieldAccessFlags) IsStatic() bool {
+	return self&ACC_STATIC != 0
+}
+func (self FieldAccessFlags) IsFinal() bool {
+	return self&ACC_FINAL != 0
+}
+func (self FieldAccessFlags) IsVolatile() bool {
+	return self&ACC_VOLATILE != 0
+}
+func (self FieldAccessFlags) IsTransient() bool {
+	return self&ACC_TRANSIENT != 0
+}
+func (self FieldAccessFlags) IsSynthetic() bool {
+	return self&ACC_SYNTHETIC != 0
+}
+func (self FieldAccessFlags) IsEnum() bool {
+	return self&ACC_ENUM != 0
+}
+
+func (self FieldAccessFlags) Names() []string {
+	return accessFlagNames(uint16(self), fieldFlagNames)
+}
+func (self FieldAccessFlags) String() string {
+	return strings.Join(self.Names(), ", ")
+}
+
+// 按照 JVM 规范 4.5 节检查字段的访问标志，inInterface 表示字段属于接口
+func (self FieldAccessFlags) Validate(inInterface bool) error {
+	switch {
+	case hasConflictingAccess(uint16(self)):
+		return errors.New("at most one of public, private and protected is allowed")
+	case self.IsFinal() && self.IsVolatile():
+		return errors.New("field must not be both final and volatile")
+	case inInterface && !(self.IsPublic() && self.IsStatic() && self.IsFinal()):
+		return errors.New("interface field must be public, static and final")
+	case inInterface && self&(ACC_VOLATILE|ACC_TRANSIENT|ACC_ENUM) != 0:
+		return errors.New("interface field must not be volatile, transient or an enum constant")
+	}
+	return nil
+}
+
+func (self MethodAccessFlags) IsPublic() bool {
+	return self&ACC_PUBLIC != 0
+}
+func (self MethodAccessFlags) IsPrivate() bool {
+	return self&ACC_PRIVATE != 0
+}
+func (self MethodAccessFlags) IsProtected() bool {
+	return self&ACC_PROTECTED != 0
+}
+func (self MethodAccessFlags) IsStatic() bool {
+	return self&ACC_STATIC != 0
+}
+func (self MethodAccessFlags) IsFinal() bool {
+	return self&ACC_FINAL != 0
+}
+func (self MethodAccessFlags) IsSynchronized() bool {
+	return self&ACC_SYNCHRONIZED != 0
+}
+func (self MethodAccessFlags) IsBridge() bool {
+	return self&ACC_BRIDGE != 0
+}
+func (self MethodAccessFlags) IsVarargs() bool {
+	return self&ACC_VARARGS != 0
+}
+func (self MethodAccessFlags) IsNative() bool {
+	return self&ACC_NATIVE != 0
+}
+func (self MethodAccessFlags) IsAbstract() bool {
+	return self&ACC_ABSTRACT != 0
+}
+func (self MethodAccessFlags) IsStrict() bool {
+	return self&ACC_STRICT != 0
+}
+func (self MethodAccessFlags) IsSynthetic() bool {
+	return self&ACC_SYNTHETIC != 0
+}
+
+func (self MethodAccessFlags) Names() []string {
+	return accessFlagNames(uint16(self), methodFlagNames)
+}
+func (self MethodAccessFlags) String() string {
+	return strings.Join(self.Names(), ", ")
+}
+
+// 按照 JVM 规范 4.6 节检查方法的访问标志，name 用于识别 <init> 和 <clinit>
+// <clinit> 的标志除 ACC_STATIC 外都被忽略，这里不检查；
+// 52（Java 8）之前的接口方法必须是 public abstract，之后可以有 private 方法和 static、default 方法
+func (self MethodAccessFlags) Validate(majorVersion uint16, inInterface bool, name string) error {
+	if name == "<clinit>" {
+		return nil
+	}
+	if hasConflictingAccess(uint16(self)) {
+		return errors.New("at most one of public, private and protected is allowed")
+	}
+	if inInterface {
+		switch {
+		case self&(ACC_PROTECTED|ACC_FINAL|ACC_SYNCHRONIZED|ACC_NATIVE) != 0:
+			return errors.New("interface method must not be protected, final, synchronized or native")
+		case majorVersion < 52 && !(self.IsPublic() && self.IsAbstract()):
+			return errors.New("interface method must be public and abstract before version 52")
+		case majorVersion >= 52 && !self.IsPublic() && !self.IsPrivate():
+			return errors.New("interface method must be either public or private")
+		}
+	}
+	if self.IsAbstract() {
+		if self&(ACC_PRIVATE|ACC_STATIC|ACC_FINAL|ACC_SYNCHRONIZED|ACC_NATIVE) != 0 {
+			return errors.New("abstract method must not be private, static, final, synchronized or native")
+		}
+		if self.IsStrict() && majorVersion >= 46 && majorVersion <= 60 {
+			return errors.New("abstract method must not be strictfp")
+		}
+	}
+	if name == "<init>" && self&(ACC_STATIC|ACC_FINAL|ACC_SYNCHRONIZED|ACC_BRIDGE|ACC_NATIVE|ACC_ABSTRACT) != 0 {
+		return errors.New("<init> may only have access flags, varargs, strictfp and synthetic")
+	}
+	return nil
+}
+
+func (self InnerClassAccessFlags) IsPublic() bool {
+	return self&ACC_PUBLIC != 0
+}
+func (self InnerClassAccessFlags) IsPrivate() bool {
+	return self&ACC_PRIVATE != 0
+}
+func (self InnerClassAccessFlags) IsProtected() bool {
+	return self&ACC_PROTECTED != 0
+}
+func (self InnerClassAccessFlags) IsStatic() bool {
+	return self&ACC_STATIC != 0
+}
+func (self InnerClassAccessFlags) IsFinal() bool {
+	return self&ACC_FINAL != 0
+}
+func (self InnerClassAccessFlags) IsInterface() bool {
+	return self&ACC_INTERFACE != 0
+}
+func (self InnerClassAccessFlags) IsAbstract() bool {
+	return self&ACC_ABSTRACT != 0
+}
+func (self InnerClassAccessFlags) IsSynthetic() bool {
+	return self&ACC_SYNTHETIC != 0
+}
+func (self InnerClassAccessFlags) IsAnnotation() bool {
+	return self&ACC_ANNOTATION != 0
+}
+func (self InnerClassAccessFlags) IsEnum() bool {
+	return self&ACC_ENUM != 0
+}
+
+func (self InnerClassAccessFlags) Names() []string {
+	return accessFlagNames(uint16(self), innerClassFlagNames)
+}
+func (self InnerClassAccessFlags) String() string {
+	return strings.Join(self.Names(), ", ")
+}
+
+// 依次检查类、字段和方法的访问标志，返回第一个错误，错误信息与 HotSpot 的 ClassFormatError 相同
+// 解析时不做这个检查，javap 等工具需要能够读取标志不合法的 class 文件，加载类时应当调用它
+func (self *ClassFile) ValidateAccessFlags() error {
+	cp := self.constantPool
+	classFlags := ClassAccessFlags(self.accessFlags)
+	if err := classFlags.Validate(self.majorVersion); err != nil {
+		return fmt.Errorf("java.lang.ClassFormatError: Illegal class modifiers in class %s: 0x%X (%v)",
+			cp.classNameOrIndex(self.thisClass), self.accessFlags, err)
+	}
+	inInterface := classFlags.IsInterface()
+	for _, field := range self.fields {
+		if err := FieldAccessFlags(field.accessFlags).Validate(inInterface); err != nil {
+			return fmt.Errorf("java.lang.ClassFormatError: Illegal field modifiers in class %s: 0x%X (field %s: %v)",
+				cp.classNameOrIndex(self.thisClass), field.accessFlags, cp.utf8OrIndex(field.nameIndex), err)
+		}
+	}
+	for _, method := range self.methods {
+		// 方法名无效时得到的 "#索引" 不是 <init> 或 <clinit>，按普通方法检查
+		name := cp.utf8OrIndex(method.nameIndex)
+		if err := MethodAccessFlags(method.accessFlags).Validate(self.majorVersion, inInterface, name); err != nil {
+			return fmt.Errorf("java.lang.ClassFormatError: Method %s in class %s has illegal modifiers: 0x%X (%v)",
+				name, cp.classNameOrIndex(self.thisClass), method.accessFlags, err)
+		}
+	}
+	return nil
+}
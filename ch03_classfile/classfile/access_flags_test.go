@@ -0,0 +1,131 @@
+package classfile
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// 同一个位在不同上下文中有不同的名称
+func TestAccessFlagNames(t *testing.T) {
+	tests := []struct {
+		got, want string
+	}{
+		{ClassAccessFlags(0x0021).String(), "ACC_PUBLIC, ACC_SUPER"},
+		{MethodAccessFlags(0x0021).String(), "ACC_PUBLIC, ACC_SYNCHRONIZED"},
+		{FieldAccessFlags(0x0040).String(), "ACC_VOLATILE"},
+		{MethodAccessFlags(0x0040).String(), "ACC_BRIDGE"},
+		{FieldAccessFlags(0x0080).String(), "ACC_TRANSIENT"},
+		{MethodAccessFlags(0x0080).String(), "ACC_VARARGS"},
+		{InnerClassAccessFlags(0x0608).String(), "ACC_STATIC, ACC_INTERFACE, ACC_ABSTRACT"},
+		{ClassAccessFlags(0x8000).String(), "ACC_MODULE"},
+		{FieldAccessFlags(0x0800).String(), ""}, // 字段没有 ACC_STRICT
+	}
+	for i, test := range tests {
+		if test.got != test.want {
+			t.Errorf("%d: got %q, want %q", i, test.got, test.want)
+		}
+	}
+}
+
+func TestValidateClassAccessFlags(t *testing.T) {
+	tests := []struct {
+		flags   uint16
+		version uint16
+		valid   bool
+	}{
+		{ACC_PUBLIC | ACC_SUPER, 52, true},
+		{ACC_FINAL | ACC_ABSTRACT, 52, false},
+		{ACC_INTERFACE | ACC_ABSTRACT, 52, true},
+		{ACC_INTERFACE, 52, false},
+		{ACC_INTERFACE | ACC_ABSTRACT | ACC_FINAL, 52, false},
+		{ACC_INTERFACE | ACC_ABSTRACT | ACC_SUPER, 52, false},
+		{ACC_INTERFACE | ACC_ABSTRACT | ACC_SUPER, 48, true}, // 49 之前不检查
+		{ACC_ANNOTATION | ACC_INTERFACE | ACC_ABSTRACT, 52, true},
+		{ACC_ANNOTATION | ACC_ABSTRACT, 52, false},
+		{ACC_MODULE, 53, true},
+		{ACC_MODULE | ACC_PUBLIC, 53, false},
+	}
+	for _, test := range tests {
+		err := ClassAccessFlags(test.flags).Validate(test.version)
+		if (err == nil) != test.valid {
+			t.Errorf("ClassAccessFlags(0x%04x).Validate(%d) = %v, want valid %v", test.flags, test.version, err, test.valid)
+		}
+	}
+}
+
+func TestValidateFieldAccessFlags(t *testing.T) {
+	tests := []struct {
+		flags       uint16
+		inInterface bool
+		valid       bool
+	}{
+		{ACC_PRIVATE | ACC_FINAL, false, true},
+		{ACC_PUBLIC | ACC_PRIVATE, false, false},
+		{ACC_FINAL | ACC_VOLATILE, false, false},
+		{ACC_PUBLIC | ACC_STATIC | ACC_FINAL, true, true},
+		{ACC_PUBLIC | ACC_STATIC, true, false},
+		{ACC_PUBLIC | ACC_STATIC | ACC_FINAL | ACC_TRANSIENT, true, false},
+	}
+	for _, test := range tests {
+		err := FieldAccessFlags(test.flags).Validate(test.inInterface)
+		if (err == nil) != test.valid {
+			t.Errorf("FieldAccessFlags(0x%04x).Validate(%v) = %v, want valid %v", test.flags, test.inInterface, err, test.valid)
+		}
+	}
+}
+
+func TestValidateMethodAccessFlags(t *testing.T) {
+	tests := []struct {
+		flags       uint16
+		version     uint16
+		inInterface bool
+		name        string
+		valid       bool
+	}{
+		{ACC_PUBLIC | ACC_STATIC, 52, false, "m", true},
+		{ACC_PUBLIC | ACC_PROTECTED, 52, false, "m", false},
+		{ACC_ABSTRACT | ACC_FINAL, 52, false, "m", false},
+		{ACC_ABSTRACT | ACC_STRICT, 52, false, "m", false},
+		{ACC_ABSTRACT | ACC_STRICT, 61, false, "m", true}, // 从 Java 17 起 strictfp 不再有意义
+		{ACC_PUBLIC | ACC_ABSTRACT, 51, true, "m", true},
+		{ACC_PUBLIC | ACC_STATIC, 51, true, "m", false},
+		{ACC_PUBLIC | ACC_STATIC, 52, true, "m", true},
+		{ACC_PRIVATE, 52, true, "m", true},
+		{0, 52, true, "m", false},
+		{ACC_PUBLIC | ACC_SYNCHRONIZED, 52, true, "m", false},
+		{ACC_PUBLIC | ACC_VARARGS, 52, false, "<init>", true},
+		{ACC_PUBLIC | ACC_STATIC, 52, false, "<init>", false},
+		{ACC_PUBLIC | ACC_PRIVATE | ACC_STATIC, 52, false, "<clinit>", true}, // <clinit> 不检查
+	}
+	for _, test := range tests {
+		err := MethodAccessFlags(test.flags).Validate(test.version, test.inInterface, test.name)
+		if (err == nil) != test.valid {
+			t.Errorf("MethodAccessFlags(0x%04x).Validate(%d, %v, %s) = %v, want valid %v",
+				test.flags, test.version, test.inInterface, test.name, err, test.valid)
+		}
+	}
+}
+
+// 类名和成员名的常量池索引无效时，错误信息中以 "#索引" 代替名称
+func TestValidateAccessFlagsWithInvalidNames(t *testing.T) {
+	cf := NewClassFile(52, 0, ACC_PUBLIC|ACC_SUPER, "T", "java/lang/Object")
+	method := cf.AddMethod(ACC_PUBLIC|ACC_PRIVATE, "m", "()V")
+	cf.thisClass = 999
+	method.nameIndex = cf.superClass
+
+	err := cf.ValidateAccessFlags()
+	if err == nil {
+		t.Fatal("ValidateAccessFlags() succeeded, want an error for public and private")
+	}
+	want := fmt.Sprintf("Method #%d in class #999 has illegal modifiers", cf.superClass)
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("ValidateAccessFlags() = %q, want it to contain %q", err, want)
+	}
+
+	field := cf.AddField(ACC_PUBLIC|ACC_PROTECTED, "f", "I")
+	field.nameIndex = 0
+	if err := cf.ValidateAccessFlags(); err == nil || !strings.Contains(err.Error(), "in class #999: 0x5 (field #0:") {
+		t.Errorf("ValidateAccessFlags() = %v, want an error for field #0", err)
+	}
+}
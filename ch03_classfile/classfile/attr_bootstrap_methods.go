@@ -0,0 +1,59 @@
+package classfile
+
+// BootstrapMethods 是变长属性，只会出现在 ClassFile 结构中，记录 invokedynamic 指令引用的引导方法，其结构如下：
+// BootstrapMethods_attribute {
+// 	   u2 attribute_name_index;
+// 	   u4 attribute_length;
+// 	   u2 num_bootstrap_methods;
+// 	   {
+// 	       u2 bootstrap_method_ref;
+// 	       u2 num_bootstrap_arguments;
+// 	       u2 bootstrap_arguments[num_bootstrap_arguments];
+// 	   } bootstrap_methods[num_bootstrap_methods];
+// }
+//
+// bootstrap_method_ref 指向 CONSTANT_MethodHandle_info，bootstrap_arguments 中的每一项也是常量池索引，
+// 可以指向 CONSTANT_String_info、CONSTANT_Class_info、数字常量、CONSTANT_MethodHandle_info 或 CONSTANT_MethodType_info
+//
+// 以 lambda 表达式为例，引导方法为 LambdaMetafactory.metafactory，三个静态参数依次是擦除后的方法类型、
+// 实现方法的句柄和实例化后的方法类型；以字符串拼接为例，引导方法为 StringConcatFactory.makeConcatWithConstants，
+// 参数为拼接模板字符串（\u0001 表示一个动态参数，\u0002 表示一个常量参数）和模板中引用的常量
+type BootstrapMethodsAttribute struct {
+	attributeHeader
+	bootstrapMethods []*BootstrapMethod
+}
+
+type BootstrapMethod struct {
+	bootstrapMethodRef uint16
+	bootstrapArguments []uint16
+}
+
+func (self *BootstrapMethodsAttribute) readInfo(reader *ClassReader) {
+	numBootstrapMethods := reader.readUint16()
+	self.bootstrapMethods = make([]*BootstrapMethod, numBootstrapMethods)
+	for i := range self.bootstrapMethods {
+		self.bootstrapMethods[i] = &BootstrapMethod{
+			bootstrapMethodRef: reader.readUint16(),
+			bootstrapArguments: reader.readUint16s(),
+		}
+	}
+}
+
+func (self *BootstrapMethodsAttribute) writeInfo(writer *ClassWriter) {
+	writer.writeUint16(uint16(len(self.bootstrapMethods)))
+	for _, bm := range self.bootstrapMethods {
+		writer.writeUint16(bm.bootstrapMethodRef)
+		writer.writeUint16s(bm.bootstrapArguments)
+	}
+}
+
+func (self *BootstrapMethodsAttribute) BootstrapMethods() []*BootstrapMethod {
+	return self.bootstrapMethods
+}
+
+func (self *BootstrapMethod) BootstrapMethodRef() uint16 {
+	return self.bootstrapMethodRef
+}
+func (self *BootstrapMethod) BootstrapArguments() []uint16 {
+	return self.bootstrapArguments
+}
@@ -24,6 +24,7 @@ package classfile
 // max_locals 给出局部变量表大小，之后是字节码，存放在 ul 表中
 // 之后是异常处理表和属性表
 type CodeAttribute struct {
+	attributeHeader
 	cp             ConstantPool
 	maxStack       uint16
 	maxLocals      uint16
@@ -48,6 +49,15 @@ func (self *CodeAttribute) readInfo(reader *ClassReader) {
 	self.attributes = readAttributes(reader, self.cp)
 }
 
+func (self *CodeAttribute) writeInfo(writer *ClassWriter) {
+	writer.writeUint16(self.maxStack)
+	writer.writeUint16(self.maxLocals)
+	writer.writeUint32(uint32(len(self.code)))
+	writer.writeBytes(self.code)
+	writeExceptionTable(writer, self.exceptionTable)
+	writeAttributes(writer, self.attributes, self.cp)
+}
+
 // 构建异常处理表
 func readExceptionTable(reader *ClassReader) []*ExceptionTableEntry {
 	exceptionTableLength := reader.readUint16()
@@ -62,3 +72,90 @@ func readExceptionTable(reader *ClassReader) []*ExceptionTableEntry {
 	}
 	return exceptionTable
 }
+
+func writeExceptionTable(writer *ClassWriter, exceptionTable []*ExceptionTableEntry) {
+	writer.writeUint16(uint16(len(exceptionTable)))
+	for _, entry := range exceptionTable {
+		writer.writeUint16(entry.startPc)
+		writer.writeUint16(entry.endPc)
+		writer.writeUint16(entry.handlerPc)
+		writer.writeUint16(entry.catchType)
+	}
+}
+
+// getter 方法
+func (self *CodeAttribute) MaxStack() uint {
+	return uint(self.maxStack)
+}
+func (self *CodeAttribute) MaxLocals() uint {
+	return uint(self.maxLocals)
+}
+func (self *CodeAttribute) Code() []byte {
+	return self.code
+}
+func (self *CodeAttribute) ExceptionTable() []*ExceptionTableEntry {
+	return self.exceptionTable
+}
+func (self *CodeAttribute) Attributes() []AttributeInfo {
+	return self.attributes
+}
+
+func (self *ExceptionTableEntry) StartPc() uint16 {
+	return self.startPc
+}
+func (self *ExceptionTableEntry) EndPc() uint16 {
+	return self.endPc
+}
+func (self *ExceptionTableEntry) HandlerPc() uint16 {
+	return self.handlerPc
+}
+
+// catchType 为 0 时表示捕获所有异常（用于实现 finally），否则指向 CONSTANT_Class_info
+func (self *ExceptionTableEntry) CatchType() uint16 {
+	return self.catchType
+}
+
+// Code 属性自身也有属性表，调试信息 LineNumberTable、LocalVariableTable 等就存放在这里
+// 查找 LineNumberTable 属性，编译时使用 -g:none 则不存在，此时返回 nil
+func (self *CodeAttribute) LineNumberTableAttribute() *LineNumberTableAttribute {
+	for _, attrInfo := range self.attributes {
+		switch attrInfo.(type) {
+		case *LineNumberTableAttribute:
+			return attrInfo.(*LineNumberTableAttribute)
+		}
+	}
+	return nil
+}
+
+// 查找 LocalVariableTable 属性，编译时没有使用 -g 则不存在，此时返回 nil
+func (self *CodeAttribute) LocalVariableTableAttribute() *LocalVariableTableAttribute {
+	for _, attrInfo := range self.attributes {
+		switch attrInfo.(type) {
+		case *LocalVariableTableAttribute:
+			return attrInfo.(*LocalVariableTableAttribute)
+		}
+	}
+	return nil
+}
+
+// 查找 LocalVariableTypeTable 属性，只有存在泛型局部变量时才会生成，否则返回 nil
+func (self *CodeAttribute) LocalVariableTypeTableAttribute() *LocalVariableTypeTableAttribute {
+	for _, attrInfo := range self.attributes {
+		switch attrInfo.(type) {
+		case *LocalVariableTypeTableAttribute:
+			return attrInfo.(*LocalVariableTypeTableAttribute)
+		}
+	}
+	return nil
+}
+
+// 查找 StackMapTable 属性，版本号 50 以下的 class 文件或没有分支的方法不存在该属性，此时返回 nil
+func (self *CodeAttribute) StackMapTableAttribute() *StackMapTableAttribute {
+	for _, attrInfo := range self.attributes {
+		switch attrInfo.(type) {
+		case *StackMapTableAttribute:
+			return attrInfo.(*StackMapTableAttribute)
+		}
+	}
+	return nil
+}
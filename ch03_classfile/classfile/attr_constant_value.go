@@ -10,6 +10,7 @@ package classfile
 // attribute_length 值永为 2，constantvalue_index 是常量池索引，但具体指向的常量因
 // 字段类型而异，如 CONSTANT_Long_info，CONSTANT_String_info 等等
 type ConstantValueAttribute struct {
+	attributeHeader
 	constantValueIndex uint16
 }
 
@@ -17,6 +18,10 @@ func (self *ConstantValueAttribute) readInfo(reader *ClassReader) {
 	self.constantValueIndex = reader.readUint16()
 }
 
+func (self *ConstantValueAttribute) writeInfo(writer *ClassWriter) {
+	writer.writeUint16(self.constantValueIndex)
+}
+
 func (self *ConstantValueAttribute) ConstantValueIndex() uint16 {
 	return self.constantValueIndex
 }
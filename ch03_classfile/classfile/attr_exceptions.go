@@ -8,6 +8,7 @@ package classfile
 // 	   u2 exception_index_table[number_of_exceptions;]
 // }
 type ExceptionsAttribute struct {
+	attributeHeader
 	exceptionIndexTable []uint16
 }
 
@@ -15,6 +16,10 @@ func (self *ExceptionsAttribute) readInfo(reader *ClassReader) {
 	self.exceptionIndexTable = reader.readUint16s()
 }
 
+func (self *ExceptionsAttribute) writeInfo(writer *ClassWriter) {
+	writer.writeUint16s(self.exceptionIndexTable)
+}
+
 func (self *ExceptionsAttribute) ExceptionIndexTable() []uint16 {
 	return self.exceptionIndexTable
 }
@@ -12,6 +12,7 @@ package classfile
 // 	   } line_number_table[line_number_table_length]
 // }
 type LineNumberTableAttribute struct {
+	attributeHeader
 	lineNumberTable []*LineNumberTableEntry
 }
 
@@ -30,3 +31,36 @@ func (self *LineNumberTableAttribute) readInfo(reader *ClassReader) {
 		}
 	}
 }
+
+func (self *LineNumberTableAttribute) writeInfo(writer *ClassWriter) {
+	writer.writeUint16(uint16(len(self.lineNumberTable)))
+	for _, entry := range self.lineNumberTable {
+		writer.writeUint16(entry.startPc)
+		writer.writeUint16(entry.lineNumber)
+	}
+}
+
+func (self *LineNumberTableAttribute) LineNumberTable() []*LineNumberTableEntry {
+	return self.lineNumberTable
+}
+
+// 根据 pc 查找源码行号：找到 startPc 不大于 pc 的最后一个表项
+// 编译器生成的表项不一定按 startPc 排序，所以这里遍历全表而不是二分查找
+// 如果找不到则返回 -1
+func (self *LineNumberTableAttribute) GetLineNumber(pc int) int {
+	lineNumber, bestPc := -1, -1
+	for _, entry := range self.lineNumberTable {
+		startPc := int(entry.startPc)
+		if startPc <= pc && startPc > bestPc {
+			lineNumber, bestPc = int(entry.lineNumber), startPc
+		}
+	}
+	return lineNumber
+}
+
+func (self *LineNumberTableEntry) StartPc() uint16 {
+	return self.startPc
+}
+func (self *LineNumberTableEntry) LineNumber() uint16 {
+	return self.lineNumber
+}
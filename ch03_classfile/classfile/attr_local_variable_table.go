@@ -1,6 +1,6 @@
 package classfile
 
-// LocalVariableTable 属于可选的调试信息，用于存放方法行号
+// LocalVariableTable 属于可选的调试信息，用于存放方法的局部变量名和类型
 // 和 LineNumberTable 属性表在结构上很像，其结构为
 /*
 LocalVariableTable_attribute {
@@ -15,11 +15,17 @@ LocalVariableTable_attribute {
     } local_variable_table[local_variable_table_length];
 }
 */
+// 每一项表示局部变量表第 index 个 slot 在 [start_pc, start_pc + length) 范围内存放的变量，
+// name_index 和 descriptor_index 都指向 CONSTANT_Utf8_info 常量
+// 同一个 slot 在不同的 pc 范围内可能被不同的变量复用，所以查找变量时需要同时给出 slot 和 pc
 type LocalVariableTableAttribute struct {
+	attributeHeader
+	cp                 ConstantPool
 	localVariableTable []*LocalVariableTableEntry
 }
 
 type LocalVariableTableEntry struct {
+	cp              ConstantPool
 	startPc         uint16
 	length          uint16
 	nameIndex       uint16
@@ -32,6 +38,7 @@ func (self *LocalVariableTableAttribute) readInfo(reader *ClassReader) {
 	self.localVariableTable = make([]*LocalVariableTableEntry, localVariableTableLength)
 	for i := range self.localVariableTable {
 		self.localVariableTable[i] = &LocalVariableTableEntry{
+			cp:              self.cp,
 			startPc:         reader.readUint16(),
 			length:          reader.readUint16(),
 			nameIndex:       reader.readUint16(),
@@ -40,3 +47,78 @@ func (self *LocalVariableTableAttribute) readInfo(reader *ClassReader) {
 		}
 	}
 }
+
+func (self *LocalVariableTableAttribute) writeInfo(writer *ClassWriter) {
+	writer.writeUint16(uint16(len(self.localVariableTable)))
+	for _, entry := range self.localVariableTable {
+		writer.writeUint16(entry.startPc)
+		writer.writeUint16(entry.length)
+		writer.writeUint16(entry.nameIndex)
+		writer.writeUint16(entry.descriptorIndex)
+		writer.writeUint16(entry.index)
+	}
+}
+
+func (self *LocalVariableTableAttribute) LocalVariableTable() []*LocalVariableTableEntry {
+	return self.localVariableTable
+}
+
+// 根据 slot 索引和 pc 查找局部变量，找不到时返回 nil
+func (self *LocalVariableTableAttribute) GetLocalVariable(index uint16, pc int) *LocalVariableTableEntry {
+	for _, entry := range self.localVariableTable {
+		if entry.index == index && entry.covers(pc) {
+			return entry
+		}
+	}
+	return nil
+}
+
+// 根据 slot 索引和 pc 查找局部变量名，找不到时返回空字符串
+func (self *LocalVariableTableAttribute) GetLocalVariableName(index uint16, pc int) string {
+	if entry := self.GetLocalVariable(index, pc); entry != nil {
+		return entry.Name()
+	}
+	return ""
+}
+
+// 根据变量名查找局部变量，同名变量（位于不同代码块中）可能有多个，全部返回
+func (self *LocalVariableTableAttribute) GetLocalVariablesByName(name string) []*LocalVariableTableEntry {
+	var entries []*LocalVariableTableEntry
+	for _, entry := range self.localVariableTable {
+		if entry.Name() == name {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func (self *LocalVariableTableEntry) covers(pc int) bool {
+	return pc >= int(self.startPc) && pc < int(self.startPc)+int(self.length)
+}
+
+// getter 方法
+func (self *LocalVariableTableEntry) StartPc() uint16 {
+	return self.startPc
+}
+func (self *LocalVariableTableEntry) Length() uint16 {
+	return self.length
+}
+func (self *LocalVariableTableEntry) NameIndex() uint16 {
+	return self.nameIndex
+}
+func (self *LocalVariableTableEntry) DescriptorIndex() uint16 {
+	return self.descriptorIndex
+}
+func (self *LocalVariableTableEntry) Index() uint16 {
+	return self.index
+}
+
+// 根据 nameIndex 从常量池获取变量名
+func (self *LocalVariableTableEntry) Name() string {
+	return self.cp.getUtf8(self.nameIndex)
+}
+
+// 根据 descriptorIndex 从常量池获取变量的类型描述符
+func (self *LocalVariableTableEntry) Descriptor() string {
+	return self.cp.getUtf8(self.descriptorIndex)
+}
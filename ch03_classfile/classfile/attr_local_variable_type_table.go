@@ -0,0 +1,97 @@
+package classfile
+
+// LocalVariableTypeTable 同样属于可选的调试信息，是 Java5 为泛型加入的属性，其结构为
+/*
+LocalVariableTypeTable_attribute {
+    u2 attribute_name_index;
+    u4 attribute_length;
+    u2 local_variable_type_table_length;
+    {   u2 start_pc;
+        u2 length;
+        u2 name_index;
+        u2 signature_index;
+        u2 index;
+    } local_variable_type_table[local_variable_type_table_length];
+}
+*/
+// 和 LocalVariableTable 的唯一区别是 descriptor_index 换成了 signature_index，给出变量的泛型签名（如 Ljava/util/List<Ljava/lang/String;>;）
+// 只有类型中包含类型变量或参数化类型的局部变量才会出现在这个表中
+type LocalVariableTypeTableAttribute struct {
+	attributeHeader
+	cp                     ConstantPool
+	localVariableTypeTable []*LocalVariableTypeTableEntry
+}
+
+type LocalVariableTypeTableEntry struct {
+	cp             ConstantPool
+	startPc        uint16
+	length         uint16
+	nameIndex      uint16
+	signatureIndex uint16
+	index          uint16
+}
+
+func (self *LocalVariableTypeTableAttribute) readInfo(reader *ClassReader) {
+	localVariableTypeTableLength := reader.readUint16()
+	self.localVariableTypeTable = make([]*LocalVariableTypeTableEntry, localVariableTypeTableLength)
+	for i := range self.localVariableTypeTable {
+		self.localVariableTypeTable[i] = &LocalVariableTypeTableEntry{
+			cp:             self.cp,
+			startPc:        reader.readUint16(),
+			length:         reader.readUint16(),
+			nameIndex:      reader.readUint16(),
+			signatureIndex: reader.readUint16(),
+			index:          reader.readUint16(),
+		}
+	}
+}
+
+func (self *LocalVariableTypeTableAttribute) writeInfo(writer *ClassWriter) {
+	writer.writeUint16(uint16(len(self.localVariableTypeTable)))
+	for _, entry := range self.localVariableTypeTable {
+		writer.writeUint16(entry.startPc)
+		writer.writeUint16(entry.length)
+		writer.writeUint16(entry.nameIndex)
+		writer.writeUint16(entry.signatureIndex)
+		writer.writeUint16(entry.index)
+	}
+}
+
+func (self *LocalVariableTypeTableAttribute) LocalVariableTypeTable() []*LocalVariableTypeTableEntry {
+	return self.localVariableTypeTable
+}
+
+// 根据 slot 索引和 pc 查找局部变量，找不到时返回 nil
+func (self *LocalVariableTypeTableAttribute) GetLocalVariable(index uint16, pc int) *LocalVariableTypeTableEntry {
+	for _, entry := range self.localVariableTypeTable {
+		if entry.index == index && pc >= int(entry.startPc) && pc < int(entry.startPc)+int(entry.length) {
+			return entry
+		}
+	}
+	return nil
+}
+
+// getter 方法
+func (self *LocalVariableTypeTableEntry) StartPc() uint16 {
+	return self.startPc
+}
+func (self *LocalVariableTypeTableEntry) Length() uint16 {
+	return self.length
+}
+func (self *LocalVariableTypeTableEntry) NameIndex() uint16 {
+	return self.nameIndex
+}
+func (self *LocalVariableTypeTableEntry) SignatureIndex() uint16 {
+	return self.signatureIndex
+}
+func (self *LocalVariableTypeTableEntry) Index() uint16 {
+	return self.index
+}
+
+func (self *LocalVariableTypeTableEntry) Name() string {
+	return self.cp.getUtf8(self.nameIndex)
+}
+
+func (self *LocalVariableTypeTableEntry) Signature() string {
+	return self.cp.getUtf8(self.signatureIndex)
+}
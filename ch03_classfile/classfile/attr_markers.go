@@ -18,6 +18,8 @@ package classfile
 type DeprecatedAttribute struct{ MarkerAttribute }
 type SyntheticAttribute struct{ MarkerAttribute }
 
-type MarkerAttribute struct{}
+type MarkerAttribute struct{ attributeHeader }
 
 func (self *MarkerAttribute) readInfo(reader *ClassReader) {}
+
+func (self *MarkerAttribute) writeInfo(writer *ClassWriter) {}
@@ -0,0 +1,62 @@
+package classfile
+
+// MethodParameters 是 Java8 加入的可选属性，只会出现在 method_info 结构中，记录方法形参的名称和访问标志，
+// 只有使用 javac -parameters 编译时才会生成，其结构为：
+// MethodParameters_attribute {
+// 	   u2 attribute_name_index;
+// 	   u4 attribute_length;
+// 	   u1 parameters_count;
+// 	   {   u2 name_index;
+// 	       u2 access_flags;
+// 	   } parameters[parameters_count];
+// }
+//
+// 注意 parameters_count 只占一个字节，name_index 可以为 0，表示形参没有名称
+// access_flags 只可能是 ACC_FINAL（0x0010）、ACC_SYNTHETIC（0x1000）和 ACC_MANDATED（0x8000）的组合
+type MethodParametersAttribute struct {
+	attributeHeader
+	cp         ConstantPool
+	parameters []*MethodParameter
+}
+
+type MethodParameter struct {
+	cp          ConstantPool
+	nameIndex   uint16
+	accessFlags uint16
+}
+
+func (self *MethodParametersAttribute) readInfo(reader *ClassReader) {
+	parametersCount := reader.readUint8()
+	self.parameters = make([]*MethodParameter, parametersCount)
+	for i := range self.parameters {
+		self.parameters[i] = &MethodParameter{
+			cp:          self.cp,
+			nameIndex:   reader.readUint16(),
+			accessFlags: reader.readUint16(),
+		}
+	}
+}
+
+func (self *MethodParametersAttribute) writeInfo(writer *ClassWriter) {
+	writer.writeUint8(uint8(len(self.parameters)))
+	for _, parameter := range self.parameters {
+		writer.writeUint16(parameter.nameIndex)
+		writer.writeUint16(parameter.accessFlags)
+	}
+}
+
+func (self *MethodParametersAttribute) Parameters() []*MethodParameter {
+	return self.parameters
+}
+
+func (self *MethodParameter) NameIndex() uint16 {
+	return self.nameIndex
+}
+func (self *MethodParameter) AccessFlags() uint16 {
+	return self.accessFlags
+}
+
+// 形参没有名称时返回空字符串
+func (self *MethodParameter) Name() string {
+	return self.cp.getOptionalUtf8(self.nameIndex)
+}
@@ -0,0 +1,200 @@
+package classfile
+
+// Module 是变长属性，只会出现在 module-info.class 的 ClassFile 结构中，描述模块的依赖和导出信息，其结构如下：
+// Module_attribute {
+// 	   u2 attribute_name_index;
+// 	   u4 attribute_length;
+//
+// 	   u2 module_name_index;
+// 	   u2 module_flags;
+// 	   u2 module_version_index;
+//
+// 	   u2 requires_count;
+// 	   {   u2 requires_index;
+// 	       u2 requires_flags;
+// 	       u2 requires_version_index;
+// 	   } requires[requires_count];
+//
+// 	   u2 exports_count;
+// 	   {   u2 exports_index;
+// 	       u2 exports_flags;
+// 	       u2 exports_to_count;
+// 	       u2 exports_to_index[exports_to_count];
+// 	   } exports[exports_count];
+//
+// 	   u2 opens_count;
+// 	   {   u2 opens_index;
+// 	       u2 opens_flags;
+// 	       u2 opens_to_count;
+// 	       u2 opens_to_index[opens_to_count];
+// 	   } opens[opens_count];
+//
+// 	   u2 uses_count;
+// 	   u2 uses_index[uses_count];
+//
+// 	   u2 provides_count;
+// 	   {   u2 provides_index;
+// 	       u2 provides_with_count;
+// 	       u2 provides_with_index[provides_with_count];
+// 	   } provides[provides_count];
+// }
+//
+// module_name_index、requires_index 和 exports_to_index 等指向 CONSTANT_Module_info，
+// exports_index 和 opens_index 指向 CONSTANT_Package_info，uses_index 和 provides 中的索引指向 CONSTANT_Class_info
+// 所有 version_index 都可以为 0，表示没有版本信息
+type ModuleAttribute struct {
+	attributeHeader
+	cp                 ConstantPool
+	moduleNameIndex    uint16
+	moduleFlags        uint16
+	moduleVersionIndex uint16
+	requires           []*ModuleRequiresEntry
+	exports            []*ModuleExportsEntry
+	opens              []*ModuleExportsEntry // opens 与 exports 结构完全相同
+	usesIndex          []uint16
+	provides           []*ModuleProvidesEntry
+}
+
+type ModuleRequiresEntry struct {
+	requiresIndex        uint16
+	requiresFlags        uint16
+	requiresVersionIndex uint16
+}
+
+type ModuleExportsEntry struct {
+	index   uint16
+	flags   uint16
+	toIndex []uint16
+}
+
+type ModuleProvidesEntry struct {
+	providesIndex     uint16
+	providesWithIndex []uint16
+}
+
+func (self *ModuleAttribute) readInfo(reader *ClassReader) {
+	self.moduleNameIndex = reader.readUint16()
+	self.moduleFlags = reader.readUint16()
+	self.moduleVersionIndex = reader.readUint16()
+
+	requiresCount := reader.readUint16()
+	self.requires = make([]*ModuleRequiresEntry, requiresCount)
+	for i := range self.requires {
+		self.requires[i] = &ModuleRequiresEntry{
+			requiresIndex:        reader.readUint16(),
+			requiresFlags:        reader.readUint16(),
+			requiresVersionIndex: reader.readUint16(),
+		}
+	}
+
+	self.exports = readModuleExports(reader)
+	self.opens = readModuleExports(reader)
+	self.usesIndex = reader.readUint16s()
+
+	providesCount := reader.readUint16()
+	self.provides = make([]*ModuleProvidesEntry, providesCount)
+	for i := range self.provides {
+		self.provides[i] = &ModuleProvidesEntry{
+			providesIndex:     reader.readUint16(),
+			providesWithIndex: reader.readUint16s(),
+		}
+	}
+}
+
+func (self *ModuleAttribute) writeInfo(writer *ClassWriter) {
+	writer.writeUint16(self.moduleNameIndex)
+	writer.writeUint16(self.moduleFlags)
+	writer.writeUint16(self.moduleVersionIndex)
+
+	writer.writeUint16(uint16(len(self.requires)))
+	for _, r := range self.requires {
+		writer.writeUint16(r.requiresIndex)
+		writer.writeUint16(r.requiresFlags)
+		writer.writeUint16(r.requiresVersionIndex)
+	}
+
+	writeModuleExports(writer, self.exports)
+	writeModuleExports(writer, self.opens)
+	writer.writeUint16s(self.usesIndex)
+
+	writer.writeUint16(uint16(len(self.provides)))
+	for _, p := range self.provides {
+		writer.writeUint16(p.providesIndex)
+		writer.writeUint16s(p.providesWithIndex)
+	}
+}
+
+// exports 和 opens 表结构相同，共用一个读取方法
+func readModuleExports(reader *ClassReader) []*ModuleExportsEntry {
+	count := reader.readUint16()
+	entries := make([]*ModuleExportsEntry, count)
+	for i := range entries {
+		entries[i] = &ModuleExportsEntry{
+			index:   reader.readUint16(),
+			flags:   reader.readUint16(),
+			toIndex: reader.readUint16s(),
+		}
+	}
+	return entries
+}
+
+func writeModuleExports(writer *ClassWriter, entries []*ModuleExportsEntry) {
+	writer.writeUint16(uint16(len(entries)))
+	for _, e := range entries {
+		writer.writeUint16(e.index)
+		writer.writeUint16(e.flags)
+		writer.writeUint16s(e.toIndex)
+	}
+}
+
+func (self *ModuleAttribute) ModuleNameIndex() uint16 {
+	return self.moduleNameIndex
+}
+func (self *ModuleAttribute) ModuleFlags() uint16 {
+	return self.moduleFlags
+}
+func (self *ModuleAttribute) ModuleVersionIndex() uint16 {
+	return self.moduleVersionIndex
+}
+func (self *ModuleAttribute) Requires() []*ModuleRequiresEntry {
+	return self.requires
+}
+func (self *ModuleAttribute) Exports() []*ModuleExportsEntry {
+	return self.exports
+}
+func (self *ModuleAttribute) Opens() []*ModuleExportsEntry {
+	return self.opens
+}
+func (self *ModuleAttribute) UsesIndex() []uint16 {
+	return self.usesIndex
+}
+func (self *ModuleAttribute) Provides() []*ModuleProvidesEntry {
+	return self.provides
+}
+
+func (self *ModuleRequiresEntry) RequiresIndex() uint16 {
+	return self.requiresIndex
+}
+func (self *ModuleRequiresEntry) RequiresFlags() uint16 {
+	return self.requiresFlags
+}
+func (self *ModuleRequiresEntry) RequiresVersionIndex() uint16 {
+	return self.requiresVersionIndex
+}
+
+func (self *ModuleExportsEntry) Index() uint16 {
+	return self.index
+}
+func (self *ModuleExportsEntry) Flags() uint16 {
+	return self.flags
+}
+func (self *ModuleExportsEntry) ToIndex() []uint16 {
+	return self.toIndex
+}
+
+func (self *ModuleProvidesEntry) ProvidesIndex() uint16 {
+	return self.providesIndex
+}
+func (self *ModuleProvidesEntry) ProvidesWithIndex() []uint16 {
+	return self.providesWithIndex
+}
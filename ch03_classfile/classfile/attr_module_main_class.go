@@ -0,0 +1,31 @@
+package classfile
+
+// ModuleMainClass 属性只会出现在 module-info.class 中，指出模块的主类，其结构如下：
+// ModuleMainClass_attribute {
+// 	   u2 attribute_name_index;
+// 	   u4 attribute_length;
+// 	   u2 main_class_index;
+// }
+//
+// main_class_index 指向 CONSTANT_Class_info
+type ModuleMainClassAttribute struct {
+	attributeHeader
+	cp             ConstantPool
+	mainClassIndex uint16
+}
+
+func (self *ModuleMainClassAttribute) readInfo(reader *ClassReader) {
+	self.mainClassIndex = reader.readUint16()
+}
+
+func (self *ModuleMainClassAttribute) writeInfo(writer *ClassWriter) {
+	writer.writeUint16(self.mainClassIndex)
+}
+
+func (self *ModuleMainClassAttribute) MainClassIndex() uint16 {
+	return self.mainClassIndex
+}
+
+func (self *ModuleMainClassAttribute) MainClassName() string {
+	return self.cp.getClassName(self.mainClassIndex)
+}
@@ -0,0 +1,37 @@
+package classfile
+
+// ModulePackages 属性只会出现在 module-info.class 中，列出模块中所有的包（包括未导出的包），其结构如下：
+// ModulePackages_attribute {
+// 	   u2 attribute_name_index;
+// 	   u4 attribute_length;
+// 	   u2 package_count;
+// 	   u2 package_index[package_count];
+// }
+//
+// package_index 中的每一项都指向 CONSTANT_Package_info
+type ModulePackagesAttribute struct {
+	attributeHeader
+	cp           ConstantPool
+	packageIndex []uint16
+}
+
+func (self *ModulePackagesAttribute) readInfo(reader *ClassReader) {
+	self.packageIndex = reader.readUint16s()
+}
+
+func (self *ModulePackagesAttribute) writeInfo(writer *ClassWriter) {
+	writer.writeUint16s(self.packageIndex)
+}
+
+func (self *ModulePackagesAttribute) PackageIndex() []uint16 {
+	return self.packageIndex
+}
+
+// 从常量池查找所有的包名
+func (self *ModulePackagesAttribute) PackageNames() []string {
+	names := make([]string, len(self.packageIndex))
+	for i, cpIndex := range self.packageIndex {
+		names[i] = self.cp.getPackageName(cpIndex)
+	}
+	return names
+}
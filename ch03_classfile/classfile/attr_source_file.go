@@ -10,6 +10,7 @@ package classfile
 // 其 attribtue_length 值永为 2
 // sourcefile_index 是常量池索引，指向一个 CONSTANT_Utf8_info 常量
 type SourceFileAttribute struct {
+	attributeHeader
 	cp              ConstantPool
 	sourceFileIndex uint16
 }
@@ -18,6 +19,14 @@ func (self *SourceFileAttribute) readInfo(reader *ClassReader) {
 	self.sourceFileIndex = reader.readUint16()
 }
 
+func (self *SourceFileAttribute) writeInfo(writer *ClassWriter) {
+	writer.writeUint16(self.sourceFileIndex)
+}
+
+func (self *SourceFileAttribute) SourceFileIndex() uint16 {
+	return self.sourceFileIndex
+}
+
 func (self *SourceFileAttribute) FileName() string {
 	return self.cp.getUtf8(self.sourceFileIndex)
 }
@@ -0,0 +1,205 @@
+package classfile
+
+// StackMapTable 是变长属性，只会出现在 Code 属性的属性表中，Java6 为了加快类型检查（type checking）而引入，
+// 版本号 50 及以上的 class 文件中，每个分支目标和异常处理入口都必须有一个对应的栈帧（stack map frame），
+// 给出该位置局部变量表和操作数栈中每一项的类型，其结构如下：
+// StackMapTable_attribute {
+// 	   u2              attribute_name_index;
+// 	   u4              attribute_length;
+// 	   u2              number_of_entries;
+// 	   stack_map_frame entries[number_of_entries];
+// }
+//
+// 为了节省空间，每一帧只记录与前一帧的 pc 差值 offset_delta，第一帧的 pc 为 offset_delta，
+// 之后每一帧的 pc 为 前一帧 pc + offset_delta + 1
+// 栈帧按照 frame_type 分为以下几种：
+// - same_frame                          0 ~ 63：   局部变量与前一帧相同，操作数栈为空，offset_delta 就是 frame_type
+// - same_locals_1_stack_item_frame      64 ~ 127： 局部变量与前一帧相同，操作数栈只有一项，offset_delta 为 frame_type - 64
+// - same_locals_1_stack_item_frame_ext  247：      同上，但 offset_delta 单独使用 u2 存放
+// - chop_frame                          248 ~ 250：去掉前一帧最后 251 - frame_type 个局部变量，操作数栈为空
+// - same_frame_extended                 251：      同 same_frame，但 offset_delta 单独使用 u2 存放
+// - append_frame                        252 ~ 254：在前一帧基础上追加 frame_type - 251 个局部变量，操作数栈为空
+// - full_frame                          255：      完整给出局部变量和操作数栈
+type StackMapTableAttribute struct {
+	attributeHeader
+	entries []*StackMapFrame
+}
+
+type StackMapFrame struct {
+	frameType   uint8
+	offsetDelta uint16
+	locals      []*VerificationTypeInfo
+	stack       []*VerificationTypeInfo
+}
+
+// 局部变量和操作数栈中每一项的类型用 verification_type_info 表示，其结构为：
+// verification_type_info {
+// 	   u1 tag;
+// 	   u2 cpool_index 或 offset; // 只有 ITEM_Object 和 ITEM_Uninitialized 才有
+// }
+//
+// ITEM_Object 的 cpool_index 指向 CONSTANT_Class_info，
+// ITEM_Uninitialized 的 offset 则是创建该对象的 new 指令的 pc
+const (
+	ITEM_Top               = 0
+	ITEM_Integer           = 1
+	ITEM_Float             = 2
+	ITEM_Double            = 3
+	ITEM_Long              = 4
+	ITEM_Null              = 5
+	ITEM_UninitializedThis = 6
+	ITEM_Object            = 7
+	ITEM_Uninitialized     = 8
+)
+
+type VerificationTypeInfo struct {
+	tag   uint8
+	value uint16 // cpool_index 或 offset
+}
+
+func (self *StackMapTableAttribute) readInfo(reader *ClassReader) {
+	numberOfEntries := reader.readUint16()
+	self.entries = make([]*StackMapFrame, numberOfEntries)
+	for i := range self.entries {
+		self.entries[i] = readStackMapFrame(reader)
+	}
+}
+
+func (self *StackMapTableAttribute) writeInfo(writer *ClassWriter) {
+	writer.writeUint16(uint16(len(self.entries)))
+	for _, frame := range self.entries {
+		frame.write(writer)
+	}
+}
+
+func readStackMapFrame(reader *ClassReader) *StackMapFrame {
+	frame := &StackMapFrame{frameType: reader.readUint8()}
+	switch t := frame.frameType; {
+	case t <= 63:
+		frame.offsetDelta = uint16(t)
+	case t <= 127:
+		frame.offsetDelta = uint16(t - 64)
+		frame.stack = readVerificationTypeInfos(reader, 1)
+	case t < 247:
+		panic("java.lang.ClassFormatError: reserved stack map frame type!")
+	case t == 247:
+		frame.offsetDelta = reader.readUint16()
+		frame.stack = readVerificationTypeInfos(reader, 1)
+	case t <= 251:
+		frame.offsetDelta = reader.readUint16()
+	case t <= 254:
+		frame.offsetDelta = reader.readUint16()
+		frame.locals = readVerificationTypeInfos(reader, int(t-251))
+	default:
+		frame.offsetDelta = reader.readUint16()
+		frame.locals = readVerificationTypeInfos(reader, int(reader.readUint16()))
+		frame.stack = readVerificationTypeInfos(reader, int(reader.readUint16()))
+	}
+	return frame
+}
+
+// 写入栈帧，与 readStackMapFrame() 对应
+// frame_type 在 0 ~ 127 之间时 offset_delta 已经编码在 frame_type 中，不再单独写入
+func (self *StackMapFrame) write(writer *ClassWriter) {
+	writer.writeUint8(self.frameType)
+	switch t := self.frameType; {
+	case t <= 63:
+	case t <= 127:
+		writeVerificationTypeInfos(writer, self.stack)
+	case t == 247:
+		writer.writeUint16(self.offsetDelta)
+		writeVerificationTypeInfos(writer, self.stack)
+	case t <= 251:
+		writer.writeUint16(self.offsetDelta)
+	case t <= 254:
+		writer.writeUint16(self.offsetDelta)
+		writeVerificationTypeInfos(writer, self.locals)
+	default:
+		writer.writeUint16(self.offsetDelta)
+		writer.writeUint16(uint16(len(self.locals)))
+		writeVerificationTypeInfos(writer, self.locals)
+		writer.writeUint16(uint16(len(self.stack)))
+		writeVerificationTypeInfos(writer, self.stack)
+	}
+}
+
+// 修改 offset_delta，必要时把 same_frame 和 same_locals_1_stack_item_frame 转换为对应的 extended 形式
+func (self *StackMapFrame) setOffsetDelta(offsetDelta uint16) {
+	self.offsetDelta = offsetDelta
+	switch t := self.frameType; {
+	case t <= 63 || t == 251:
+		if offsetDelta <= 63 {
+			self.frameType = uint8(offsetDelta)
+		} else {
+			self.frameType = 251
+		}
+	case t <= 127 || t == 247:
+		if offsetDelta <= 63 {
+			self.frameType = uint8(64 + offsetDelta)
+		} else {
+			self.frameType = 247
+		}
+	}
+}
+
+func readVerificationTypeInfos(reader *ClassReader, n int) []*VerificationTypeInfo {
+	infos := make([]*VerificationTypeInfo, n)
+	for i := range infos {
+		infos[i] = &VerificationTypeInfo{tag: reader.readUint8()}
+		if infos[i].tag == ITEM_Object || infos[i].tag == ITEM_Uninitialized {
+			infos[i].value = reader.readUint16()
+		}
+	}
+	return infos
+}
+
+func writeVerificationTypeInfos(writer *ClassWriter, infos []*VerificationTypeInfo) {
+	for _, info := range infos {
+		writer.writeUint8(info.tag)
+		if info.tag == ITEM_Object || info.tag == ITEM_Uninitialized {
+			writer.writeUint16(info.value)
+		}
+	}
+}
+
+// getter 方法
+func (self *StackMapTableAttribute) Entries() []*StackMapFrame {
+	return self.entries
+}
+
+func (self *StackMapFrame) FrameType() uint8 {
+	return self.frameType
+}
+func (self *StackMapFrame) OffsetDelta() uint16 {
+	return self.offsetDelta
+}
+
+// 对于 append_frame 是追加的局部变量，对于 full_frame 是全部局部变量，其余栈帧类型为空
+func (self *StackMapFrame) Locals() []*VerificationTypeInfo {
+	return self.locals
+}
+func (self *StackMapFrame) Stack() []*VerificationTypeInfo {
+	return self.stack
+}
+
+// chop_frame 去掉的局部变量个数，其余栈帧类型返回 0
+func (self *StackMapFrame) ChoppedLocals() int {
+	if self.frameType >= 248 && self.frameType <= 250 {
+		return int(251 - self.frameType)
+	}
+	return 0
+}
+
+func (self *VerificationTypeInfo) Tag() uint8 {
+	return self.tag
+}
+
+// ITEM_Object 的 CONSTANT_Class_info 索引
+func (self *VerificationTypeInfo) CpoolIndex() uint16 {
+	return self.value
+}
+
+// ITEM_Uninitialized 对应 new 指令的 pc
+func (self *VerificationTypeInfo) Offset() uint16 {
+	return self.value
+}
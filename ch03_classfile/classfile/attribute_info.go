@@ -17,6 +17,26 @@ package classfile
 
 type AttributeInfo interface {
 	readInfo(reader *ClassReader)
+	// writeInfo() 是 readInfo() 的逆过程，只写入 info 部分，属性名和属性长度由 writeAttribute() 负责写入
+	writeInfo(writer *ClassWriter)
+	// 以下两个方法由 attributeHeader 提供
+	attributeNameIndex() uint16
+	setAttributeNameIndex(index uint16)
+}
+
+// 每种属性都嵌入 attributeHeader，记录读取时的 attribute_name_index
+// 常量池中可能有多个内容相同的 Utf8 常量，写出时如果按属性名重新查找索引，得到的可能是另一个常量，
+// 所以原样写回读取时的索引，才能保证逐字节相同；新创建的属性索引为 0，写出时再按属性名查找
+type attributeHeader struct {
+	nameIndex uint16
+}
+
+func (self *attributeHeader) attributeNameIndex() uint16 {
+	return self.nameIndex
+}
+
+func (self *attributeHeader) setAttributeNameIndex(index uint16) {
+	self.nameIndex = index
 }
 
 // readAttributes() 挨个读取属性信息，并返回一个 AttributeInfo 接口实例组成的数组
@@ -36,11 +56,96 @@ func readAttribute(reader *ClassReader, cp ConstantPool) AttributeInfo {
 	attrName := cp.getUtf8(attrNameIndex)
 	attrLen := reader.readUint32()
 	attrInfo := newAttributeInfo(attrName, attrLen, cp)
+	attrInfo.setAttributeNameIndex(attrNameIndex)
+	attrInfo.readInfo(reader)
 	return attrInfo
 }
 
+// 写入属性表，与 readAttributes() 对应
+func writeAttributes(writer *ClassWriter, attributes []AttributeInfo, cp ConstantPool) {
+	writer.writeUint16(uint16(len(attributes)))
+	for _, attrInfo := range attributes {
+		writeAttribute(writer, attrInfo, cp)
+	}
+}
+
+// 写入单个属性，与 readAttribute() 对应
+// 属性长度在写入 info 之前无法得知，所以先把 info 写入一个临时的 ClassWriter，再计算长度
+func writeAttribute(writer *ClassWriter, attrInfo AttributeInfo, cp ConstantPool) {
+	attrName := attributeName(attrInfo)
+	attrNameIndex := attrInfo.attributeNameIndex()
+	if name, err := cp.GetUtf8(attrNameIndex); err != nil || name != attrName {
+		attrNameIndex = cp.findUtf8(attrName)
+	}
+	if attrNameIndex == 0 {
+		panic("java.lang.ClassFormatError: attribute name not in constant pool: " + attrName)
+	}
+	info := &ClassWriter{}
+	attrInfo.writeInfo(info)
+	writer.writeUint16(attrNameIndex)
+	writer.writeUint32(uint32(len(info.data)))
+	writer.writeBytes(info.data)
+}
+
+// 从属性表中删除指定名称的属性，返回新的属性表
+func removeAttributes(attributes []AttributeInfo, attrNames ...string) []AttributeInfo {
+	kept := make([]AttributeInfo, 0, len(attributes))
+	for _, attrInfo := range attributes {
+		remove := false
+		for _, attrName := range attrNames {
+			if attributeName(attrInfo) == attrName {
+				remove = true
+			}
+		}
+		if !remove {
+			kept = append(kept, attrInfo)
+		}
+	}
+	return kept
+}
+
+// newAttributeInfo() 的逆过程，根据属性类型找到属性名
+func attributeName(attrInfo AttributeInfo) string {
+	switch attr := attrInfo.(type) {
+	case *BootstrapMethodsAttribute:
+		return "BootstrapMethods"
+	case *CodeAttribute:
+		return "Code"
+	case *ConstantValueAttribute:
+		return "ConstantValue"
+	case *DeprecatedAttribute:
+		return "Deprecated"
+	case *ExceptionsAttribute:
+		return "Exceptions"
+	case *LineNumberTableAttribute:
+		return "LineNumberTable"
+	case *LocalVariableTableAttribute:
+		return "LocalVariableTable"
+	case *LocalVariableTypeTableAttribute:
+		return "LocalVariableTypeTable"
+	case *MethodParametersAttribute:
+		return "MethodParameters"
+	case *ModuleAttribute:
+		return "Module"
+	case *ModuleMainClassAttribute:
+		return "ModuleMainClass"
+	case *ModulePackagesAttribute:
+		return "ModulePackages"
+	case *SourceFileAttribute:
+		return "SourceFile"
+	case *StackMapTableAttribute:
+		return "StackMapTable"
+	case *SyntheticAttribute:
+		return "Synthetic"
+	case *UnparsedAttribute:
+		return attr.name
+	default:
+		panic("java.lang.ClassFormatError: unknown attribute type!")
+	}
+}
+
 // newAttributeInfo() 根据属性名创建 AttributeInfo 接口实例
-// JVM 规范制定了 23 种属性，这里先解析其中的 8 种
+// JVM 规范制定了 23 种属性，这里先解析其中的 15 种
 //
 // 按照 23 预定义属性，其可以分成三组：
 // - （必选）第一组是实现 JVM 的必须属性，共有 5 种
@@ -49,6 +154,9 @@ func readAttribute(reader *ClassReader, cp ConstantPool) AttributeInfo {
 // 中也能够实现它们
 func newAttributeInfo(attrName string, attrLen uint32, cp ConstantPool) AttributeInfo {
 	switch attrName {
+	case "BootstrapMethods":
+		// BootstrapMethods 是变长属性，只会出现在 ClassFile 结构中，记录 invokedynamic 指令使用的引导方法
+		return &BootstrapMethodsAttribute{}
 	case "Code":
 		// Code 是变长属性，只存在 method_info 结构中，用于存放字节码等相关信息
 		return &CodeAttribute{cp: cp}
@@ -66,15 +174,33 @@ func newAttributeInfo(attrName string, attrLen uint32, cp ConstantPool) Attribut
 		return &LineNumberTableAttribute{}
 	case "LocalVariableTable":
 		// LocalVariableTable 存放方法的局部变量信息，它属于可选的调试信息，不是运行时的必要信息
-		return &LocalVariableTableAttribute{}
+		return &LocalVariableTableAttribute{cp: cp}
+	case "LocalVariableTypeTable":
+		// LocalVariableTypeTable 存放泛型局部变量的签名，同样属于可选的调试信息
+		return &LocalVariableTypeTableAttribute{cp: cp}
+	case "MethodParameters":
+		// MethodParameters 存放方法形参的名称和访问标志，只有使用 javac -parameters 编译时才会生成
+		return &MethodParametersAttribute{cp: cp}
+	case "Module":
+		// Module 是变长属性，只会出现在 module-info.class 中，描述模块的 requires/exports/opens/uses/provides
+		return &ModuleAttribute{cp: cp}
+	case "ModuleMainClass":
+		// ModuleMainClass 是定长属性，只会出现在 module-info.class 中，指出模块主类
+		return &ModuleMainClassAttribute{cp: cp}
+	case "ModulePackages":
+		// ModulePackages 是变长属性，只会出现在 module-info.class 中，列出模块包含的所有包
+		return &ModulePackagesAttribute{cp: cp}
 	case "SourceFile":
 		// SourceFile 属性是可选长属性，只会出现在 ClassFile 结构中，用于指出源文件名，它属于可选的调试信息，不是运行时的必要信息
 		return &SourceFileAttribute{cp: cp}
+	case "StackMapTable":
+		// StackMapTable 只会出现在 Code 属性中，给出分支目标处的局部变量和操作数栈类型，用于类型检查
+		return &StackMapTableAttribute{}
 	case "Synthetic":
 		// Synthetic 是最贱的属性，仅乞讨标志作用，不包含任何数据
 		return &SyntheticAttribute{}
 	default:
 		// 未能处理的属性类型
-		return &UnparsedAttribute{attrName, attrLen, nil}
+		return &UnparsedAttribute{name: attrName, length: attrLen}
 	}
 }
@@ -2,6 +2,7 @@ package classfile
 
 // 这里定义了未能处理的属性类型
 type UnparsedAttribute struct {
+	attributeHeader
 	name   string
 	length uint32
 	info   []byte
@@ -10,3 +11,16 @@ type UnparsedAttribute struct {
 func (self *UnparsedAttribute) readInfo(reader *ClassReader) {
 	self.info = reader.readBytes(self.length)
 }
+
+// 未能处理的属性原样写回
+func (self *UnparsedAttribute) writeInfo(writer *ClassWriter) {
+	writer.writeBytes(self.info)
+}
+
+// getter 方法
+func (self *UnparsedAttribute) Name() string {
+	return self.name
+}
+func (self *UnparsedAttribute) Info() []byte {
+	return self.info
+}
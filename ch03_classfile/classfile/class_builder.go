@@ -0,0 +1,105 @@
+package classfile
+
+// 下面的方法用于从头生成 class 文件，例如汇编器和测试用例：先用 NewClassFile() 创建只有类名的 ClassFile，
+// 再依次添加接口、字段和方法，方法的字节码通过 NewCode() 返回的 InstructionList 生成，最后调用 Serialize() 写出
+//
+// 与 AddXxx() 相同，这里只保证写出的 class 文件在格式上可以被解析，字节码是否能通过验证由调用者负责
+
+// 创建一个没有任何成员的 ClassFile，superClassName 为空表示没有超类（只有 java/lang/Object 才是这样）
+func NewClassFile(majorVersion, minorVersion, accessFlags uint16, className, superClassName string) *ClassFile {
+	cf := &ClassFile{majorVersion: majorVersion, minorVersion: minorVersion, accessFlags: accessFlags}
+	cf.thisClass = cf.AddClass(className)
+	if superClassName != "" {
+		cf.superClass = cf.AddClass(superClassName)
+	}
+	return cf
+}
+
+func (self *ClassFile) SetAccessFlags(accessFlags uint16) {
+	self.accessFlags = accessFlags
+}
+
+func (self *ClassFile) AddInterface(interfaceName string) {
+	self.interfaces = append(self.interfaces, self.AddClass(interfaceName))
+}
+
+// 添加字段，返回新字段的 MemberInfo
+func (self *ClassFile) AddField(accessFlags uint16, name, descriptor string) *MemberInfo {
+	field := self.newMember(accessFlags, name, descriptor)
+	self.fields = append(self.fields, field)
+	return field
+}
+
+// 添加方法，返回新方法的 MemberInfo，需要字节码的方法再调用 NewCode()
+func (self *ClassFile) AddMethod(accessFlags uint16, name, descriptor string) *MemberInfo {
+	method := self.newMember(accessFlags, name, descriptor)
+	self.methods = append(self.methods, method)
+	return method
+}
+
+func (self *ClassFile) newMember(accessFlags uint16, name, descriptor string) *MemberInfo {
+	nameIndex := self.AddUtf8(name)
+	descriptorIndex := self.AddUtf8(descriptor)
+	return &MemberInfo{
+		cp:              self.constantPool,
+		accessFlags:     accessFlags,
+		nameIndex:       nameIndex,
+		descriptorIndex: descriptorIndex,
+	}
+}
+
+// 为方法添加空的 Code 属性，返回用于生成字节码的 InstructionList，添加完指令后调用 Commit() 写回 Code 属性
+// max_locals 的初始值为参数所占的 slot 数（实例方法包括 this），Commit() 时再根据指令实际用到的局部变量扩大
+func (self *ClassFile) NewCode(method *MemberInfo) *InstructionList {
+	if method.CodeAttribute() != nil {
+		panic("method already has a Code attribute: " + method.Name())
+	}
+	self.AddUtf8("Code")
+	argSlots, _ := methodDescriptorSlots(method.Descriptor())
+	if method.accessFlags&ACC_STATIC == 0 {
+		argSlots++
+	}
+	codeAttr := &CodeAttribute{cp: self.constantPool, maxLocals: uint16(argSlots)}
+	method.attributes = append(method.attributes, codeAttr)
+	return &InstructionList{cf: self, method: method, codeAttr: codeAttr, maxStack: -1, maxLocals: -1}
+}
+
+// 为字段添加 ConstantValue 属性，valueIndex 是 AddInteger()、AddString() 等方法返回的常量池索引
+func (self *ClassFile) SetConstantValue(field *MemberInfo, valueIndex uint16) {
+	self.AddUtf8("ConstantValue")
+	field.attributes = removeAttributes(field.attributes, "ConstantValue")
+	field.attributes = append(field.attributes, &ConstantValueAttribute{constantValueIndex: valueIndex})
+}
+
+// 在方法的 Exceptions 属性中添加一个异常类，没有 Exceptions 属性时自动添加
+func (self *ClassFile) AddThrows(method *MemberInfo, className string) {
+	classIndex := self.AddClass(className)
+	exceptions := method.ExceptionsAttribute()
+	if exceptions == nil {
+		self.AddUtf8("Exceptions")
+		exceptions = &ExceptionsAttribute{}
+		method.attributes = append(method.attributes, exceptions)
+	}
+	exceptions.exceptionIndexTable = append(exceptions.exceptionIndexTable, classIndex)
+}
+
+// 设置 SourceFile 属性
+func (self *ClassFile) SetSourceFile(fileName string) {
+	self.AddUtf8("SourceFile")
+	sourceFileIndex := self.AddUtf8(fileName)
+	self.attributes = removeAttributes(self.attributes, "SourceFile")
+	self.attributes = append(self.attributes, &SourceFileAttribute{cp: self.constantPool, sourceFileIndex: sourceFileIndex})
+}
+
+// 追加常量之后，字段、方法和 Code 属性中保存的仍然是追加之前的常量池切片，其中找不到新添加的属性名，
+// 所以写出之前统一换成完整的常量池
+func (self *ClassFile) updateConstantPoolRefs() {
+	for _, members := range [][]*MemberInfo{self.fields, self.methods} {
+		for _, member := range members {
+			member.cp = self.constantPool
+			if codeAttr := member.CodeAttribute(); codeAttr != nil {
+				codeAttr.cp = self.constantPool
+			}
+		}
+	}
+}
@@ -37,6 +37,25 @@ func Parse(classData []byte) (cf *ClassFile, err error) {
 	return
 }
 
+// Serialize() 是 Parse() 的逆过程，把 ClassFile 结构体重新写成 class 文件字节数据
+// 对于未经修改的 ClassFile，写出的数据与解析前的数据逐字节相同，未能识别的属性也会原样写回
+func Serialize(cf *ClassFile) (classData []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			var ok bool
+			err, ok = r.(error)
+			if !ok {
+				err = fmt.Errorf("%v", r)
+			}
+		}
+	}()
+
+	cw := &ClassWriter{}
+	cf.write(cw)
+	classData = cw.Bytes()
+	return
+}
+
 // read() 方法绑定至了 ClassFile 结构体，是解析 class 文件的入口方法
 func (self *ClassFile) read(reader *ClassReader) {
 	self.readAndCheckMagic(reader)
@@ -51,6 +70,42 @@ func (self *ClassFile) read(reader *ClassReader) {
 	self.attributes = readAttributes(reader, self.constantPool)
 }
 
+// write() 按照与 read() 相同的顺序写入 class 文件的各个部分
+func (self *ClassFile) write(writer *ClassWriter) {
+	self.updateConstantPoolRefs()
+	writer.writeUint32(0xCAFEBABE)
+	writer.writeUint16(self.minorVersion)
+	writer.writeUint16(self.majorVersion)
+	writeConstantPool(writer, self.constantPool)
+	writer.writeUint16(self.accessFlags)
+	writer.writeUint16(self.thisClass)
+	writer.writeUint16(self.superClass)
+	writer.writeUint16s(self.interfaces)
+	writeMembers(writer, self.fields)
+	writeMembers(writer, self.methods)
+	writeAttributes(writer, self.attributes, self.constantPool)
+}
+
+// 修改 class 文件的版本号
+// 注意这里只修改版本号本身，例如把版本号提升到 50 以上时，调用者需要自己保证方法带有正确的 StackMapTable
+func (self *ClassFile) SetVersion(majorVersion, minorVersion uint16) {
+	self.majorVersion = majorVersion
+	self.minorVersion = minorVersion
+}
+
+// 去除调试信息，效果类似于 javac -g:none：删除 SourceFile 属性，以及所有 Code 属性中的
+// LineNumberTable、LocalVariableTable 和 LocalVariableTypeTable 属性
+// 这些属性名对应的常量仍然保留在常量池中，不影响 class 文件的合法性
+func (self *ClassFile) StripDebugInfo() {
+	self.attributes = removeAttributes(self.attributes, "SourceFile", "SourceDebugExtension")
+	for _, method := range self.methods {
+		if codeAttr := method.CodeAttribute(); codeAttr != nil {
+			codeAttr.attributes = removeAttributes(codeAttr.attributes,
+				"LineNumberTable", "LocalVariableTable", "LocalVariableTypeTable")
+		}
+	}
+}
+
 // 下面几个是类似 getter 的方法，绑定至了 ClassFile 结构体用于让其它包共享数据
 func (self *ClassFile) MinorVersion() uint16 {
 	return self.minorVersion
@@ -70,6 +125,62 @@ func (self *ClassFile) Fileds() []*MemberInfo {
 func (self *ClassFile) Methods() []*MemberInfo {
 	return self.methods
 }
+func (self *ClassFile) ThisClass() uint16 {
+	return self.thisClass
+}
+func (self *ClassFile) SuperClass() uint16 {
+	return self.superClass
+}
+func (self *ClassFile) Interfaces() []uint16 {
+	return self.interfaces
+}
+func (self *ClassFile) Attributes() []AttributeInfo {
+	return self.attributes
+}
+
+// 查找 BootstrapMethods 属性，class 文件中不存在 invokedynamic 指令时返回 nil
+func (self *ClassFile) BootstrapMethodsAttribute() *BootstrapMethodsAttribute {
+	for _, attrInfo := range self.attributes {
+		switch attrInfo.(type) {
+		case *BootstrapMethodsAttribute:
+			return attrInfo.(*BootstrapMethodsAttribute)
+		}
+	}
+	return nil
+}
+
+// 查找 Module 属性，只有 module-info.class 才包含该属性，否则返回 nil
+func (self *ClassFile) ModuleAttribute() *ModuleAttribute {
+	for _, attrInfo := range self.attributes {
+		switch attrInfo.(type) {
+		case *ModuleAttribute:
+			return attrInfo.(*ModuleAttribute)
+		}
+	}
+	return nil
+}
+
+// 查找 ModulePackages 属性，该属性可选，不存在时返回 nil
+func (self *ClassFile) ModulePackagesAttribute() *ModulePackagesAttribute {
+	for _, attrInfo := range self.attributes {
+		switch attrInfo.(type) {
+		case *ModulePackagesAttribute:
+			return attrInfo.(*ModulePackagesAttribute)
+		}
+	}
+	return nil
+}
+
+// 查找 ModuleMainClass 属性，该属性可选，不存在时返回 nil
+func (self *ClassFile) ModuleMainClassAttribute() *ModuleMainClassAttribute {
+	for _, attrInfo := range self.attributes {
+		switch attrInfo.(type) {
+		case *ModuleMainClassAttribute:
+			return attrInfo.(*ModuleMainClassAttribute)
+		}
+	}
+	return nil
+}
 
 // 魔法数字：JVM 规定某些文件（如 class 文件）必须以固定字节开头
 // 0xCAFEBABE 是所有 class 文件的开头字节。当 JVM 遇到非法的 class 开头字节时会抛出 java.lang.ClassFormatError 异常
@@ -84,18 +195,25 @@ func (self *ClassFile) readAndCheckMagic(reader *ClassReader) {
 // 魔法数字是文件开头，之后便是版本号
 // 版本号：class 文件都有一个主版本号 M 和次版本号 m，都是双字节 uint16 类型，完整版本号为 M.m
 // 目前次版本号已经不再使用，都为 0
-// 主版本号从 Java1 的 45 开始，在每一个 Java 版本发布时都会 +1，故 Java8 版本号为 52（0x34）
+// 主版本号从 Java1 的 45 开始，在每一个 Java 版本发布时都会 +1，故 Java8 版本号为 52（0x34），Java9 为 53
 // 通常情况下 JVM 能够向后兼容旧版本的 class，如果版本号不能支持则会抛出 java.lang.UnsupportedClassVersionError 异常
+//
+// Java9 之后 module-info.class 和运行时镜像中的类都使用 53 及以上的版本号，这里一直支持到 Java25（69）
+// Java12 开始次版本号 0xFFFF 用于标记使用了预览特性的 class 文件
 func (self *ClassFile) readAndCheckVersion(reader *ClassReader) {
 	self.minorVersion = reader.readUint16()
 	self.majorVersion = reader.readUint16()
-	switch self.majorVersion {
-	case 45:
+	switch {
+	case self.majorVersion == 45:
 		return
-	case 46, 47, 48, 49, 50, 51, 52:
+	case self.majorVersion >= 46 && self.majorVersion <= 55:
 		if self.minorVersion == 0 {
 			return
 		}
+	case self.majorVersion >= 56 && self.majorVersion <= 69:
+		if self.minorVersion == 0 || self.minorVersion == 0xFFFF {
+			return
+		}
 	}
 	panic("java.lang.UnsupportedClassVersionError!")
 }
@@ -119,6 +237,11 @@ func (self *ClassFile) InterfaceNames() []string {
 	return interfaceNames
 }
 
+// 从常量池中查找当前类名
+func (self *ClassFile) ClassName() string {
+	return self.constantPool.getClassName(self.thisClass)
+}
+
 // 当前类和父类索引之后是接口索引，其中保存的也是常量池索引，大小为 uint16
 // 从常量池中查找继承的父类名
 func (self *ClassFile) SuperClassName() string {
@@ -0,0 +1,82 @@
+package classfile
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testdata/roundtrip.jar 中的类覆盖了 invokedynamic、CONSTANT_Dynamic、注解、module-info 等，
+// 其中 Dup.class 的常量池里 "Code" 和 "SourceFile" 各有两个 Utf8 常量，属性引用的是后一个
+// 设置环境变量 JVMGO_ROUNDTRIP_JARS（以路径分隔符分隔）可以额外检查其它 jar，例如 JDK 的 rt.jar
+func TestSerializeRoundTrip(t *testing.T) {
+	jars := []string{filepath.Join("testdata", "roundtrip.jar")}
+	if extra := os.Getenv("JVMGO_ROUNDTRIP_JARS"); extra != "" {
+		jars = append(jars, filepath.SplitList(extra)...)
+	}
+	for _, jar := range jars {
+		roundTripJar(t, jar)
+	}
+}
+
+func roundTripJar(t *testing.T, jar string) {
+	r, err := zip.OpenReader(jar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	count := 0
+	for _, file := range r.File {
+		if !strings.HasSuffix(file.Name, ".class") {
+			continue
+		}
+		data := readZipFile(t, file)
+		cf, err := Parse(data)
+		if err != nil {
+			t.Errorf("%s!/%s: Parse: %v", jar, file.Name, err)
+			continue
+		}
+		out, err := Serialize(cf)
+		if err != nil {
+			t.Errorf("%s!/%s: Serialize: %v", jar, file.Name, err)
+			continue
+		}
+		if !bytes.Equal(data, out) {
+			t.Errorf("%s!/%s: serialized bytes differ from the original at offset %d", jar, file.Name, firstDifference(data, out))
+		}
+		count++
+	}
+	if count == 0 {
+		t.Errorf("%s: no classes", jar)
+	}
+}
+
+func readZipFile(tb testing.TB, file *zip.File) []byte {
+	rc, err := file.Open()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer rc.Close()
+	data, err := ioutil.ReadAll(rc)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return data
+}
+
+func firstDifference(a, b []byte) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	if len(a) < len(b) {
+		return len(a)
+	}
+	return len(b)
+}
@@ -0,0 +1,55 @@
+package classfile
+
+import (
+	"encoding/binary"
+)
+
+// ClassWriter 是 ClassReader 的逆过程，把数据按照大端序依次追加到 byte 数组末尾
+// 所有的 writeXxx() 方法都和 ClassReader 中的 readXxx() 方法一一对应
+type ClassWriter struct {
+	data []byte
+}
+
+// 写入一个字节 u1
+func (self *ClassWriter) writeUint8(val uint8) {
+	self.data = append(self.data, val)
+}
+
+// 写入 u2
+func (self *ClassWriter) writeUint16(val uint16) {
+	var buf [2]byte
+	binary.BigEndian.PutUint16(buf[:], val)
+	self.data = append(self.data, buf[:]...)
+}
+
+// 写入 uint16 数组，先写入 uint16 类型的数组大小，与 readUint16s() 对应
+func (self *ClassWriter) writeUint16s(vals []uint16) {
+	self.writeUint16(uint16(len(vals)))
+	for _, val := range vals {
+		self.writeUint16(val)
+	}
+}
+
+// 写入 u4
+func (self *ClassWriter) writeUint32(val uint32) {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], val)
+	self.data = append(self.data, buf[:]...)
+}
+
+// 写入 u8
+func (self *ClassWriter) writeUint64(val uint64) {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], val)
+	self.data = append(self.data, buf[:]...)
+}
+
+// 原样写入字节数组
+func (self *ClassWriter) writeBytes(bytes []byte) {
+	self.data = append(self.data, bytes...)
+}
+
+// 返回目前为止写入的全部数据
+func (self *ClassWriter) Bytes() []byte {
+	return self.data
+}
@@ -7,7 +7,7 @@ package classfile
 // 	   u1 info[];
 // }
 //
-// JVM 总共规范了 14 种常量 tag，如下：
+// JVM 总共规范了 14 种常量 tag，Java9 之后又为模块系统加入了 CONSTANT_Module 和 CONSTANT_Package，Java11 加入了动态计算常量 CONSTANT_Dynamic，如下：
 const (
 	CONSTANT_Class              = 7
 	CONSTANT_Fieldref           = 9
@@ -22,15 +22,41 @@ const (
 	CONSTANT_Utf8               = 1
 	CONSTANT_MethodHandle       = 15
 	CONSTANT_MethodType         = 16
+	CONSTANT_Dynamic            = 17
 	CONSTANT_InvokeDynamic      = 18
+	CONSTANT_Module             = 19
+	CONSTANT_Package            = 20
 )
 
+// 常量类型的名称，用于错误信息
+var constantTagNames = map[uint8]string{
+	CONSTANT_Class:              "CONSTANT_Class",
+	CONSTANT_Fieldref:           "CONSTANT_Fieldref",
+	CONSTANT_Methodref:          "CONSTANT_Methodref",
+	CONSTANT_InterfaceMethodref: "CONSTANT_InterfaceMethodref",
+	CONSTANT_String:             "CONSTANT_String",
+	CONSTANT_Integer:            "CONSTANT_Integer",
+	CONSTANT_Float:              "CONSTANT_Float",
+	CONSTANT_Long:               "CONSTANT_Long",
+	CONSTANT_Double:             "CONSTANT_Double",
+	CONSTANT_NameAndType:        "CONSTANT_NameAndType",
+	CONSTANT_Utf8:               "CONSTANT_Utf8",
+	CONSTANT_MethodHandle:       "CONSTANT_MethodHandle",
+	CONSTANT_MethodType:         "CONSTANT_MethodType",
+	CONSTANT_Dynamic:            "CONSTANT_Dynamic",
+	CONSTANT_InvokeDynamic:      "CONSTANT_InvokeDynamic",
+	CONSTANT_Module:             "CONSTANT_Module",
+	CONSTANT_Package:            "CONSTANT_Package",
+}
+
 // ConstantInfo 用于展示常量信息
 type ConstantInfo interface {
 	// readInfo() 方法用于读取常量信息，具体先由常量结构体 readConstantInfo() 读出 tag 值，
 	// 然后调用 newConstantInfo() 来根据 tag 创建具体常量
 	// 最后调用本接口的 readInfo() 方法来读取常量信息
 	readInfo(reader *ClassReader)
+	// writeInfo() 是 readInfo() 的逆过程，只写入 tag 之后的常量信息，tag 由 writeConstantInfo() 负责写入
+	writeInfo(writer *ClassWriter)
 }
 
 // 读取 tag 字节
@@ -41,6 +67,54 @@ func readConstantInfo(reader *ClassReader, cp ConstantPool) ConstantInfo {
 	return c
 }
 
+// 写入 tag 字节和常量信息，与 readConstantInfo() 对应
+func writeConstantInfo(writer *ClassWriter, c ConstantInfo) {
+	writer.writeUint8(constantInfoTag(c))
+	c.writeInfo(writer)
+}
+
+// newConstantInfo() 的逆过程，根据常量类型找到 tag 值
+func constantInfoTag(c ConstantInfo) uint8 {
+	switch c.(type) {
+	case *ConstantIntegerInfo:
+		return CONSTANT_Integer
+	case *ConstantFloatInfo:
+		return CONSTANT_Float
+	case *ConstantLongInfo:
+		return CONSTANT_Long
+	case *ConstantDoubleInfo:
+		return CONSTANT_Double
+	case *ConstantUtf8Info:
+		return CONSTANT_Utf8
+	case *ConstantStringInfo:
+		return CONSTANT_String
+	case *ConstantClassInfo:
+		return CONSTANT_Class
+	case *ConstantFieldrefInfo:
+		return CONSTANT_Fieldref
+	case *ConstantMethodrefInfo:
+		return CONSTANT_Methodref
+	case *ConstantInterfaceMethodrefInfo:
+		return CONSTANT_InterfaceMethodref
+	case *ConstantNameAndTypeInfo:
+		return CONSTANT_NameAndType
+	case *ConstantMethodTypeInfo:
+		return CONSTANT_MethodType
+	case *ConstantMethodHandleInfo:
+		return CONSTANT_MethodHandle
+	case *ConstantDynamicInfo:
+		return CONSTANT_Dynamic
+	case *ConstantInvokeDynamicInfo:
+		return CONSTANT_InvokeDynamic
+	case *ConstantModuleInfo:
+		return CONSTANT_Module
+	case *ConstantPackageInfo:
+		return CONSTANT_Package
+	default:
+		panic("java.lang.ClassFormatError: unknown constant type!")
+	}
+}
+
 // 根据 tag 创建常量实例
 func newConstantInfo(tag uint8, cp ConstantPool) ConstantInfo {
 	switch tag {
@@ -67,11 +141,17 @@ func newConstantInfo(tag uint8, cp ConstantPool) ConstantInfo {
 	case CONSTANT_NameAndType:
 		return &ConstantNameAndTypeInfo{}
 	case CONSTANT_MethodType:
-		return &ConstantMethodTypeInfo{}
+		return &ConstantMethodTypeInfo{cp: cp}
 	case CONSTANT_MethodHandle:
-		return &ConstantMethodHandleInfo{}
+		return &ConstantMethodHandleInfo{cp: cp}
+	case CONSTANT_Dynamic:
+		return &ConstantDynamicInfo{cp: cp}
 	case CONSTANT_InvokeDynamic:
-		return &ConstantInvokeDynamicInfo{}
+		return &ConstantInvokeDynamicInfo{cp: cp}
+	case CONSTANT_Module:
+		return &ConstantModuleInfo{cp: cp}
+	case CONSTANT_Package:
+		return &ConstantPackageInfo{cp: cp}
 	default:
 		panic("java.lang.ClassFormatError: constant pool tag!")
 	}
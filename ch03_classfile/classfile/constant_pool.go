@@ -1,5 +1,9 @@
 package classfile
 
+import (
+	"fmt"
+)
+
 // 常量池占据了 class 文件的很大一部分，里面存放着各种常量信息，包括数字常量，字符串常量，
 // 类名，接口名，字段，方法等等
 //
@@ -21,32 +25,176 @@ func readConstantPool(reader *ClassReader) ConstantPool {
 			i++
 		}
 	}
+	return cp
 }
 
+// 写入常量池，与 readConstantPool() 对应
+// long 和 double 之后的第二个位置在切片中是 nil，直接跳过即可
+func writeConstantPool(writer *ClassWriter, cp ConstantPool) {
+	writer.writeUint16(uint16(len(cp)))
+	for i := 1; i < len(cp); i++ {
+		if cp[i] != nil {
+			writeConstantInfo(writer, cp[i])
+		}
+	}
+}
+
+// 下面几个导出的查找方法供其它包使用，索引无效或者常量类型不符时返回 error 而不是 panic，
+// 适合读取可能有问题的 class 文件；索引 0 和 long、double 之后空出的位置都是无效索引
+
 // 从常量池按照索引查找常量
+func (self ConstantPool) GetConstantInfo(index uint16) (ConstantInfo, error) {
+	switch {
+	case index == 0 || int(index) >= len(self):
+		return nil, fmt.Errorf("invalid constant pool index #%d", index)
+	case self[index] == nil:
+		return nil, fmt.Errorf("invalid constant pool index #%d: unusable entry after a long or double constant", index)
+	}
+	return self[index], nil
+}
+
+// 从常量池查找 utf8 字符串
+func (self ConstantPool) GetUtf8(index uint16) (string, error) {
+	c, err := self.getConstantOfType(index, CONSTANT_Utf8)
+	if err != nil {
+		return "", err
+	}
+	return c.(*ConstantUtf8Info).str, nil
+}
+
+// 从常量池查找类名
+func (self ConstantPool) GetClassName(index uint16) (string, error) {
+	c, err := self.getConstantOfType(index, CONSTANT_Class)
+	if err != nil {
+		return "", err
+	}
+	return self.GetUtf8(c.(*ConstantClassInfo).nameIndex)
+}
+
+// 从常量池查找字段或方法名和描述符
+func (self ConstantPool) GetNameAndType(index uint16) (name, descriptor string, err error) {
+	c, err := self.getConstantOfType(index, CONSTANT_NameAndType)
+	if err != nil {
+		return "", "", err
+	}
+	ntInfo := c.(*ConstantNameAndTypeInfo)
+	if name, err = self.GetUtf8(ntInfo.nameIndex); err != nil {
+		return "", "", err
+	}
+	if descriptor, err = self.GetUtf8(ntInfo.descriptorIndex); err != nil {
+		return "", "", err
+	}
+	return name, descriptor, nil
+}
+
+// 按照索引从小到大遍历常量池中的常量，跳过索引 0 和 long、double 之后空出的位置
+// fn 返回 error 时停止遍历，并把该 error 返回给调用者
+func (self ConstantPool) Walk(fn func(index uint16, c ConstantInfo) error) error {
+	for i := 1; i < len(self); i++ {
+		if self[i] == nil {
+			continue
+		}
+		if err := fn(uint16(i), self[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 查找常量并检查其类型
+func (self ConstantPool) getConstantOfType(index uint16, tag uint8) (ConstantInfo, error) {
+	c, err := self.GetConstantInfo(index)
+	if err != nil {
+		return nil, err
+	}
+	if actual := constantInfoTag(c); actual != tag {
+		return nil, fmt.Errorf("constant pool index #%d is a %s, not a %s", index, constantTagNames[actual], constantTagNames[tag])
+	}
+	return c, nil
+}
+
+// 下面几个未导出的方法在解析和读取属性时使用，出错时直接 panic，由 Parse() 等函数的 recover 转换为 error
 func (self ConstantPool) getConstantInfo(index uint16) ConstantInfo {
-	if cpInfo := self[index]; cpInfo != nil {
-		return cpInfo
+	c, err := self.GetConstantInfo(index)
+	if err != nil {
+		panic(err)
 	}
-	panic("Invalid constant pool index!")
+	return c
 }
 
-// 从常量池查找字段或方法名和描述符
 func (self ConstantPool) getNameAndType(index uint16) (string, string) {
-	ntInfo := self.getConstantInfo(index).(*ConstantNameAndTypeInfo)
-	name := self.getUtf8(ntInfo.nameIndex)
-	_type := self.getUtf8(ntInfo.descriptorIndex)
+	name, _type, err := self.GetNameAndType(index)
+	if err != nil {
+		panic(err)
+	}
 	return name, _type
 }
 
-// 从常量池查找类名
 func (self ConstantPool) getClassName(index uint16) string {
-	classInfo := self.getConstantInfo(index).(*ConstantClassInfo)
-	return self.getUtf8(classInfo.nameIndex)
+	name, err := self.GetClassName(index)
+	if err != nil {
+		panic(err)
+	}
+	return name
 }
 
-// 从常量池查找 utf8 字符串
 func (self ConstantPool) getUtf8(index uint16) string {
-	utf8Info := self.getConstantInfo(index).(*ConstantUtf8Info)
-	return utf8Info.str
+	str, err := self.GetUtf8(index)
+	if err != nil {
+		panic(err)
+	}
+	return str
+}
+
+// 和 getUtf8 相同，但允许索引为 0（表示该项不存在），此时返回空字符串
+func (self ConstantPool) getOptionalUtf8(index uint16) string {
+	if index == 0 {
+		return ""
+	}
+	return self.getUtf8(index)
+}
+
+// 用于错误信息的 utf8 字符串和类名，索引无效或常量类型不符时返回 "#索引"，不会 panic
+func (self ConstantPool) utf8OrIndex(index uint16) string {
+	if str, err := self.GetUtf8(index); err == nil {
+		return str
+	}
+	return fmt.Sprintf("#%d", index)
+}
+
+func (self ConstantPool) classNameOrIndex(index uint16) string {
+	if name, err := self.GetClassName(index); err == nil {
+		return name
+	}
+	return fmt.Sprintf("#%d", index)
+}
+
+// 从常量池查找模块名
+func (self ConstantPool) getModuleName(index uint16) string {
+	c, err := self.getConstantOfType(index, CONSTANT_Module)
+	if err != nil {
+		panic(err)
+	}
+	return self.getUtf8(c.(*ConstantModuleInfo).nameIndex)
+}
+
+// 从常量池查找包名
+func (self ConstantPool) getPackageName(index uint16) string {
+	c, err := self.getConstantOfType(index, CONSTANT_Package)
+	if err != nil {
+		panic(err)
+	}
+	return self.getUtf8(c.(*ConstantPackageInfo).nameIndex)
+}
+
+// 查找字符串在常量池中的索引，找不到时返回 0
+// 用于写入属性名：属性结构体中并不保存 attribute_name_index，写回时需要重新查找
+// javac 等编译器生成的常量池中不会出现重复的 CONSTANT_Utf8_info，所以找到的就是原来的索引
+func (self ConstantPool) findUtf8(str string) uint16 {
+	for i, cpInfo := range self {
+		if utf8Info, ok := cpInfo.(*ConstantUtf8Info); ok && utf8Info.str == str {
+			return uint16(i)
+		}
+	}
+	return 0
 }
@@ -0,0 +1,145 @@
+package classfile
+
+import (
+	"math"
+)
+
+// 插入探针等修改字节码的操作通常需要引用新的类、方法或字符串，下面的 AddXxx() 方法用于向常量池追加常量，
+// 如果常量池中已经存在相同的常量则直接返回其索引，否则追加到常量池末尾并返回新的索引
+//
+// 注意：已有的常量、字段和方法中保存的是追加之前的常量池切片，它们只会引用追加之前就存在的索引，所以不受影响；
+// 追加之后应当通过 ClassFile.ConstantPool() 获取完整的常量池
+func (self *ClassFile) AddUtf8(str string) uint16 {
+	if index := self.constantPool.findUtf8(str); index != 0 {
+		return index
+	}
+	return self.addConstant(&ConstantUtf8Info{str: str})
+}
+
+func (self *ClassFile) AddClass(className string) uint16 {
+	nameIndex := self.AddUtf8(className)
+	for i, cpInfo := range self.constantPool {
+		if classInfo, ok := cpInfo.(*ConstantClassInfo); ok && classInfo.nameIndex == nameIndex {
+			return uint16(i)
+		}
+	}
+	return self.addConstant(&ConstantClassInfo{cp: self.constantPool, nameIndex: nameIndex})
+}
+
+func (self *ClassFile) AddString(str string) uint16 {
+	stringIndex := self.AddUtf8(str)
+	for i, cpInfo := range self.constantPool {
+		if stringInfo, ok := cpInfo.(*ConstantStringInfo); ok && stringInfo.stringIndex == stringIndex {
+			return uint16(i)
+		}
+	}
+	return self.addConstant(&ConstantStringInfo{cp: self.constantPool, stringIndex: stringIndex})
+}
+
+func (self *ClassFile) AddInteger(val int32) uint16 {
+	for i, cpInfo := range self.constantPool {
+		if intInfo, ok := cpInfo.(*ConstantIntegerInfo); ok && intInfo.val == val {
+			return uint16(i)
+		}
+	}
+	return self.addConstant(&ConstantIntegerInfo{val: val})
+}
+
+// 浮点数按照位模式比较，这样 NaN 和 -0.0 也能被正确复用
+func (self *ClassFile) AddFloat(val float32) uint16 {
+	for i, cpInfo := range self.constantPool {
+		if floatInfo, ok := cpInfo.(*ConstantFloatInfo); ok && math.Float32bits(floatInfo.val) == math.Float32bits(val) {
+			return uint16(i)
+		}
+	}
+	return self.addConstant(&ConstantFloatInfo{val: val})
+}
+
+func (self *ClassFile) AddLong(val int64) uint16 {
+	for i, cpInfo := range self.constantPool {
+		if longInfo, ok := cpInfo.(*ConstantLongInfo); ok && longInfo.val == val {
+			return uint16(i)
+		}
+	}
+	return self.addConstant(&ConstantLongInfo{val: val})
+}
+
+func (self *ClassFile) AddDouble(val float64) uint16 {
+	for i, cpInfo := range self.constantPool {
+		if doubleInfo, ok := cpInfo.(*ConstantDoubleInfo); ok && math.Float64bits(doubleInfo.val) == math.Float64bits(val) {
+			return uint16(i)
+		}
+	}
+	return self.addConstant(&ConstantDoubleInfo{val: val})
+}
+
+func (self *ClassFile) AddNameAndType(name, descriptor string) uint16 {
+	nameIndex := self.AddUtf8(name)
+	descriptorIndex := self.AddUtf8(descriptor)
+	for i, cpInfo := range self.constantPool {
+		if ntInfo, ok := cpInfo.(*ConstantNameAndTypeInfo); ok &&
+			ntInfo.nameIndex == nameIndex && ntInfo.descriptorIndex == descriptorIndex {
+			return uint16(i)
+		}
+	}
+	return self.addConstant(&ConstantNameAndTypeInfo{nameIndex: nameIndex, descriptorIndex: descriptorIndex})
+}
+
+func (self *ClassFile) AddFieldref(className, name, descriptor string) uint16 {
+	classIndex, ntIndex := self.addMemberref(className, name, descriptor)
+	for i, cpInfo := range self.constantPool {
+		if ref, ok := cpInfo.(*ConstantFieldrefInfo); ok && ref.classIndex == classIndex && ref.nameAndTypeIndex == ntIndex {
+			return uint16(i)
+		}
+	}
+	return self.addConstant(&ConstantFieldrefInfo{self.newMemberref(classIndex, ntIndex)})
+}
+
+func (self *ClassFile) AddMethodref(className, name, descriptor string) uint16 {
+	classIndex, ntIndex := self.addMemberref(className, name, descriptor)
+	for i, cpInfo := range self.constantPool {
+		if ref, ok := cpInfo.(*ConstantMethodrefInfo); ok && ref.classIndex == classIndex && ref.nameAndTypeIndex == ntIndex {
+			return uint16(i)
+		}
+	}
+	return self.addConstant(&ConstantMethodrefInfo{self.newMemberref(classIndex, ntIndex)})
+}
+
+func (self *ClassFile) AddInterfaceMethodref(className, name, descriptor string) uint16 {
+	classIndex, ntIndex := self.addMemberref(className, name, descriptor)
+	for i, cpInfo := range self.constantPool {
+		if ref, ok := cpInfo.(*ConstantInterfaceMethodrefInfo); ok && ref.classIndex == classIndex && ref.nameAndTypeIndex == ntIndex {
+			return uint16(i)
+		}
+	}
+	return self.addConstant(&ConstantInterfaceMethodrefInfo{self.newMemberref(classIndex, ntIndex)})
+}
+
+// 字段和方法引用都需要先追加 CONSTANT_Class_info 和 CONSTANT_NameAndType_info
+func (self *ClassFile) addMemberref(className, name, descriptor string) (uint16, uint16) {
+	classIndex := self.AddClass(className)
+	ntIndex := self.AddNameAndType(name, descriptor)
+	return classIndex, ntIndex
+}
+
+func (self *ClassFile) newMemberref(classIndex, ntIndex uint16) ConstantMemberrefInfo {
+	return ConstantMemberrefInfo{cp: self.constantPool, classIndex: classIndex, nameAndTypeIndex: ntIndex}
+}
+
+// 把常量追加到常量池末尾，long 和 double 需要多占一个位置
+// 常量池大小使用 u2 存放，所以最多只能有 65535 项
+func (self *ClassFile) addConstant(c ConstantInfo) uint16 {
+	if len(self.constantPool) == 0 {
+		self.constantPool = ConstantPool{nil} // 索引 0 无效
+	}
+	index := len(self.constantPool)
+	self.constantPool = append(self.constantPool, c)
+	switch c.(type) {
+	case *ConstantLongInfo, *ConstantDoubleInfo:
+		self.constantPool = append(self.constantPool, nil)
+	}
+	if len(self.constantPool) > math.MaxUint16 {
+		panic("java.lang.ClassFormatError: too many constants!")
+	}
+	return uint16(index)
+}
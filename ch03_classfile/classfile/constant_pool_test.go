@@ -0,0 +1,125 @@
+package classfile
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// #1 Utf8 "A"，#2 Class #1，#3 Long（#4 是第二个位置），#5 NameAndType #1:#6，#6 Utf8 "I"，
+// #7 Class #3（名字指向 Long），#8 NameAndType #1:#2（描述符指向 Class）
+func newTestConstantPool() ConstantPool {
+	cp := ConstantPool{
+		nil,
+		&ConstantUtf8Info{str: "A"},
+		nil,
+		&ConstantLongInfo{val: 42},
+		nil,
+		&ConstantNameAndTypeInfo{nameIndex: 1, descriptorIndex: 6},
+		&ConstantUtf8Info{str: "I"},
+		nil,
+		&ConstantNameAndTypeInfo{nameIndex: 1, descriptorIndex: 2},
+	}
+	cp[2] = &ConstantClassInfo{cp: cp, nameIndex: 1}
+	cp[7] = &ConstantClassInfo{cp: cp, nameIndex: 3}
+	return cp
+}
+
+func TestConstantPoolLookups(t *testing.T) {
+	cp := newTestConstantPool()
+	if c, err := cp.GetConstantInfo(3); err != nil || c.(*ConstantLongInfo).Value() != 42 {
+		t.Errorf("GetConstantInfo(3) = %v, %v", c, err)
+	}
+	if s, err := cp.GetUtf8(1); err != nil || s != "A" {
+		t.Errorf("GetUtf8(1) = %q, %v", s, err)
+	}
+	if s, err := cp.GetClassName(2); err != nil || s != "A" {
+		t.Errorf("GetClassName(2) = %q, %v", s, err)
+	}
+	if name, desc, err := cp.GetNameAndType(5); err != nil || name != "A" || desc != "I" {
+		t.Errorf("GetNameAndType(5) = %q, %q, %v", name, desc, err)
+	}
+}
+
+// 索引为 0、越界、指向 long 之后的第二个位置或者常量类型不符时返回 error，不会 panic
+func TestConstantPoolLookupErrors(t *testing.T) {
+	cp := newTestConstantPool()
+	tests := []struct {
+		name   string
+		lookup func() error
+		want   string
+	}{
+		{"index 0", func() error { _, err := cp.GetConstantInfo(0); return err }, "invalid constant pool index #0"},
+		{"out of range", func() error { _, err := cp.GetConstantInfo(9); return err }, "invalid constant pool index #9"},
+		{"far out of range", func() error { _, err := cp.GetUtf8(0xFFFF); return err }, "invalid constant pool index #65535"},
+		{"second slot of a long", func() error { _, err := cp.GetConstantInfo(4); return err }, "unusable entry after a long or double"},
+		{"utf8 in the second slot", func() error { _, err := cp.GetUtf8(4); return err }, "unusable entry after a long or double"},
+		{"wrong tag", func() error { _, err := cp.GetUtf8(2); return err }, "#2 is a CONSTANT_Class, not a CONSTANT_Utf8"},
+		{"class is a long", func() error { _, err := cp.GetClassName(3); return err }, "#3 is a CONSTANT_Long, not a CONSTANT_Class"},
+		{"class name is a long", func() error { _, err := cp.GetClassName(7); return err }, "#3 is a CONSTANT_Long, not a CONSTANT_Utf8"},
+		{"name and type is a utf8", func() error { _, _, err := cp.GetNameAndType(1); return err }, "#1 is a CONSTANT_Utf8, not a CONSTANT_NameAndType"},
+		{"descriptor is a class", func() error { _, _, err := cp.GetNameAndType(8); return err }, "#2 is a CONSTANT_Class, not a CONSTANT_Utf8"},
+	}
+	for _, test := range tests {
+		err := test.lookup()
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: error = %v, want %q", test.name, err, test.want)
+		}
+	}
+}
+
+// 解析时使用的未导出方法出错时 panic，错误信息与导出的方法相同
+func TestConstantPoolGetterPanics(t *testing.T) {
+	cp := newTestConstantPool()
+	tests := []struct {
+		name   string
+		lookup func()
+		want   string
+	}{
+		{"getUtf8(0)", func() { cp.getUtf8(0) }, "invalid constant pool index #0"},
+		{"getUtf8(4)", func() { cp.getUtf8(4) }, "unusable entry after a long or double"},
+		{"getClassName(1)", func() { cp.getClassName(1) }, "not a CONSTANT_Class"},
+		{"getNameAndType(2)", func() { cp.getNameAndType(2) }, "not a CONSTANT_NameAndType"},
+	}
+	for _, test := range tests {
+		func() {
+			defer func() {
+				r := recover()
+				err, ok := r.(error)
+				if !ok || !strings.Contains(err.Error(), test.want) {
+					t.Errorf("%s: panicked with %v, want an error containing %q", test.name, r, test.want)
+				}
+			}()
+			test.lookup()
+		}()
+	}
+}
+
+func TestConstantPoolWalk(t *testing.T) {
+	cp := newTestConstantPool()
+	var indexes []uint16
+	if err := cp.Walk(func(index uint16, c ConstantInfo) error {
+		indexes = append(indexes, index)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint16{1, 2, 3, 5, 6, 7, 8}; !reflect.DeepEqual(indexes, want) {
+		t.Errorf("Walk() visited %v, want %v", indexes, want)
+	}
+
+	// fn 返回的 error 会停止遍历并原样返回
+	errLong := errors.New("long found")
+	n := 0
+	err := cp.Walk(func(index uint16, c ConstantInfo) error {
+		n++
+		if _, ok := c.(*ConstantLongInfo); ok {
+			return errLong
+		}
+		return nil
+	})
+	if err != errLong || n != 3 {
+		t.Errorf("Walk() = %v after %d constants, want %v after 3", err, n, errLong)
+	}
+}
@@ -16,6 +16,14 @@ func (self *ConstantClassInfo) readInfo(reader *ClassReader) {
 	self.nameIndex = reader.readUint16()
 }
 
+func (self *ConstantClassInfo) writeInfo(writer *ClassWriter) {
+	writer.writeUint16(self.nameIndex)
+}
+
+func (self *ConstantClassInfo) NameIndex() uint16 {
+	return self.nameIndex
+}
+
 func (self *ConstantClassInfo) Name() string {
 	return self.cp.getUtf8(self.nameIndex)
 }
@@ -0,0 +1,164 @@
+package classfile
+
+// CONSTANT_MethodHandle_info、CONSTANT_MethodType_info 和 CONSTANT_InvokeDynamic_info
+// 是 Java7 之后为了支持 invokedynamic 指令而加入的常量类型，lambda 表达式和（Java9 之后的）字符串拼接
+// 都依赖它们，所以即便暂时不执行 invokedynamic 指令，也必须能够正确解析它们
+
+// 方法句柄的 reference_kind，决定了 reference_index 指向的常量类型以及句柄的字节码行为
+const (
+	REF_getField         = 1
+	REF_getStatic        = 2
+	REF_putField         = 3
+	REF_putStatic        = 4
+	REF_invokeVirtual    = 5
+	REF_invokeStatic     = 6
+	REF_invokeSpecial    = 7
+	REF_newInvokeSpecial = 8
+	REF_invokeInterface  = 9
+)
+
+// CONSTANT_MethodHandle_info 表示方法句柄，其结构如下：
+// CONSTANT_MethodHandle_info {
+// 	   u1 tag;
+// 	   u1 reference_kind;
+// 	   u2 reference_index;
+// }
+//
+// reference_kind 取值为 1 ~ 9，1 ~ 4 时 reference_index 指向 CONSTANT_Fieldref_info，
+// 其余情况指向 CONSTANT_Methodref_info 或 CONSTANT_InterfaceMethodref_info
+type ConstantMethodHandleInfo struct {
+	cp             ConstantPool
+	referenceKind  uint8
+	referenceIndex uint16
+}
+
+func (self *ConstantMethodHandleInfo) readInfo(reader *ClassReader) {
+	self.referenceKind = reader.readUint8()
+	self.referenceIndex = reader.readUint16()
+}
+
+func (self *ConstantMethodHandleInfo) writeInfo(writer *ClassWriter) {
+	writer.writeUint8(self.referenceKind)
+	writer.writeUint16(self.referenceIndex)
+}
+
+func (self *ConstantMethodHandleInfo) ReferenceKind() uint8 {
+	return self.referenceKind
+}
+func (self *ConstantMethodHandleInfo) ReferenceIndex() uint16 {
+	return self.referenceIndex
+}
+
+// 方法句柄引用的字段或方法，统一以 ConstantMemberrefInfo 的形式返回
+func (self *ConstantMethodHandleInfo) Reference() *ConstantMemberrefInfo {
+	switch ref := self.cp.getConstantInfo(self.referenceIndex).(type) {
+	case *ConstantFieldrefInfo:
+		return &ref.ConstantMemberrefInfo
+	case *ConstantMethodrefInfo:
+		return &ref.ConstantMemberrefInfo
+	case *ConstantInterfaceMethodrefInfo:
+		return &ref.ConstantMemberrefInfo
+	}
+	panic("java.lang.ClassFormatError: invalid method handle reference!")
+}
+
+// CONSTANT_MethodType_info 表示方法类型，其结构如下：
+// CONSTANT_MethodType_info {
+// 	   u1 tag;
+// 	   u2 descriptor_index;
+// }
+//
+// descriptor_index 指向一个存放方法描述符的 CONSTANT_Utf8_info 常量
+type ConstantMethodTypeInfo struct {
+	cp              ConstantPool
+	descriptorIndex uint16
+}
+
+func (self *ConstantMethodTypeInfo) readInfo(reader *ClassReader) {
+	self.descriptorIndex = reader.readUint16()
+}
+
+func (self *ConstantMethodTypeInfo) writeInfo(writer *ClassWriter) {
+	writer.writeUint16(self.descriptorIndex)
+}
+
+func (self *ConstantMethodTypeInfo) DescriptorIndex() uint16 {
+	return self.descriptorIndex
+}
+
+func (self *ConstantMethodTypeInfo) Descriptor() string {
+	return self.cp.getUtf8(self.descriptorIndex)
+}
+
+// CONSTANT_Dynamic_info 是 Java11 加入的动态计算常量（condy），结构与 CONSTANT_InvokeDynamic_info 相同：
+// CONSTANT_Dynamic_info {
+// 	   u1 tag;
+// 	   u2 bootstrap_method_attr_index;
+// 	   u2 name_and_type_index;
+// }
+//
+// 区别在于 name_and_type_index 给出的是字段描述符，即常量的类型，常量值由引导方法在第一次 ldc 时计算
+type ConstantDynamicInfo struct {
+	cp                       ConstantPool
+	bootstrapMethodAttrIndex uint16
+	nameAndTypeIndex         uint16
+}
+
+func (self *ConstantDynamicInfo) readInfo(reader *ClassReader) {
+	self.bootstrapMethodAttrIndex = reader.readUint16()
+	self.nameAndTypeIndex = reader.readUint16()
+}
+
+func (self *ConstantDynamicInfo) writeInfo(writer *ClassWriter) {
+	writer.writeUint16(self.bootstrapMethodAttrIndex)
+	writer.writeUint16(self.nameAndTypeIndex)
+}
+
+func (self *ConstantDynamicInfo) BootstrapMethodAttrIndex() uint16 {
+	return self.bootstrapMethodAttrIndex
+}
+
+func (self *ConstantDynamicInfo) NameAndTypeIndex() uint16 {
+	return self.nameAndTypeIndex
+}
+
+func (self *ConstantDynamicInfo) NameAndDescriptor() (string, string) {
+	return self.cp.getNameAndType(self.nameAndTypeIndex)
+}
+
+// CONSTANT_InvokeDynamic_info 给出 invokedynamic 指令所需的引导方法和方法名、描述符，其结构如下：
+// CONSTANT_InvokeDynamic_info {
+// 	   u1 tag;
+// 	   u2 bootstrap_method_attr_index;
+// 	   u2 name_and_type_index;
+// }
+//
+// bootstrap_method_attr_index 不是常量池索引，而是 BootstrapMethods 属性中 bootstrap_methods 表的索引
+// name_and_type_index 指向 CONSTANT_NameAndType_info，给出调用点的方法名和描述符
+type ConstantInvokeDynamicInfo struct {
+	cp                       ConstantPool
+	bootstrapMethodAttrIndex uint16
+	nameAndTypeIndex         uint16
+}
+
+func (self *ConstantInvokeDynamicInfo) readInfo(reader *ClassReader) {
+	self.bootstrapMethodAttrIndex = reader.readUint16()
+	self.nameAndTypeIndex = reader.readUint16()
+}
+
+func (self *ConstantInvokeDynamicInfo) writeInfo(writer *ClassWriter) {
+	writer.writeUint16(self.bootstrapMethodAttrIndex)
+	writer.writeUint16(self.nameAndTypeIndex)
+}
+
+func (self *ConstantInvokeDynamicInfo) BootstrapMethodAttrIndex() uint16 {
+	return self.bootstrapMethodAttrIndex
+}
+
+func (self *ConstantInvokeDynamicInfo) NameAndTypeIndex() uint16 {
+	return self.nameAndTypeIndex
+}
+
+func (self *ConstantInvokeDynamicInfo) NameAndDescriptor() (string, string) {
+	return self.cp.getNameAndType(self.nameAndTypeIndex)
+}
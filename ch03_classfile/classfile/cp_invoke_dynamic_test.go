@@ -0,0 +1,65 @@
+package classfile
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// testdata/Condy.class 的常量池里有两个 CONSTANT_Dynamic（#13 和 #17），
+// 它们都引用 BootstrapMethods 中唯一的引导方法 Condy.bsm
+func TestConstantDynamic(t *testing.T) {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "Condy.class"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cp := cf.ConstantPool()
+	tests := []struct {
+		index      uint16
+		name, desc string
+	}{
+		{13, "_", "Ljava/lang/Object;"},
+		{17, "_", "J"},
+	}
+	for _, test := range tests {
+		info, ok := cp.getConstantInfo(test.index).(*ConstantDynamicInfo)
+		if !ok {
+			t.Errorf("#%d: got %T, want *ConstantDynamicInfo", test.index, cp.getConstantInfo(test.index))
+			continue
+		}
+		if info.BootstrapMethodAttrIndex() != 0 {
+			t.Errorf("#%d: bootstrap method index = %d, want 0", test.index, info.BootstrapMethodAttrIndex())
+		}
+		if name, desc := info.NameAndDescriptor(); name != test.name || desc != test.desc {
+			t.Errorf("#%d: got %s:%s, want %s:%s", test.index, name, desc, test.name, test.desc)
+		}
+	}
+
+	bsmAttr := cf.BootstrapMethodsAttribute()
+	if bsmAttr == nil {
+		t.Fatal("no BootstrapMethods attribute")
+	}
+	methods := bsmAttr.BootstrapMethods()
+	if len(methods) != 1 {
+		t.Fatalf("got %d bootstrap methods, want 1", len(methods))
+	}
+	mh, ok := cp.getConstantInfo(methods[0].BootstrapMethodRef()).(*ConstantMethodHandleInfo)
+	if !ok {
+		t.Fatalf("bootstrap method ref #%d is not a MethodHandle", methods[0].BootstrapMethodRef())
+	}
+	if mh.ReferenceKind() != 6 {
+		t.Errorf("reference kind = %d, want 6 (REF_invokeStatic)", mh.ReferenceKind())
+	}
+	ref := mh.Reference()
+	if name, _ := ref.NameAndDescriptor(); ref.ClassName() != "Condy" || name != "bsm" {
+		t.Errorf("bootstrap method = %s.%s, want Condy.bsm", ref.ClassName(), name)
+	}
+	if args := methods[0].BootstrapArguments(); len(args) != 0 {
+		t.Errorf("got %d bootstrap arguments, want 0", len(args))
+	}
+}
@@ -23,6 +23,18 @@ func (self *ConstantMemberrefInfo) readInfo(reader *ClassReader) {
 	self.nameAndTypeIndex = reader.readUint16()
 }
 
+func (self *ConstantMemberrefInfo) writeInfo(writer *ClassWriter) {
+	writer.writeUint16(self.classIndex)
+	writer.writeUint16(self.nameAndTypeIndex)
+}
+
+func (self *ConstantMemberrefInfo) ClassIndex() uint16 {
+	return self.classIndex
+}
+func (self *ConstantMemberrefInfo) NameAndTypeIndex() uint16 {
+	return self.nameAndTypeIndex
+}
+
 func (self *ConstantMemberrefInfo) ClassName() string {
 	return self.cp.getClassName(self.classIndex)
 }
@@ -41,4 +53,4 @@ type ConstantInterfaceMethodrefInfo struct{ ConstantMemberrefInfo }
 // CONSTANT_MethodType_info
 // CONSTANT_MethodHandle_info
 // CONSTANT_InvokeDynamic_info
-// 他们是 Java7 之后才支持的常量类型，用于支持 invokeDynamic 指令，这里不讨论这一块
+// 他们是 Java7 之后才支持的常量类型，用于支持 invokeDynamic 指令，参考 cp_invoke_dynamic.go 代码
@@ -0,0 +1,56 @@
+package classfile
+
+// CONSTANT_Module_info 和 CONSTANT_Package_info 是 Java9 为模块系统加入的常量类型，
+// 只允许出现在 module-info.class 的常量池中，其结构相同：
+// CONSTANT_Module_info {
+// 	   u1 tag;
+// 	   u2 name_index;
+// }
+// CONSTANT_Package_info {
+// 	   u1 tag;
+// 	   u2 name_index;
+// }
+//
+// name_index 指向 CONSTANT_Utf8_info 常量，模块名使用 "." 分隔（如 java.base），
+// 包名则和类名一样使用 "/" 分隔（如 java/lang）
+type ConstantModuleInfo struct {
+	cp        ConstantPool
+	nameIndex uint16
+}
+
+func (self *ConstantModuleInfo) readInfo(reader *ClassReader) {
+	self.nameIndex = reader.readUint16()
+}
+
+func (self *ConstantModuleInfo) writeInfo(writer *ClassWriter) {
+	writer.writeUint16(self.nameIndex)
+}
+
+func (self *ConstantModuleInfo) NameIndex() uint16 {
+	return self.nameIndex
+}
+
+func (self *ConstantModuleInfo) Name() string {
+	return self.cp.getUtf8(self.nameIndex)
+}
+
+type ConstantPackageInfo struct {
+	cp        ConstantPool
+	nameIndex uint16
+}
+
+func (self *ConstantPackageInfo) readInfo(reader *ClassReader) {
+	self.nameIndex = reader.readUint16()
+}
+
+func (self *ConstantPackageInfo) writeInfo(writer *ClassWriter) {
+	writer.writeUint16(self.nameIndex)
+}
+
+func (self *ConstantPackageInfo) NameIndex() uint16 {
+	return self.nameIndex
+}
+
+func (self *ConstantPackageInfo) Name() string {
+	return self.cp.getUtf8(self.nameIndex)
+}
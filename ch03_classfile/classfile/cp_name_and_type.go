@@ -22,6 +22,18 @@ func (self *ConstantNameAndTypeInfo) readInfo(reader *ClassReader) {
 	self.descriptorIndex = reader.readUint16()
 }
 
+func (self *ConstantNameAndTypeInfo) writeInfo(writer *ClassWriter) {
+	writer.writeUint16(self.nameIndex)
+	writer.writeUint16(self.descriptorIndex)
+}
+
+func (self *ConstantNameAndTypeInfo) NameIndex() uint16 {
+	return self.nameIndex
+}
+func (self *ConstantNameAndTypeInfo) DescriptorIndex() uint16 {
+	return self.descriptorIndex
+}
+
 // JVM 规范定义了一种简单的语法来描述字段或方法，并生成描述符 descriptor：
 // A. 类型描述符
 //   - 基本类型 byte、short、char、int、long、float 和 double 的描述符为单个字母，分别是
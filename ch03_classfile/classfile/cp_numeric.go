@@ -19,6 +19,14 @@ func (self *ConstantIntegerInfo) readInfo(reader *ClassReader) {
 	self.val = int32(bytes)
 }
 
+func (self *ConstantIntegerInfo) writeInfo(writer *ClassWriter) {
+	writer.writeUint32(uint32(self.val))
+}
+
+func (self *ConstantIntegerInfo) Value() int32 {
+	return self.val
+}
+
 // CONSTANT_Float_info 使用 1 个字节存储 tag，4 个字节存储浮点常量，其结构定义为
 //
 // CONSTANT_Float_info {
@@ -34,6 +42,14 @@ func (self *ConstantFloatInfo) readInfo(reader *ClassReader) {
 	self.val = math.Float32frombits(bytes) // 将 4 个字节转换为浮点
 }
 
+func (self *ConstantFloatInfo) writeInfo(writer *ClassWriter) {
+	writer.writeUint32(math.Float32bits(self.val))
+}
+
+func (self *ConstantFloatInfo) Value() float32 {
+	return self.val
+}
+
 // CONSTANT_Double_info 使用 1 个字节存储 tag，8 个字节存储双精度浮点常量，其结构定义为
 //
 // CONSTANT_Double_info {
@@ -50,6 +66,14 @@ func (self *ConstantDoubleInfo) readInfo(reader *ClassReader) {
 	self.val = math.Float64frombits(bytes)
 }
 
+func (self *ConstantDoubleInfo) writeInfo(writer *ClassWriter) {
+	writer.writeUint64(math.Float64bits(self.val))
+}
+
+func (self *ConstantDoubleInfo) Value() float64 {
+	return self.val
+}
+
 // CONSTANT_Long_info 使用 1 个字节存储 tag，8 个字节存储整数常量，其结构定义为
 //
 // CONSTANT_Long_info {
@@ -65,3 +89,11 @@ func (self *ConstantLongInfo) readInfo(reader *ClassReader) {
 	bytes := reader.readUint64()
 	self.val = int64(bytes)
 }
+
+func (self *ConstantLongInfo) writeInfo(writer *ClassWriter) {
+	writer.writeUint64(uint64(self.val))
+}
+
+func (self *ConstantLongInfo) Value() int64 {
+	return self.val
+}
@@ -19,7 +19,15 @@ func (self *ConstantStringInfo) readInfo(reader *ClassReader) {
 	self.stringIndex = reader.readUint16()
 }
 
+func (self *ConstantStringInfo) writeInfo(writer *ClassWriter) {
+	writer.writeUint16(self.stringIndex)
+}
+
 // String() 方法从常量池中根据索引查找字符串
+func (self *ConstantStringInfo) StringIndex() uint16 {
+	return self.stringIndex
+}
+
 func (self *ConstantStringInfo) String() string {
 	return self.cp.getUtf8(self.stringIndex)
 }
@@ -22,7 +22,23 @@ func (self *ConstantUtf8Info) readInfo(reader *ClassReader) {
 	self.str = decodeMUTF8(bytes)
 }
 
+// 将字符串重新编码为 MUTF-8 字节后写入，与 readInfo() 对应
+func (self *ConstantUtf8Info) writeInfo(writer *ClassWriter) {
+	bytes := encodeMUTF8(self.str)
+	writer.writeUint16(uint16(len(bytes)))
+	writer.writeBytes(bytes)
+}
+
+func (self *ConstantUtf8Info) Value() string {
+	return self.str
+}
+
 // TODO: 简化版，完成版查看项目源码
 func decodeMUTF8(bytes []byte) string {
 	return string(bytes)
 }
+
+// decodeMUTF8() 的逆过程，同样是简化版，二者必须保持互逆才能保证 class 文件原样写回
+func encodeMUTF8(str string) []byte {
+	return []byte(str)
+}
@@ -0,0 +1,525 @@
+package classfile
+
+import (
+	"fmt"
+	"strings"
+
+	"jvmgo/ch03_classfile/descriptor"
+)
+
+// Parse() 只保证 class 文件能够按照结构解码，JVM 规范 4.8 节要求的格式检查由 CheckFormat() 完成：
+// 常量池中每个引用的索引和类型、类名、字段名、方法名和描述符、字段和方法是否重复、属性出现的位置和次数、
+// Code 属性中的指令边界和异常表，以及 long 和 double 之后空出的常量池位置
+//
+// 与 ValidateAccessFlags() 不同，CheckFormat() 不会在第一个问题处停止，而是返回全部问题及其位置，
+// 方便一次性查看有问题的 class 文件；访问标志的检查也包含在内
+
+// FormatError 描述 class 文件中一处不符合格式要求的地方
+// location 指出问题所在的位置，例如 "constant pool #12"、"method main:([Ljava/lang/String;)V Code pc 7"
+type FormatError struct {
+	location string
+	message  string
+}
+
+// getter 方法
+func (self *FormatError) Location() string {
+	return self.location
+}
+func (self *FormatError) Message() string {
+	return self.message
+}
+
+func (self *FormatError) String() string {
+	return self.location + ": " + self.message
+}
+
+type formatChecker struct {
+	cf   *ClassFile
+	cp   ConstantPool
+	errs []*FormatError
+}
+
+func (self *formatChecker) add(location, format string, args ...interface{}) {
+	self.errs = append(self.errs, &FormatError{location: location, message: fmt.Sprintf(format, args...)})
+}
+
+// 检查 class 文件的格式，返回发现的全部问题，没有问题时返回 nil
+func (self *ClassFile) CheckFormat() []*FormatError {
+	checker := &formatChecker{cf: self, cp: self.constantPool}
+	checker.checkConstantPool()
+	checker.checkClass()
+	checker.checkFields()
+	checker.checkMethods()
+	checker.checkAttributes("class", attrInClass, self.attributes)
+	return checker.errs
+}
+
+// 可以作为引导方法静态参数的常量，ldc 和 ldc_w 还不能加载 long 和 double
+var loadableTags = []uint8{CONSTANT_Integer, CONSTANT_Float, CONSTANT_Long, CONSTANT_Double,
+	CONSTANT_String, CONSTANT_Class, CONSTANT_MethodHandle, CONSTANT_MethodType, CONSTANT_Dynamic}
+
+// 返回 index 处的常量，索引为 0、越界或者是 long 和 double 之后空出的位置时返回 nil
+func (self *formatChecker) constant(index uint16) ConstantInfo {
+	if int(index) < len(self.cp) {
+		return self.cp[index]
+	}
+	return nil
+}
+
+// 检查 field 给出的常量池索引是否指向 tags 中的某一种常量，合法时返回该常量，否则记录问题并返回 nil
+func (self *formatChecker) ref(location, field string, index uint16, tags ...uint8) ConstantInfo {
+	c := self.constant(index)
+	if c == nil {
+		if index > 1 && int(index) < len(self.cp) && isLongOrDouble(self.cp[index-1]) {
+			self.add(location, "%s #%d refers to the unusable entry after a long or double constant", field, index)
+		} else {
+			self.add(location, "%s #%d is not a valid constant pool index", field, index)
+		}
+		return nil
+	}
+	tag := constantInfoTag(c)
+	names := make([]string, len(tags))
+	for i, t := range tags {
+		if t == tag {
+			return c
+		}
+		names[i] = constantTagNames[t]
+	}
+	self.add(location, "%s #%d must be %s, not %s", field, index, strings.Join(names, " or "), constantTagNames[tag])
+	return nil
+}
+
+// 检查 field 是否指向 CONSTANT_Utf8_info，合法时返回字符串
+func (self *formatChecker) utf8(location, field string, index uint16) (string, bool) {
+	if c := self.ref(location, field, index, CONSTANT_Utf8); c != nil {
+		return c.(*ConstantUtf8Info).str, true
+	}
+	return "", false
+}
+
+// 和 utf8() 相同，但索引为 0 表示该项不存在，是合法的
+func (self *formatChecker) optionalUtf8(location, field string, index uint16) {
+	if index != 0 {
+		self.utf8(location, field, index)
+	}
+}
+
+// 不记录问题，只在 index 指向 CONSTANT_Utf8_info 时返回字符串，用于生成位置信息
+func (self *formatChecker) utf8Value(index uint16) (string, bool) {
+	if utf8Info, ok := self.constant(index).(*ConstantUtf8Info); ok {
+		return utf8Info.str, true
+	}
+	return "", false
+}
+
+func isLongOrDouble(c ConstantInfo) bool {
+	switch c.(type) {
+	case *ConstantLongInfo, *ConstantDoubleInfo:
+		return true
+	}
+	return false
+}
+
+// 内部形式的类名或接口名（JVM 规范 4.2.1 节），以 "/" 分隔的每一部分都是合法的非限定名
+// CONSTANT_Class_info 还可以是数组类型的描述符
+func isValidClassName(name string) bool {
+	if strings.HasPrefix(name, "[") {
+		_, err := descriptor.ParseFieldDescriptor(name)
+		return err == nil
+	}
+	for _, part := range strings.Split(name, "/") {
+		if !isValidUnqualifiedName(part) {
+			return false
+		}
+	}
+	return true
+}
+
+// 非限定名（JVM 规范 4.2.2 节）不能为空，也不能包含 . ; [ /
+func isValidUnqualifiedName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, ".;[/")
+}
+
+// 方法名除 <init> 和 <clinit> 之外还不能包含 < 和 >
+func isValidMethodName(name string) bool {
+	if name == "<init>" || name == "<clinit>" {
+		return true
+	}
+	return isValidUnqualifiedName(name) && !strings.ContainsAny(name, "<>")
+}
+
+func (self *formatChecker) requireVersion(location, what string, version uint16) {
+	if self.cf.majorVersion < version {
+		self.add(location, "%s requires class file version %d or above, got %d", what, version, self.cf.majorVersion)
+	}
+}
+
+// 检查常量池中每个常量引用的其它常量，以及其中的名称和描述符
+func (self *formatChecker) checkConstantPool() {
+	isModule := ClassAccessFlags(self.cf.accessFlags).IsModule()
+	for i := 1; i < len(self.cp); i++ {
+		location := fmt.Sprintf("constant pool #%d", i)
+		switch c := self.cp[i].(type) {
+		case nil:
+			if !isLongOrDouble(self.cp[i-1]) {
+				self.add(location, "missing constant")
+			}
+		case *ConstantLongInfo, *ConstantDoubleInfo:
+			// long 和 double 占两个位置，之后的位置不能再存放常量，也不能超出常量池
+			if i+1 >= len(self.cp) {
+				self.add(location, "%s takes two entries but is the last entry of the constant pool",
+					constantTagNames[constantInfoTag(c)])
+			} else if self.cp[i+1] != nil {
+				self.add(location, "entry #%d after a %s must be unused", i+1, constantTagNames[constantInfoTag(c)])
+			}
+		case *ConstantStringInfo:
+			self.utf8(location, "string_index", c.stringIndex)
+		case *ConstantClassInfo:
+			if name, ok := self.utf8(location, "name_index", c.nameIndex); ok && !isValidClassName(name) {
+				self.add(location, "invalid class name %q", name)
+			}
+		case *ConstantFieldrefInfo:
+			self.checkMemberref(location, &c.ConstantMemberrefInfo, "field")
+		case *ConstantMethodrefInfo:
+			self.checkMemberref(location, &c.ConstantMemberrefInfo, "method")
+		case *ConstantInterfaceMethodrefInfo:
+			self.checkMemberref(location, &c.ConstantMemberrefInfo, "interface method")
+		case *ConstantNameAndTypeInfo:
+			self.checkNameAndType(location, c)
+		case *ConstantMethodHandleInfo:
+			self.requireVersion(location, "CONSTANT_MethodHandle", 51)
+			self.checkMethodHandle(location, c)
+		case *ConstantMethodTypeInfo:
+			self.requireVersion(location, "CONSTANT_MethodType", 51)
+			if desc, ok := self.utf8(location, "descriptor_index", c.descriptorIndex); ok {
+				if _, err := descriptor.ParseMethodDescriptor(desc); err != nil {
+					self.add(location, "%v", err)
+				}
+			}
+		case *ConstantDynamicInfo:
+			self.requireVersion(location, "CONSTANT_Dynamic", 55)
+			self.checkDynamic(location, "CONSTANT_Dynamic", c.bootstrapMethodAttrIndex, c.nameAndTypeIndex)
+		case *ConstantInvokeDynamicInfo:
+			self.requireVersion(location, "CONSTANT_InvokeDynamic", 51)
+			self.checkDynamic(location, "CONSTANT_InvokeDynamic", c.bootstrapMethodAttrIndex, c.nameAndTypeIndex)
+		case *ConstantModuleInfo:
+			if !isModule {
+				self.add(location, "CONSTANT_Module is only allowed in module-info")
+			}
+			if name, ok := self.utf8(location, "name_index", c.nameIndex); ok && name == "" {
+				self.add(location, "empty module name")
+			}
+		case *ConstantPackageInfo:
+			if !isModule {
+				self.add(location, "CONSTANT_Package is only allowed in module-info")
+			}
+			if name, ok := self.utf8(location, "name_index", c.nameIndex); ok && !isValidClassName(name) {
+				self.add(location, "invalid package name %q", name)
+			}
+		}
+	}
+}
+
+// 名称和描述符本身在 CONSTANT_NameAndType_info 处检查，这里只检查字段引用的是字段描述符，方法引用的是方法描述符，
+// 以及方法引用中只能出现 <init> 这一个特殊方法名，且返回值必须是 void
+func (self *formatChecker) checkMemberref(location string, ref *ConstantMemberrefInfo, kind string) {
+	self.ref(location, "class_index", ref.classIndex, CONSTANT_Class)
+	ntInfo, ok := self.ref(location, "name_and_type_index", ref.nameAndTypeIndex, CONSTANT_NameAndType).(*ConstantNameAndTypeInfo)
+	if !ok {
+		return
+	}
+	name, ok1 := self.utf8Value(ntInfo.nameIndex)
+	desc, ok2 := self.utf8Value(ntInfo.descriptorIndex)
+	if !ok1 || !ok2 {
+		return
+	}
+	isMethodDesc := strings.HasPrefix(desc, "(")
+	switch {
+	case kind == "field" && isMethodDesc:
+		self.add(location, "field reference %s has a method descriptor %s", name, desc)
+	case kind != "field" && !isMethodDesc:
+		self.add(location, "%s reference %s has a field descriptor %s", kind, name, desc)
+	case kind == "method" && name == "<init>":
+		if !strings.HasSuffix(desc, ")V") {
+			self.add(location, "<init> must return void, got descriptor %s", desc)
+		}
+	case kind != "field" && strings.HasPrefix(name, "<"):
+		self.add(location, "%s reference must not refer to %s", kind, name)
+	}
+}
+
+// 描述符以 "(" 开头时是方法的名称和描述符，否则是字段的
+func (self *formatChecker) checkNameAndType(location string, ntInfo *ConstantNameAndTypeInfo) {
+	name, ok1 := self.utf8(location, "name_index", ntInfo.nameIndex)
+	desc, ok2 := self.utf8(location, "descriptor_index", ntInfo.descriptorIndex)
+	if !ok1 || !ok2 {
+		return
+	}
+	if strings.HasPrefix(desc, "(") {
+		if !isValidMethodName(name) {
+			self.add(location, "invalid method name %q", name)
+		}
+		if _, err := descriptor.ParseMethodDescriptor(desc); err != nil {
+			self.add(location, "%v", err)
+		}
+	} else {
+		if !isValidUnqualifiedName(name) {
+			self.add(location, "invalid field name %q", name)
+		}
+		if _, err := descriptor.ParseFieldDescriptor(desc); err != nil {
+			self.add(location, "%v", err)
+		}
+	}
+}
+
+// reference_kind 决定了 reference_index 指向的常量类型（JVM 规范 4.4.8 节）
+func (self *formatChecker) checkMethodHandle(location string, mhInfo *ConstantMethodHandleInfo) {
+	var c ConstantInfo
+	switch mhInfo.referenceKind {
+	case REF_getField, REF_getStatic, REF_putField, REF_putStatic:
+		c = self.ref(location, "reference_index", mhInfo.referenceIndex, CONSTANT_Fieldref)
+	case REF_invokeVirtual, REF_newInvokeSpecial:
+		c = self.ref(location, "reference_index", mhInfo.referenceIndex, CONSTANT_Methodref)
+	case REF_invokeStatic, REF_invokeSpecial:
+		if self.cf.majorVersion < 52 {
+			c = self.ref(location, "reference_index", mhInfo.referenceIndex, CONSTANT_Methodref)
+		} else {
+			c = self.ref(location, "reference_index", mhInfo.referenceIndex, CONSTANT_Methodref, CONSTANT_InterfaceMethodref)
+		}
+	case REF_invokeInterface:
+		c = self.ref(location, "reference_index", mhInfo.referenceIndex, CONSTANT_InterfaceMethodref)
+	default:
+		self.add(location, "invalid reference_kind %d", mhInfo.referenceKind)
+		return
+	}
+	if c == nil || mhInfo.referenceKind <= REF_putStatic {
+		return
+	}
+	ntInfo, ok := self.constant(c.(memberref).NameAndTypeIndex()).(*ConstantNameAndTypeInfo)
+	if !ok {
+		return
+	}
+	name, ok := self.utf8Value(ntInfo.nameIndex)
+	switch {
+	case !ok:
+	case mhInfo.referenceKind == REF_newInvokeSpecial && name != "<init>":
+		self.add(location, "REF_newInvokeSpecial must refer to <init>, not %s", name)
+	case mhInfo.referenceKind != REF_newInvokeSpecial && (name == "<init>" || name == "<clinit>"):
+		self.add(location, "method handle must not refer to %s", name)
+	}
+}
+
+// 三种成员引用都嵌入了 ConstantMemberrefInfo，通过这个接口统一取出 name_and_type_index
+type memberref interface {
+	NameAndTypeIndex() uint16
+}
+
+// CONSTANT_InvokeDynamic 的描述符必须是方法描述符，CONSTANT_Dynamic 的描述符必须是字段描述符
+func (self *formatChecker) checkDynamic(location, kind string, bootstrapMethodAttrIndex, nameAndTypeIndex uint16) {
+	bootstrapMethods := self.cf.BootstrapMethodsAttribute()
+	switch {
+	case bootstrapMethods == nil:
+		self.add(location, "%s requires a BootstrapMethods attribute", kind)
+	case int(bootstrapMethodAttrIndex) >= len(bootstrapMethods.bootstrapMethods):
+		self.add(location, "bootstrap_method_attr_index %d is out of range, there are %d bootstrap methods",
+			bootstrapMethodAttrIndex, len(bootstrapMethods.bootstrapMethods))
+	}
+	ntInfo, ok := self.ref(location, "name_and_type_index", nameAndTypeIndex, CONSTANT_NameAndType).(*ConstantNameAndTypeInfo)
+	if !ok {
+		return
+	}
+	desc, ok := self.utf8Value(ntInfo.descriptorIndex)
+	switch {
+	case !ok:
+	case kind == "CONSTANT_InvokeDynamic" && !strings.HasPrefix(desc, "("):
+		self.add(location, "invokedynamic has a field descriptor %s", desc)
+	case kind == "CONSTANT_Dynamic" && strings.HasPrefix(desc, "("):
+		self.add(location, "dynamic constant has a method descriptor %s", desc)
+	}
+}
+
+// 检查 this_class、super_class 和接口表，以及类的访问标志
+// module-info 没有超类、接口、字段和方法（JVM 规范 4.1 节）
+func (self *formatChecker) checkClass() {
+	cf := self.cf
+	classFlags := ClassAccessFlags(cf.accessFlags)
+	if err := classFlags.Validate(cf.majorVersion); err != nil {
+		self.add("class", "illegal class modifiers 0x%04X: %v", cf.accessFlags, err)
+	}
+
+	className := ""
+	if classInfo, ok := self.ref("this_class", "this_class", cf.thisClass, CONSTANT_Class).(*ConstantClassInfo); ok {
+		className, _ = self.utf8Value(classInfo.nameIndex)
+	}
+
+	if cf.majorVersion >= 53 && classFlags.IsModule() {
+		if className != "module-info" {
+			self.add("this_class", "module class must be named module-info, not %s", className)
+		}
+		if cf.superClass != 0 {
+			self.add("super_class", "module-info must not have a superclass")
+		}
+		if len(cf.interfaces)+len(cf.fields)+len(cf.methods) > 0 {
+			self.add("class", "module-info must not have interfaces, fields or methods")
+		}
+		if cf.ModuleAttribute() == nil {
+			self.add("class", "module-info must have a Module attribute")
+		}
+		return
+	}
+
+	if cf.superClass == 0 {
+		if className != "java/lang/Object" {
+			self.add("super_class", "only java/lang/Object may have no superclass")
+		}
+	} else if classInfo, ok := self.ref("super_class", "super_class", cf.superClass, CONSTANT_Class).(*ConstantClassInfo); ok {
+		superName, _ := self.utf8Value(classInfo.nameIndex)
+		switch {
+		case classFlags.IsInterface() && superName != "java/lang/Object":
+			self.add("super_class", "superclass of an interface must be java/lang/Object, not %s", superName)
+		case strings.HasPrefix(superName, "["):
+			self.add("super_class", "superclass must not be an array type %s", superName)
+		}
+	}
+
+	seen := map[string]bool{}
+	for i, index := range cf.interfaces {
+		location := fmt.Sprintf("interfaces[%d]", i)
+		classInfo, ok := self.ref(location, "interface", index, CONSTANT_Class).(*ConstantClassInfo)
+		if !ok {
+			continue
+		}
+		if name, ok := self.utf8Value(classInfo.nameIndex); ok {
+			if seen[name] {
+				self.add(location, "duplicate interface %s", name)
+			}
+			seen[name] = true
+		}
+	}
+}
+
+// 字段或方法的位置，名称和描述符无法从常量池得到时使用其在字段表或方法表中的下标
+func (self *formatChecker) memberLocation(kind string, i int, member *MemberInfo) string {
+	name, ok1 := self.utf8Value(member.nameIndex)
+	desc, ok2 := self.utf8Value(member.descriptorIndex)
+	if ok1 && ok2 {
+		return kind + " " + name + ":" + desc
+	}
+	return fmt.Sprintf("%s #%d", kind, i)
+}
+
+func (self *formatChecker) checkFields() {
+	inInterface := ClassAccessFlags(self.cf.accessFlags).IsInterface()
+	seen := map[string]bool{}
+	for i, field := range self.cf.fields {
+		location := self.memberLocation("field", i, field)
+		if err := FieldAccessFlags(field.accessFlags).Validate(inInterface); err != nil {
+			self.add(location, "illegal field modifiers 0x%04X: %v", field.accessFlags, err)
+		}
+		name, ok1 := self.utf8(location, "name_index", field.nameIndex)
+		desc, ok2 := self.utf8(location, "descriptor_index", field.descriptorIndex)
+		if ok1 && !isValidUnqualifiedName(name) {
+			self.add(location, "invalid field name %q", name)
+		}
+		if ok2 {
+			if _, err := descriptor.ParseFieldDescriptor(desc); err != nil {
+				self.add(location, "%v", err)
+			}
+		}
+		if ok1 && ok2 {
+			if seen[name+":"+desc] {
+				self.add(location, "duplicate field")
+			}
+			seen[name+":"+desc] = true
+		}
+
+		self.checkAttributes(location, attrInField, field.attributes)
+		if cvAttr := field.ConstantValueAttribute(); cvAttr != nil && ok2 {
+			self.checkConstantValue(location, cvAttr, desc)
+		}
+	}
+}
+
+// ConstantValue 指向的常量类型由字段类型决定（JVM 规范 4.7.2 节）
+func (self *formatChecker) checkConstantValue(location string, cvAttr *ConstantValueAttribute, desc string) {
+	field := "ConstantValue"
+	switch desc {
+	case "J":
+		self.ref(location, field, cvAttr.constantValueIndex, CONSTANT_Long)
+	case "F":
+		self.ref(location, field, cvAttr.constantValueIndex, CONSTANT_Float)
+	case "D":
+		self.ref(location, field, cvAttr.constantValueIndex, CONSTANT_Double)
+	case "I", "S", "C", "B", "Z":
+		self.ref(location, field, cvAttr.constantValueIndex, CONSTANT_Integer)
+	case "Ljava/lang/String;":
+		self.ref(location, field, cvAttr.constantValueIndex, CONSTANT_String)
+	default:
+		self.add(location, "ConstantValue attribute is not allowed for a field of type %s", desc)
+	}
+}
+
+// 除了访问标志、名称和描述符之外，还检查参数占用的 slot 数（JVM 规范 4.3.3 节），
+// 以及只有 abstract 和 native 方法没有 Code 属性
+func (self *formatChecker) checkMethods() {
+	inInterface := ClassAccessFlags(self.cf.accessFlags).IsInterface()
+	seen := map[string]bool{}
+	for i, method := range self.cf.methods {
+		location := self.memberLocation("method", i, method)
+		flags := MethodAccessFlags(method.accessFlags)
+		name, ok1 := self.utf8(location, "name_index", method.nameIndex)
+		desc, ok2 := self.utf8(location, "descriptor_index", method.descriptorIndex)
+		if err := flags.Validate(self.cf.majorVersion, inInterface, name); err != nil {
+			self.add(location, "illegal method modifiers 0x%04X: %v", method.accessFlags, err)
+		}
+		if ok1 && !isValidMethodName(name) {
+			self.add(location, "invalid method name %q", name)
+		}
+		if ok1 && inInterface && name == "<init>" {
+			self.add(location, "interface must not have an <init> method")
+		}
+
+		var methodType *descriptor.MethodType
+		if ok2 {
+			var err error
+			if methodType, err = descriptor.ParseMethodDescriptor(desc); err != nil {
+				self.add(location, "%v", err)
+				methodType = nil
+			}
+		}
+		if methodType != nil {
+			argSlots := methodType.ArgSlots()
+			if !flags.IsStatic() {
+				argSlots++
+			}
+			if argSlots > 255 {
+				self.add(location, "parameters take %d slots, more than 255", argSlots)
+			}
+			switch {
+			case (name == "<init>" || name == "<clinit>") && methodType.ReturnSlots() != 0:
+				self.add(location, "%s must return void", name)
+			case name == "<clinit>" && len(methodType.ParameterTypes()) > 0 && self.cf.majorVersion >= 51:
+				self.add(location, "<clinit> must not take parameters")
+			}
+		}
+		if ok1 && ok2 {
+			if seen[name+":"+desc] {
+				self.add(location, "duplicate method")
+			}
+			seen[name+":"+desc] = true
+		}
+
+		self.checkAttributes(location, attrInMethod, method.attributes)
+		codeAttr := method.CodeAttribute()
+		switch {
+		case flags.IsAbstract() || flags.IsNative():
+			if codeAttr != nil {
+				self.add(location, "abstract or native method must not have a Code attribute")
+			}
+		case codeAttr == nil:
+			self.add(location, "method must have a Code attribute")
+		default:
+			self.checkCode(location+" Code", flags, methodType, codeAttr)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package classfile
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+
+	"jvmgo/ch03_classfile/descriptor"
+)
+
+// 属性可以出现的位置，JVM 规范 4.7 节的表 4.7-C 给出了每个预定义属性的位置
+const (
+	attrInClass = 1 << iota
+	attrInField
+	attrInMethod
+	attrInCode
+)
+
+var attrLocationNames = map[int]string{
+	attrInClass:  "ClassFile",
+	attrInField:  "field_info",
+	attrInMethod: "method_info",
+	attrInCode:   "Code attribute",
+}
+
+// 预定义属性允许出现的位置，不在表中的属性都是自定义属性，可以出现在任何位置，JVM 会忽略它们
+var predefinedAttributes = map[string]int{
+	"SourceFile":                           attrInClass,
+	"InnerClasses":                         attrInClass,
+	"EnclosingMethod":                      attrInClass,
+	"SourceDebugExtension":                 attrInClass,
+	"BootstrapMethods":                     attrInClass,
+	"Module":                               attrInClass,
+	"ModulePackages":                       attrInClass,
+	"ModuleMainClass":                      attrInClass,
+	"NestHost":                             attrInClass,
+	"NestMembers":                          attrInClass,
+	"Record":                               attrInClass,
+	"PermittedSubclasses":                  attrInClass,
+	"ConstantValue":                        attrInField,
+	"Code":                                 attrInMethod,
+	"Exceptions":                           attrInMethod,
+	"RuntimeVisibleParameterAnnotations":   attrInMethod,
+	"RuntimeInvisibleParameterAnnotations": attrInMethod,
+	"AnnotationDefault":                    attrInMethod,
+	"MethodParameters":                     attrInMethod,
+	"Synthetic":                            attrInClass | attrInField | attrInMethod,
+	"Deprecated":                           attrInClass | attrInField | attrInMethod,
+	"Signature":                            attrInClass | attrInField | attrInMethod,
+	"RuntimeVisibleAnnotations":            attrInClass | attrInField | attrInMethod,
+	"RuntimeInvisibleAnnotations":          attrInClass | attrInField | attrInMethod,
+	"LineNumberTable":                      attrInCode,
+	"LocalVariableTable":                   attrInCode,
+	"LocalVariableTypeTable":               attrInCode,
+	"StackMapTable":                        attrInCode,
+	"RuntimeVisibleTypeAnnotations":        attrInClass | attrInField | attrInMethod | attrInCode,
+	"RuntimeInvisibleTypeAnnotations":      attrInClass | attrInField | attrInMethod | attrInCode,
+}
+
+// 可以出现多次的预定义属性，其余的预定义属性在同一个位置最多出现一次
+var repeatableAttributes = map[string]bool{
+	"LineNumberTable":        true,
+	"LocalVariableTable":     true,
+	"LocalVariableTypeTable": true,
+	"Synthetic":              true,
+	"Deprecated":             true,
+}
+
+// 检查属性表中每个属性出现的位置和次数，以及不依赖于字段或方法的属性内容
+// 依赖字段类型的 ConstantValue 和依赖方法的 Code 由 checkFields() 和 checkMethods() 检查
+func (self *formatChecker) checkAttributes(location string, where int, attributes []AttributeInfo) {
+	seen := map[string]bool{}
+	for _, attrInfo := range attributes {
+		attrName := attributeName(attrInfo)
+		if allowed, ok := predefinedAttributes[attrName]; ok {
+			if allowed&where == 0 {
+				self.add(location, "%s attribute is not allowed in %s", attrName, attrLocationNames[where])
+			} else if seen[attrName] && !repeatableAttributes[attrName] {
+				self.add(location, "duplicate %s attribute", attrName)
+			}
+			seen[attrName] = true
+		}
+
+		switch attr := attrInfo.(type) {
+		case *SourceFileAttribute:
+			self.utf8(location, "SourceFile", attr.sourceFileIndex)
+		case *ExceptionsAttribute:
+			for _, index := range attr.exceptionIndexTable {
+				self.ref(location, "Exceptions entry", index, CONSTANT_Class)
+			}
+		case *MethodParametersAttribute:
+			for i, param := range attr.parameters {
+				if param.nameIndex == 0 {
+					continue
+				}
+				if name, ok := self.utf8(location, fmt.Sprintf("MethodParameters[%d]", i), param.nameIndex); ok && !isValidUnqualifiedName(name) {
+					self.add(location, "invalid parameter name %q", name)
+				}
+			}
+		case *BootstrapMethodsAttribute:
+			for i, method := range attr.bootstrapMethods {
+				bootstrapLocation := fmt.Sprintf("BootstrapMethods[%d]", i)
+				self.ref(bootstrapLocation, "bootstrap_method_ref", method.bootstrapMethodRef, CONSTANT_MethodHandle)
+				for _, arg := range method.bootstrapArguments {
+					self.ref(bootstrapLocation, "bootstrap argument", arg, loadableTags...)
+				}
+			}
+		case *ModuleAttribute:
+			if !ClassAccessFlags(self.cf.accessFlags).IsModule() {
+				self.add(location, "Module attribute is only allowed in module-info")
+			}
+			self.checkModule(attr)
+		case *ModuleMainClassAttribute:
+			self.ref(location, "ModuleMainClass", attr.mainClassIndex, CONSTANT_Class)
+		case *ModulePackagesAttribute:
+			for _, index := range attr.packageIndex {
+				self.ref(location, "ModulePackages entry", index, CONSTANT_Package)
+			}
+		}
+	}
+}
+
+// Module 属性中每个索引指向的常量类型见 attr_module.go
+func (self *formatChecker) checkModule(attr *ModuleAttribute) {
+	location := "Module"
+	self.ref(location, "module_name_index", attr.moduleNameIndex, CONSTANT_Module)
+	self.optionalUtf8(location, "module_version_index", attr.moduleVersionIndex)
+	for i, entry := range attr.requires {
+		entryLocation := fmt.Sprintf("Module requires[%d]", i)
+		self.ref(entryLocation, "requires_index", entry.requiresIndex, CONSTANT_Module)
+		self.optionalUtf8(entryLocation, "requires_version_index", entry.requiresVersionIndex)
+	}
+	self.checkModuleExports("exports", attr.exports)
+	self.checkModuleExports("opens", attr.opens)
+	for _, index := range attr.usesIndex {
+		self.ref(location, "uses_index", index, CONSTANT_Class)
+	}
+	for i, entry := range attr.provides {
+		entryLocation := fmt.Sprintf("Module provides[%d]", i)
+		self.ref(entryLocation, "provides_index", entry.providesIndex, CONSTANT_Class)
+		for _, index := range entry.providesWithIndex {
+			self.ref(entryLocation, "provides_with_index", index, CONSTANT_Class)
+		}
+	}
+}
+
+// exports 和 opens 结构相同，kind 用于位置信息
+func (self *formatChecker) checkModuleExports(kind string, entries []*ModuleExportsEntry) {
+	for i, entry := range entries {
+		entryLocation := fmt.Sprintf("Module %s[%d]", kind, i)
+		self.ref(entryLocation, kind+"_index", entry.index, CONSTANT_Package)
+		for _, index := range entry.toIndex {
+			self.ref(entryLocation, kind+"_to_index", index, CONSTANT_Module)
+		}
+	}
+}
+
+// 各条指令的常量池操作数必须指向的常量类型（JVM 规范 4.9.1 节）
+var cpOperandTags = map[uint8][]uint8{
+	OP_ldc:             {CONSTANT_Integer, CONSTANT_Float, CONSTANT_String, CONSTANT_Class, CONSTANT_MethodHandle, CONSTANT_MethodType, CONSTANT_Dynamic},
+	OP_ldc_w:           {CONSTANT_Integer, CONSTANT_Float, CONSTANT_String, CONSTANT_Class, CONSTANT_MethodHandle, CONSTANT_MethodType, CONSTANT_Dynamic},
+	OP_ldc2_w:          {CONSTANT_Long, CONSTANT_Double, CONSTANT_Dynamic},
+	OP_getstatic:       {CONSTANT_Fieldref},
+	OP_putstatic:       {CONSTANT_Fieldref},
+	OP_getfield:        {CONSTANT_Fieldref},
+	OP_putfield:        {CONSTANT_Fieldref},
+	OP_invokevirtual:   {CONSTANT_Methodref},
+	OP_invokespecial:   {CONSTANT_Methodref, CONSTANT_InterfaceMethodref},
+	OP_invokestatic:    {CONSTANT_Methodref, CONSTANT_InterfaceMethodref},
+	OP_invokeinterface: {CONSTANT_InterfaceMethodref},
+	OP_invokedynamic:   {CONSTANT_InvokeDynamic},
+	OP_new:             {CONSTANT_Class},
+	OP_anewarray:       {CONSTANT_Class},
+	OP_checkcast:       {CONSTANT_Class},
+	OP_instanceof:      {CONSTANT_Class},
+	OP_multianewarray:  {CONSTANT_Class},
+}
+
+// Code 属性的检查：code 的长度、max_locals 能否容纳参数、每条指令能否解码、跳转目标和异常表是否落在指令的开头，
+// 指令的常量池操作数，以及 LineNumberTable、LocalVariableTable 等属性中的 pc 和局部变量索引
+// methodType 为 nil 表示方法描述符不合法，此时不检查 max_locals
+func (self *formatChecker) checkCode(location string, flags MethodAccessFlags, methodType *descriptor.MethodType, codeAttr *CodeAttribute) {
+	codeLength := len(codeAttr.code)
+	if codeLength == 0 || codeLength >= 65536 {
+		self.add(location, "code_length %d must be between 1 and 65535", codeLength)
+	}
+	if methodType != nil {
+		argSlots := methodType.ArgSlots()
+		if !flags.IsStatic() {
+			argSlots++
+		}
+		if int(codeAttr.maxLocals) < argSlots {
+			self.add(location, "max_locals %d is less than the %d slots taken by the parameters", codeAttr.maxLocals, argSlots)
+		}
+	}
+
+	starts := self.checkInstructions(location, codeAttr)
+	// 指令无法解码时不知道指令的边界，只检查 pc 是否在 code 的范围内
+	isStart := func(pc int) bool {
+		if starts == nil {
+			return pc >= 0 && pc < codeLength
+		}
+		return pc >= 0 && pc < codeLength && starts[pc]
+	}
+
+	for i, entry := range codeAttr.exceptionTable {
+		entryLocation := fmt.Sprintf("%s exception_table[%d]", location, i)
+		if entry.startPc >= entry.endPc {
+			self.add(entryLocation, "start_pc %d must be less than end_pc %d", entry.startPc, entry.endPc)
+		}
+		if !isStart(int(entry.startPc)) {
+			self.add(entryLocation, "start_pc %d is not the start of an instruction", entry.startPc)
+		}
+		if int(entry.endPc) != codeLength && !isStart(int(entry.endPc)) {
+			self.add(entryLocation, "end_pc %d is neither the start of an instruction nor code_length", entry.endPc)
+		}
+		if !isStart(int(entry.handlerPc)) {
+			self.add(entryLocation, "handler_pc %d is not the start of an instruction", entry.handlerPc)
+		}
+		if entry.catchType != 0 {
+			self.ref(entryLocation, "catch_type", entry.catchType, CONSTANT_Class)
+		}
+	}
+
+	self.checkAttributes(location, attrInCode, codeAttr.attributes)
+	for _, attrInfo := range codeAttr.attributes {
+		switch attr := attrInfo.(type) {
+		case *LineNumberTableAttribute:
+			for i, entry := range attr.lineNumberTable {
+				if !isStart(int(entry.startPc)) {
+					self.add(fmt.Sprintf("%s LineNumberTable[%d]", location, i),
+						"start_pc %d is not the start of an instruction", entry.startPc)
+				}
+			}
+		case *LocalVariableTableAttribute:
+			for i, entry := range attr.localVariableTable {
+				entryLocation := fmt.Sprintf("%s LocalVariableTable[%d]", location, i)
+				self.checkLocalVariable(entryLocation, codeAttr, isStart, entry.startPc, entry.length, entry.nameIndex, entry.index)
+				if desc, ok := self.utf8(entryLocation, "descriptor_index", entry.descriptorIndex); ok {
+					if t, err := descriptor.ParseFieldDescriptor(desc); err != nil {
+						self.add(entryLocation, "%v", err)
+					} else if int(entry.index)+t.Slots() > int(codeAttr.maxLocals) {
+						self.add(entryLocation, "local variable %d of type %s exceeds max_locals %d", entry.index, desc, codeAttr.maxLocals)
+					}
+				}
+			}
+		case *LocalVariableTypeTableAttribute:
+			for i, entry := range attr.localVariableTypeTable {
+				entryLocation := fmt.Sprintf("%s LocalVariableTypeTable[%d]", location, i)
+				self.checkLocalVariable(entryLocation, codeAttr, isStart, entry.startPc, entry.length, entry.nameIndex, entry.index)
+				if signature, ok := self.utf8(entryLocation, "signature_index", entry.signatureIndex); ok {
+					if _, err := descriptor.ParseFieldSignature(signature); err != nil {
+						self.add(entryLocation, "%v", err)
+					}
+				}
+			}
+		case *StackMapTableAttribute:
+			self.checkStackMapTable(location+" StackMapTable", attr, isStart, codeAttr.code)
+		}
+	}
+}
+
+// 逐条解码指令，检查跳转目标和常量池操作数，返回每条指令开头的 pc 组成的表，无法解码时返回 nil
+func (self *formatChecker) checkInstructions(location string, codeAttr *CodeAttribute) []bool {
+	code := codeAttr.code
+	starts := make([]bool, len(code))
+	var branches [][2]int // 跳转指令的 pc 和目标 pc
+	for pc := 0; pc < len(code); {
+		insn, targets, next, err := safeDecodeInstruction(code, pc)
+		if err != nil {
+			self.add(fmt.Sprintf("%s pc %d", location, pc), "%v", err)
+			return nil
+		}
+		starts[pc] = true
+		for _, target := range targets {
+			branches = append(branches, [2]int{pc, target})
+		}
+		if tags, ok := cpOperandTags[insn.opcode]; ok {
+			self.ref(fmt.Sprintf("%s pc %d", location, pc), insn.Name()+" operand", insn.CpIndex(), tags...)
+		}
+		pc = next
+	}
+	for _, branch := range branches {
+		if target := branch[1]; target < 0 || target >= len(code) || !starts[target] {
+			self.add(fmt.Sprintf("%s pc %d", location, branch[0]), "branch target %d is not the start of an instruction", target)
+		}
+	}
+	return starts
+}
+
+// decodeInstruction() 遇到非法的操作码时会 panic，指令被截断时会越界，这里都转换为 error
+func safeDecodeInstruction(code []byte, pc int) (insn *Instruction, targets []int, next int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(runtime.Error); ok {
+				err = fmt.Errorf("instruction %s is truncated", OpcodeName(code[pc]))
+			} else {
+				err = fmt.Errorf("%s", strings.TrimPrefix(fmt.Sprint(r), "java.lang.ClassFormatError: "))
+			}
+		}
+	}()
+	insn, targets, next = decodeInstruction(code, pc)
+	if next > len(code) {
+		err = fmt.Errorf("instruction %s is truncated", insn.Name())
+	}
+	return
+}
+
+// LocalVariableTable 和 LocalVariableTypeTable 共同的检查：[start_pc, start_pc + length) 必须覆盖完整的指令，
+// 名称必须是非限定名，index 必须小于 max_locals
+func (self *formatChecker) checkLocalVariable(location string, codeAttr *CodeAttribute, isStart func(int) bool,
+	startPc, length, nameIndex, index uint16) {
+	if !isStart(int(startPc)) {
+		self.add(location, "start_pc %d is not the start of an instruction", startPc)
+	}
+	if end := int(startPc) + int(length); end != len(codeAttr.code) && !isStart(end) {
+		self.add(location, "start_pc + length %d is neither the start of an instruction nor code_length", end)
+	}
+	if name, ok := self.utf8(location, "name_index", nameIndex); ok && !isValidUnqualifiedName(name) {
+		self.add(location, "invalid local variable name %q", name)
+	}
+	if index >= codeAttr.maxLocals {
+		self.add(location, "local variable index %d exceeds max_locals %d", index, codeAttr.maxLocals)
+	}
+}
+
+// 栈帧所在的 pc 必须是指令的开头，Object 类型指向 CONSTANT_Class_info，Uninitialized 类型的 offset 必须是 new 指令
+func (self *formatChecker) checkStackMapTable(location string, attr *StackMapTableAttribute, isStart func(int) bool, code []byte) {
+	pc := -1
+	for i, frame := range attr.entries {
+		pc += int(frame.offsetDelta) + 1
+		frameLocation := fmt.Sprintf("%s[%d]", location, i)
+		if !isStart(pc) {
+			self.add(frameLocation, "frame at pc %d is not at the start of an instruction", pc)
+		}
+		for _, info := range append(append([]*VerificationTypeInfo{}, frame.locals...), frame.stack...) {
+			switch info.tag {
+			case ITEM_Object:
+				self.ref(frameLocation, "Object_variable_info", info.value, CONSTANT_Class)
+			case ITEM_Uninitialized:
+				if offset := int(info.value); !isStart(offset) || code[offset] != OP_new {
+					self.add(frameLocation, "Uninitialized_variable_info offset %d is not a new instruction", offset)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package classfile
+
+import (
+	"archive/zip"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 用 NewClassFile() 构造带有几处格式错误的类，写出后重新解析再检查，
+// CheckFormat() 应当报告全部问题，而不是停在第一个问题处
+func TestCheckFormatViolations(t *testing.T) {
+	cf := NewClassFile(52, 0, ACC_PUBLIC|ACC_SUPER, "T", "java/lang/Object")
+	cf.AddInterface("java/lang/Runnable")
+	cf.AddInterface("java/lang/Runnable")
+	cf.AddField(ACC_PRIVATE, "f", "I")
+	cf.AddField(ACC_PRIVATE, "f", "I")
+	field := cf.AddField(ACC_STATIC|ACC_FINAL, "g", "J")
+	cf.SetConstantValue(field, cf.AddString("not a long"))
+	cf.AddField(ACC_PRIVATE, "h", "Q")
+	method := cf.AddMethod(ACC_PUBLIC|ACC_ABSTRACT, "m", "()V")
+	list := cf.NewCode(method)
+	list.Append(NewInstruction(OP_return))
+	if err := list.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	cf.AddMethod(ACC_PUBLIC, "n", "()V")
+	cf.AddMethod(ACC_PUBLIC, "<init>", "()I")
+
+	data, err := Serialize(cf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cf, err = Parse(data); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range cf.CheckFormat() {
+		got = append(got, e.String())
+	}
+	for _, want := range []string{
+		"duplicate interface java/lang/Runnable",
+		"field f:I: duplicate field",
+		"field g:J: ConstantValue",
+		"field h:Q: invalid field descriptor",
+		"method m:()V: abstract or native method must not have a Code attribute",
+		"method n:()V: method must have a Code attribute",
+		"<init> must return void",
+	} {
+		found := false
+		for _, e := range got {
+			found = found || strings.Contains(e, want)
+		}
+		if !found {
+			t.Errorf("CheckFormat() does not report %q:\n%s", want, strings.Join(got, "\n"))
+		}
+	}
+}
+
+// 把 testdata/roundtrip.jar 中每个类的每个字节依次改为几个不同的值，能够解析的结果交给 CheckFormat()，
+// 格式错误只应该报告为 FormatError，不能 panic
+func TestCheckFormatMutatedClasses(t *testing.T) {
+	for name, data := range roundTripClasses(t) {
+		for i := 8; i < len(data); i++ {
+			for _, v := range []byte{0, 1, 0x7F, 0xFF, data[i] + 1, data[i] - 1} {
+				mutated := append([]byte{}, data...)
+				mutated[i] = v
+				if !checkFormatNoPanic(t, mutated) {
+					t.Fatalf("%s: CheckFormat panicked with byte %d set to 0x%02X", name, i, v)
+				}
+			}
+		}
+	}
+}
+
+// go test -fuzz=FuzzCheckFormat 以 roundtrip.jar 中的类为种子查找 CheckFormat() 中的 panic
+func FuzzCheckFormat(f *testing.F) {
+	for _, data := range roundTripClasses(f) {
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		checkFormatNoPanic(t, data)
+	})
+}
+
+func checkFormatNoPanic(t *testing.T, data []byte) (ok bool) {
+	cf, err := Parse(data)
+	if err != nil {
+		return true
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			t.Errorf("CheckFormat: %v", r)
+			ok = false
+		}
+	}()
+	cf.CheckFormat()
+	return true
+}
+
+func roundTripClasses(tb testing.TB) map[string][]byte {
+	r, err := zip.OpenReader(filepath.Join("testdata", "roundtrip.jar"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer r.Close()
+	classes := map[string][]byte{}
+	for _, file := range r.File {
+		if strings.HasSuffix(file.Name, ".class") {
+			classes[file.Name] = readZipFile(tb, file)
+		}
+	}
+	return classes
+}
@@ -0,0 +1,550 @@
+package classfile
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 超出范围的条件跳转被改写为 `if!cond L; goto_w target; L:` 之后，L 成为新的跳转目标，
+// 如果方法有 StackMapTable，就需要在 L 处增加一个栈帧，这里负责推导出这个栈帧
+//
+// 合法的字节码在每条 goto、return、athrow、switch 之后都有栈帧，所以从跳转指令之前最近的栈帧（没有时为方法入口）
+// 到跳转指令是一段直线代码，按顺序模拟每条指令对局部变量和操作数栈类型的影响就能得到 L 处的类型，
+// 不需要像完整的类型推导那样在多条路径汇合处计算类型的公共父类（那需要加载类的继承关系）
+
+// 模拟时使用的类型，long 和 double 占两个 slot，第二个 slot 为 ITEM_Top
+// ITEM_Uninitialized 直接引用 new 指令，因为 Commit() 完成之前指令的 pc 还会变化
+type verificationType struct {
+	tag     uint8
+	cpIndex uint16       // ITEM_Object 的 CONSTANT_Class_info 索引
+	newInsn *Instruction // ITEM_Uninitialized 对应的 new 指令
+}
+
+var (
+	topType    = verificationType{tag: ITEM_Top}
+	intType    = verificationType{tag: ITEM_Integer}
+	floatType  = verificationType{tag: ITEM_Float}
+	longType   = verificationType{tag: ITEM_Long}
+	doubleType = verificationType{tag: ITEM_Double}
+	nullType   = verificationType{tag: ITEM_Null}
+)
+
+// load、store、数组和算术指令按照 i、l、f、d 的顺序排列
+var primitiveTypes = [4]verificationType{intType, longType, floatType, doubleType}
+
+func (self verificationType) isCategory2() bool {
+	return self.tag == ITEM_Long || self.tag == ITEM_Double
+}
+
+// 某条指令处局部变量表和操作数栈中的类型，都按 slot 保存
+type frameState struct {
+	locals []verificationType
+	stack  []verificationType
+}
+
+func (self *frameState) clone() *frameState {
+	return &frameState{
+		locals: append([]verificationType{}, self.locals...),
+		stack:  append([]verificationType{}, self.stack...),
+	}
+}
+
+func (self *frameState) push(t verificationType) {
+	self.stack = append(self.stack, t)
+	if t.isCategory2() {
+		self.stack = append(self.stack, topType)
+	}
+}
+
+// 弹出一个值，long 和 double 弹出两个 slot
+func (self *frameState) pop() verificationType {
+	n := len(self.stack)
+	if n >= 2 && self.stack[n-1] == topType && self.stack[n-2].isCategory2() {
+		t := self.stack[n-2]
+		self.stack = self.stack[:n-2]
+		return t
+	}
+	self.popSlots(1)
+	return self.stack[:n][n-1] // popSlots() 只缩短了切片，底层数组中的值还在
+}
+
+func (self *frameState) popSlots(n int) {
+	if len(self.stack) < n {
+		panic(fmt.Errorf("operand stack underflow while computing stack map frame"))
+	}
+	self.stack = self.stack[:len(self.stack)-n]
+}
+
+// 复制栈顶 n 个 slot，插入到它们下面 depth 个 slot 之下，对应 dup、dup_x1、dup2_x2 等指令
+func (self *frameState) dup(n, depth int) {
+	at := len(self.stack) - n - depth
+	if at < 0 {
+		panic(fmt.Errorf("operand stack underflow while computing stack map frame"))
+	}
+	top := append([]verificationType{}, self.stack[len(self.stack)-n:]...)
+	self.stack = append(self.stack[:at], append(top, self.stack[at:]...)...)
+}
+
+func (self *frameState) local(index int) verificationType {
+	if index >= len(self.locals) {
+		return topType
+	}
+	return self.locals[index]
+}
+
+// 写入局部变量会破坏覆盖到的 long 或 double
+func (self *frameState) setLocal(index int, t verificationType) {
+	size := 1
+	if t.isCategory2() {
+		size = 2
+	}
+	for len(self.locals) < index+size {
+		self.locals = append(self.locals, topType)
+	}
+	if index > 0 && self.locals[index-1].isCategory2() {
+		self.locals[index-1] = topType
+	}
+	self.locals[index] = t
+	if size == 2 {
+		self.locals[index+1] = topType
+	}
+}
+
+// 构造方法调用之后，所有未初始化的 this 或 new 出来的对象都变为已初始化的类型
+func (self *frameState) initialize(from, to verificationType) {
+	for _, types := range [][]verificationType{self.locals, self.stack} {
+		for i := range types {
+			if types[i] == from {
+				types[i] = to
+			}
+		}
+	}
+}
+
+func equalTypes(a, b []verificationType) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// 为改写后的条件跳转的下一条指令（新的跳转目标）增加栈帧，已经有栈帧时不需要
+func (self *InstructionList) addFallThroughFrame(branch *Instruction) {
+	target := branch.next
+	order := map[*Instruction]int{}
+	i := 0
+	for insn := self.first; insn != nil; insn = insn.next {
+		order[insn] = i
+		i++
+	}
+	frames, states := self.expandFrames(order)
+
+	prev := -1 // branch 之前（含 branch）最近的栈帧
+	for i, f := range frames {
+		if f.at == target {
+			return
+		}
+		if order[f.at] <= order[branch] {
+			prev = i
+		}
+	}
+	start, base := self.first, self.initialFrameState()
+	if prev >= 0 {
+		start, base = frames[prev].at, states[prev]
+	}
+	state := base.clone()
+	for insn := start; insn != branch; insn = insn.next {
+		self.execute(state, insn)
+	}
+	self.execute(state, branch)
+
+	frame := self.newFrame(base, state)
+	// 后一个栈帧原来相对于 base 编码，局部变量不同时需要改为 full_frame
+	if frame.frameType == 255 && prev+1 < len(frames) && frames[prev+1].frame.frameType != 255 {
+		self.toFullFrame(frames[prev+1].frame, states[prev+1])
+	}
+	self.frames = append(self.frames, &frameRef{at: target, frame: frame})
+}
+
+// 按照指令在链表中的顺序排列栈帧，并依次展开，得到每个栈帧处完整的类型
+func (self *InstructionList) expandFrames(order map[*Instruction]int) ([]*frameRef, []*frameState) {
+	frames := make([]*frameRef, len(self.frames))
+	copy(frames, self.frames)
+	for _, f := range frames {
+		if f.at == nil {
+			panic(fmt.Errorf("stack map frame points past the end of code"))
+		}
+	}
+	sort.SliceStable(frames, func(i, j int) bool { return order[frames[i].at] < order[frames[j].at] })
+
+	uninitialized := map[*VerificationTypeInfo]*Instruction{}
+	for _, u := range self.uninitialized {
+		uninitialized[u.info] = u.newInsn
+	}
+	states := make([]*frameState, len(frames))
+	state := self.initialFrameState()
+	for i, f := range frames {
+		state = applyFrame(state, f.frame, uninitialized)
+		states[i] = state
+	}
+	return frames, states
+}
+
+// 方法入口处的隐式栈帧：this（构造方法中为 UninitializedThis）和参数
+func (self *InstructionList) initialFrameState() *frameState {
+	state := &frameState{}
+	if self.method.accessFlags&ACC_STATIC == 0 {
+		if self.method.Name() == "<init>" && self.cf.ClassName() != "java/lang/Object" {
+			state.locals = append(state.locals, verificationType{tag: ITEM_UninitializedThis})
+		} else {
+			state.locals = append(state.locals, verificationType{tag: ITEM_Object, cpIndex: self.cf.thisClass})
+		}
+	}
+	params, _ := methodDescriptorTypes(self.method.Descriptor())
+	for _, param := range params {
+		state.setLocal(len(state.locals), self.descriptorType(param))
+	}
+	return state
+}
+
+// 在前一个栈帧的基础上展开 frame
+func applyFrame(prev *frameState, frame *StackMapFrame, uninitialized map[*VerificationTypeInfo]*Instruction) *frameState {
+	state := &frameState{locals: append([]verificationType{}, prev.locals...)}
+	switch t := frame.frameType; {
+	case t <= 63 || t == 251:
+	case t <= 127 || t == 247:
+		state.stack = typesOf(frame.stack, uninitialized)
+	case t <= 250:
+		for n := int(251 - t); n > 0; n-- {
+			if len(state.locals) == 0 {
+				panic(fmt.Errorf("chop_frame removes more locals than the previous frame has"))
+			}
+			state.locals = state.locals[:len(state.locals)-1]
+			if n := len(state.locals); n > 0 && state.locals[n-1].isCategory2() {
+				state.locals = state.locals[:n-1]
+			}
+		}
+	case t <= 254:
+		state.locals = append(state.locals, typesOf(frame.locals, uninitialized)...)
+	default:
+		state.locals = typesOf(frame.locals, uninitialized)
+		state.stack = typesOf(frame.stack, uninitialized)
+	}
+	return state
+}
+
+func typesOf(infos []*VerificationTypeInfo, uninitialized map[*VerificationTypeInfo]*Instruction) []verificationType {
+	var types []verificationType
+	for _, info := range infos {
+		t := verificationType{tag: info.tag}
+		switch info.tag {
+		case ITEM_Object:
+			t.cpIndex = info.value
+		case ITEM_Uninitialized:
+			t.newInsn = uninitialized[info]
+		}
+		types = append(types, t)
+		if t.isCategory2() {
+			types = append(types, topType)
+		}
+	}
+	return types
+}
+
+// 局部变量与 base 相同时使用 same_frame 或 same_locals_1_stack_item_frame，否则使用 full_frame
+// frame_type 中的 offset_delta 由 Commit() 计算
+func (self *InstructionList) newFrame(base, state *frameState) *StackMapFrame {
+	stack := self.typeInfos(state.stack)
+	if equalTypes(base.locals, state.locals) && len(stack) <= 1 {
+		if len(stack) == 0 {
+			return &StackMapFrame{frameType: 0}
+		}
+		return &StackMapFrame{frameType: 64, stack: stack}
+	}
+	locals := state.locals
+	for n := len(locals); n > 0 && locals[n-1] == topType && !(n >= 2 && locals[n-2].isCategory2()); n-- {
+		locals = locals[:n-1]
+	}
+	return &StackMapFrame{frameType: 255, locals: self.typeInfos(locals), stack: stack}
+}
+
+// 把栈帧改写为 full_frame，局部变量末尾的 ITEM_Top 也保留，使之后的 chop_frame 含义不变
+func (self *InstructionList) toFullFrame(frame *StackMapFrame, state *frameState) {
+	old := map[*VerificationTypeInfo]bool{}
+	for _, info := range append(append([]*VerificationTypeInfo{}, frame.locals...), frame.stack...) {
+		old[info] = true
+	}
+	uninitialized := self.uninitialized[:0]
+	for _, u := range self.uninitialized {
+		if !old[u.info] {
+			uninitialized = append(uninitialized, u)
+		}
+	}
+	self.uninitialized = uninitialized
+
+	frame.frameType = 255
+	frame.locals = self.typeInfos(state.locals)
+	frame.stack = self.typeInfos(state.stack)
+}
+
+// 把按 slot 保存的类型转换为 verification_type_info，long 和 double 只占一项
+func (self *InstructionList) typeInfos(types []verificationType) []*VerificationTypeInfo {
+	var infos []*VerificationTypeInfo
+	for i := 0; i < len(types); i++ {
+		t := types[i]
+		info := &VerificationTypeInfo{tag: t.tag, value: t.cpIndex}
+		if t.tag == ITEM_Uninitialized {
+			if t.newInsn == nil {
+				panic(fmt.Errorf("uninitialized verification type no longer points at a new instruction"))
+			}
+			self.uninitialized = append(self.uninitialized, &uninitializedRef{newInsn: t.newInsn, info: info})
+		}
+		infos = append(infos, info)
+		if t.isCategory2() {
+			i++
+		}
+	}
+	return infos
+}
+
+// 模拟一条顺序执行到下一条指令的指令；goto、return、athrow、switch、jsr 和 ret 之后必须有栈帧，不会出现在直线代码中
+func (self *InstructionList) execute(state *frameState, insn *Instruction) {
+	op := insn.opcode
+	info := opcodeTable[op]
+	if t, ok := fixedResultType(op); ok {
+		state.popSlots(int(info.pop))
+		state.push(t)
+		return
+	}
+	switch {
+	case op == OP_nop || op == OP_iinc:
+	case op == OP_ldc || op == OP_ldc_w || op == OP_ldc2_w:
+		state.push(self.constantType(insn))
+	case op == OP_aload || op >= OP_aload_0 && op <= OP_aload_3:
+		index, _ := insn.localIndex()
+		state.push(state.local(index))
+	case op == OP_aaload:
+		state.pop()
+		state.push(self.componentType(state.pop()))
+	case op >= OP_istore && op <= OP_astore_3:
+		index, _ := insn.localIndex()
+		state.setLocal(index, state.pop())
+	case op >= OP_dup && op <= OP_dup2_x2:
+		n := int(op-OP_dup)/3 + 1
+		state.dup(n, int(op-OP_dup)%3)
+	case op == OP_swap:
+		state.dup(1, 1)
+		state.popSlots(1)
+	case op == OP_getstatic || op == OP_getfield:
+		_, descriptor := self.memberref(insn)
+		if op == OP_getfield {
+			state.pop()
+		}
+		state.push(self.descriptorType(descriptor))
+	case op == OP_putstatic || op == OP_putfield:
+		state.pop()
+		if op == OP_putfield {
+			state.pop()
+		}
+	case op >= OP_invokevirtual && op <= OP_invokedynamic:
+		name, descriptor := self.memberref(insn)
+		params, returnType := methodDescriptorTypes(descriptor)
+		for range params {
+			state.pop()
+		}
+		if op != OP_invokestatic && op != OP_invokedynamic {
+			receiver := state.pop()
+			if op == OP_invokespecial && name == "<init>" {
+				state.initialize(receiver, self.initializedType(receiver))
+			}
+		}
+		if returnType != "V" {
+			state.push(self.descriptorType(returnType))
+		}
+	case op == OP_new:
+		state.push(verificationType{tag: ITEM_Uninitialized, newInsn: insn})
+	case op == OP_newarray:
+		state.pop()
+		atype := int(insn.operands[0])
+		if atype < 4 || atype > 11 {
+			panic(fmt.Errorf("invalid newarray type %d at pc %d", atype, insn.pc))
+		}
+		state.push(self.objectType("[" + "ZCFDBSIJ"[atype-4:atype-3]))
+	case op == OP_anewarray:
+		state.pop()
+		name := self.cf.constantPool.getClassName(insn.CpIndex())
+		if strings.HasPrefix(name, "[") {
+			state.push(self.objectType("[" + name))
+		} else {
+			state.push(self.objectType("[L" + name + ";"))
+		}
+	case op == OP_checkcast:
+		state.pop()
+		state.push(verificationType{tag: ITEM_Object, cpIndex: insn.CpIndex()})
+	case op == OP_multianewarray:
+		state.popSlots(int(insn.operands[2]))
+		state.push(verificationType{tag: ITEM_Object, cpIndex: insn.CpIndex()})
+	case insn.fallsThrough() && info.pop >= 0 && info.push == 0:
+		// 条件跳转、数组写入、pop、pop2、monitorenter、monitorexit 只弹出操作数
+		state.popSlots(int(info.pop))
+	default:
+		panic(fmt.Errorf("cannot compute stack map frame after %s at pc %d", info.name, insn.pc))
+	}
+}
+
+// 弹出 opcodeTable 中给出的 slot 数，再压入一个固定类型的值的指令
+func fixedResultType(op uint8) (verificationType, bool) {
+	switch {
+	case op == OP_aconst_null:
+		return nullType, true
+	case op >= OP_iconst_m1 && op <= OP_iconst_5 || op == OP_bipush || op == OP_sipush:
+		return intType, true
+	case op == OP_lconst_0 || op == OP_lconst_1:
+		return longType, true
+	case op >= OP_fconst_0 && op <= OP_fconst_2:
+		return floatType, true
+	case op == OP_dconst_0 || op == OP_dconst_1:
+		return doubleType, true
+	case op >= OP_iload && op <= OP_dload:
+		return primitiveTypes[op-OP_iload], true
+	case op >= OP_iload_0 && op <= OP_dload_3:
+		return primitiveTypes[(op-OP_iload_0)/4], true
+	case op >= OP_iaload && op <= OP_daload:
+		return primitiveTypes[op-OP_iaload], true
+	case op >= OP_baload && op <= OP_saload:
+		return intType, true
+	case op >= OP_iadd && op <= OP_dneg:
+		return primitiveTypes[(op-OP_iadd)%4], true
+	case op >= OP_ishl && op <= OP_lxor:
+		return primitiveTypes[(op-OP_ishl)%2], true
+	case op >= OP_i2l && op <= OP_i2s:
+		return conversionTypes[op-OP_i2l], true
+	case op >= OP_lcmp && op <= OP_dcmpg || op == OP_arraylength || op == OP_instanceof:
+		return intType, true
+	}
+	return verificationType{}, false
+}
+
+// i2l、i2f、i2d、l2i、l2f、l2d、f2i、f2l、f2d、d2i、d2l、d2f、i2b、i2c、i2s 的结果类型
+var conversionTypes = [...]verificationType{
+	longType, floatType, doubleType, intType, floatType, doubleType, intType, longType,
+	doubleType, intType, longType, floatType, intType, intType, intType,
+}
+
+// 执行完之后不会顺序执行下一条指令的指令
+func (self *Instruction) fallsThrough() bool {
+	switch self.opcode {
+	case OP_goto, OP_goto_w, OP_jsr, OP_jsr_w, OP_ret, OP_tableswitch, OP_lookupswitch,
+		OP_ireturn, OP_lreturn, OP_freturn, OP_dreturn, OP_areturn, OP_return, OP_athrow:
+		return false
+	}
+	return true
+}
+
+// ldc、ldc_w、ldc2_w 压入的类型，CONSTANT_Dynamic 的类型由它的描述符给出
+func (self *InstructionList) constantType(insn *Instruction) verificationType {
+	var cpIndex uint16
+	if insn.opcode == OP_ldc {
+		cpIndex = uint16(insn.operands[0])
+	} else {
+		cpIndex = binary.BigEndian.Uint16(insn.operands)
+	}
+	switch c := self.cf.constantPool.getConstantInfo(cpIndex).(type) {
+	case *ConstantIntegerInfo:
+		return intType
+	case *ConstantFloatInfo:
+		return floatType
+	case *ConstantLongInfo:
+		return longType
+	case *ConstantDoubleInfo:
+		return doubleType
+	case *ConstantStringInfo:
+		return self.objectType("java/lang/String")
+	case *ConstantClassInfo:
+		return self.objectType("java/lang/Class")
+	case *ConstantMethodTypeInfo:
+		return self.objectType("java/lang/invoke/MethodType")
+	case *ConstantMethodHandleInfo:
+		return self.objectType("java/lang/invoke/MethodHandle")
+	case *ConstantDynamicInfo:
+		_, descriptor := c.NameAndDescriptor()
+		return self.descriptorType(descriptor)
+	}
+	panic(fmt.Errorf("%s at pc %d does not reference a loadable constant", insn.Name(), insn.pc))
+}
+
+// aaload 从数组中取出的元素类型
+func (self *InstructionList) componentType(array verificationType) verificationType {
+	if array.tag == ITEM_Null {
+		return nullType
+	}
+	if array.tag == ITEM_Object {
+		if name := self.cf.constantPool.getClassName(array.cpIndex); strings.HasPrefix(name, "[") {
+			return self.descriptorType(name[1:])
+		}
+	}
+	panic(fmt.Errorf("aaload on a value that is not an array while computing stack map frame"))
+}
+
+// 构造方法调用之后 UninitializedThis 变为当前类，Uninitialized 变为 new 指令创建的类
+func (self *InstructionList) initializedType(t verificationType) verificationType {
+	switch t.tag {
+	case ITEM_UninitializedThis:
+		return verificationType{tag: ITEM_Object, cpIndex: self.cf.thisClass}
+	case ITEM_Uninitialized:
+		if t.newInsn == nil {
+			panic(fmt.Errorf("uninitialized verification type no longer points at a new instruction"))
+		}
+		return verificationType{tag: ITEM_Object, cpIndex: t.newInsn.CpIndex()}
+	}
+	return t
+}
+
+// 字段描述符对应的类型，boolean、byte、char、short 都按 int 处理
+func (self *InstructionList) descriptorType(descriptor string) verificationType {
+	switch descriptor[0] {
+	case 'B', 'C', 'I', 'S', 'Z':
+		return intType
+	case 'F':
+		return floatType
+	case 'J':
+		return longType
+	case 'D':
+		return doubleType
+	case 'L':
+		return self.objectType(descriptor[1 : len(descriptor)-1])
+	}
+	return self.objectType(descriptor)
+}
+
+// ITEM_Object 需要引用 CONSTANT_Class_info，常量池中没有时追加
+func (self *InstructionList) objectType(className string) verificationType {
+	return verificationType{tag: ITEM_Object, cpIndex: self.cf.AddClass(className)}
+}
+
+// 把方法描述符拆分为参数和返回值的字段描述符，如 (I[Ljava/lang/String;)V 返回 [I [Ljava/lang/String;] 和 V
+func methodDescriptorTypes(descriptor string) ([]string, string) {
+	var params []string
+	i := 1 // 跳过 '('
+	for descriptor[i] != ')' {
+		start := i
+		for descriptor[i] == '[' {
+			i++
+		}
+		if descriptor[i] == 'L' {
+			for descriptor[i] != ';' {
+				i++
+			}
+		}
+		i++
+		params = append(params, descriptor[start:i])
+	}
+	return params, descriptor[i+1:]
+}
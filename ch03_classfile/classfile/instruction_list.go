@@ -0,0 +1,1145 @@
+package classfile
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Code 属性中的字节码是一个紧凑的 byte 数组，跳转指令、异常处理表、LineNumberTable、LocalVariableTable
+// 和 StackMapTable 中都保存着 pc（字节码偏移量），直接在字节数组中插入或删除指令会让这些 pc 全部失效
+//
+// InstructionList 把字节码解码成一个双向链表，链表中所有引用 pc 的地方都改为引用具体的 Instruction，
+// 调用者可以随意插入、删除、移动指令，最后调用 Commit() 重新计算 pc、跳转偏移量、switch 填充，
+// 并把异常处理表、调试信息、StackMapTable、max_stack 和 max_locals 写回 Code 属性
+//
+// 使用时需要注意以下几点：
+// 1. 插入的探针代码应当是不含跳转的直线代码，且执行前后操作数栈保持不变，否则 StackMapTable 将不再正确；
+//    探针中的跳转目标和异常处理入口没有栈帧时 Commit() 返回错误，超出范围的条件跳转被改写后需要的栈帧则由 Commit() 推导
+// 2. 探针可以使用超出原有 max_locals 的局部变量，Commit() 会自动扩大 max_locals
+// 3. 如果插入的代码引用了新的常量，需要先通过 ClassFile.AddXxx() 方法把常量加入常量池
+type InstructionList struct {
+	cf            *ClassFile
+	method        *MemberInfo
+	codeAttr      *CodeAttribute
+	first         *Instruction
+	last          *Instruction
+	handlers      []*handlerRef
+	lines         []*lineRef
+	localVars     []*localVarRef
+	frames        []*frameRef
+	uninitialized []*uninitializedRef
+	maxStack      int // SetMaxStack() 指定的值，-1 表示由 Commit() 计算
+	maxLocals     int // SetMaxLocals() 指定的值，-1 表示由 Commit() 计算
+}
+
+// Instruction 表示一条指令，跳转目标以 *Instruction 的形式保存，不再保存偏移量
+// operands 中保存除跳转偏移量之外的操作数，wide 前缀不包含在 operands 中
+type Instruction struct {
+	opcode        uint8
+	wide          bool
+	operands      []byte
+	target        *Instruction   // 条件跳转、goto、jsr 的目标
+	defaultTarget *Instruction   // switch 的默认目标
+	low           int32          // tableswitch 的最小值，最大值为 low + len(targets) - 1
+	keys          []int32        // lookupswitch 的 match 值，与 targets 一一对应
+	targets       []*Instruction // switch 各分支的目标
+	pc            int            // 最后一次解码或 Commit() 时的 pc，新插入的指令为 -1
+	prev          *Instruction
+	next          *Instruction
+}
+
+// 异常处理表、调试信息和 StackMapTable 中对指令的引用
+// end 为 nil 时表示字节码末尾
+type handlerRef struct {
+	start     *Instruction
+	end       *Instruction
+	handler   *Instruction
+	catchType uint16
+}
+
+type lineRef struct {
+	start *Instruction
+	entry *LineNumberTableEntry
+}
+
+// LocalVariableTable 和 LocalVariableTypeTable 结构相同，这里直接引用表项的 start_pc 和 length 字段
+type localVarRef struct {
+	start   *Instruction
+	end     *Instruction
+	startPc *uint16
+	length  *uint16
+}
+
+type frameRef struct {
+	at    *Instruction
+	frame *StackMapFrame
+}
+
+// ITEM_Uninitialized 引用的 new 指令
+type uninitializedRef struct {
+	newInsn *Instruction
+	info    *VerificationTypeInfo
+}
+
+// 把方法的字节码解码为 InstructionList，抽象方法和本地方法没有 Code 属性，返回错误
+// 这里和 Parse() 一样使用 panic - recover 处理格式错误
+func NewInstructionList(cf *ClassFile, method *MemberInfo) (list *InstructionList, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			var ok bool
+			err, ok = r.(error)
+			if !ok {
+				err = fmt.Errorf("%v", r)
+			}
+			list = nil
+		}
+	}()
+
+	codeAttr := method.CodeAttribute()
+	if codeAttr == nil {
+		return nil, errors.New("method has no Code attribute: " + method.Name())
+	}
+	list = &InstructionList{cf: cf, method: method, codeAttr: codeAttr, maxStack: -1, maxLocals: -1}
+	list.decode(codeAttr.code)
+	return
+}
+
+// 解码字节码，先按顺序解码出所有指令，再把跳转偏移量和各种表中的 pc 转换为对指令的引用
+func (self *InstructionList) decode(code []byte) {
+	byPc := map[int]*Instruction{}
+	branchPcs := map[*Instruction][]int{} // 第一项为跳转目标或 default，其余为 switch 各分支目标
+
+	for pc := 0; pc < len(code); {
+		insn, targetPcs, next := decodeInstruction(code, pc)
+		byPc[pc] = insn
+		if targetPcs != nil {
+			branchPcs[insn] = targetPcs
+		}
+		self.append(insn)
+		pc = next
+	}
+
+	// at() 把 pc 转换为指令，pc 等于字节码长度时返回 nil 表示末尾，不在指令边界上的 pc 是非法的
+	at := func(pc int, allowEnd bool) *Instruction {
+		if insn, ok := byPc[pc]; ok {
+			return insn
+		}
+		if allowEnd && pc == len(code) {
+			return nil
+		}
+		panic(fmt.Errorf("java.lang.ClassFormatError: invalid pc %d in Code attribute", pc))
+	}
+
+	for insn, pcs := range branchPcs {
+		switch opcodeTable[insn.opcode].format {
+		case operandBranch, operandBranchWide:
+			insn.target = at(pcs[0], false)
+		default:
+			insn.defaultTarget = at(pcs[0], false)
+			insn.targets = make([]*Instruction, len(pcs)-1)
+			for i, pc := range pcs[1:] {
+				insn.targets[i] = at(pc, false)
+			}
+		}
+	}
+
+	for _, entry := range self.codeAttr.exceptionTable {
+		self.handlers = append(self.handlers, &handlerRef{
+			start:     at(int(entry.startPc), false),
+			end:       at(int(entry.endPc), true),
+			handler:   at(int(entry.handlerPc), false),
+			catchType: entry.catchType,
+		})
+	}
+	if lnt := self.codeAttr.LineNumberTableAttribute(); lnt != nil {
+		for _, entry := range lnt.lineNumberTable {
+			self.lines = append(self.lines, &lineRef{start: at(int(entry.startPc), false), entry: entry})
+		}
+	}
+	if lvt := self.codeAttr.LocalVariableTableAttribute(); lvt != nil {
+		for _, entry := range lvt.localVariableTable {
+			self.localVars = append(self.localVars, &localVarRef{
+				start:   at(int(entry.startPc), false),
+				end:     at(int(entry.startPc)+int(entry.length), true),
+				startPc: &entry.startPc,
+				length:  &entry.length,
+			})
+		}
+	}
+	if lvtt := self.codeAttr.LocalVariableTypeTableAttribute(); lvtt != nil {
+		for _, entry := range lvtt.localVariableTypeTable {
+			self.localVars = append(self.localVars, &localVarRef{
+				start:   at(int(entry.startPc), false),
+				end:     at(int(entry.startPc)+int(entry.length), true),
+				startPc: &entry.startPc,
+				length:  &entry.length,
+			})
+		}
+	}
+	if smt := self.codeAttr.StackMapTableAttribute(); smt != nil {
+		pc := -1
+		for _, frame := range smt.entries {
+			pc += int(frame.offsetDelta) + 1
+			self.frames = append(self.frames, &frameRef{at: at(pc, false), frame: frame})
+			for _, info := range append(append([]*VerificationTypeInfo{}, frame.locals...), frame.stack...) {
+				if info.tag == ITEM_Uninitialized {
+					self.uninitialized = append(self.uninitialized, &uninitializedRef{
+						newInsn: at(int(info.value), false),
+						info:    info,
+					})
+				}
+			}
+		}
+	}
+}
+
+// 解码 pc 处的一条指令，返回指令、跳转目标的绝对 pc（没有跳转时为 nil）和下一条指令的 pc
+func decodeInstruction(code []byte, pc int) (*Instruction, []int, int) {
+	insn := &Instruction{opcode: code[pc], pc: pc}
+	info := opcodeTable[insn.opcode]
+	if info == nil {
+		panic(fmt.Errorf("java.lang.ClassFormatError: invalid opcode 0x%02x at pc %d", insn.opcode, pc))
+	}
+	p := pc + 1
+	operands := func(n int) {
+		insn.operands = append([]byte{}, code[p:p+n]...)
+		p += n
+	}
+	s4 := func() int32 {
+		val := int32(binary.BigEndian.Uint32(code[p:]))
+		p += 4
+		return val
+	}
+
+	switch info.format {
+	case operandNone:
+	case operandByte, operandCpIndex1, operandLocal:
+		operands(1)
+	case operandShort, operandCpIndex, operandIinc:
+		operands(2)
+	case operandMultiANewArray:
+		operands(3)
+	case operandInvokeInterface, operandInvokeDynamic:
+		operands(4)
+	case operandBranch:
+		offset := int16(binary.BigEndian.Uint16(code[p:]))
+		return insn, []int{pc + int(offset)}, p + 2
+	case operandBranchWide:
+		offset := s4()
+		return insn, []int{pc + int(offset)}, p
+	case operandTableSwitch, operandLookupSwitch:
+		p += switchPadding(pc)
+		targetPcs := []int{pc + int(s4())}
+		if info.format == operandTableSwitch {
+			insn.low = s4()
+			high := s4()
+			if high < insn.low {
+				panic(fmt.Errorf("java.lang.ClassFormatError: tableswitch low > high at pc %d", pc))
+			}
+			for i := int64(insn.low); i <= int64(high); i++ {
+				targetPcs = append(targetPcs, pc+int(s4()))
+			}
+		} else {
+			npairs := s4()
+			for i := int32(0); i < npairs; i++ {
+				insn.keys = append(insn.keys, s4())
+				targetPcs = append(targetPcs, pc+int(s4()))
+			}
+		}
+		return insn, targetPcs, p
+	case operandWide:
+		// wide 前缀与后面的指令合并成一条指令
+		insn.wide = true
+		insn.opcode = code[p]
+		p++
+		switch opcodeTable[insn.opcode].format {
+		case operandLocal:
+			operands(2)
+		case operandIinc:
+			operands(4)
+		default:
+			panic(fmt.Errorf("java.lang.ClassFormatError: invalid wide instruction at pc %d", pc))
+		}
+	}
+	return insn, nil, p
+}
+
+// tableswitch 和 lookupswitch 的操作数必须从 4 的倍数处开始
+func switchPadding(pc int) int {
+	return (4 - (pc+1)%4) % 4
+}
+
+// 创建一条没有跳转目标的指令，operands 的长度必须符合指令格式
+// 操作数为局部变量索引的指令可以传入两个字节的索引（或四个字节的 iinc 操作数），此时会自动加上 wide 前缀
+func NewInstruction(opcode uint8, operands ...byte) *Instruction {
+	info := opcodeTable[opcode]
+	if info == nil {
+		panic(fmt.Sprintf("invalid opcode 0x%02x", opcode))
+	}
+	insn := &Instruction{opcode: opcode, operands: operands, pc: -1}
+	expected := map[uint8]int{
+		operandNone: 0, operandByte: 1, operandCpIndex1: 1, operandLocal: 1,
+		operandShort: 2, operandCpIndex: 2, operandIinc: 2,
+		operandMultiANewArray: 3, operandInvokeInterface: 4, operandInvokeDynamic: 4,
+	}
+	n, ok := expected[info.format]
+	if !ok {
+		panic("use NewBranchInstruction for " + info.name)
+	}
+	if (info.format == operandLocal || info.format == operandIinc) && len(operands) == 2*n {
+		insn.wide = true
+	} else if len(operands) != n {
+		panic(fmt.Sprintf("%s expects %d operand bytes, got %d", info.name, n, len(operands)))
+	}
+	return insn
+}
+
+// 创建引用常量池的指令，如 getstatic、invokestatic、ldc 等
+// invokeinterface 的 count 操作数会在 Commit() 时根据方法描述符自动计算
+func NewCpInstruction(opcode uint8, cpIndex uint16) *Instruction {
+	switch opcodeTable[opcode].format {
+	case operandCpIndex1:
+		if cpIndex > 0xFF {
+			panic("ldc index out of range, use ldc_w")
+		}
+		return NewInstruction(opcode, uint8(cpIndex))
+	case operandInvokeInterface, operandInvokeDynamic:
+		return NewInstruction(opcode, uint8(cpIndex>>8), uint8(cpIndex), 0, 0)
+	case operandMultiANewArray:
+		panic("multianewarray needs dimensions, use NewInstruction")
+	}
+	return NewInstruction(opcode, uint8(cpIndex>>8), uint8(cpIndex))
+}
+
+// 创建读写局部变量的指令，索引大于 255 时自动使用 wide 前缀
+func NewLocalInstruction(opcode uint8, index uint16) *Instruction {
+	if index > 0xFF {
+		return NewInstruction(opcode, uint8(index>>8), uint8(index))
+	}
+	return NewInstruction(opcode, uint8(index))
+}
+
+// 创建跳转指令（条件跳转、goto、jsr 及其 _w 形式）
+func NewBranchInstruction(opcode uint8, target *Instruction) *Instruction {
+	info := opcodeTable[opcode]
+	if info == nil || (info.format != operandBranch && info.format != operandBranchWide) {
+		panic(fmt.Sprintf("0x%02x is not a branch instruction", opcode))
+	}
+	return &Instruction{opcode: opcode, target: target, pc: -1}
+}
+
+// 创建 tableswitch 指令，targets 依次对应 low、low + 1、……
+// 跳转目标可以暂时为 nil，之后再通过 SetSwitchTargets() 设置，例如汇编时引用后面的标签
+func NewTableSwitchInstruction(low int32, defaultTarget *Instruction, targets []*Instruction) *Instruction {
+	if int64(low)+int64(len(targets))-1 > 0x7FFFFFFF {
+		panic("tableswitch high out of range")
+	}
+	return &Instruction{opcode: OP_tableswitch, low: low, defaultTarget: defaultTarget, targets: targets, pc: -1}
+}
+
+// 创建 lookupswitch 指令，keys 必须按升序排列，并与 targets 一一对应
+func NewLookupSwitchInstruction(keys []int32, defaultTarget *Instruction, targets []*Instruction) *Instruction {
+	if len(keys) != len(targets) {
+		panic("lookupswitch keys and targets differ in length")
+	}
+	for i := 1; i < len(keys); i++ {
+		if keys[i] <= keys[i-1] {
+			panic("lookupswitch keys must be sorted and unique")
+		}
+	}
+	return &Instruction{opcode: OP_lookupswitch, keys: keys, defaultTarget: defaultTarget, targets: targets, pc: -1}
+}
+
+// 设置跳转指令的目标
+func (self *Instruction) SetTarget(target *Instruction) {
+	if format := opcodeTable[self.opcode].format; format != operandBranch && format != operandBranchWide {
+		panic(self.Name() + " is not a branch instruction")
+	}
+	self.target = target
+}
+
+// 设置 switch 指令的默认目标和各分支目标，targets 的个数不能改变
+func (self *Instruction) SetSwitchTargets(defaultTarget *Instruction, targets []*Instruction) {
+	if self.opcode != OP_tableswitch && self.opcode != OP_lookupswitch {
+		panic(self.Name() + " is not a switch instruction")
+	}
+	if len(targets) != len(self.targets) {
+		panic(fmt.Sprintf("%s has %d targets, got %d", self.Name(), len(self.targets), len(targets)))
+	}
+	self.defaultTarget = defaultTarget
+	self.targets = targets
+}
+
+// getter 方法
+func (self *Instruction) Opcode() uint8 {
+	return self.opcode
+}
+func (self *Instruction) Name() string {
+	return opcodeTable[self.opcode].name
+}
+func (self *Instruction) IsWide() bool {
+	return self.wide
+}
+func (self *Instruction) Operands() []byte {
+	return self.operands
+}
+func (self *Instruction) Target() *Instruction {
+	return self.target
+}
+func (self *Instruction) DefaultTarget() *Instruction {
+	return self.defaultTarget
+}
+func (self *Instruction) SwitchTargets() []*Instruction {
+	return self.targets
+}
+
+// switch 各分支的 case 值，与 SwitchTargets() 一一对应
+func (self *Instruction) SwitchKeys() []int32 {
+	if self.opcode == OP_lookupswitch {
+		return self.keys
+	}
+	keys := make([]int32, len(self.targets))
+	for i := range keys {
+		keys[i] = self.low + int32(i)
+	}
+	return keys
+}
+
+// 指令引用的常量池索引，不引用常量池的指令返回 0
+func (self *Instruction) CpIndex() uint16 {
+	switch opcodeTable[self.opcode].format {
+	case operandCpIndex1:
+		return uint16(self.operands[0])
+	case operandCpIndex, operandInvokeInterface, operandInvokeDynamic, operandMultiANewArray:
+		return binary.BigEndian.Uint16(self.operands)
+	}
+	return 0
+}
+
+// 指令访问的局部变量索引，包括 iload_0 这类隐含索引的指令，不访问局部变量的指令返回 -1
+func (self *Instruction) LocalIndex() int {
+	index, _ := self.localIndex()
+	return index
+}
+
+// 最后一次解码或 Commit() 时的 pc，之后新插入的指令返回 -1
+func (self *Instruction) Pc() int {
+	return self.pc
+}
+func (self *Instruction) Next() *Instruction {
+	return self.next
+}
+func (self *Instruction) Prev() *Instruction {
+	return self.prev
+}
+
+func (self *InstructionList) First() *Instruction {
+	return self.first
+}
+func (self *InstructionList) Last() *Instruction {
+	return self.last
+}
+
+// 在链表末尾追加指令，用于从头生成字节码，参考 ClassFile.NewCode()
+func (self *InstructionList) Append(insns ...*Instruction) {
+	self.link(nil, insns)
+}
+
+// 添加异常处理，保护范围为 [start, end)，end 为 nil 表示到字节码末尾，catchType 为 0 时捕获所有异常
+// 异常处理按照添加的顺序写入异常处理表，JVM 也按照这个顺序查找异常处理
+func (self *InstructionList) AddExceptionHandler(start, end, handler *Instruction, catchType uint16) {
+	self.handlers = append(self.handlers, &handlerRef{start: start, end: end, handler: handler, catchType: catchType})
+}
+
+// 添加行号，表示从 start 开始的指令对应源码的第 line 行，Code 属性没有 LineNumberTable 时会自动添加
+func (self *InstructionList) AddLineNumber(start *Instruction, line uint16) {
+	if self.codeAttr.LineNumberTableAttribute() == nil {
+		self.cf.AddUtf8("LineNumberTable")
+		self.codeAttr.attributes = append(self.codeAttr.attributes, &LineNumberTableAttribute{})
+	}
+	self.lines = append(self.lines, &lineRef{start: start, entry: &LineNumberTableEntry{lineNumber: line}})
+}
+
+// 指定 max_stack 和 max_locals，Commit() 时不再计算，用于生成故意不合法的字节码，
+// 或者 Commit() 无法分析的字节码（例如操作数栈下溢）
+func (self *InstructionList) SetMaxStack(maxStack uint16) {
+	self.maxStack = int(maxStack)
+}
+func (self *InstructionList) SetMaxLocals(maxLocals uint16) {
+	self.maxLocals = int(maxLocals)
+}
+
+// 在链表末尾追加指令
+func (self *InstructionList) append(insn *Instruction) {
+	insn.prev, insn.next = self.last, nil
+	if self.last == nil {
+		self.first = insn
+	} else {
+		self.last.next = insn
+	}
+	self.last = insn
+}
+
+// 把 insns 链接到 at 之前，at 为 nil 时追加到末尾
+func (self *InstructionList) link(at *Instruction, insns []*Instruction) {
+	for _, insn := range insns {
+		if at == nil {
+			self.append(insn)
+			continue
+		}
+		insn.prev, insn.next = at.prev, at
+		if at.prev == nil {
+			self.first = insn
+		} else {
+			at.prev.next = insn
+		}
+		at.prev = insn
+	}
+}
+
+// 从链表中摘除指令
+func (self *InstructionList) unlink(insn *Instruction) {
+	if insn.prev == nil {
+		self.first = insn.next
+	} else {
+		insn.prev.next = insn.next
+	}
+	if insn.next == nil {
+		self.last = insn.prev
+	} else {
+		insn.next.prev = insn.prev
+	}
+	insn.prev, insn.next = nil, nil
+}
+
+// InsertBefore() 在 at 之前插入指令，原来跳转到 at 的指令、从 at 开始的异常处理范围和异常处理入口、
+// 位于 at 的栈帧和行号都会转移到插入的第一条指令上，也就是说插入的代码在任何到达 at 的路径上都会被执行
+// 局部变量的作用域和 ITEM_Uninitialized 不会转移
+func (self *InstructionList) InsertBefore(at *Instruction, insns ...*Instruction) {
+	if len(insns) == 0 {
+		return
+	}
+	self.link(at, insns)
+	if at != nil {
+		self.retarget(at, insns[0], false)
+	}
+}
+
+// InsertAfter() 在 at 之后插入指令，所有的引用保持不变
+func (self *InstructionList) InsertAfter(at *Instruction, insns ...*Instruction) {
+	self.link(at.next, insns)
+}
+
+// InsertAtEntry() 在方法入口插入指令，与 InsertBefore(First()) 不同，跳转回方法开头的循环不会再次执行插入的代码
+func (self *InstructionList) InsertAtEntry(insns ...*Instruction) {
+	self.link(self.first, insns)
+}
+
+// InsertBeforeOpcode() 在每一条操作码为 opcode 的指令之前插入 probe() 返回的指令
+// 每次插入都会重新调用 probe()，因为同一个 Instruction 不能同时出现在链表的多个位置
+func (self *InstructionList) InsertBeforeOpcode(opcode uint8, probe func(insn *Instruction) []*Instruction) {
+	for insn := self.first; insn != nil; insn = insn.next {
+		if insn.opcode == opcode {
+			self.InsertBefore(insn, probe(insn)...)
+		}
+	}
+}
+
+// InsertBeforeExits() 在每一条 return 指令之前插入 probe() 返回的指令，用于实现方法出口探针
+// 注意这里不包括 athrow，需要的话可以额外调用 InsertBeforeOpcode(OP_athrow, ...)
+func (self *InstructionList) InsertBeforeExits(probe func(insn *Instruction) []*Instruction) {
+	for insn := self.first; insn != nil; insn = insn.next {
+		if insn.opcode >= OP_ireturn && insn.opcode <= OP_return {
+			self.InsertBefore(insn, probe(insn)...)
+		}
+	}
+}
+
+// Remove() 删除指令，所有对它的引用都转移到下一条指令
+func (self *InstructionList) Remove(insn *Instruction) {
+	next := insn.next
+	self.unlink(insn)
+	self.retarget(insn, next, true)
+}
+
+// Move() 把指令移动到 before 之前（before 为 nil 时移动到末尾），原来对它的引用转移到它原来的下一条指令，
+// ITEM_Uninitialized 仍然引用这条指令本身
+func (self *InstructionList) Move(insn, before *Instruction) {
+	if insn == before {
+		return
+	}
+	next := insn.next
+	self.unlink(insn)
+	self.retarget(insn, next, false)
+	self.link(before, []*Instruction{insn})
+}
+
+// 把所有对 from 的引用转移到 to，to 为 nil 表示字节码末尾
+// vars 为 true 时同时转移局部变量作用域和 ITEM_Uninitialized
+func (self *InstructionList) retarget(from, to *Instruction, vars bool) {
+	for insn := self.first; insn != nil; insn = insn.next {
+		if insn.target == from {
+			insn.target = to
+		}
+		if insn.defaultTarget == from {
+			insn.defaultTarget = to
+		}
+		for i, target := range insn.targets {
+			if target == from {
+				insn.targets[i] = to
+			}
+		}
+	}
+	for _, h := range self.handlers {
+		if h.start == from {
+			h.start = to
+		}
+		if h.end == from {
+			h.end = to
+		}
+		if h.handler == from {
+			h.handler = to
+		}
+	}
+	for _, l := range self.lines {
+		if l.start == from {
+			l.start = to
+		}
+	}
+	for _, f := range self.frames {
+		if f.at == from {
+			f.at = to
+		}
+	}
+	if vars {
+		for _, v := range self.localVars {
+			if v.start == from {
+				v.start = to
+			}
+			if v.end == from {
+				v.end = to
+			}
+		}
+		for _, u := range self.uninitialized {
+			if u.newInsn == from {
+				u.newInsn = to
+			}
+		}
+	}
+}
+
+// Commit() 重新编码字节码，并把所有引用 pc 的表写回 Code 属性
+// 如果编码失败（例如字节码超过 65535 字节、跳转目标被删除、跳转目标缺少栈帧），Code 属性保持不变并返回错误
+func (self *InstructionList) Commit() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			var ok bool
+			err, ok = r.(error)
+			if !ok {
+				err = fmt.Errorf("%v", r)
+			}
+		}
+	}()
+
+	self.layout()
+	code := self.encode()
+	if len(code) > 0xFFFF {
+		return errors.New("code too large: " + fmt.Sprint(len(code)) + " bytes")
+	}
+	self.fillInvokeInterfaceCounts(code)
+	exceptionTable := self.buildExceptionTable()
+	maxStack, maxLocals := self.maxStack, self.maxLocals
+	if maxStack < 0 {
+		maxStack = self.computeMaxStack()
+	}
+	if maxLocals < 0 {
+		maxLocals = self.computeMaxLocals()
+	}
+	frames := self.sortFrames()
+	self.checkFrameTargets(frames)
+
+	// 所有可能失败的步骤都已完成，下面开始修改 Code 属性
+	self.codeAttr.code = code
+	self.codeAttr.exceptionTable = exceptionTable
+	self.codeAttr.maxStack = uint16(maxStack)
+	self.codeAttr.maxLocals = uint16(maxLocals)
+	self.updateLines()
+	self.updateLocalVars()
+	self.updateFrames(frames)
+	return nil
+}
+
+// 计算每条指令的 pc，并在跳转偏移量超出 16 位范围时改用 goto_w/jsr_w
+// 改用宽跳转会让后面的指令后移，可能导致其它跳转也超出范围，所以需要循环直到不再变化
+func (self *InstructionList) layout() {
+	for {
+		pc := 0
+		for insn := self.first; insn != nil; insn = insn.next {
+			insn.pc = pc
+			pc += insn.size()
+		}
+		if !self.widenBranches() {
+			return
+		}
+	}
+}
+
+// 指令在 pc 处编码后的长度，switch 的长度取决于 pc
+func (self *Instruction) size() int {
+	n := 1 + len(self.operands)
+	if self.wide {
+		n++
+	}
+	switch opcodeTable[self.opcode].format {
+	case operandBranch:
+		n += 2
+	case operandBranchWide:
+		n += 4
+	case operandTableSwitch:
+		n += switchPadding(self.pc) + 12 + 4*len(self.targets)
+	case operandLookupSwitch:
+		n += switchPadding(self.pc) + 8 + 8*len(self.targets)
+	}
+	return n
+}
+
+// 条件跳转指令的反转，用于把超出范围的条件跳转改写为 `if!cond L; goto_w target; L:`
+var invertedBranches = map[uint8]uint8{
+	OP_ifeq: OP_ifne, OP_ifne: OP_ifeq, OP_iflt: OP_ifge, OP_ifge: OP_iflt, OP_ifgt: OP_ifle, OP_ifle: OP_ifgt,
+	OP_if_icmpeq: OP_if_icmpne, OP_if_icmpne: OP_if_icmpeq, OP_if_icmplt: OP_if_icmpge,
+	OP_if_icmpge: OP_if_icmplt, OP_if_icmpgt: OP_if_icmple, OP_if_icmple: OP_if_icmpgt,
+	OP_if_acmpeq: OP_if_acmpne, OP_if_acmpne: OP_if_acmpeq, OP_ifnull: OP_ifnonnull, OP_ifnonnull: OP_ifnull,
+}
+
+func (self *InstructionList) widenBranches() bool {
+	changed := false
+	for insn := self.first; insn != nil; insn = insn.next {
+		if opcodeTable[insn.opcode].format != operandBranch {
+			continue
+		}
+		if insn.target == nil {
+			panic(fmt.Errorf("%s at pc %d has no branch target", insn.Name(), insn.pc))
+		}
+		offset := insn.target.pc - insn.pc
+		if offset >= -0x8000 && offset <= 0x7FFF {
+			continue
+		}
+		changed = true
+		switch insn.opcode {
+		case OP_goto:
+			insn.opcode = OP_goto_w
+		case OP_jsr:
+			insn.opcode = OP_jsr_w
+		default:
+			if insn.next == nil {
+				panic(fmt.Errorf("%s at pc %d is the last instruction", insn.Name(), insn.pc))
+			}
+			// 改写条件跳转后，goto_w 之后的指令成为新的跳转目标，需要新的栈帧
+			if self.codeAttr.StackMapTableAttribute() != nil {
+				self.addFallThroughFrame(insn)
+			}
+			gotoW := NewBranchInstruction(OP_goto_w, insn.target)
+			insn.opcode = invertedBranches[insn.opcode]
+			insn.target = insn.next
+			self.link(insn.next, []*Instruction{gotoW})
+		}
+	}
+	return changed
+}
+
+// 按照 layout() 计算出的 pc 编码所有指令
+func (self *InstructionList) encode() []byte {
+	writer := &ClassWriter{}
+	for insn := self.first; insn != nil; insn = insn.next {
+		if insn.wide {
+			writer.writeUint8(OP_wide)
+		}
+		writer.writeUint8(insn.opcode)
+		writer.writeBytes(insn.operands)
+		switch opcodeTable[insn.opcode].format {
+		case operandBranch:
+			writer.writeUint16(uint16(int16(insn.target.pc - insn.pc)))
+		case operandBranchWide:
+			if insn.target == nil {
+				panic(fmt.Errorf("%s at pc %d has no branch target", insn.Name(), insn.pc))
+			}
+			writer.writeUint32(uint32(int32(insn.target.pc - insn.pc)))
+		case operandTableSwitch, operandLookupSwitch:
+			writer.writeBytes(make([]byte, switchPadding(insn.pc)))
+			writer.writeUint32(uint32(int32(insn.switchOffset(insn.defaultTarget))))
+			if insn.opcode == OP_tableswitch {
+				writer.writeUint32(uint32(insn.low))
+				writer.writeUint32(uint32(insn.low + int32(len(insn.targets)) - 1))
+			} else {
+				writer.writeUint32(uint32(len(insn.targets)))
+			}
+			for i, target := range insn.targets {
+				if insn.opcode == OP_lookupswitch {
+					writer.writeUint32(uint32(insn.keys[i]))
+				}
+				writer.writeUint32(uint32(int32(insn.switchOffset(target))))
+			}
+		}
+	}
+	return writer.data
+}
+
+func (self *Instruction) switchOffset(target *Instruction) int {
+	if target == nil {
+		panic(fmt.Errorf("%s at pc %d has no branch target", self.Name(), self.pc))
+	}
+	return target.pc - self.pc
+}
+
+// 新创建的 invokeinterface 指令 count 为 0，这里根据方法描述符计算参数所占的 slot 数（包括 this）
+func (self *InstructionList) fillInvokeInterfaceCounts(code []byte) {
+	for insn := self.first; insn != nil; insn = insn.next {
+		if insn.opcode == OP_invokeinterface && insn.operands[2] == 0 {
+			_, descriptor := self.memberref(insn)
+			argSlots, _ := methodDescriptorSlots(descriptor)
+			insn.operands[2] = uint8(argSlots + 1)
+			code[insn.pc+3] = insn.operands[2]
+		}
+	}
+}
+
+func (self *InstructionList) buildExceptionTable() []*ExceptionTableEntry {
+	var exceptionTable []*ExceptionTableEntry
+	for _, h := range self.handlers {
+		if h.handler == nil {
+			panic(errors.New("exception handler has been removed"))
+		}
+		startPc, endPc := pcOf(h.start, self.last), pcOf(h.end, self.last)
+		if startPc >= endPc {
+			continue // 保护范围内的指令已经全部删除
+		}
+		exceptionTable = append(exceptionTable, &ExceptionTableEntry{
+			startPc:   uint16(startPc),
+			endPc:     uint16(endPc),
+			handlerPc: uint16(h.handler.pc),
+			catchType: h.catchType,
+		})
+	}
+	return exceptionTable
+}
+
+// 指令的 pc，nil 表示字节码末尾
+func pcOf(insn, last *Instruction) int {
+	if insn == nil {
+		if last == nil {
+			return 0
+		}
+		return last.pc + last.size()
+	}
+	return insn.pc
+}
+
+// 按照 pc 排序栈帧，并检查栈帧和 ITEM_Uninitialized 引用的指令是否仍然有效
+func (self *InstructionList) sortFrames() []*frameRef {
+	frames := make([]*frameRef, len(self.frames))
+	copy(frames, self.frames)
+	for _, f := range frames {
+		if f.at == nil {
+			panic(errors.New("stack map frame points past the end of code"))
+		}
+	}
+	sort.SliceStable(frames, func(i, j int) bool { return frames[i].at.pc < frames[j].at.pc })
+	for i := 1; i < len(frames); i++ {
+		if frames[i].at.pc == frames[i-1].at.pc {
+			panic(fmt.Errorf("two stack map frames at pc %d", frames[i].at.pc))
+		}
+	}
+	for _, u := range self.uninitialized {
+		if u.newInsn == nil || u.newInsn.opcode != OP_new {
+			panic(errors.New("uninitialized verification type no longer points at a new instruction"))
+		}
+	}
+	return frames
+}
+
+// 有 StackMapTable 的方法中，每个跳转目标和异常处理入口都必须有栈帧，插入的探针中含有跳转时就会缺少栈帧
+func (self *InstructionList) checkFrameTargets(frames []*frameRef) {
+	if self.codeAttr.StackMapTableAttribute() == nil {
+		return
+	}
+	hasFrame := map[*Instruction]bool{}
+	for _, f := range frames {
+		hasFrame[f.at] = true
+	}
+	check := func(insn, target *Instruction) {
+		if target != nil && !hasFrame[target] {
+			panic(fmt.Errorf("%s at pc %d branches to pc %d, which has no stack map frame", insn.Name(), insn.pc, target.pc))
+		}
+	}
+	for insn := self.first; insn != nil; insn = insn.next {
+		switch opcodeTable[insn.opcode].format {
+		case operandBranch, operandBranchWide:
+			check(insn, insn.target)
+		case operandTableSwitch, operandLookupSwitch:
+			check(insn, insn.defaultTarget)
+			for _, target := range insn.targets {
+				check(insn, target)
+			}
+		}
+	}
+	for _, h := range self.handlers {
+		if pcOf(h.start, self.last) < pcOf(h.end, self.last) && !hasFrame[h.handler] {
+			panic(fmt.Errorf("exception handler at pc %d has no stack map frame", h.handler.pc))
+		}
+	}
+}
+
+// 重新计算每个栈帧的 offset_delta，并更新 ITEM_Uninitialized 中 new 指令的 pc
+func (self *InstructionList) updateFrames(frames []*frameRef) {
+	smt := self.codeAttr.StackMapTableAttribute()
+	if smt == nil {
+		return
+	}
+	smt.entries = make([]*StackMapFrame, len(frames))
+	prevPc := -1
+	for i, f := range frames {
+		f.frame.setOffsetDelta(uint16(f.at.pc - prevPc - 1))
+		prevPc = f.at.pc
+		smt.entries[i] = f.frame
+	}
+	self.frames = frames
+	for _, u := range self.uninitialized {
+		u.info.value = uint16(u.newInsn.pc)
+	}
+}
+
+// 更新 LineNumberTable，起始指令被删除到末尾的表项直接丢弃
+func (self *InstructionList) updateLines() {
+	lnt := self.codeAttr.LineNumberTableAttribute()
+	if lnt == nil {
+		return
+	}
+	var entries []*LineNumberTableEntry
+	var refs []*lineRef
+	for _, l := range self.lines {
+		if l.start == nil {
+			continue
+		}
+		l.entry.startPc = uint16(l.start.pc)
+		entries = append(entries, l.entry)
+		refs = append(refs, l)
+	}
+	lnt.lineNumberTable = entries
+	self.lines = refs
+}
+
+// 更新 LocalVariableTable 和 LocalVariableTypeTable 的 start_pc 和 length
+func (self *InstructionList) updateLocalVars() {
+	for _, v := range self.localVars {
+		startPc, endPc := pcOf(v.start, self.last), pcOf(v.end, self.last)
+		if startPc > endPc {
+			startPc = endPc
+		}
+		*v.startPc = uint16(startPc)
+		*v.length = uint16(endPc - startPc)
+	}
+}
+
+// 通过数据流分析计算操作数栈的最大深度：从方法入口和每个异常处理入口（栈中只有一个异常对象）出发，
+// 沿着顺序执行和跳转的路径传播栈深度
+//
+// jsr 跳转到子程序时压入返回地址，子程序通过 ret 返回到 jsr 的下一条指令，
+// 这里假设子程序返回时栈深度与调用 jsr 之前相同
+func (self *InstructionList) computeMaxStack() int {
+	depths := map[*Instruction]int{}
+	var worklist []*Instruction
+	visit := func(insn *Instruction, depth int) {
+		if insn == nil {
+			return
+		}
+		if _, ok := depths[insn]; !ok {
+			depths[insn] = depth
+			worklist = append(worklist, insn)
+		}
+	}
+
+	maxStack := 0
+	visit(self.first, 0)
+	for _, h := range self.handlers {
+		visit(h.handler, 1)
+		if maxStack < 1 {
+			maxStack = 1
+		}
+	}
+	for len(worklist) > 0 {
+		insn := worklist[len(worklist)-1]
+		worklist = worklist[:len(worklist)-1]
+
+		depth := depths[insn]
+		pop, push := self.stackEffect(insn)
+		if depth < pop {
+			panic(fmt.Errorf("operand stack underflow at %s (pc %d)", insn.Name(), insn.pc))
+		}
+		after := depth - pop + push
+		if after > maxStack {
+			maxStack = after
+		}
+
+		switch insn.opcode {
+		case OP_jsr, OP_jsr_w:
+			visit(insn.target, after)
+			visit(insn.next, depth)
+		case OP_goto, OP_goto_w:
+			visit(insn.target, after)
+		case OP_tableswitch, OP_lookupswitch:
+			visit(insn.defaultTarget, after)
+			for _, target := range insn.targets {
+				visit(target, after)
+			}
+		case OP_ireturn, OP_lreturn, OP_freturn, OP_dreturn, OP_areturn, OP_return, OP_athrow, OP_ret:
+		default:
+			if insn.target != nil {
+				visit(insn.target, after)
+			}
+			visit(insn.next, after)
+		}
+	}
+	return maxStack
+}
+
+// 计算指令弹出和压入的 slot 数，字段和方法指令需要查找常量池中的描述符
+func (self *InstructionList) stackEffect(insn *Instruction) (int, int) {
+	info := opcodeTable[insn.opcode]
+	if info.pop >= 0 && info.push >= 0 {
+		return int(info.pop), int(info.push)
+	}
+	switch insn.opcode {
+	case OP_ldc, OP_ldc_w:
+		var cpIndex uint16
+		if insn.opcode == OP_ldc {
+			cpIndex = uint16(insn.operands[0])
+		} else {
+			cpIndex = binary.BigEndian.Uint16(insn.operands)
+		}
+		switch self.cf.constantPool.getConstantInfo(cpIndex).(type) {
+		case *ConstantLongInfo, *ConstantDoubleInfo:
+			return 0, 2
+		}
+		return 0, 1
+	case OP_getstatic, OP_putstatic, OP_getfield, OP_putfield:
+		_, descriptor := self.memberref(insn)
+		size := fieldDescriptorSlots(descriptor)
+		switch insn.opcode {
+		case OP_getstatic:
+			return 0, size
+		case OP_putstatic:
+			return size, 0
+		case OP_getfield:
+			return 1, size
+		default:
+			return 1 + size, 0
+		}
+	case OP_invokevirtual, OP_invokespecial, OP_invokeinterface, OP_invokestatic, OP_invokedynamic:
+		_, descriptor := self.memberref(insn)
+		argSlots, returnSlots := methodDescriptorSlots(descriptor)
+		if insn.opcode != OP_invokestatic && insn.opcode != OP_invokedynamic {
+			argSlots++ // this
+		}
+		return argSlots, returnSlots
+	case OP_multianewarray:
+		return int(insn.operands[2]), 1
+	}
+	panic(fmt.Errorf("unknown stack effect of %s", info.name))
+}
+
+// 查找字段、方法或 invokedynamic 指令引用的名称和描述符
+func (self *InstructionList) memberref(insn *Instruction) (string, string) {
+	cpIndex := binary.BigEndian.Uint16(insn.operands)
+	switch ref := self.cf.constantPool.getConstantInfo(cpIndex).(type) {
+	case *ConstantFieldrefInfo:
+		return ref.NameAndDescriptor()
+	case *ConstantMethodrefInfo:
+		return ref.NameAndDescriptor()
+	case *ConstantInterfaceMethodrefInfo:
+		return ref.NameAndDescriptor()
+	case *ConstantInvokeDynamicInfo:
+		return ref.NameAndDescriptor()
+	}
+	panic(fmt.Errorf("%s at pc %d does not reference a member", insn.Name(), insn.pc))
+}
+
+// 局部变量表大小取原来的 max_locals（其中包含了方法参数）和所有指令用到的最大局部变量索引中的较大值
+func (self *InstructionList) computeMaxLocals() int {
+	maxLocals := int(self.codeAttr.maxLocals)
+	for insn := self.first; insn != nil; insn = insn.next {
+		index, size := insn.localIndex()
+		if index >= 0 && index+size > maxLocals {
+			maxLocals = index + size
+		}
+	}
+	return maxLocals
+}
+
+// 返回指令访问的局部变量索引和大小，不访问局部变量的指令返回 -1
+func (self *Instruction) localIndex() (int, int) {
+	op := self.opcode
+	switch {
+	case op >= OP_iload_0 && op <= OP_aload_3:
+		n := int(op - OP_iload_0)
+		return n % 4, slotsOfKind(n / 4)
+	case op >= OP_istore_0 && op <= OP_astore_3:
+		n := int(op - OP_istore_0)
+		return n % 4, slotsOfKind(n / 4)
+	case op >= OP_iload && op <= OP_aload:
+		return self.localOperand(), slotsOfKind(int(op - OP_iload))
+	case op >= OP_istore && op <= OP_astore:
+		return self.localOperand(), slotsOfKind(int(op - OP_istore))
+	case op == OP_iinc || op == OP_ret:
+		return self.localOperand(), 1
+	}
+	return -1, 0
+}
+
+// load/store 指令按照 i、l、f、d、a 的顺序排列，l 和 d 占两个 slot
+func slotsOfKind(kind int) int {
+	if kind == 1 || kind == 3 {
+		return 2
+	}
+	return 1
+}
+
+func (self *Instruction) localOperand() int {
+	if self.wide {
+		return int(binary.BigEndian.Uint16(self.operands))
+	}
+	return int(self.operands[0])
+}
+
+// 字段描述符所占的 slot 数，long 和 double 占两个
+func fieldDescriptorSlots(descriptor string) int {
+	if descriptor == "J" || descriptor == "D" {
+		return 2
+	}
+	return 1
+}
+
+// 计算方法描述符中参数和返回值所占的 slot 数，如 (IJ[Ljava/lang/String;)D 返回 4, 2
+func methodDescriptorSlots(descriptor string) (int, int) {
+	argSlots := 0
+	i := 1 // 跳过 '('
+	for descriptor[i] != ')' {
+		start := i
+		for descriptor[i] == '[' {
+			i++
+		}
+		if descriptor[i] == 'L' {
+			for descriptor[i] != ';' {
+				i++
+			}
+		}
+		i++
+		argSlots += fieldDescriptorSlots(descriptor[start:i])
+	}
+	returnType := descriptor[i+1:]
+	if returnType == "V" {
+		return argSlots, 0
+	}
+	return argSlots, fieldDescriptorSlots(returnType)
+}
@@ -0,0 +1,102 @@
+package classfile
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// testdata/Branch.class 中只有一个方法 static int m(int)，条件跳转之前写入了局部变量，跳转目标处是一个 append_frame：
+//
+//	lconst_0; lstore_1; iload_0; ifeq L; iconst_1; ireturn; L: iconst_0; ireturn
+//	StackMapTable: append_frame [long] at L
+func newBranchMethod(t *testing.T) (*ClassFile, *InstructionList) {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "Branch.class"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := NewInstructionList(cf, cf.Methods()[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cf, list
+}
+
+func reparse(t *testing.T, cf *ClassFile) *ClassFile {
+	data, err := Serialize(cf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err = Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cf
+}
+
+func nops(n int) []*Instruction {
+	insns := make([]*Instruction, n)
+	for i := range insns {
+		insns[i] = NewInstruction(OP_nop)
+	}
+	return insns
+}
+
+func TestCommitAddsFrameForWidenedBranch(t *testing.T) {
+	cf, list := newBranchMethod(t)
+	ifeq := list.First().Next().Next().Next()
+	list.InsertAfter(ifeq, nops(40000)...)
+	if err := list.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	cf = reparse(t, cf)
+	list, err := NewInstructionList(cf, cf.Methods()[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ifne := list.First().Next().Next().Next()
+	if ifne.Opcode() != OP_ifne || ifne.Next().Opcode() != OP_goto_w {
+		t.Fatalf("branch was not rewritten: %s, %s", ifne.Name(), ifne.Next().Name())
+	}
+	if len(list.frames) != 2 {
+		t.Fatalf("got %d stack map frames, want 2", len(list.frames))
+	}
+
+	// 新的跳转目标和原来的跳转目标处的局部变量都是 int、long，原来的 append_frame 改为 full_frame
+	for i, want := range []*Instruction{ifne.Target(), ifne.Next().Target()} {
+		f := list.frames[i]
+		if f.at != want {
+			t.Errorf("frame %d is at pc %d, want pc %d", i, f.at.pc, want.pc)
+		}
+		if f.frame.frameType != 255 || len(f.frame.stack) != 0 || len(f.frame.locals) != 2 ||
+			f.frame.locals[0].tag != ITEM_Integer || f.frame.locals[1].tag != ITEM_Long {
+			t.Errorf("frame %d: unexpected frame %+v", i, f.frame)
+		}
+	}
+}
+
+// 探针中的跳转目标没有栈帧，Commit() 返回错误且不修改 Code 属性
+func TestCommitRejectsBranchTargetWithoutFrame(t *testing.T) {
+	cf, list := newBranchMethod(t)
+	code := append([]byte{}, cf.Methods()[0].CodeAttribute().Code()...)
+
+	target := NewInstruction(OP_nop)
+	list.InsertAtEntry(
+		NewInstruction(OP_iload_0),
+		NewBranchInstruction(OP_ifeq, target),
+		NewInstruction(OP_nop),
+		target,
+	)
+	if err := list.Commit(); err == nil {
+		t.Fatal("Commit() succeeded, want an error for the branch target without a stack map frame")
+	}
+	if !bytes.Equal(cf.Methods()[0].CodeAttribute().Code(), code) {
+		t.Error("Code attribute was modified by a failed Commit()")
+	}
+}
@@ -17,6 +17,15 @@ type MemberInfo struct {
 func (self *MemberInfo) AccessFlags() uint16 {
 	return self.accessFlags
 }
+func (self *MemberInfo) NameIndex() uint16 {
+	return self.nameIndex
+}
+func (self *MemberInfo) DescriptorIndex() uint16 {
+	return self.descriptorIndex
+}
+func (self *MemberInfo) Attributes() []AttributeInfo {
+	return self.attributes
+}
 
 // 读取字段或方法表，返回 MemberInfo 类型数组
 func readMembers(reader *ClassReader, cp ConstantPool) []*MemberInfo {
@@ -39,6 +48,17 @@ func readMember(reader *ClassReader, cp ConstantPool) *MemberInfo {
 	}
 }
 
+// 写入字段或方法表，与 readMembers() 对应
+func writeMembers(writer *ClassWriter, members []*MemberInfo) {
+	writer.writeUint16(uint16(len(members)))
+	for _, member := range members {
+		writer.writeUint16(member.accessFlags)
+		writer.writeUint16(member.nameIndex)
+		writer.writeUint16(member.descriptorIndex)
+		writeAttributes(writer, member.attributes, member.cp)
+	}
+}
+
 // 根据 nameIndex 从常量池获取字段或方法名
 func (self *MemberInfo) Name() string {
 	return self.cp.getUtf8(self.nameIndex)
@@ -48,3 +68,47 @@ func (self *MemberInfo) Name() string {
 func (self *MemberInfo) Descriptor() string {
 	return self.cp.getUtf8(self.descriptorIndex)
 }
+
+// 查找方法的 Code 属性，抽象方法和本地方法没有 Code 属性，此时返回 nil
+func (self *MemberInfo) CodeAttribute() *CodeAttribute {
+	for _, attrInfo := range self.attributes {
+		switch attrInfo.(type) {
+		case *CodeAttribute:
+			return attrInfo.(*CodeAttribute)
+		}
+	}
+	return nil
+}
+
+// 查找方法的 Exceptions 属性，方法没有声明 throws 时返回 nil
+func (self *MemberInfo) ExceptionsAttribute() *ExceptionsAttribute {
+	for _, attrInfo := range self.attributes {
+		switch attrInfo.(type) {
+		case *ExceptionsAttribute:
+			return attrInfo.(*ExceptionsAttribute)
+		}
+	}
+	return nil
+}
+
+// 查找字段的 ConstantValue 属性，只有 static final 的常量字段才有该属性，否则返回 nil
+func (self *MemberInfo) ConstantValueAttribute() *ConstantValueAttribute {
+	for _, attrInfo := range self.attributes {
+		switch attrInfo.(type) {
+		case *ConstantValueAttribute:
+			return attrInfo.(*ConstantValueAttribute)
+		}
+	}
+	return nil
+}
+
+// 查找方法的 MethodParameters 属性，只有使用 javac -parameters 编译时才会生成，否则返回 nil
+func (self *MemberInfo) MethodParametersAttribute() *MethodParametersAttribute {
+	for _, attrInfo := range self.attributes {
+		switch attrInfo.(type) {
+		case *MethodParametersAttribute:
+			return attrInfo.(*MethodParametersAttribute)
+		}
+	}
+	return nil
+}
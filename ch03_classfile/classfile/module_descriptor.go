@@ -0,0 +1,276 @@
+package classfile
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Module 属性中各种 flags 的取值
+const (
+	ACC_OPEN         = 0x0020 // module_flags：开放模块，所有包都对反射开放
+	ACC_TRANSITIVE   = 0x0020 // requires_flags：依赖传递给读取当前模块的模块
+	ACC_STATIC_PHASE = 0x0040 // requires_flags：仅编译期依赖
+	ACC_SYNTHETIC    = 0x1000 // 编译器生成，源码中不存在
+	ACC_MANDATED     = 0x8000 // 隐式声明，如对 java.base 的依赖
+)
+
+// Module 属性中存放的都是常量池索引，使用起来并不方便
+// ModuleDescriptor 把 Module、ModulePackages 和 ModuleMainClass 三个属性中的索引全部解析成字符串，
+// 作用类似于 java.lang.module.ModuleDescriptor
+//
+// 注意：包名和类名保持 class 文件内部的 "/" 分隔形式，只有 String() 输出时才转换为 "." 分隔
+type ModuleDescriptor struct {
+	name      string
+	flags     uint16
+	version   string
+	requires  []*ModuleRequires
+	exports   []*ModuleExports
+	opens     []*ModuleExports
+	uses      []string
+	provides  []*ModuleProvides
+	packages  []string
+	mainClass string
+}
+
+type ModuleRequires struct {
+	name    string
+	flags   uint16
+	version string
+}
+
+type ModuleExports struct {
+	pkg     string
+	flags   uint16
+	targets []string // 为空时表示非限定导出
+}
+
+type ModuleProvides struct {
+	service string
+	with    []string
+}
+
+// 根据 class 文件的 Module、ModulePackages 和 ModuleMainClass 属性生成模块描述符
+// 如果 class 文件不是 module-info.class（没有 Module 属性），返回 nil；属性中的常量池索引无效时返回错误
+func (self *ClassFile) ModuleDescriptor() (md *ModuleDescriptor, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			md = nil
+			var ok bool
+			err, ok = r.(error)
+			if !ok {
+				err = fmt.Errorf("%v", r)
+			}
+		}
+	}()
+
+	moduleAttr := self.ModuleAttribute()
+	if moduleAttr == nil {
+		return nil, nil
+	}
+	cp := self.constantPool
+	md = &ModuleDescriptor{
+		name:    cp.getModuleName(moduleAttr.moduleNameIndex),
+		flags:   moduleAttr.moduleFlags,
+		version: cp.getOptionalUtf8(moduleAttr.moduleVersionIndex),
+	}
+	for _, r := range moduleAttr.requires {
+		md.requires = append(md.requires, &ModuleRequires{
+			name:    cp.getModuleName(r.requiresIndex),
+			flags:   r.requiresFlags,
+			version: cp.getOptionalUtf8(r.requiresVersionIndex),
+		})
+	}
+	md.exports = newModuleExports(cp, moduleAttr.exports)
+	md.opens = newModuleExports(cp, moduleAttr.opens)
+	for _, cpIndex := range moduleAttr.usesIndex {
+		md.uses = append(md.uses, cp.getClassName(cpIndex))
+	}
+	for _, p := range moduleAttr.provides {
+		provides := &ModuleProvides{service: cp.getClassName(p.providesIndex)}
+		for _, cpIndex := range p.providesWithIndex {
+			provides.with = append(provides.with, cp.getClassName(cpIndex))
+		}
+		md.provides = append(md.provides, provides)
+	}
+	if packagesAttr := self.ModulePackagesAttribute(); packagesAttr != nil {
+		md.packages = packagesAttr.PackageNames()
+	}
+	if mainClassAttr := self.ModuleMainClassAttribute(); mainClassAttr != nil {
+		md.mainClass = mainClassAttr.MainClassName()
+	}
+	return md, nil
+}
+
+func newModuleExports(cp ConstantPool, entries []*ModuleExportsEntry) []*ModuleExports {
+	exports := make([]*ModuleExports, len(entries))
+	for i, e := range entries {
+		exports[i] = &ModuleExports{
+			pkg:   cp.getPackageName(e.index),
+			flags: e.flags,
+		}
+		for _, cpIndex := range e.toIndex {
+			exports[i].targets = append(exports[i].targets, cp.getModuleName(cpIndex))
+		}
+	}
+	return exports
+}
+
+// getter 方法
+func (self *ModuleDescriptor) Name() string {
+	return self.name
+}
+func (self *ModuleDescriptor) Flags() uint16 {
+	return self.flags
+}
+func (self *ModuleDescriptor) IsOpen() bool {
+	return self.flags&ACC_OPEN != 0
+}
+func (self *ModuleDescriptor) Version() string {
+	return self.version
+}
+func (self *ModuleDescriptor) Requires() []*ModuleRequires {
+	return self.requires
+}
+func (self *ModuleDescriptor) Exports() []*ModuleExports {
+	return self.exports
+}
+func (self *ModuleDescriptor) Opens() []*ModuleExports {
+	return self.opens
+}
+func (self *ModuleDescriptor) Uses() []string {
+	return self.uses
+}
+func (self *ModuleDescriptor) Provides() []*ModuleProvides {
+	return self.provides
+}
+func (self *ModuleDescriptor) Packages() []string {
+	return self.packages
+}
+func (self *ModuleDescriptor) MainClass() string {
+	return self.mainClass
+}
+
+func (self *ModuleRequires) Name() string {
+	return self.name
+}
+func (self *ModuleRequires) Flags() uint16 {
+	return self.flags
+}
+func (self *ModuleRequires) Version() string {
+	return self.version
+}
+func (self *ModuleRequires) IsTransitive() bool {
+	return self.flags&ACC_TRANSITIVE != 0
+}
+func (self *ModuleRequires) IsStatic() bool {
+	return self.flags&ACC_STATIC_PHASE != 0
+}
+
+func (self *ModuleExports) Package() string {
+	return self.pkg
+}
+func (self *ModuleExports) Flags() uint16 {
+	return self.flags
+}
+func (self *ModuleExports) Targets() []string {
+	return self.targets
+}
+func (self *ModuleExports) IsQualified() bool {
+	return len(self.targets) > 0
+}
+
+func (self *ModuleProvides) Service() string {
+	return self.service
+}
+func (self *ModuleProvides) With() []string {
+	return self.with
+}
+
+// String() 的输出格式与 `java --describe-module` 保持一致，例如：
+// java.sql@11
+// exports java.sql
+// requires java.base mandated
+// requires java.logging transitive
+// uses java.sql.Driver
+func (self *ModuleDescriptor) String() string {
+	var lines []string
+	exported := map[string]bool{}
+
+	for _, e := range self.exports {
+		exported[e.pkg] = true
+		lines = append(lines, describeExports("exports", e))
+	}
+	for _, r := range self.requires {
+		line := "requires " + r.name
+		if r.version != "" {
+			line += "@" + r.version
+		}
+		lines = append(lines, line+describeFlags(r.flags, map[uint16]string{
+			ACC_TRANSITIVE:   "transitive",
+			ACC_STATIC_PHASE: "static",
+			ACC_SYNTHETIC:    "synthetic",
+			ACC_MANDATED:     "mandated",
+		}))
+	}
+	for _, u := range self.uses {
+		lines = append(lines, "uses "+toJavaName(u))
+	}
+	for _, p := range self.provides {
+		with := make([]string, len(p.with))
+		for i, impl := range p.with {
+			with[i] = toJavaName(impl)
+		}
+		lines = append(lines, "provides "+toJavaName(p.service)+" with "+strings.Join(with, " "))
+	}
+	for _, o := range self.opens {
+		exported[o.pkg] = true
+		lines = append(lines, describeExports("opens", o))
+	}
+	for _, pkg := range self.packages {
+		if !exported[pkg] {
+			lines = append(lines, "contains "+toJavaName(pkg))
+		}
+	}
+	if self.mainClass != "" {
+		lines = append(lines, "main-class "+toJavaName(self.mainClass))
+	}
+	sort.Strings(lines)
+
+	header := self.name
+	if self.version != "" {
+		header += "@" + self.version
+	}
+	if self.IsOpen() {
+		header += " open"
+	}
+	return strings.Join(append([]string{header}, lines...), "\n")
+}
+
+// exports 和 opens 共用的输出格式，限定导出形如 `qualified exports a.b to m1 m2`
+func describeExports(keyword string, e *ModuleExports) string {
+	line := keyword + " " + toJavaName(e.pkg)
+	if e.IsQualified() {
+		line = "qualified " + line + " to " + strings.Join(e.targets, " ")
+	}
+	return line + describeFlags(e.flags, map[uint16]string{
+		ACC_SYNTHETIC: "synthetic",
+		ACC_MANDATED:  "mandated",
+	})
+}
+
+// 按 flag 值从小到大输出 flag 名称
+func describeFlags(flags uint16, names map[uint16]string) string {
+	var s string
+	for bit := uint16(1); bit != 0; bit <<= 1 {
+		if flags&bit != 0 && names[bit] != "" {
+			s += " " + names[bit]
+		}
+	}
+	return s
+}
+
+// 将 class 文件内部的 "/" 分隔名称转换为 Java 源码中的 "." 分隔名称
+func toJavaName(internalName string) string {
+	return strings.Replace(internalName, "/", ".", -1)
+}
@@ -0,0 +1,115 @@
+package classfile
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func readModuleInfo(t *testing.T) *ClassFile {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "module-info.class"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cf
+}
+
+func TestModuleDescriptor(t *testing.T) {
+	md, err := readModuleInfo(t).ModuleDescriptor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md == nil {
+		t.Fatal("ModuleDescriptor() = nil")
+	}
+
+	want := `com.foo@1.0 open
+contains com.foo.internal
+exports com.foo.api
+main-class com.foo.Main
+provides com.foo.api.Service with com.foo.internal.Impl
+qualified exports com.foo.spi to com.bar com.baz
+requires java.base@11 mandated
+requires java.sql transitive
+requires lombok static
+uses com.foo.api.Service`
+	if got := md.String(); got != want {
+		t.Errorf("String() =\n%s\nwant\n%s", got, want)
+	}
+
+	if md.Name() != "com.foo" || md.Version() != "1.0" || !md.IsOpen() {
+		t.Errorf("got name %q, version %q, open %v", md.Name(), md.Version(), md.IsOpen())
+	}
+	if md.MainClass() != "com/foo/Main" {
+		t.Errorf("MainClass() = %q, want com/foo/Main", md.MainClass())
+	}
+	requires := map[string]*ModuleRequires{}
+	for _, r := range md.Requires() {
+		requires[r.Name()] = r
+	}
+	if r := requires["java.sql"]; r == nil || !r.IsTransitive() || r.IsStatic() {
+		t.Errorf("requires java.sql: got %+v, want transitive", r)
+	}
+	if r := requires["lombok"]; r == nil || r.IsTransitive() || !r.IsStatic() {
+		t.Errorf("requires lombok: got %+v, want static", r)
+	}
+	for _, e := range md.Exports() {
+		switch e.Package() {
+		case "com/foo/api":
+			if e.IsQualified() {
+				t.Errorf("exports com/foo/api: got targets %v, want none", e.Targets())
+			}
+		case "com/foo/spi":
+			if targets := e.Targets(); len(targets) != 2 || targets[0] != "com.bar" || targets[1] != "com.baz" {
+				t.Errorf("exports com/foo/spi: got targets %v, want [com.bar com.baz]", targets)
+			}
+		default:
+			t.Errorf("unexpected export %s", e.Package())
+		}
+	}
+}
+
+// 不是 module-info.class 时返回 nil，而不是错误
+func TestModuleDescriptorNotModule(t *testing.T) {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "Condy.class"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md, err := cf.ModuleDescriptor(); md != nil || err != nil {
+		t.Errorf("ModuleDescriptor() = %v, %v; want nil, nil", md, err)
+	}
+}
+
+// 属性中的常量池索引越界、为 0 或者指向错误类型的常量时，ModuleDescriptor 返回错误而不是 panic
+func TestModuleDescriptorMalformed(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(attr *ModuleAttribute)
+	}{
+		{"module name out of range", func(attr *ModuleAttribute) { attr.moduleNameIndex = 0xFFFF }},
+		{"module name zero", func(attr *ModuleAttribute) { attr.moduleNameIndex = 0 }},
+		{"module name wrong tag", func(attr *ModuleAttribute) { attr.moduleNameIndex = attr.requires[0].requiresVersionIndex }},
+		{"requires wrong tag", func(attr *ModuleAttribute) { attr.requires[0].requiresIndex = attr.exports[0].index }},
+		{"exports wrong tag", func(attr *ModuleAttribute) { attr.exports[0].index = attr.moduleNameIndex }},
+		{"uses out of range", func(attr *ModuleAttribute) { attr.usesIndex[0] = 0xFFFF }},
+		{"version wrong tag", func(attr *ModuleAttribute) { attr.moduleVersionIndex = attr.moduleNameIndex }},
+	}
+	for _, test := range tests {
+		cf := readModuleInfo(t)
+		test.mutate(cf.ModuleAttribute())
+		md, err := cf.ModuleDescriptor()
+		if err == nil {
+			t.Errorf("%s: got %v, want an error", test.name, md)
+		} else if md != nil {
+			t.Errorf("%s: got a descriptor together with error %v", test.name, err)
+		}
+	}
+}
@@ -0,0 +1,458 @@
+package classfile
+
+// JVM 指令由一个字节的操作码（opcode）和若干字节的操作数（operand）组成，这里列出全部 205 条指令的
+// 助记符、操作数格式以及对操作数栈的影响，供 InstructionList 解码、重新编码和计算 max_stack 使用
+//
+// 操作数格式决定了指令的长度，大部分指令的长度是固定的，只有以下几种例外：
+// - tableswitch 和 lookupswitch 在操作码之后有 0 ~ 3 字节的填充，使之后的操作数按 4 字节对齐
+// - wide 是前缀指令，用于扩展后面一条指令的局部变量索引（u1 -> u2）和 iinc 的增量（s1 -> s2）
+const (
+	operandNone            = iota // 没有操作数
+	operandByte                   // 一个字节：bipush 的 s1，newarray 的 atype
+	operandShort                  // 两个字节：sipush 的 s2
+	operandCpIndex1               // 一个字节的常量池索引：ldc
+	operandCpIndex                // 两个字节的常量池索引
+	operandLocal                  // 一个字节的局部变量索引，wide 时为两个字节
+	operandIinc                   // 局部变量索引 + 有符号增量，wide 时都为两个字节
+	operandBranch                 // 两个字节的有符号跳转偏移量
+	operandBranchWide             // 四个字节的有符号跳转偏移量：goto_w、jsr_w
+	operandTableSwitch            // tableswitch
+	operandLookupSwitch           // lookupswitch
+	operandInvokeInterface        // 常量池索引 + count + 0
+	operandInvokeDynamic          // 常量池索引 + 0 + 0
+	operandMultiANewArray         // 常量池索引 + dimensions
+	operandWide                   // wide 前缀
+)
+
+const (
+	OP_nop             = 0x00
+	OP_aconst_null     = 0x01
+	OP_iconst_m1       = 0x02
+	OP_iconst_0        = 0x03
+	OP_iconst_1        = 0x04
+	OP_iconst_2        = 0x05
+	OP_iconst_3        = 0x06
+	OP_iconst_4        = 0x07
+	OP_iconst_5        = 0x08
+	OP_lconst_0        = 0x09
+	OP_lconst_1        = 0x0a
+	OP_fconst_0        = 0x0b
+	OP_fconst_1        = 0x0c
+	OP_fconst_2        = 0x0d
+	OP_dconst_0        = 0x0e
+	OP_dconst_1        = 0x0f
+	OP_bipush          = 0x10
+	OP_sipush          = 0x11
+	OP_ldc             = 0x12
+	OP_ldc_w           = 0x13
+	OP_ldc2_w          = 0x14
+	OP_iload           = 0x15
+	OP_lload           = 0x16
+	OP_fload           = 0x17
+	OP_dload           = 0x18
+	OP_aload           = 0x19
+	OP_iload_0         = 0x1a
+	OP_iload_1         = 0x1b
+	OP_iload_2         = 0x1c
+	OP_iload_3         = 0x1d
+	OP_lload_0         = 0x1e
+	OP_lload_1         = 0x1f
+	OP_lload_2         = 0x20
+	OP_lload_3         = 0x21
+	OP_fload_0         = 0x22
+	OP_fload_1         = 0x23
+	OP_fload_2         = 0x24
+	OP_fload_3         = 0x25
+	OP_dload_0         = 0x26
+	OP_dload_1         = 0x27
+	OP_dload_2         = 0x28
+	OP_dload_3         = 0x29
+	OP_aload_0         = 0x2a
+	OP_aload_1         = 0x2b
+	OP_aload_2         = 0x2c
+	OP_aload_3         = 0x2d
+	OP_iaload          = 0x2e
+	OP_laload          = 0x2f
+	OP_faload          = 0x30
+	OP_daload          = 0x31
+	OP_aaload          = 0x32
+	OP_baload          = 0x33
+	OP_caload          = 0x34
+	OP_saload          = 0x35
+	OP_istore          = 0x36
+	OP_lstore          = 0x37
+	OP_fstore          = 0x38
+	OP_dstore          = 0x39
+	OP_astore          = 0x3a
+	OP_istore_0        = 0x3b
+	OP_istore_1        = 0x3c
+	OP_istore_2        = 0x3d
+	OP_istore_3        = 0x3e
+	OP_lstore_0        = 0x3f
+	OP_lstore_1        = 0x40
+	OP_lstore_2        = 0x41
+	OP_lstore_3        = 0x42
+	OP_fstore_0        = 0x43
+	OP_fstore_1        = 0x44
+	OP_fstore_2        = 0x45
+	OP_fstore_3        = 0x46
+	OP_dstore_0        = 0x47
+	OP_dstore_1        = 0x48
+	OP_dstore_2        = 0x49
+	OP_dstore_3        = 0x4a
+	OP_astore_0        = 0x4b
+	OP_astore_1        = 0x4c
+	OP_astore_2        = 0x4d
+	OP_astore_3        = 0x4e
+	OP_iastore         = 0x4f
+	OP_lastore         = 0x50
+	OP_fastore         = 0x51
+	OP_dastore         = 0x52
+	OP_aastore         = 0x53
+	OP_bastore         = 0x54
+	OP_castore         = 0x55
+	OP_sastore         = 0x56
+	OP_pop             = 0x57
+	OP_pop2            = 0x58
+	OP_dup             = 0x59
+	OP_dup_x1          = 0x5a
+	OP_dup_x2          = 0x5b
+	OP_dup2            = 0x5c
+	OP_dup2_x1         = 0x5d
+	OP_dup2_x2         = 0x5e
+	OP_swap            = 0x5f
+	OP_iadd            = 0x60
+	OP_ladd            = 0x61
+	OP_fadd            = 0x62
+	OP_dadd            = 0x63
+	OP_isub            = 0x64
+	OP_lsub            = 0x65
+	OP_fsub            = 0x66
+	OP_dsub            = 0x67
+	OP_imul            = 0x68
+	OP_lmul            = 0x69
+	OP_fmul            = 0x6a
+	OP_dmul            = 0x6b
+	OP_idiv            = 0x6c
+	OP_ldiv            = 0x6d
+	OP_fdiv            = 0x6e
+	OP_ddiv            = 0x6f
+	OP_irem            = 0x70
+	OP_lrem            = 0x71
+	OP_frem            = 0x72
+	OP_drem            = 0x73
+	OP_ineg            = 0x74
+	OP_lneg            = 0x75
+	OP_fneg            = 0x76
+	OP_dneg            = 0x77
+	OP_ishl            = 0x78
+	OP_lshl            = 0x79
+	OP_ishr            = 0x7a
+	OP_lshr            = 0x7b
+	OP_iushr           = 0x7c
+	OP_lushr           = 0x7d
+	OP_iand            = 0x7e
+	OP_land            = 0x7f
+	OP_ior             = 0x80
+	OP_lor             = 0x81
+	OP_ixor            = 0x82
+	OP_lxor            = 0x83
+	OP_iinc            = 0x84
+	OP_i2l             = 0x85
+	OP_i2f             = 0x86
+	OP_i2d             = 0x87
+	OP_l2i             = 0x88
+	OP_l2f             = 0x89
+	OP_l2d             = 0x8a
+	OP_f2i             = 0x8b
+	OP_f2l             = 0x8c
+	OP_f2d             = 0x8d
+	OP_d2i             = 0x8e
+	OP_d2l             = 0x8f
+	OP_d2f             = 0x90
+	OP_i2b             = 0x91
+	OP_i2c             = 0x92
+	OP_i2s             = 0x93
+	OP_lcmp            = 0x94
+	OP_fcmpl           = 0x95
+	OP_fcmpg           = 0x96
+	OP_dcmpl           = 0x97
+	OP_dcmpg           = 0x98
+	OP_ifeq            = 0x99
+	OP_ifne            = 0x9a
+	OP_iflt            = 0x9b
+	OP_ifge            = 0x9c
+	OP_ifgt            = 0x9d
+	OP_ifle            = 0x9e
+	OP_if_icmpeq       = 0x9f
+	OP_if_icmpne       = 0xa0
+	OP_if_icmplt       = 0xa1
+	OP_if_icmpge       = 0xa2
+	OP_if_icmpgt       = 0xa3
+	OP_if_icmple       = 0xa4
+	OP_if_acmpeq       = 0xa5
+	OP_if_acmpne       = 0xa6
+	OP_goto            = 0xa7
+	OP_jsr             = 0xa8
+	OP_ret             = 0xa9
+	OP_tableswitch     = 0xaa
+	OP_lookupswitch    = 0xab
+	OP_ireturn         = 0xac
+	OP_lreturn         = 0xad
+	OP_freturn         = 0xae
+	OP_dreturn         = 0xaf
+	OP_areturn         = 0xb0
+	OP_return          = 0xb1
+	OP_getstatic       = 0xb2
+	OP_putstatic       = 0xb3
+	OP_getfield        = 0xb4
+	OP_putfield        = 0xb5
+	OP_invokevirtual   = 0xb6
+	OP_invokespecial   = 0xb7
+	OP_invokestatic    = 0xb8
+	OP_invokeinterface = 0xb9
+	OP_invokedynamic   = 0xba
+	OP_new             = 0xbb
+	OP_newarray        = 0xbc
+	OP_anewarray       = 0xbd
+	OP_arraylength     = 0xbe
+	OP_athrow          = 0xbf
+	OP_checkcast       = 0xc0
+	OP_instanceof      = 0xc1
+	OP_monitorenter    = 0xc2
+	OP_monitorexit     = 0xc3
+	OP_wide            = 0xc4
+	OP_multianewarray  = 0xc5
+	OP_ifnull          = 0xc6
+	OP_ifnonnull       = 0xc7
+	OP_goto_w          = 0xc8
+	OP_jsr_w           = 0xc9
+	OP_breakpoint      = 0xca
+	OP_impdep1         = 0xfe
+	OP_impdep2         = 0xff
+)
+
+// pop/push 给出指令从操作数栈弹出和压入的 slot 数，long 和 double 占两个 slot
+// 值为 -1 表示需要根据常量池中的描述符或指令的操作数才能计算，参考 stackEffect()
+type opcodeInfo struct {
+	name   string
+	format uint8
+	pop    int8
+	push   int8
+}
+
+var opcodeTable = [256]*opcodeInfo{
+	OP_nop:             {"nop", operandNone, 0, 0},
+	OP_aconst_null:     {"aconst_null", operandNone, 0, 1},
+	OP_iconst_m1:       {"iconst_m1", operandNone, 0, 1},
+	OP_iconst_0:        {"iconst_0", operandNone, 0, 1},
+	OP_iconst_1:        {"iconst_1", operandNone, 0, 1},
+	OP_iconst_2:        {"iconst_2", operandNone, 0, 1},
+	OP_iconst_3:        {"iconst_3", operandNone, 0, 1},
+	OP_iconst_4:        {"iconst_4", operandNone, 0, 1},
+	OP_iconst_5:        {"iconst_5", operandNone, 0, 1},
+	OP_lconst_0:        {"lconst_0", operandNone, 0, 2},
+	OP_lconst_1:        {"lconst_1", operandNone, 0, 2},
+	OP_fconst_0:        {"fconst_0", operandNone, 0, 1},
+	OP_fconst_1:        {"fconst_1", operandNone, 0, 1},
+	OP_fconst_2:        {"fconst_2", operandNone, 0, 1},
+	OP_dconst_0:        {"dconst_0", operandNone, 0, 2},
+	OP_dconst_1:        {"dconst_1", operandNone, 0, 2},
+	OP_bipush:          {"bipush", operandByte, 0, 1},
+	OP_sipush:          {"sipush", operandShort, 0, 1},
+	OP_ldc:             {"ldc", operandCpIndex1, 0, -1},
+	OP_ldc_w:           {"ldc_w", operandCpIndex, 0, -1},
+	OP_ldc2_w:          {"ldc2_w", operandCpIndex, 0, 2},
+	OP_iload:           {"iload", operandLocal, 0, 1},
+	OP_lload:           {"lload", operandLocal, 0, 2},
+	OP_fload:           {"fload", operandLocal, 0, 1},
+	OP_dload:           {"dload", operandLocal, 0, 2},
+	OP_aload:           {"aload", operandLocal, 0, 1},
+	OP_iload_0:         {"iload_0", operandNone, 0, 1},
+	OP_iload_1:         {"iload_1", operandNone, 0, 1},
+	OP_iload_2:         {"iload_2", operandNone, 0, 1},
+	OP_iload_3:         {"iload_3", operandNone, 0, 1},
+	OP_lload_0:         {"lload_0", operandNone, 0, 2},
+	OP_lload_1:         {"lload_1", operandNone, 0, 2},
+	OP_lload_2:         {"lload_2", operandNone, 0, 2},
+	OP_lload_3:         {"lload_3", operandNone, 0, 2},
+	OP_fload_0:         {"fload_0", operandNone, 0, 1},
+	OP_fload_1:         {"fload_1", operandNone, 0, 1},
+	OP_fload_2:         {"fload_2", operandNone, 0, 1},
+	OP_fload_3:         {"fload_3", operandNone, 0, 1},
+	OP_dload_0:         {"dload_0", operandNone, 0, 2},
+	OP_dload_1:         {"dload_1", operandNone, 0, 2},
+	OP_dload_2:         {"dload_2", operandNone, 0, 2},
+	OP_dload_3:         {"dload_3", operandNone, 0, 2},
+	OP_aload_0:         {"aload_0", operandNone, 0, 1},
+	OP_aload_1:         {"aload_1", operandNone, 0, 1},
+	OP_aload_2:         {"aload_2", operandNone, 0, 1},
+	OP_aload_3:         {"aload_3", operandNone, 0, 1},
+	OP_iaload:          {"iaload", operandNone, 2, 1},
+	OP_laload:          {"laload", operandNone, 2, 2},
+	OP_faload:          {"faload", operandNone, 2, 1},
+	OP_daload:          {"daload", operandNone, 2, 2},
+	OP_aaload:          {"aaload", operandNone, 2, 1},
+	OP_baload:          {"baload", operandNone, 2, 1},
+	OP_caload:          {"caload", operandNone, 2, 1},
+	OP_saload:          {"saload", operandNone, 2, 1},
+	OP_istore:          {"istore", operandLocal, 1, 0},
+	OP_lstore:          {"lstore", operandLocal, 2, 0},
+	OP_fstore:          {"fstore", operandLocal, 1, 0},
+	OP_dstore:          {"dstore", operandLocal, 2, 0},
+	OP_astore:          {"astore", operandLocal, 1, 0},
+	OP_istore_0:        {"istore_0", operandNone, 1, 0},
+	OP_istore_1:        {"istore_1", operandNone, 1, 0},
+	OP_istore_2:        {"istore_2", operandNone, 1, 0},
+	OP_istore_3:        {"istore_3", operandNone, 1, 0},
+	OP_lstore_0:        {"lstore_0", operandNone, 2, 0},
+	OP_lstore_1:        {"lstore_1", operandNone, 2, 0},
+	OP_lstore_2:        {"lstore_2", operandNone, 2, 0},
+	OP_lstore_3:        {"lstore_3", operandNone, 2, 0},
+	OP_fstore_0:        {"fstore_0", operandNone, 1, 0},
+	OP_fstore_1:        {"fstore_1", operandNone, 1, 0},
+	OP_fstore_2:        {"fstore_2", operandNone, 1, 0},
+	OP_fstore_3:        {"fstore_3", operandNone, 1, 0},
+	OP_dstore_0:        {"dstore_0", operandNone, 2, 0},
+	OP_dstore_1:        {"dstore_1", operandNone, 2, 0},
+	OP_dstore_2:        {"dstore_2", operandNone, 2, 0},
+	OP_dstore_3:        {"dstore_3", operandNone, 2, 0},
+	OP_astore_0:        {"astore_0", operandNone, 1, 0},
+	OP_astore_1:        {"astore_1", operandNone, 1, 0},
+	OP_astore_2:        {"astore_2", operandNone, 1, 0},
+	OP_astore_3:        {"astore_3", operandNone, 1, 0},
+	OP_iastore:         {"iastore", operandNone, 3, 0},
+	OP_lastore:         {"lastore", operandNone, 4, 0},
+	OP_fastore:         {"fastore", operandNone, 3, 0},
+	OP_dastore:         {"dastore", operandNone, 4, 0},
+	OP_aastore:         {"aastore", operandNone, 3, 0},
+	OP_bastore:         {"bastore", operandNone, 3, 0},
+	OP_castore:         {"castore", operandNone, 3, 0},
+	OP_sastore:         {"sastore", operandNone, 3, 0},
+	OP_pop:             {"pop", operandNone, 1, 0},
+	OP_pop2:            {"pop2", operandNone, 2, 0},
+	OP_dup:             {"dup", operandNone, 1, 2},
+	OP_dup_x1:          {"dup_x1", operandNone, 2, 3},
+	OP_dup_x2:          {"dup_x2", operandNone, 3, 4},
+	OP_dup2:            {"dup2", operandNone, 2, 4},
+	OP_dup2_x1:         {"dup2_x1", operandNone, 3, 5},
+	OP_dup2_x2:         {"dup2_x2", operandNone, 4, 6},
+	OP_swap:            {"swap", operandNone, 2, 2},
+	OP_iadd:            {"iadd", operandNone, 2, 1},
+	OP_ladd:            {"ladd", operandNone, 4, 2},
+	OP_fadd:            {"fadd", operandNone, 2, 1},
+	OP_dadd:            {"dadd", operandNone, 4, 2},
+	OP_isub:            {"isub", operandNone, 2, 1},
+	OP_lsub:            {"lsub", operandNone, 4, 2},
+	OP_fsub:            {"fsub", operandNone, 2, 1},
+	OP_dsub:            {"dsub", operandNone, 4, 2},
+	OP_imul:            {"imul", operandNone, 2, 1},
+	OP_lmul:            {"lmul", operandNone, 4, 2},
+	OP_fmul:            {"fmul", operandNone, 2, 1},
+	OP_dmul:            {"dmul", operandNone, 4, 2},
+	OP_idiv:            {"idiv", operandNone, 2, 1},
+	OP_ldiv:            {"ldiv", operandNone, 4, 2},
+	OP_fdiv:            {"fdiv", operandNone, 2, 1},
+	OP_ddiv:            {"ddiv", operandNone, 4, 2},
+	OP_irem:            {"irem", operandNone, 2, 1},
+	OP_lrem:            {"lrem", operandNone, 4, 2},
+	OP_frem:            {"frem", operandNone, 2, 1},
+	OP_drem:            {"drem", operandNone, 4, 2},
+	OP_ineg:            {"ineg", operandNone, 1, 1},
+	OP_lneg:            {"lneg", operandNone, 2, 2},
+	OP_fneg:            {"fneg", operandNone, 1, 1},
+	OP_dneg:            {"dneg", operandNone, 2, 2},
+	OP_ishl:            {"ishl", operandNone, 2, 1},
+	OP_lshl:            {"lshl", operandNone, 3, 2},
+	OP_ishr:            {"ishr", operandNone, 2, 1},
+	OP_lshr:            {"lshr", operandNone, 3, 2},
+	OP_iushr:           {"iushr", operandNone, 2, 1},
+	OP_lushr:           {"lushr", operandNone, 3, 2},
+	OP_iand:            {"iand", operandNone, 2, 1},
+	OP_land:            {"land", operandNone, 4, 2},
+	OP_ior:             {"ior", operandNone, 2, 1},
+	OP_lor:             {"lor", operandNone, 4, 2},
+	OP_ixor:            {"ixor", operandNone, 2, 1},
+	OP_lxor:            {"lxor", operandNone, 4, 2},
+	OP_iinc:            {"iinc", operandIinc, 0, 0},
+	OP_i2l:             {"i2l", operandNone, 1, 2},
+	OP_i2f:             {"i2f", operandNone, 1, 1},
+	OP_i2d:             {"i2d", operandNone, 1, 2},
+	OP_l2i:             {"l2i", operandNone, 2, 1},
+	OP_l2f:             {"l2f", operandNone, 2, 1},
+	OP_l2d:             {"l2d", operandNone, 2, 2},
+	OP_f2i:             {"f2i", operandNone, 1, 1},
+	OP_f2l:             {"f2l", operandNone, 1, 2},
+	OP_f2d:             {"f2d", operandNone, 1, 2},
+	OP_d2i:             {"d2i", operandNone, 2, 1},
+	OP_d2l:             {"d2l", operandNone, 2, 2},
+	OP_d2f:             {"d2f", operandNone, 2, 1},
+	OP_i2b:             {"i2b", operandNone, 1, 1},
+	OP_i2c:             {"i2c", operandNone, 1, 1},
+	OP_i2s:             {"i2s", operandNone, 1, 1},
+	OP_lcmp:            {"lcmp", operandNone, 4, 1},
+	OP_fcmpl:           {"fcmpl", operandNone, 2, 1},
+	OP_fcmpg:           {"fcmpg", operandNone, 2, 1},
+	OP_dcmpl:           {"dcmpl", operandNone, 4, 1},
+	OP_dcmpg:           {"dcmpg", operandNone, 4, 1},
+	OP_ifeq:            {"ifeq", operandBranch, 1, 0},
+	OP_ifne:            {"ifne", operandBranch, 1, 0},
+	OP_iflt:            {"iflt", operandBranch, 1, 0},
+	OP_ifge:            {"ifge", operandBranch, 1, 0},
+	OP_ifgt:            {"ifgt", operandBranch, 1, 0},
+	OP_ifle:            {"ifle", operandBranch, 1, 0},
+	OP_if_icmpeq:       {"if_icmpeq", operandBranch, 2, 0},
+	OP_if_icmpne:       {"if_icmpne", operandBranch, 2, 0},
+	OP_if_icmplt:       {"if_icmplt", operandBranch, 2, 0},
+	OP_if_icmpge:       {"if_icmpge", operandBranch, 2, 0},
+	OP_if_icmpgt:       {"if_icmpgt", operandBranch, 2, 0},
+	OP_if_icmple:       {"if_icmple", operandBranch, 2, 0},
+	OP_if_acmpeq:       {"if_acmpeq", operandBranch, 2, 0},
+	OP_if_acmpne:       {"if_acmpne", operandBranch, 2, 0},
+	OP_goto:            {"goto", operandBranch, 0, 0},
+	OP_jsr:             {"jsr", operandBranch, 0, 1},
+	OP_ret:             {"ret", operandLocal, 0, 0},
+	OP_tableswitch:     {"tableswitch", operandTableSwitch, 1, 0},
+	OP_lookupswitch:    {"lookupswitch", operandLookupSwitch, 1, 0},
+	OP_ireturn:         {"ireturn", operandNone, 1, 0},
+	OP_lreturn:         {"lreturn", operandNone, 2, 0},
+	OP_freturn:         {"freturn", operandNone, 1, 0},
+	OP_dreturn:         {"dreturn", operandNone, 2, 0},
+	OP_areturn:         {"areturn", operandNone, 1, 0},
+	OP_return:          {"return", operandNone, 0, 0},
+	OP_getstatic:       {"getstatic", operandCpIndex, -1, -1},
+	OP_putstatic:       {"putstatic", operandCpIndex, -1, -1},
+	OP_getfield:        {"getfield", operandCpIndex, -1, -1},
+	OP_putfield:        {"putfield", operandCpIndex, -1, -1},
+	OP_invokevirtual:   {"invokevirtual", operandCpIndex, -1, -1},
+	OP_invokespecial:   {"invokespecial", operandCpIndex, -1, -1},
+	OP_invokestatic:    {"invokestatic", operandCpIndex, -1, -1},
+	OP_invokeinterface: {"invokeinterface", operandInvokeInterface, -1, -1},
+	OP_invokedynamic:   {"invokedynamic", operandInvokeDynamic, -1, -1},
+	OP_new:             {"new", operandCpIndex, 0, 1},
+	OP_newarray:        {"newarray", operandByte, 1, 1},
+	OP_anewarray:       {"anewarray", operandCpIndex, 1, 1},
+	OP_arraylength:     {"arraylength", operandNone, 1, 1},
+	OP_athrow:          {"athrow", operandNone, 1, 0},
+	OP_checkcast:       {"checkcast", operandCpIndex, 1, 1},
+	OP_instanceof:      {"instanceof", operandCpIndex, 1, 1},
+	OP_monitorenter:    {"monitorenter", operandNone, 1, 0},
+	OP_monitorexit:     {"monitorexit", operandNone, 1, 0},
+	OP_wide:            {"wide", operandWide, 0, 0},
+	OP_multianewarray:  {"multianewarray", operandMultiANewArray, -1, 1},
+	OP_ifnull:          {"ifnull", operandBranch, 1, 0},
+	OP_ifnonnull:       {"ifnonnull", operandBranch, 1, 0},
+	OP_goto_w:          {"goto_w", operandBranchWide, 0, 0},
+	OP_jsr_w:           {"jsr_w", operandBranchWide, 0, 1},
+	OP_breakpoint:      {"breakpoint", operandNone, 0, 0},
+	OP_impdep1:         {"impdep1", operandNone, 0, 0},
+	OP_impdep2:         {"impdep2", operandNone, 0, 0},
+}
+
+// 返回操作码对应的助记符，未定义的操作码返回空字符串
+func OpcodeName(opcode uint8) string {
+	if info := opcodeTable[opcode]; info != nil {
+		return info.name
+	}
+	return ""
+}
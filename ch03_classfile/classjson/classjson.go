@@ -0,0 +1,534 @@
+package classjson
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"jvmgo/ch03_classfile/classfile"
+	"math"
+)
+
+// classjson 把解析后的 ClassFile 导出为 JSON，供其它工具读取 class 文件的元数据而不必自己实现解析
+//
+// 输出的结构与 class 文件一一对应，键名采用 JVM 规范中的 snake_case 名称，并在引用常量池的地方同时给出索引和解析后的值。
+// 格式发生不兼容的变化时 SchemaVersion 加一，新增键不算不兼容的变化，读取方应当忽略不认识的键。
+// 常量池按索引顺序输出，跳过索引 0 以及 long、double 之后不可用的位置。
+// 字节数组（字节码、未解析属性的内容）输出为十六进制字符串。
+// float 和 double 的 NaN 和无穷大在 JSON 中没有对应的数值，输出为字符串 "NaN"、"Infinity" 和 "-Infinity"。
+// 属性统一输出为 {"name": 属性名, "value": 内容}，Deprecated 和 Synthetic 没有 value，
+// 其余属性的 value 是对应的 XxxValue 结构，无法识别的属性输出为 UnparsedValue。
+const SchemaVersion = 1
+
+type Class struct {
+	SchemaVersion int          `json:"schema_version"`
+	MinorVersion  uint16       `json:"minor_version"`
+	MajorVersion  uint16       `json:"major_version"`
+	ConstantPool  []*Constant  `json:"constant_pool"`
+	AccessFlags   uint16       `json:"access_flags"`
+	ThisClass     *ClassRef    `json:"this_class"`
+	SuperClass    *ClassRef    `json:"super_class"` // 只有 java/lang/Object 为 null
+	Interfaces    []*ClassRef  `json:"interfaces"`
+	Fields        []*Member    `json:"fields"`
+	Methods       []*Member    `json:"methods"`
+	Attributes    []*Attribute `json:"attributes"`
+}
+
+// 常量池中的一项，Tag 是去掉 CONSTANT_ 前缀的名称，例如 "Methodref"，其余的键只在对应的常量类型中出现
+type Constant struct {
+	Index                    uint16      `json:"index"`
+	Tag                      string      `json:"tag"`
+	TagValue                 uint8       `json:"tag_value"`
+	Value                    interface{} `json:"value,omitempty"` // Utf8、Integer、Float、Long、Double、String 的值
+	NameIndex                uint16      `json:"name_index,omitempty"`
+	ClassIndex               uint16      `json:"class_index,omitempty"`
+	NameAndTypeIndex         uint16      `json:"name_and_type_index,omitempty"`
+	DescriptorIndex          uint16      `json:"descriptor_index,omitempty"`
+	StringIndex              uint16      `json:"string_index,omitempty"`
+	ReferenceKind            uint8       `json:"reference_kind,omitempty"`
+	ReferenceIndex           uint16      `json:"reference_index,omitempty"`
+	BootstrapMethodAttrIndex *uint16     `json:"bootstrap_method_attr_index,omitempty"` // 可以为 0，所以用指针
+	ClassName                string      `json:"class_name,omitempty"`                  // 以下为解析后的名称
+	Name                     string      `json:"name,omitempty"`
+	Descriptor               string      `json:"descriptor,omitempty"`
+}
+
+// 对 CONSTANT_Class 的引用
+type ClassRef struct {
+	Index uint16 `json:"index"`
+	Name  string `json:"name"`
+}
+
+type Member struct {
+	AccessFlags     uint16       `json:"access_flags"`
+	NameIndex       uint16       `json:"name_index"`
+	Name            string       `json:"name"`
+	DescriptorIndex uint16       `json:"descriptor_index"`
+	Descriptor      string       `json:"descriptor"`
+	Attributes      []*Attribute `json:"attributes"`
+}
+
+type Attribute struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+type CodeValue struct {
+	MaxStack       uint                   `json:"max_stack"`
+	MaxLocals      uint                   `json:"max_locals"`
+	Code           string                 `json:"code"`
+	ExceptionTable []*ExceptionTableEntry `json:"exception_table"`
+	Attributes     []*Attribute           `json:"attributes"`
+}
+
+type ExceptionTableEntry struct {
+	StartPc   uint16    `json:"start_pc"`
+	EndPc     uint16    `json:"end_pc"`
+	HandlerPc uint16    `json:"handler_pc"`
+	CatchType *ClassRef `json:"catch_type"` // finally 为 null
+}
+
+type ConstantValueValue struct {
+	ConstantValueIndex uint16      `json:"constant_value_index"`
+	Value              interface{} `json:"value"`
+}
+
+type ExceptionsValue struct {
+	Exceptions []*ClassRef `json:"exceptions"`
+}
+
+type SourceFileValue struct {
+	SourceFileIndex uint16 `json:"source_file_index"`
+	SourceFile      string `json:"source_file"`
+}
+
+type LineNumberTableValue struct {
+	LineNumberTable []*LineNumber `json:"line_number_table"`
+}
+
+type LineNumber struct {
+	StartPc    uint16 `json:"start_pc"`
+	LineNumber uint16 `json:"line_number"`
+}
+
+// LocalVariableTable 和 LocalVariableTypeTable 共用，前者给出 descriptor，后者给出 signature
+type LocalVariableTableValue struct {
+	LocalVariables []*LocalVariable `json:"local_variables"`
+}
+
+type LocalVariable struct {
+	StartPc    uint16 `json:"start_pc"`
+	Length     uint16 `json:"length"`
+	Index      uint16 `json:"index"`
+	Name       string `json:"name"`
+	Descriptor string `json:"descriptor,omitempty"`
+	Signature  string `json:"signature,omitempty"`
+}
+
+type MethodParametersValue struct {
+	Parameters []*MethodParameter `json:"parameters"`
+}
+
+type MethodParameter struct {
+	Name        string `json:"name"` // 没有名字的参数为空字符串
+	AccessFlags uint16 `json:"access_flags"`
+}
+
+type StackMapTableValue struct {
+	Entries []*StackMapFrame `json:"entries"`
+}
+
+type StackMapFrame struct {
+	FrameType   uint8                   `json:"frame_type"`
+	OffsetDelta uint16                  `json:"offset_delta"`
+	Locals      []*VerificationTypeInfo `json:"locals"`
+	Stack       []*VerificationTypeInfo `json:"stack"`
+}
+
+type VerificationTypeInfo struct {
+	Tag        uint8     `json:"tag"`
+	CpoolIndex *ClassRef `json:"cpool_index,omitempty"` // ITEM_Object
+	Offset     *uint16   `json:"offset,omitempty"`      // ITEM_Uninitialized
+}
+
+type BootstrapMethodsValue struct {
+	BootstrapMethods []*BootstrapMethod `json:"bootstrap_methods"`
+}
+
+type BootstrapMethod struct {
+	BootstrapMethodRef uint16   `json:"bootstrap_method_ref"`
+	BootstrapArguments []uint16 `json:"bootstrap_arguments"`
+}
+
+// Module 属性中的名称都已从常量池中解析出来
+type ModuleValue struct {
+	Name     string            `json:"name"`
+	Flags    uint16            `json:"flags"`
+	Version  string            `json:"version,omitempty"`
+	Requires []*ModuleRequires `json:"requires"`
+	Exports  []*ModuleExports  `json:"exports"`
+	Opens    []*ModuleExports  `json:"opens"`
+	Uses     []string          `json:"uses"`
+	Provides []*ModuleProvides `json:"provides"`
+}
+
+type ModuleRequires struct {
+	Name    string `json:"name"`
+	Flags   uint16 `json:"flags"`
+	Version string `json:"version,omitempty"`
+}
+
+type ModuleExports struct {
+	Package string   `json:"package"`
+	Flags   uint16   `json:"flags"`
+	To      []string `json:"to"`
+}
+
+type ModuleProvides struct {
+	Service string   `json:"service"`
+	With    []string `json:"with"`
+}
+
+type ModulePackagesValue struct {
+	Packages []string `json:"packages"`
+}
+
+type ModuleMainClassValue struct {
+	MainClass string `json:"main_class"`
+}
+
+type UnparsedValue struct {
+	Info string `json:"info"`
+}
+
+// 把 ClassFile 转换为导出用的结构，常量池索引无效等问题会导致返回错误
+func Export(cf *classfile.ClassFile) (class *Class, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			class = nil
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	cp := cf.ConstantPool()
+	class = &Class{
+		SchemaVersion: SchemaVersion,
+		ConstantPool:  []*Constant{},
+		MinorVersion:  cf.MinorVersion(),
+		MajorVersion:  cf.MajorVersion(),
+		AccessFlags:   cf.AccessFlags(),
+		ThisClass:     classRef(cp, cf.ThisClass()),
+		SuperClass:    classRef(cp, cf.SuperClass()),
+		Interfaces:    []*ClassRef{},
+		Fields:        exportMembers(cp, cf.Fileds()),
+		Methods:       exportMembers(cp, cf.Methods()),
+		Attributes:    exportAttributes(cf, cp, cf.Attributes()),
+	}
+	cp.Walk(func(index uint16, c classfile.ConstantInfo) error {
+		class.ConstantPool = append(class.ConstantPool, exportConstant(index, c))
+		return nil
+	})
+	for _, index := range cf.Interfaces() {
+		class.Interfaces = append(class.Interfaces, classRef(cp, index))
+	}
+	return class, nil
+}
+
+// 以缩进格式写出 JSON，末尾带换行，多个类依次写出时每个类是一个独立的 JSON 文档
+func Write(w io.Writer, cf *classfile.ClassFile) error {
+	class, err := Export(cf)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(class)
+}
+
+func exportConstant(index uint16, c classfile.ConstantInfo) *Constant {
+	constant := &Constant{Index: index}
+	switch c := c.(type) {
+	case *classfile.ConstantUtf8Info:
+		constant.Tag, constant.TagValue, constant.Value = "Utf8", classfile.CONSTANT_Utf8, c.Value()
+	case *classfile.ConstantIntegerInfo:
+		constant.Tag, constant.TagValue, constant.Value = "Integer", classfile.CONSTANT_Integer, c.Value()
+	case *classfile.ConstantFloatInfo:
+		constant.Tag, constant.TagValue, constant.Value = "Float", classfile.CONSTANT_Float, floatValue(float64(c.Value()))
+	case *classfile.ConstantLongInfo:
+		constant.Tag, constant.TagValue, constant.Value = "Long", classfile.CONSTANT_Long, c.Value()
+	case *classfile.ConstantDoubleInfo:
+		constant.Tag, constant.TagValue, constant.Value = "Double", classfile.CONSTANT_Double, floatValue(c.Value())
+	case *classfile.ConstantStringInfo:
+		constant.Tag, constant.TagValue = "String", classfile.CONSTANT_String
+		constant.StringIndex, constant.Value = c.StringIndex(), c.String()
+	case *classfile.ConstantClassInfo:
+		constant.Tag, constant.TagValue = "Class", classfile.CONSTANT_Class
+		constant.NameIndex, constant.Name = c.NameIndex(), c.Name()
+	case *classfile.ConstantFieldrefInfo:
+		constant.Tag, constant.TagValue = "Fieldref", classfile.CONSTANT_Fieldref
+		exportMemberref(constant, &c.ConstantMemberrefInfo)
+	case *classfile.ConstantMethodrefInfo:
+		constant.Tag, constant.TagValue = "Methodref", classfile.CONSTANT_Methodref
+		exportMemberref(constant, &c.ConstantMemberrefInfo)
+	case *classfile.ConstantInterfaceMethodrefInfo:
+		constant.Tag, constant.TagValue = "InterfaceMethodref", classfile.CONSTANT_InterfaceMethodref
+		exportMemberref(constant, &c.ConstantMemberrefInfo)
+	case *classfile.ConstantNameAndTypeInfo:
+		constant.Tag, constant.TagValue = "NameAndType", classfile.CONSTANT_NameAndType
+		constant.NameIndex, constant.DescriptorIndex = c.NameIndex(), c.DescriptorIndex()
+	case *classfile.ConstantMethodHandleInfo:
+		constant.Tag, constant.TagValue = "MethodHandle", classfile.CONSTANT_MethodHandle
+		constant.ReferenceKind, constant.ReferenceIndex = c.ReferenceKind(), c.ReferenceIndex()
+		ref := c.Reference()
+		constant.ClassName = ref.ClassName()
+		constant.Name, constant.Descriptor = ref.NameAndDescriptor()
+	case *classfile.ConstantMethodTypeInfo:
+		constant.Tag, constant.TagValue = "MethodType", classfile.CONSTANT_MethodType
+		constant.DescriptorIndex, constant.Descriptor = c.DescriptorIndex(), c.Descriptor()
+	case *classfile.ConstantDynamicInfo:
+		constant.Tag, constant.TagValue = "Dynamic", classfile.CONSTANT_Dynamic
+		bootstrapIndex := c.BootstrapMethodAttrIndex()
+		constant.BootstrapMethodAttrIndex = &bootstrapIndex
+		constant.NameAndTypeIndex = c.NameAndTypeIndex()
+		constant.Name, constant.Descriptor = c.NameAndDescriptor()
+	case *classfile.ConstantInvokeDynamicInfo:
+		constant.Tag, constant.TagValue = "InvokeDynamic", classfile.CONSTANT_InvokeDynamic
+		bootstrapIndex := c.BootstrapMethodAttrIndex()
+		constant.BootstrapMethodAttrIndex = &bootstrapIndex
+		constant.NameAndTypeIndex = c.NameAndTypeIndex()
+		constant.Name, constant.Descriptor = c.NameAndDescriptor()
+	case *classfile.ConstantModuleInfo:
+		constant.Tag, constant.TagValue = "Module", classfile.CONSTANT_Module
+		constant.NameIndex, constant.Name = c.NameIndex(), c.Name()
+	case *classfile.ConstantPackageInfo:
+		constant.Tag, constant.TagValue = "Package", classfile.CONSTANT_Package
+		constant.NameIndex, constant.Name = c.NameIndex(), c.Name()
+	default:
+		panic(fmt.Sprintf("unknown constant type %T at #%d", c, index))
+	}
+	return constant
+}
+
+func exportMemberref(constant *Constant, ref *classfile.ConstantMemberrefInfo) {
+	constant.ClassIndex, constant.NameAndTypeIndex = ref.ClassIndex(), ref.NameAndTypeIndex()
+	constant.ClassName = ref.ClassName()
+	constant.Name, constant.Descriptor = ref.NameAndDescriptor()
+}
+
+// NaN 和无穷大不能编码为 JSON 数值
+func floatValue(val float64) interface{} {
+	switch {
+	case math.IsNaN(val):
+		return "NaN"
+	case math.IsInf(val, 1):
+		return "Infinity"
+	case math.IsInf(val, -1):
+		return "-Infinity"
+	}
+	return val
+}
+
+// 常量池中可以作为字段初始值的常量
+func constantValue(cp classfile.ConstantPool, index uint16) interface{} {
+	switch c := constantAt(cp, index).(type) {
+	case *classfile.ConstantIntegerInfo:
+		return c.Value()
+	case *classfile.ConstantFloatInfo:
+		return floatValue(float64(c.Value()))
+	case *classfile.ConstantLongInfo:
+		return c.Value()
+	case *classfile.ConstantDoubleInfo:
+		return floatValue(c.Value())
+	case *classfile.ConstantStringInfo:
+		return c.String()
+	}
+	panic(fmt.Sprintf("invalid constant value index #%d", index))
+}
+
+func constantAt(cp classfile.ConstantPool, index uint16) classfile.ConstantInfo {
+	c, err := cp.GetConstantInfo(index)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// 索引为 0 时返回 nil，例如 java/lang/Object 的 super_class 和 finally 的 catch_type
+func classRef(cp classfile.ConstantPool, index uint16) *ClassRef {
+	if index == 0 {
+		return nil
+	}
+	classInfo, ok := constantAt(cp, index).(*classfile.ConstantClassInfo)
+	if !ok {
+		panic(fmt.Sprintf("constant #%d is not a Class", index))
+	}
+	return &ClassRef{Index: index, Name: classInfo.Name()}
+}
+
+func exportMembers(cp classfile.ConstantPool, members []*classfile.MemberInfo) []*Member {
+	exported := []*Member{}
+	for _, member := range members {
+		exported = append(exported, &Member{
+			AccessFlags:     member.AccessFlags(),
+			NameIndex:       member.NameIndex(),
+			Name:            member.Name(),
+			DescriptorIndex: member.DescriptorIndex(),
+			Descriptor:      member.Descriptor(),
+			Attributes:      exportAttributes(nil, cp, member.Attributes()),
+		})
+	}
+	return exported
+}
+
+// cf 只用于取得 Module 属性的解析结果，字段和方法的属性传入 nil
+func exportAttributes(cf *classfile.ClassFile, cp classfile.ConstantPool, attributes []classfile.AttributeInfo) []*Attribute {
+	exported := []*Attribute{}
+	for _, attrInfo := range attributes {
+		exported = append(exported, exportAttribute(cf, cp, attrInfo))
+	}
+	return exported
+}
+
+func exportAttribute(cf *classfile.ClassFile, cp classfile.ConstantPool, attrInfo classfile.AttributeInfo) *Attribute {
+	switch attr := attrInfo.(type) {
+	case *classfile.CodeAttribute:
+		value := &CodeValue{
+			MaxStack:       attr.MaxStack(),
+			MaxLocals:      attr.MaxLocals(),
+			Code:           hex.EncodeToString(attr.Code()),
+			ExceptionTable: []*ExceptionTableEntry{},
+			Attributes:     exportAttributes(nil, cp, attr.Attributes()),
+		}
+		for _, entry := range attr.ExceptionTable() {
+			value.ExceptionTable = append(value.ExceptionTable, &ExceptionTableEntry{
+				StartPc:   entry.StartPc(),
+				EndPc:     entry.EndPc(),
+				HandlerPc: entry.HandlerPc(),
+				CatchType: classRef(cp, entry.CatchType()),
+			})
+		}
+		return &Attribute{Name: "Code", Value: value}
+	case *classfile.ConstantValueAttribute:
+		index := attr.ConstantValueIndex()
+		return &Attribute{Name: "ConstantValue", Value: &ConstantValueValue{index, constantValue(cp, index)}}
+	case *classfile.DeprecatedAttribute:
+		return &Attribute{Name: "Deprecated"}
+	case *classfile.SyntheticAttribute:
+		return &Attribute{Name: "Synthetic"}
+	case *classfile.ExceptionsAttribute:
+		value := &ExceptionsValue{Exceptions: []*ClassRef{}}
+		for _, index := range attr.ExceptionIndexTable() {
+			value.Exceptions = append(value.Exceptions, classRef(cp, index))
+		}
+		return &Attribute{Name: "Exceptions", Value: value}
+	case *classfile.SourceFileAttribute:
+		return &Attribute{Name: "SourceFile", Value: &SourceFileValue{attr.SourceFileIndex(), attr.FileName()}}
+	case *classfile.LineNumberTableAttribute:
+		value := &LineNumberTableValue{LineNumberTable: []*LineNumber{}}
+		for _, entry := range attr.LineNumberTable() {
+			value.LineNumberTable = append(value.LineNumberTable, &LineNumber{entry.StartPc(), entry.LineNumber()})
+		}
+		return &Attribute{Name: "LineNumberTable", Value: value}
+	case *classfile.LocalVariableTableAttribute:
+		value := &LocalVariableTableValue{LocalVariables: []*LocalVariable{}}
+		for _, entry := range attr.LocalVariableTable() {
+			value.LocalVariables = append(value.LocalVariables, &LocalVariable{
+				StartPc: entry.StartPc(), Length: entry.Length(), Index: entry.Index(),
+				Name: entry.Name(), Descriptor: entry.Descriptor(),
+			})
+		}
+		return &Attribute{Name: "LocalVariableTable", Value: value}
+	case *classfile.LocalVariableTypeTableAttribute:
+		value := &LocalVariableTableValue{LocalVariables: []*LocalVariable{}}
+		for _, entry := range attr.LocalVariableTypeTable() {
+			value.LocalVariables = append(value.LocalVariables, &LocalVariable{
+				StartPc: entry.StartPc(), Length: entry.Length(), Index: entry.Index(),
+				Name: entry.Name(), Signature: entry.Signature(),
+			})
+		}
+		return &Attribute{Name: "LocalVariableTypeTable", Value: value}
+	case *classfile.MethodParametersAttribute:
+		value := &MethodParametersValue{Parameters: []*MethodParameter{}}
+		for _, param := range attr.Parameters() {
+			value.Parameters = append(value.Parameters, &MethodParameter{param.Name(), param.AccessFlags()})
+		}
+		return &Attribute{Name: "MethodParameters", Value: value}
+	case *classfile.StackMapTableAttribute:
+		value := &StackMapTableValue{Entries: []*StackMapFrame{}}
+		for _, frame := range attr.Entries() {
+			value.Entries = append(value.Entries, &StackMapFrame{
+				FrameType:   frame.FrameType(),
+				OffsetDelta: frame.OffsetDelta(),
+				Locals:      exportVerificationTypes(cp, frame.Locals()),
+				Stack:       exportVerificationTypes(cp, frame.Stack()),
+			})
+		}
+		return &Attribute{Name: "StackMapTable", Value: value}
+	case *classfile.BootstrapMethodsAttribute:
+		value := &BootstrapMethodsValue{BootstrapMethods: []*BootstrapMethod{}}
+		for _, method := range attr.BootstrapMethods() {
+			args := append([]uint16{}, method.BootstrapArguments()...)
+			value.BootstrapMethods = append(value.BootstrapMethods, &BootstrapMethod{method.BootstrapMethodRef(), args})
+		}
+		return &Attribute{Name: "BootstrapMethods", Value: value}
+	case *classfile.ModuleAttribute:
+		md, err := cf.ModuleDescriptor()
+		if err != nil {
+			panic(err)
+		}
+		return &Attribute{Name: "Module", Value: exportModule(md)}
+	case *classfile.ModulePackagesAttribute:
+		packages := append([]string{}, attr.PackageNames()...)
+		return &Attribute{Name: "ModulePackages", Value: &ModulePackagesValue{packages}}
+	case *classfile.ModuleMainClassAttribute:
+		return &Attribute{Name: "ModuleMainClass", Value: &ModuleMainClassValue{attr.MainClassName()}}
+	case *classfile.UnparsedAttribute:
+		return &Attribute{Name: attr.Name(), Value: &UnparsedValue{hex.EncodeToString(attr.Info())}}
+	}
+	panic(fmt.Sprintf("unknown attribute type %T", attrInfo))
+}
+
+func exportVerificationTypes(cp classfile.ConstantPool, types []*classfile.VerificationTypeInfo) []*VerificationTypeInfo {
+	exported := []*VerificationTypeInfo{}
+	for _, t := range types {
+		info := &VerificationTypeInfo{Tag: t.Tag()}
+		switch t.Tag() {
+		case classfile.ITEM_Object:
+			info.CpoolIndex = classRef(cp, t.CpoolIndex())
+		case classfile.ITEM_Uninitialized:
+			offset := t.Offset()
+			info.Offset = &offset
+		}
+		exported = append(exported, info)
+	}
+	return exported
+}
+
+func exportModule(module *classfile.ModuleDescriptor) *ModuleValue {
+	value := &ModuleValue{
+		Name:     module.Name(),
+		Flags:    module.Flags(),
+		Version:  module.Version(),
+		Requires: []*ModuleRequires{},
+		Exports:  exportModuleExports(module.Exports()),
+		Opens:    exportModuleExports(module.Opens()),
+		Uses:     append([]string{}, module.Uses()...),
+		Provides: []*ModuleProvides{},
+	}
+	for _, requires := range module.Requires() {
+		value.Requires = append(value.Requires, &ModuleRequires{requires.Name(), requires.Flags(), requires.Version()})
+	}
+	for _, provides := range module.Provides() {
+		value.Provides = append(value.Provides, &ModuleProvides{provides.Service(), append([]string{}, provides.With()...)})
+	}
+	return value
+}
+
+func exportModuleExports(exports []*classfile.ModuleExports) []*ModuleExports {
+	exported := []*ModuleExports{}
+	for _, export := range exports {
+		exported = append(exported, &ModuleExports{export.Package(), export.Flags(), append([]string{}, export.Targets()...)})
+	}
+	return exported
+}
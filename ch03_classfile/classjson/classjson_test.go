@@ -0,0 +1,85 @@
+package classjson
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"jvmgo/ch03_classfile/classfile"
+)
+
+var update = flag.Bool("update", false, "重新生成 testdata 中的 .json 文件")
+
+// ../classfile/testdata/roundtrip.jar 中的每个类都有一个对应的 testdata/<类名>.json，
+// 类名中的 / 换成 .，例如 demo.Demo.json。期望结果由 go test -update 生成并经人工检查
+func TestWriteGolden(t *testing.T) {
+	r, err := zip.OpenReader(filepath.Join("..", "classfile", "testdata", "roundtrip.jar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	count := 0
+	for _, file := range r.File {
+		if !strings.HasSuffix(file.Name, ".class") {
+			continue
+		}
+		count++
+		name := strings.Replace(strings.TrimSuffix(file.Name, ".class"), "/", ".", -1)
+		t.Run(name, func(t *testing.T) {
+			cf, err := classfile.Parse(readZipFile(t, file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := Write(&buf, cf); err != nil {
+				t.Fatal(err)
+			}
+
+			// 输出必须能够读回导出用的结构
+			var class Class
+			if err := json.Unmarshal(buf.Bytes(), &class); err != nil {
+				t.Fatalf("output is not valid JSON: %v", err)
+			}
+			if class.ThisClass.Name != cf.ClassName() {
+				t.Errorf("this_class = %q, want %q", class.ThisClass.Name, cf.ClassName())
+			}
+
+			golden := filepath.Join("testdata", name+".json")
+			if *update {
+				if err := ioutil.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != string(want) {
+				t.Errorf("output differs from %s:\n%s", golden, got)
+			}
+		})
+	}
+	if count == 0 {
+		t.Fatal("no classes in roundtrip.jar")
+	}
+}
+
+func readZipFile(t *testing.T, file *zip.File) []byte {
+	rc, err := file.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	data, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
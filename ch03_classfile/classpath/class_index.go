@@ -0,0 +1,258 @@
+package classpath
+
+import (
+	"fmt"
+	"path"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Classpath 按照 boot -> ext -> user 的顺序搜索 class，CompositeEntry 又会依次尝试每一个子路径，
+// 所以一次查找失败意味着对每个目录做一次文件系统查询、对每个 jar 做一次查找，大型应用的 classpath 可能有上百项
+//
+// classIndex 在启动时读取 jar、jmod 等不会变化的 Entry 的目录，记录每个 Entry 包含哪些包，
+// 之后查找 class 时只需要尝试包含该 class 所在包的 Entry，并且会缓存找不到的 class，避免重复查找
+//
+// 目录的内容在运行期间可能变化，而且遍历整个目录树（例如 classpath 为 "." 时）的代价很高，所以目录不参与启动时的索引：
+// 每次查找时只检查包对应的子目录是否存在。找不到的 class 按包缓存，只有当前没有任何目录包含该包时才缓存，
+// 某个目录中出现了该包时丢弃这个包的缓存，之后新增到目录中的类仍然可以找到
+//
+// 远程 jar 文件等需要下载的 Entry 不会为了建立索引而在启动时下载，下载完成之前它对任何包都是候选，
+// 找不到的 class 也不缓存（下载可能失败后重试）；第一次查找触发下载之后再为它建立包索引
+type classIndex struct {
+	entries   []Entry                    // 按照搜索顺序排列的叶子 Entry
+	mutex     sync.RWMutex               // 保护下面的字段
+	packages  []map[string]bool          // 与 entries 一一对应，nil 表示该 Entry 无法枚举、是目录或者尚未下载，任何包都需要查找
+	unfetched map[int]bool               // 尚未下载、还没有建立包索引的 Entry 的下标
+	byPackage map[string][]Entry         // 包名 -> 可能包含该包的 Entry（包括所有目录），按需计算
+	missing   map[string]map[string]bool // 包名 -> 该包中找不到的 class 文件名
+	stats     *Stats
+}
+
+// 能够枚举自身包含哪些包的 Entry 实现这个接口，返回的包名使用 "/" 分隔，默认包为空字符串
+// 返回 false 表示无法枚举
+type packageLister interface {
+	packages() ([]string, bool)
+}
+
+func newClassIndex(stats *Stats, roots ...Entry) *classIndex {
+	index := &classIndex{
+		unfetched: map[int]bool{},
+		byPackage: map[string][]Entry{},
+		missing:   map[string]map[string]bool{},
+		stats:     stats,
+	}
+	for _, root := range roots {
+		index.entries = append(index.entries, leafEntries(root)...)
+	}
+	index.build()
+	return index
+}
+
+// 内容可能在运行期间变化的 Entry（目录）实现这个接口，classIndex 不会事先枚举它的包，
+// 而是在每次查找时调用 hasPackage() 检查，也不会缓存在其中找不到的 class
+type packageProber interface {
+	hasPackage(pkg string) bool
+}
+
+// 需要下载之后才能知道内容的 Entry 实现这个接口，fetched() 返回是否已经下载完成
+type fetcher interface {
+	fetched() bool
+}
+
+// 把 CompositeEntry 展开为叶子 Entry，保持搜索顺序不变
+func leafEntries(entry Entry) []Entry {
+	if composite, ok := entry.(CompositeEntry); ok {
+		var leaves []Entry
+		for _, child := range composite {
+			leaves = append(leaves, leafEntries(child)...)
+		}
+		return leaves
+	}
+	return []Entry{entry}
+}
+
+// 遍历所有叶子 Entry 建立包索引，目录和尚未下载的 Entry 除外
+func (self *classIndex) build() {
+	start := time.Now()
+	self.packages = make([]map[string]bool, len(self.entries))
+	for i, entry := range self.entries {
+		if _, ok := entry.(packageProber); ok {
+			continue
+		}
+		if f, ok := entry.(fetcher); ok && !f.fetched() {
+			self.unfetched[i] = true
+			continue
+		}
+		self.packages[i] = listPackages(entry)
+	}
+	self.stats.addIndexTime(time.Since(start))
+}
+
+// 返回 Entry 包含的包，无法枚举时返回 nil
+func listPackages(entry Entry) map[string]bool {
+	lister, ok := entry.(packageLister)
+	if !ok {
+		return nil
+	}
+	pkgs, ok := lister.packages()
+	if !ok {
+		return nil
+	}
+	set := make(map[string]bool, len(pkgs))
+	for _, pkg := range pkgs {
+		set[pkg] = true
+	}
+	return set
+}
+
+// 为查找过程中下载完成的 Entry 建立包索引，并丢弃已经计算的候选 Entry 列表
+func (self *classIndex) indexFetched() {
+	self.mutex.RLock()
+	n := len(self.unfetched)
+	self.mutex.RUnlock()
+	if n == 0 {
+		return
+	}
+
+	start := time.Now()
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	for i := range self.unfetched {
+		if !self.entries[i].(fetcher).fetched() {
+			continue
+		}
+		delete(self.unfetched, i)
+		self.packages[i] = listPackages(self.entries[i])
+		self.byPackage = map[string][]Entry{}
+	}
+	self.stats.addIndexTime(time.Since(start))
+}
+
+// 返回可能包含 className 的 Entry，className 形如 java/lang/Object.class
+// 目录只有在包对应的子目录存在时才返回，此时 inDirectory 为 true，并且丢弃这个包中找不到的 class 的缓存
+func (self *classIndex) candidates(className string) (candidates []Entry, inDirectory bool) {
+	pkg := packageOf(className)
+	for _, entry := range self.packageEntries(pkg) {
+		if prober, ok := entry.(packageProber); ok {
+			if !prober.hasPackage(pkg) {
+				continue
+			}
+			inDirectory = true
+		}
+		candidates = append(candidates, entry)
+	}
+	if inDirectory {
+		self.forgetMissing(pkg)
+	}
+	return candidates, inDirectory
+}
+
+func (self *classIndex) packageEntries(pkg string) []Entry {
+	self.mutex.RLock()
+	entries, ok := self.byPackage[pkg]
+	if !ok {
+		for i, entry := range self.entries {
+			if self.packages[i] == nil || self.packages[i][pkg] {
+				entries = append(entries, entry)
+			}
+		}
+	}
+	self.mutex.RUnlock()
+	if ok {
+		return entries
+	}
+
+	self.mutex.Lock()
+	self.byPackage[pkg] = entries
+	self.mutex.Unlock()
+	return entries
+}
+
+func (self *classIndex) isMissing(className string) bool {
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+	return self.missing[packageOf(className)][className]
+}
+
+// 调用者需要保证查找时没有目录包含 className 所在的包，见 candidates()
+// 还有尚未下载的 Entry 时不缓存，下载成功之后其中可能有这个 class
+func (self *classIndex) addMissing(className string) {
+	pkg := packageOf(className)
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if len(self.unfetched) > 0 {
+		return
+	}
+	if self.missing[pkg] == nil {
+		self.missing[pkg] = map[string]bool{}
+	}
+	self.missing[pkg][className] = true
+}
+
+func (self *classIndex) forgetMissing(pkg string) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	delete(self.missing, pkg)
+}
+
+// class 文件名所在的包，默认包为空字符串
+func packageOf(className string) string {
+	if pkg := path.Dir(className); pkg != "." {
+		return pkg
+	}
+	return ""
+}
+
+// Stats 记录 classpath 查找的开销，所有计数都是并发安全的
+type Stats struct {
+	lookups       uint64
+	hits          uint64
+	misses        uint64
+	negativeHits  uint64 // 命中了找不到 class 的缓存
+	entriesProbed uint64 // 实际尝试读取的 Entry 次数
+	indexNanos    int64
+	lookupNanos   int64
+}
+
+func (self *Stats) addIndexTime(d time.Duration) {
+	atomic.AddInt64(&self.indexNanos, int64(d))
+}
+
+func (self *Stats) addLookupTime(d time.Duration) {
+	atomic.AddInt64(&self.lookupNanos, int64(d))
+}
+
+// getter 方法
+func (self *Stats) Lookups() uint64 {
+	return atomic.LoadUint64(&self.lookups)
+}
+func (self *Stats) Hits() uint64 {
+	return atomic.LoadUint64(&self.hits)
+}
+func (self *Stats) Misses() uint64 {
+	return atomic.LoadUint64(&self.misses)
+}
+func (self *Stats) NegativeCacheHits() uint64 {
+	return atomic.LoadUint64(&self.negativeHits)
+}
+func (self *Stats) EntriesProbed() uint64 {
+	return atomic.LoadUint64(&self.entriesProbed)
+}
+
+// 建立索引所花费的时间
+func (self *Stats) IndexTime() time.Duration {
+	return time.Duration(atomic.LoadInt64(&self.indexNanos))
+}
+
+// ReadClass() 花费的总时间，包括建立索引和读取 class 数据的时间
+func (self *Stats) LookupTime() time.Duration {
+	return time.Duration(atomic.LoadInt64(&self.lookupNanos))
+}
+
+func (self *Stats) String() string {
+	return fmt.Sprintf("lookups:%d hits:%d misses:%d negative-cache-hits:%d entries-probed:%d index-time:%v lookup-time:%v",
+		self.Lookups(), self.Hits(), self.Misses(), self.NegativeCacheHits(), self.EntriesProbed(),
+		self.IndexTime(), self.LookupTime())
+}
@@ -0,0 +1,129 @@
+package classpath
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestClasspath(entries ...Entry) *Classpath {
+	cp := &Classpath{userClasspath: CompositeEntry(entries)}
+	cp.index = newClassIndex(&cp.stats, cp.userClasspath)
+	return cp
+}
+
+func writeTestClass(t *testing.T, dir, className string) {
+	path := filepath.Join(dir, filepath.FromSlash(className)+".class")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte{0xCA, 0xFE, 0xBA, 0xBE}, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 目录中找不到的 class 不缓存，之后新建的包和 class 文件可以找到
+func TestClassIndexFindsClassesAddedToDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jvmgo-index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	entry, err := newDirEntry(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp := newTestClasspath(entry)
+
+	if _, _, err := cp.ReadClass("p/A"); err == nil {
+		t.Fatal("found p/A before it was created")
+	}
+	writeTestClass(t, dir, "p/A")
+	if _, _, err := cp.ReadClass("p/A"); err != nil {
+		t.Fatalf("p/A was added to the directory but is not found: %v", err)
+	}
+	if n := cp.Stats().NegativeCacheHits(); n != 0 {
+		t.Errorf("negative cache hits = %d, want 0", n)
+	}
+}
+
+// jar 的内容不会变化，找不到的 class 仍然缓存
+func TestClassIndexCachesMissesInJars(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jvmgo-index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	jar := filepath.Join(dir, "a.jar")
+	writeBenchJar(t, jar, 1, 1)
+	entry, err := newZipEntry(jar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer entry.Close()
+	cp := newTestClasspath(entry)
+
+	for i := 0; i < 2; i++ {
+		if _, _, err := cp.ReadClass("bench/p0/Missing"); err == nil {
+			t.Fatal("found a class that is not in the jar")
+		}
+	}
+	if n := cp.Stats().NegativeCacheHits(); n != 1 {
+		t.Errorf("negative cache hits = %d, want 1", n)
+	}
+	if _, _, err := cp.ReadClass("bench/p0/C0"); err != nil {
+		t.Error(err)
+	}
+}
+
+// classpath 中有目录（默认的 classpath 就是 "."）时，目录中没有的包仍然缓存找不到的 class；
+// 目录中出现该包之后缓存作废，新增的 class 可以找到
+func TestClassIndexCachesMissesWithDirectoryOnPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jvmgo-index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	classes := filepath.Join(dir, "classes")
+	writeTestClass(t, classes, "app/Main")
+	jar := filepath.Join(dir, "a.jar")
+	writeBenchJar(t, jar, 1, 1)
+	zipEntry, err := newZipEntry(jar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zipEntry.Close()
+	dirEntry, err := newDirEntry(classes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp := newTestClasspath(dirEntry, zipEntry)
+
+	for i := 0; i < 3; i++ {
+		if _, _, err := cp.ReadClass("bench/p0/Missing"); err == nil {
+			t.Fatal("found a class that is not on the classpath")
+		}
+	}
+	if n := cp.Stats().NegativeCacheHits(); n != 2 {
+		t.Errorf("negative cache hits = %d, want 2", n)
+	}
+
+	// 目录中已有的包不缓存
+	for i := 0; i < 2; i++ {
+		if _, _, err := cp.ReadClass("app/Missing"); err == nil {
+			t.Fatal("found app/Missing before it was created")
+		}
+	}
+	if n := cp.Stats().NegativeCacheHits(); n != 2 {
+		t.Errorf("negative cache hits = %d after looking up a class in a directory package, want 2", n)
+	}
+
+	writeTestClass(t, classes, "bench/p0/Missing")
+	if _, _, err := cp.ReadClass("bench/p0/Missing"); err != nil {
+		t.Fatalf("bench/p0/Missing was added to the directory but is not found: %v", err)
+	}
+	if n := cp.Stats().NegativeCacheHits(); n != 2 {
+		t.Errorf("negative cache hits = %d, want 2", n)
+	}
+}
@@ -1,8 +1,15 @@
 package classpath
 
 import (
+	"errors"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // 用户使用 -Xjre 选项配置启动类和扩展类路径，通过 -classpath/-cp 选项配置用户类路径
@@ -11,64 +18,265 @@ type Classpath struct {
 	bootClasspath Entry
 	extClasspath  Entry
 	userClasspath Entry
+	release       int          // 运行时的 Java 版本，决定多版本 jar 使用哪个版本的 class
+	jre           *JreInfo     // 选择了哪个 JRE 目录以及原因
+	diagnostics   diagnostics  // 不存在或无法读取的路径
+	modules       *moduleGraph // 通过 ResolveModules() 解析得到的模块图，未使用模块路径时为 nil
+	index         *classIndex  // 包名到 Entry 的索引以及找不到的 class 的缓存
+	mutex         sync.RWMutex // 保护 userClasspath 和 index，AppendSource() 可能与查找并发执行
+	stats         Stats
 }
 
-func Parse(jreOption, cpOption string) *Classpath {
+// 解析 classpath，只有找不到 JRE 目录时才返回错误
+// classpath 中不存在或无法读取的路径会被忽略，并记录在 Diagnostics() 中
+func Parse(jreOption, cpOption string) (*Classpath, error) {
 	cp := &Classpath{}
-	cp.parseBootAntExtClasspath(jreOption) // 解析 -Xjre 选项配置的 classpath
-	cp.parseUserClasspath(cpOption)        // 解析 -cp 选项配置的用户 classpath
-	return cp
+	if err := cp.parseBootAntExtClasspath(jreOption); err != nil { // 解析 -Xjre 选项配置的 classpath
+		return nil, err
+	}
+	cp.parseUserClasspath(cpOption) // 解析 -cp 选项配置的用户 classpath
+	for _, entry := range []Entry{cp.bootClasspath, cp.extClasspath, cp.userClasspath} {
+		setRelease(entry, cp.release)
+		cp.diagnostics.checkEntry(entry)
+	}
+	cp.buildIndex()
+	return cp, nil
+}
+
+// 把目标 Java 版本传递给所有 ZipEntry，必须在 jar 文件第一次打开（建立索引）之前调用
+func setRelease(entry Entry, release int) {
+	switch entry := entry.(type) {
+	case *ZipEntry:
+		entry.release = release
+	case CompositeEntry:
+		for _, child := range entry {
+			setRelease(child, release)
+		}
+	}
+}
+
+// 搜索顺序为 boot -> ext -> 模块路径 -> user
+func (self *Classpath) buildIndex() {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	roots := []Entry{self.bootClasspath, self.extClasspath}
+	if self.modules != nil {
+		roots = append(roots, self.modules.entry())
+	}
+	roots = append(roots, self.userClasspath)
+	self.index = newClassIndex(&self.stats, roots...)
+}
+
+func (self *Classpath) getIndex() *classIndex {
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+	return self.index
+}
+
+// 在模块路径（--module-path）中查找模块，从根模块开始解析模块图，
+// 之后 ReadClass() 会在启动类路径之后搜索所有解析到的模块，模块路径上未被解析的模块不可见
+// 缺少依赖的模块等解析错误会一起返回，此时 classpath 保持不变
+func (self *Classpath) ResolveModules(modulePath, rootModule string) error {
+	found, err := findModules(modulePath, self.release)
+	if err != nil {
+		return err
+	}
+	graph, err := resolveModules(rootModule, found, self.isSystemModule)
+	if err != nil {
+		return err
+	}
+	self.modules = graph
+	self.buildIndex()
+	return nil
+}
+
+// 判断模块是否由启动类路径提供：JDK 9 及以上版本查找 lib/modules，
+// 更早的版本没有模块，所有 java.* 和 jdk.* 模块都视为由 rt.jar 等提供
+func (self *Classpath) isSystemModule(name string) bool {
+	if image, ok := self.bootClasspath.(*JImageEntry); ok {
+		return image.hasModule(name)
+	}
+	return strings.HasPrefix(name, "java.") || strings.HasPrefix(name, "jdk.")
+}
+
+// 返回解析得到的模块，模块不存在或者没有解析模块图时返回 nil
+func (self *Classpath) Module(name string) *Module {
+	if self.modules == nil {
+		return nil
+	}
+	return self.modules.modules[name]
+}
+
+// 按照解析顺序返回所有解析得到的模块，根模块在最前面
+func (self *Classpath) Modules() []*Module {
+	if self.modules == nil {
+		return nil
+	}
+	return self.modules.order
+}
+
+// 以模块 moduleName 的视角读取 class：只搜索启动类路径和该模块可以读取的模块，
+// 命名模块不能读取未命名模块，所以不会搜索用户类路径
+func (self *Classpath) ReadClassFromModule(moduleName, className string) ([]byte, Entry, error) {
+	from := self.Module(moduleName)
+	if from == nil {
+		return nil, nil, errors.New("module not resolved: " + moduleName)
+	}
+	className = className + ".class"
+	if data, entry, err := self.bootClasspath.readClass(className); err == nil {
+		return data, entry, nil
+	}
+	for _, module := range self.modules.order {
+		if !from.CanRead(module.name) {
+			continue
+		}
+		if data, entry, err := module.readClass(className); err == nil {
+			return data, entry, nil
+		}
+	}
+	return nil, nil, errors.New("class not found: " + className)
 }
 
 // Classpath 的 ReadClass 方法按照 boot -> ext -> user 的顺序搜索提供的 class 文件名
+// 借助索引只尝试包含该 class 所在包的 Entry，找不到的 class 会被缓存，再次查找时直接返回错误
 func (self *Classpath) ReadClass(className string) ([]byte, Entry, error) {
+	start := time.Now()
+	defer func() { self.stats.addLookupTime(time.Since(start)) }()
+	atomic.AddUint64(&self.stats.lookups, 1)
+
 	className = className + ".class"
-	if data, entry, err := self.bootClasspath.readClass(className); err == nil {
-		return data, entry, err
+	index := self.getIndex()
+	candidates, inDirectory := index.candidates(className)
+	if !inDirectory && index.isMissing(className) {
+		atomic.AddUint64(&self.stats.negativeHits, 1)
+		return nil, nil, errors.New("class not found: " + className)
+	}
+	for _, entry := range candidates {
+		atomic.AddUint64(&self.stats.entriesProbed, 1)
+		if data, from, err := entry.readClass(className); err == nil {
+			atomic.AddUint64(&self.stats.hits, 1)
+			index.indexFetched()
+			return data, from, nil
+		}
 	}
-	if data, entry, err := self.extClasspath.readClass(className); err == nil {
-		return data, entry, err
+	atomic.AddUint64(&self.stats.misses, 1)
+	index.indexFetched() // 远程 jar 文件可能在这次查找中下载完成
+	if !inDirectory {
+		index.addMissing(className)
 	}
-	return self.userClasspath.readClass(className)
+	return nil, nil, errors.New("class not found: " + className)
+}
+
+// 返回 classpath 查找的统计信息
+func (self *Classpath) Stats() *Stats {
+	return &self.stats
+}
+
+// 关闭 boot、ext、user 三个 classpath 以及模块路径中打开的 jar 文件
+// 关闭之后仍然可以继续读取 class，jar 文件会在需要时重新打开
+func (self *Classpath) Close() error {
+	var firstErr error
+	self.mutex.RLock()
+	entries := []Entry{self.bootClasspath, self.extClasspath, self.userClasspath}
+	self.mutex.RUnlock()
+	if self.modules != nil {
+		entries = append(entries, self.modules.entry())
+	}
+	for _, entry := range entries {
+		if err := entry.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
 }
 
 func (self *Classpath) String() string {
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
 	return self.userClasspath.String()
 }
 
-func (self *Classpath) parseBootAntExtClasspath(jreOption string) {
+func (self *Classpath) parseBootAntExtClasspath(jreOption string) error {
 	// 获取 jre 路径，为 bootClasspath 与 extClasspath 服务
-	jreDir := getJreDir(jreOption)
+	jre, err := findJre(jreOption)
+	if err != nil {
+		return err
+	}
+	self.jre = jre
 
-	jreLibPath := filepath.Join(jreDir, "lib", "*")
-	self.bootClasspath = newWildcardEntry(jreLibPath) // 建立 bootClasspath
-	jreExtPath := filepath.Join(jreDir, "lib", "ext", "*")
-	self.extClasspath = newWildcardEntry(jreExtPath) // 建立 extClasspath
+	// JDK 9 及以上版本把所有模块打包为 lib/modules，并且取消了扩展机制，所以 extClasspath 为空
+	if modules := filepath.Join(jre.dir, "lib", "modules"); exists(modules) {
+		image, err := newJImageEntry(modules)
+		if err != nil {
+			return err
+		}
+		self.bootClasspath = image
+		self.extClasspath = CompositeEntry{}
+		self.release = detectRelease(jre.dir, 9)
+		jre.layout, jre.release = "lib/modules", self.release
+		return nil
+	}
+	self.release = detectRelease(jre.dir, 8)
+	jre.layout, jre.release = "lib/*.jar, lib/ext/*.jar", self.release
+
+	jreLibPath := filepath.Join(jre.dir, "lib", "*")
+	self.bootClasspath = newWildcardEntry(jreLibPath, &self.diagnostics) // 建立 bootClasspath
+	if len(self.bootClasspath.(CompositeEntry)) == 0 {
+		self.diagnostics.add(filepath.Join(jre.dir, "lib"), "no jar files found for the boot classpath")
+	}
+	jreExtPath := filepath.Join(jre.dir, "lib", "ext", "*")
+	self.extClasspath = newWildcardEntry(jreExtPath, &self.diagnostics) // 建立 extClasspath
+	return nil
 }
 
 func (self *Classpath) parseUserClasspath(cpOption string) {
 	if cpOption == "" {
 		cpOption = "." // 如果用户未通过 -cp，则默认使用当前路径为 userclasspath
 	}
-	self.userClasspath = newEntry(cpOption)
+	if self.userClasspath = newEntry(cpOption, &self.diagnostics); self.userClasspath == nil {
+		self.userClasspath = CompositeEntry{}
+	}
 }
 
-// 根据配置值尝试建立 jre 路径，为 bootClasspath 与 extClasspath 服务
-// 优先使用 -Xjre 选项配置的路径作为 classpath，若无则使用 JAVA_HOME
-func getJreDir(jreOption string) string {
-	// 如果输入的路径存在，则立刻返回
-	if jreOption != "" && exists(jreOption) {
-		return jreOption
-	}
-	// 如果输入路径无效，则尝试在当前目录下寻找 jre 目录
-	if exists("./jre") {
-		return "./jre"
-	}
-	// 如果 jre 目录不存在，则尝试寻找环境变量
-	if jh := os.Getenv("JAVA_HOME"); jh != "" {
-		return filepath.Join(jh, "jre")
+// 选择了哪个 JRE 目录以及原因
+func (self *Classpath) Jre() *JreInfo {
+	return self.jre
+}
+
+// classpath 中不存在或无法读取的路径
+func (self *Classpath) Diagnostics() []*Diagnostic {
+	return self.diagnostics
+}
+
+// 从 JDK 根目录下的 release 文件中读取 JAVA_VERSION，得到主版本号（feature version），
+// 例如 "1.8.0_202" 为 8，"11.0.2" 为 11。JDK 8 的 jre 目录位于 JDK 根目录之下，所以也会查找上一级目录
+// 找不到 release 文件或者无法解析时返回 defaultRelease
+func detectRelease(jreDir string, defaultRelease int) int {
+	for _, path := range []string{filepath.Join(jreDir, "release"), filepath.Join(jreDir, "..", "release")} {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if !strings.HasPrefix(line, "JAVA_VERSION=") {
+				continue
+			}
+			version := strings.Trim(strings.TrimSpace(line[len("JAVA_VERSION="):]), `"`)
+			version = strings.TrimPrefix(version, "1.")
+			if end := strings.IndexAny(version, "._-+"); end >= 0 {
+				version = version[:end]
+			}
+			if release, err := strconv.Atoi(version); err == nil {
+				return release
+			}
+		}
 	}
-	panic("Cannot find jre folder!")
+	return defaultRelease
+}
+
+// 运行时的 Java 版本
+func (self *Classpath) Release() int {
+	return self.release
 }
 
 // 判断一个目录是否存在
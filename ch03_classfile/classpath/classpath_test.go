@@ -0,0 +1,248 @@
+package classpath
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 在 dir 下建立 JDK 8 风格的 JRE：lib/rt.jar、lib/ext/ 以及 release 文件
+func writeTestJre8(t *testing.T, dir string) {
+	writeTestFile(t, filepath.Join(dir, "lib", "rt.jar"), zipBytes(t, map[string][]byte{
+		"java/lang/Object.class": testClass,
+	}))
+	if err := os.MkdirAll(filepath.Join(dir, "lib", "ext"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(dir, "release"), []byte("JAVA_VERSION=\"1.8.0_202\"\n"))
+}
+
+// 在 dir 下建立 JDK 9 及以上版本风格的 JRE：lib/modules 以及 release 文件
+func writeTestJre11(t *testing.T, dir string) {
+	builder := newJImageBuilder(binary.LittleEndian)
+	builder.add("java.base", "java/lang", "Object", "class", testClassData(), "")
+	image, _, _ := builder.bytes()
+	writeTestFile(t, filepath.Join(dir, "lib", "modules"), image)
+	writeTestFile(t, filepath.Join(dir, "release"), []byte("JAVA_VERSION=\"11.0.2\"\n"))
+}
+
+// 切换到 dir 作为当前目录，测试结束后恢复，findJre() 会查找当前目录下的 jre 目录
+func chdir(t *testing.T, dir string) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestFindJre(t *testing.T) {
+	tests := []struct {
+		name      string
+		layout    []string // 需要建立的目录，相对于临时目录
+		jreOption string
+		javaHome  string
+		dir       string // 选择的 JRE 目录，为空表示应当返回错误
+		source    string
+		rejected  []string // Rejected() 或错误信息中应当包含的内容
+	}{
+		{
+			name:      "-Xjre",
+			layout:    []string{"myjre", "jre"},
+			jreOption: "myjre",
+			dir:       "myjre",
+			source:    "-Xjre",
+		},
+		{
+			name:      "missing -Xjre falls back to ./jre",
+			layout:    []string{"jre"},
+			jreOption: "nojre",
+			dir:       "./jre",
+			source:    "current directory",
+			rejected:  []string{"-Xjre nojre: no such directory"},
+		},
+		{
+			name:     "JDK 9+ JAVA_HOME",
+			layout:   []string{"jdk11/lib/modules"},
+			javaHome: "jdk11",
+			dir:      "jdk11",
+			source:   "JAVA_HOME",
+			rejected: []string{"-Xjre: not specified", "current directory ./jre: no such directory"},
+		},
+		{
+			name:     "JDK 8 JAVA_HOME",
+			layout:   []string{"jdk8/jre/lib"},
+			javaHome: "jdk8",
+			dir:      "jdk8/jre",
+			source:   "JAVA_HOME",
+		},
+		{
+			name:     "standalone JRE in JAVA_HOME",
+			layout:   []string{"jre8/lib"},
+			javaHome: "jre8",
+			dir:      "jre8",
+			source:   "JAVA_HOME",
+		},
+		{
+			name:     "JAVA_HOME not set",
+			rejected: []string{"-Xjre: not specified", "current directory ./jre: no such directory", "JAVA_HOME: not set"},
+		},
+		{
+			name:      "nothing exists",
+			jreOption: "nojre",
+			javaHome:  "nojdk",
+			rejected:  []string{"-Xjre nojre: no such directory", "JAVA_HOME nojdk: no such directory"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chdir(t, t.TempDir())
+			for _, dir := range test.layout {
+				if err := os.MkdirAll(dir, 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			t.Setenv("JAVA_HOME", test.javaHome)
+
+			jre, err := findJre(test.jreOption)
+			if test.dir == "" {
+				if err == nil {
+					t.Fatalf("found %s, want an error", jre.Dir())
+				}
+				for _, want := range test.rejected {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("error %q does not mention %q", err, want)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if jre.Dir() != filepath.FromSlash(test.dir) {
+				t.Errorf("dir = %s, want %s", jre.Dir(), test.dir)
+			}
+			if jre.Source() != test.source {
+				t.Errorf("source = %s, want %s", jre.Source(), test.source)
+			}
+			rejected := strings.Join(jre.Rejected(), "\n")
+			for _, want := range test.rejected {
+				if !strings.Contains(rejected, want) {
+					t.Errorf("rejected %q does not mention %q", jre.Rejected(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseJreInfo(t *testing.T) {
+	tests := []struct {
+		name    string
+		write   func(t *testing.T, dir string)
+		layout  string
+		release int
+	}{
+		{"JDK 8", writeTestJre8, "lib/*.jar, lib/ext/*.jar", 8},
+		{"JDK 11", writeTestJre11, "lib/modules", 11},
+	}
+	for _, test := range tests {
+		dir := t.TempDir()
+		test.write(t, dir)
+		cp, err := Parse(dir, dir)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		jre := cp.Jre()
+		if jre.Dir() != dir || jre.Source() != "-Xjre" || jre.Layout() != test.layout || jre.Release() != test.release {
+			t.Errorf("%s: jre = %s (%s), layout %q, release %d; want %s (-Xjre), layout %q, release %d",
+				test.name, jre.Dir(), jre.Source(), jre.Layout(), jre.Release(), dir, test.layout, test.release)
+		}
+		if cp.Release() != test.release {
+			t.Errorf("%s: classpath release = %d, want %d", test.name, cp.Release(), test.release)
+		}
+		if _, _, err := cp.ReadClass("java/lang/Object"); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+		if diags := cp.Diagnostics(); len(diags) != 0 {
+			t.Errorf("%s: unexpected diagnostics %v", test.name, diags)
+		}
+		cp.Close()
+	}
+}
+
+func TestParseDiagnostics(t *testing.T) {
+	dir := t.TempDir()
+	jre := filepath.Join(dir, "jre")
+	writeTestJre8(t, jre)
+	classes := filepath.Join(dir, "classes")
+	if err := os.MkdirAll(classes, 0755); err != nil {
+		t.Fatal(err)
+	}
+	notJar := filepath.Join(dir, "notes.txt")
+	writeTestFile(t, notJar, []byte("not a class path entry"))
+	badJar := filepath.Join(dir, "bad.jar")
+	writeTestFile(t, badJar, []byte("not a zip file"))
+	missingDir := filepath.Join(dir, "missing")
+	missingJar := filepath.Join(dir, "missing.jar")
+
+	cpOption := strings.Join([]string{classes, missingDir, notJar, badJar, missingJar}, string(os.PathListSeparator))
+	cp, err := Parse(jre, cpOption)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cp.Close()
+
+	want := map[string]string{
+		missingDir: "no such file or directory",
+		notJar:     "not a directory or jar file",
+		badJar:     "cannot open jar file",
+		missingJar: "no such file or directory",
+	}
+	got := map[string]string{}
+	for _, diag := range cp.Diagnostics() {
+		got[diag.Path()] = diag.Message()
+	}
+	for path, message := range want {
+		if !strings.Contains(got[path], message) {
+			t.Errorf("%s: diagnostic %q, want %q", path, got[path], message)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("diagnostics = %v, want %d entries", cp.Diagnostics(), len(want))
+	}
+	if _, _, err := cp.ReadClass("java/lang/Object"); err != nil {
+		t.Errorf("boot classpath unusable after diagnostics: %v", err)
+	}
+}
+
+// JRE 的 lib 目录中没有 jar 文件时 Parse() 仍然成功，但会给出警告
+func TestParseEmptyJreLib(t *testing.T) {
+	jre := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(jre, "lib"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	cp, err := Parse(jre, jre)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, diag := range cp.Diagnostics() {
+		if diag.Path() == filepath.Join(jre, "lib") && strings.Contains(diag.Message(), "no jar files found") {
+			return
+		}
+	}
+	t.Errorf("diagnostics = %v, want a \"no jar files found\" warning for lib", cp.Diagnostics())
+}
+
+// 找不到 JRE 是 Parse() 唯一的错误
+func TestParseNoJre(t *testing.T) {
+	chdir(t, t.TempDir())
+	t.Setenv("JAVA_HOME", "")
+	if cp, err := Parse(filepath.Join("no", "such", "jre"), "."); err == nil {
+		t.Errorf("Parse() = %v, want an error", cp)
+	}
+}
@@ -0,0 +1,139 @@
+package classpath
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Diagnostic 描述 classpath 中一个有问题的路径，例如路径不存在、jar 文件无法打开等
+// 与 java 命令一样，这些问题不会导致 Parse() 失败，有问题的路径只是找不到任何 class，
+// 但它们常常是 "找不到类" 的原因，命令行可以通过 -Xlint:classpath 输出
+type Diagnostic struct {
+	path    string
+	message string
+}
+
+type diagnostics []*Diagnostic
+
+func (self *diagnostics) add(path, format string, args ...interface{}) {
+	*self = append(*self, &Diagnostic{path: path, message: fmt.Sprintf(format, args...)})
+}
+
+// getter 方法
+func (self *Diagnostic) Path() string {
+	return self.path
+}
+func (self *Diagnostic) Message() string {
+	return self.message
+}
+
+func (self *Diagnostic) String() string {
+	return self.path + ": " + self.message
+}
+
+// 检查 Entry 中的每个目录和 jar 文件能否读取
+func (self *diagnostics) checkEntry(entry Entry) {
+	for _, leaf := range leafEntries(entry) {
+		switch leaf := leaf.(type) {
+		case *DirEntry:
+			info, err := os.Stat(leaf.absDir)
+			switch {
+			case os.IsNotExist(err):
+				self.add(leaf.absDir, "no such file or directory")
+			case err != nil:
+				self.add(leaf.absDir, "%v", err)
+			case !info.IsDir():
+				self.add(leaf.absDir, "not a directory or jar file")
+			}
+		case *ZipEntry:
+			if !exists(leaf.absPath) {
+				self.add(leaf.absPath, "no such file or directory")
+			} else if err := leaf.open(); err != nil {
+				self.add(leaf.absPath, "cannot open jar file: %v", err)
+			}
+		}
+	}
+}
+
+// JreInfo 说明选择了哪个 JRE 目录、为什么选择它，以及之前尝试过但被放弃的候选目录
+// 候选目录依次为：-Xjre 选项、当前目录下的 jre 目录、JAVA_HOME
+type JreInfo struct {
+	dir      string
+	source   string   // 目录来自哪里："-Xjre"、"current directory" 或 "JAVA_HOME"
+	layout   string   // 启动类的布局：lib/modules（JDK 9+）或 lib/*.jar
+	release  int      // 运行时的 Java 版本
+	rejected []string // 被放弃的候选目录及原因
+}
+
+func findJre(jreOption string) (*JreInfo, error) {
+	jre := &JreInfo{}
+	try := func(dir, source string) bool {
+		if !exists(dir) {
+			jre.rejected = append(jre.rejected, fmt.Sprintf("%s %s: no such directory", source, dir))
+			return false
+		}
+		jre.dir, jre.source = dir, source
+		return true
+	}
+
+	if jreOption == "" {
+		jre.rejected = append(jre.rejected, "-Xjre: not specified")
+	} else if try(jreOption, "-Xjre") {
+		return jre, nil
+	}
+	if try("./jre", "current directory") {
+		return jre, nil
+	}
+	jh := os.Getenv("JAVA_HOME")
+	switch {
+	case jh == "":
+		jre.rejected = append(jre.rejected, "JAVA_HOME: not set")
+	case exists(filepath.Join(jh, "lib", "modules")):
+		// JDK 9 及以上版本没有 jre 子目录，lib/modules 直接位于 JAVA_HOME 下
+		if try(jh, "JAVA_HOME") {
+			return jre, nil
+		}
+	case exists(filepath.Join(jh, "jre")):
+		if try(filepath.Join(jh, "jre"), "JAVA_HOME") {
+			return jre, nil
+		}
+	default:
+		// JAVA_HOME 本身可能就是独立安装的 JRE
+		if try(jh, "JAVA_HOME") {
+			return jre, nil
+		}
+	}
+	return nil, errors.New("cannot find jre folder: " + strings.Join(jre.rejected, "; "))
+}
+
+// getter 方法
+func (self *JreInfo) Dir() string {
+	return self.dir
+}
+func (self *JreInfo) Source() string {
+	return self.source
+}
+func (self *JreInfo) Layout() string {
+	return self.layout
+}
+func (self *JreInfo) Release() int {
+	return self.release
+}
+func (self *JreInfo) Rejected() []string {
+	return self.rejected
+}
+
+func (self *JreInfo) String() string {
+	lines := []string{
+		fmt.Sprintf("jre: %s (from %s)", self.dir, self.source),
+		fmt.Sprintf("layout: %s", self.layout),
+		fmt.Sprintf("release: %d", self.release),
+	}
+	for _, rejected := range self.rejected {
+		lines = append(lines, "skipped: "+rejected)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -12,30 +12,55 @@ import (
 // （分隔符因系统而定，Win 为 `;`，类 UNIX 为 `:`）
 const pathListSeparator = string(os.PathListSeparator)
 
-// Entry 是一个接口，包含两个方法
+// Entry 是一个接口，包含五个方法
 type Entry interface {
 	// 负责寻找和加载 .class 文件（相对路径），返回字节数据、Entry 实例和错误信息
 	// golang 和 Python 类似，可以同时返回多个返回值
 	readClass(className string) ([]byte, Entry, error) // 根据提供的 className 读取 class 字节码
+	readResource(name string) ([]byte, Entry, error)   // 读取任意资源文件，name 是以 "/" 分隔的相对路径
 	String() string                                    // 类似于 Java 的 toString() 作用
+	Close() error                                      // 释放打开的文件句柄，长期运行的程序可以用它回收资源
+	Walk(fn func(name string) error) error             // 按文件名顺序遍历所有文件，fn 返回错误时停止遍历并返回该错误
 }
 
 // 根据参数创建不同类型的 Entry 接口实例
 // Entry 接口共有 4 个实现方式，分别是 DirEntry、ZipEntry、CompositeEntry 和 WildcardEntry
-func newEntry(path string) Entry {
-	// 若包含系统分隔符（即加载多个类和目录），则返回 CompositeEntry 实例
-	if strings.Contains(path, pathListSeparator) {
-		return newCompositeEntry(path)
+// 另外 JImageEntry 只用于 JDK 9 及以上版本的启动类路径，不会由 newEntry() 创建
+// URL 形式的路径（如 http://host/classes/）由 RegisterScheme() 注册的 SourceOpener 创建 SourceEntry
+// 无法创建的路径会记录在 diag 中并返回 nil
+func newEntry(path string, diag *diagnostics) Entry {
+	// 若包含系统分隔符（即加载多个类和目录），则返回 CompositeEntry 实例，URL 中的 ":" 不算分隔符
+	if len(splitPathList(path)) > 1 {
+		return newCompositeEntry(path, diag)
+	}
+	// 若以已注册的 URL scheme 开头，则由对应的 SourceOpener 创建 ClassSource
+	if _, opener := schemeOf(path); opener != nil {
+		source, err := opener(path)
+		if err != nil {
+			diag.add(path, "%v", err)
+			return nil
+		}
+		return newSourceEntry(source)
 	}
 	// 若包含 `*`（即加载目录下所有 jar 文件），则返回 WildcardEntry 实例
 	if strings.Contains(path, "*") {
-		return newWildcardEntry(path)
+		return newWildcardEntry(path, diag)
 	}
 	// 若包含 jar/zip 文件名，则返回 ZipEntry 实例
 	if strings.HasSuffix(path, ".jar") || strings.HasSuffix(path, ".JAR") ||
 		strings.HasSuffix(path, ".zip") || strings.HasSuffix(path, ".ZIP") {
-		return newZipEntry(path)
+		entry, err := newZipEntry(path)
+		if err != nil {
+			diag.add(path, "%v", err)
+			return nil
+		}
+		return entry
 	}
 	// 加载目录，返回 DirEntry
-	return newDirEntry(path)
+	entry, err := newDirEntry(path)
+	if err != nil {
+		diag.add(path, "%v", err)
+		return nil
+	}
+	return entry
 }
@@ -9,11 +9,13 @@ import (
 // 这里通过 type 定义了一个新的数据结构：Entry 数组
 type CompositeEntry []Entry
 
-func newCompositeEntry(pathList string) CompositeEntry {
+func newCompositeEntry(pathList string, diag *diagnostics) CompositeEntry {
 	compositeEntry := []Entry{} // 先创建一个存储 Entry 接口类型的数组
-	for _, path := range strings.Split(pathList, pathListSeparator) {
-		entry := newEntry(path) // 切割 pathList 并遍历每一个 path，通过 path 建立继承自 Entry 接口的结构体实例
-		compositeEntry = append(compositeEntry, entry)
+	for _, path := range splitPathList(pathList) {
+		entry := newEntry(path, diag) // 切割 pathList 并遍历每一个 path，通过 path 建立继承自 Entry 接口的结构体实例
+		if entry != nil {
+			compositeEntry = append(compositeEntry, entry)
+		}
 	}
 	return compositeEntry
 }
@@ -32,6 +34,38 @@ func (self CompositeEntry) readClass(className string) ([]byte, Entry, error) {
 	return nil, nil, errors.New("class not found: " + className)
 }
 
+// 依次在每一个子路径中查找资源，返回第一个找到的资源
+func (self CompositeEntry) readResource(name string) ([]byte, Entry, error) {
+	for _, entry := range self {
+		data, from, err := entry.readResource(name)
+		if err == nil {
+			return data, from, nil
+		}
+	}
+	return nil, nil, errors.New("resource not found: " + name)
+}
+
+// 依次遍历每一个子路径
+func (self CompositeEntry) Walk(fn func(name string) error) error {
+	for _, entry := range self {
+		if err := entry.Walk(fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 依次关闭每一个子路径，返回遇到的第一个错误
+func (self CompositeEntry) Close() error {
+	var firstErr error
+	for _, entry := range self {
+		if err := entry.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
 func (self CompositeEntry) String() string {
 	strs := make([]string, len(self))
 	for i, entry := range self {
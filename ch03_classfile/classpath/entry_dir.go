@@ -1,8 +1,11 @@
 package classpath
 
 import (
+	"errors"
 	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strings"
 )
 
 // DirEntry 结构体，只有一个字段，用于存放 classpath 绝对路径
@@ -10,12 +13,12 @@ type DirEntry struct {
 	absDir string
 }
 
-func newDirEntry(path string) *DirEntry {
+func newDirEntry(path string) (*DirEntry, error) {
 	dir, err := filepath.Abs(path) // 将相对路径转换为绝对路径
 	if err != nil {                // 通过多值返回捕获可能的异常
-		panic(err) // 有异常则进行 panic() 中断执行
+		return nil, err // 有异常则返回错误，由调用者决定如何处理
 	}
-	return &DirEntry{absDir: dir}
+	return &DirEntry{absDir: dir}, nil
 }
 
 // DirEntry 结构体实现 Entry 接口 readClass() 方法
@@ -26,8 +29,76 @@ func (self *DirEntry) readClass(className string) ([]byte, Entry, error) {
 	return data, self, err
 }
 
+// 读取目录下的资源文件，name 中不能有 ".." 等跳出目录的部分
+func (self *DirEntry) readResource(name string) ([]byte, Entry, error) {
+	if !isValidResourceName(name) {
+		return nil, nil, errors.New("invalid resource name: " + name)
+	}
+	return self.readClass(name)
+}
+
+// 遍历目录树，文件名是相对于 absDir 的 "/" 分隔路径
+func (self *DirEntry) Walk(fn func(name string) error) error {
+	return filepath.Walk(self.absDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(self.absDir, path)
+		if err != nil {
+			return err
+		}
+		return fn(filepath.ToSlash(rel))
+	})
+}
+
+// DirEntry 每次读取都直接打开文件，不持有文件句柄，所以 Close() 什么也不做
+func (self *DirEntry) Close() error {
+	return nil
+}
+
 // DirEntry 结构体实现 Entry 接口 String() 方法
 // 至此结构体 DirEntry 已经实现了 Entry 接口的所有方法，DirEntry 成为了 Entry 接口的实现
 func (self *DirEntry) String() string {
 	return self.absDir
 }
+
+// 检查包对应的子目录是否存在，classIndex 每次查找都会调用，所以之后新建的包也能找到
+func (self *DirEntry) hasPackage(pkg string) bool {
+	info, err := os.Stat(filepath.Join(self.absDir, filepath.FromSlash(pkg)))
+	return err == nil && info.IsDir()
+}
+
+// 遍历目录，返回所有包含 class 文件的子目录（即包名），用于没有 ModulePackages 属性的展开模块
+// 遍历出错时返回 false
+func (self *DirEntry) packages() ([]string, bool) {
+	seen := map[string]bool{}
+	pkgs := []string{}
+	err := filepath.Walk(self.absDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".class") {
+			return nil
+		}
+		rel, err := filepath.Rel(self.absDir, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		pkg := filepath.ToSlash(rel)
+		if pkg == "." {
+			pkg = ""
+		}
+		if !seen[pkg] {
+			seen[pkg] = true
+			pkgs = append(pkgs, pkg)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false
+	}
+	return pkgs, true
+}
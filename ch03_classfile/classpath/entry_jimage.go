@@ -0,0 +1,162 @@
+package classpath
+
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// JImageEntry 从 JDK 9 及以上版本的 lib/modules 文件中读取 class，用作启动类路径
+// 与 ZipEntry 一样在第一次读取时打开文件并一直保持打开，Close() 之后再次读取会重新打开
+type JImageEntry struct {
+	absPath string
+	mutex   sync.RWMutex
+	image   *jimage
+}
+
+func newJImageEntry(path string) (*JImageEntry, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	return &JImageEntry{absPath: absPath}, nil
+}
+
+// JImageEntry 结构体实现 Entry 接口 readClass() 方法
+// jimage 中的资源名称包含模块名，所以先根据包名找到模块，再拼出 /模块名/className 查找
+func (self *JImageEntry) readClass(className string) ([]byte, Entry, error) {
+	for {
+		self.mutex.RLock()
+		if self.image != nil {
+			break
+		}
+		self.mutex.RUnlock()
+		if err := self.open(); err != nil {
+			return nil, nil, err
+		}
+	}
+	defer self.mutex.RUnlock()
+
+	module, ok := self.image.modules[packageOf(className)]
+	if !ok {
+		return nil, nil, errors.New("class not found: " + className)
+	}
+	loc, ok := self.image.findLocation("/" + module + "/" + className)
+	if !ok {
+		return nil, nil, errors.New("class not found: " + className)
+	}
+	data, err := self.image.readResource(loc)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, self, nil
+}
+
+// 包中的资源只需在该包所属的模块中查找；不属于任何包的资源（如 META-INF/services/ 下的文件）需要依次查找每个模块
+func (self *JImageEntry) readResource(name string) ([]byte, Entry, error) {
+	for {
+		self.mutex.RLock()
+		if self.image != nil {
+			break
+		}
+		self.mutex.RUnlock()
+		if err := self.open(); err != nil {
+			return nil, nil, err
+		}
+	}
+	defer self.mutex.RUnlock()
+
+	var modules []string
+	if module, ok := self.image.modules[packageOf(name)]; ok {
+		modules = []string{module}
+	} else {
+		for module := range self.image.names {
+			modules = append(modules, module)
+		}
+		sort.Strings(modules)
+	}
+	for _, module := range modules {
+		if loc, ok := self.image.findLocation("/" + module + "/" + name); ok {
+			data, err := self.image.readResource(loc)
+			if err != nil {
+				return nil, nil, err
+			}
+			return data, self, nil
+		}
+	}
+	return nil, nil, errors.New("resource not found: " + name)
+}
+
+func (self *JImageEntry) open() error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if self.image != nil {
+		return nil
+	}
+	image, err := openJImage(self.absPath)
+	if err != nil {
+		return err
+	}
+	self.image = image
+	return nil
+}
+
+// 返回 jimage 中所有模块包含的包
+func (self *JImageEntry) packages() ([]string, bool) {
+	if err := self.open(); err != nil {
+		return nil, false
+	}
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+	if self.image == nil {
+		return nil, false
+	}
+	pkgs := make([]string, 0, len(self.image.modules))
+	for pkg := range self.image.modules {
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs, true
+}
+
+// jimage 中是否有名为 name 的模块
+func (self *JImageEntry) hasModule(name string) bool {
+	if err := self.open(); err != nil {
+		return false
+	}
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+	return self.image != nil && self.image.names[name]
+}
+
+// 遍历 jimage 中所有模块的文件，文件名不含模块名；每个模块都有的 module-info.class 只出现一次
+func (self *JImageEntry) Walk(fn func(name string) error) error {
+	for {
+		self.mutex.RLock()
+		if self.image != nil {
+			break
+		}
+		self.mutex.RUnlock()
+		if err := self.open(); err != nil {
+			return err
+		}
+	}
+	names := self.image.resourceNames()
+	self.mutex.RUnlock()
+	return walkNames(names, fn)
+}
+
+func (self *JImageEntry) Close() error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if self.image == nil {
+		return nil
+	}
+	err := self.image.close()
+	self.image = nil
+	return err
+}
+
+func (self *JImageEntry) String() string {
+	return self.absPath
+}
@@ -0,0 +1,176 @@
+package classpath
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// jmod 文件是 JDK 9 引入的模块打包格式（位于 $JAVA_HOME/jmods），由 4 个字节的文件头 "JM" 0x01 0x00
+// 和紧随其后的 zip 文件组成，class 文件位于 zip 中的 classes/ 目录下
+// zip 的中央目录中的偏移是相对于文件头之后的位置计算的，所以读取时需要跳过文件头
+const jmodClassesPrefix = "classes/"
+
+var jmodMagic = []byte{'J', 'M', 0x01, 0x00}
+
+// JmodEntry 与 ZipEntry 一样，在第一次读取时打开文件并建立索引，Close() 之后再次读取会重新打开
+type JmodEntry struct {
+	absPath string
+	mutex   sync.RWMutex
+	file    *os.File
+	files   map[string]*zip.File // 去掉 classes/ 前缀的文件名 -> zip 文件中的文件
+}
+
+func newJmodEntry(path string) (*JmodEntry, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	return &JmodEntry{absPath: absPath}, nil
+}
+
+// JmodEntry 结构体实现 Entry 接口 readClass() 方法
+func (self *JmodEntry) readClass(className string) ([]byte, Entry, error) {
+	for {
+		self.mutex.RLock()
+		if self.file != nil {
+			break
+		}
+		self.mutex.RUnlock()
+		if err := self.open(); err != nil {
+			return nil, nil, err
+		}
+	}
+	defer self.mutex.RUnlock()
+
+	f, ok := self.files[className]
+	if !ok {
+		return nil, nil, errors.New("class not found: " + className)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rc.Close()
+
+	data, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, self, nil
+}
+
+// 资源文件同样位于 classes/ 目录下
+func (self *JmodEntry) readResource(name string) ([]byte, Entry, error) {
+	return self.readClass(name)
+}
+
+// 打开 jmod 文件，校验文件头并为 classes/ 下的文件建立索引
+func (self *JmodEntry) open() error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if self.file != nil {
+		return nil
+	}
+
+	file, err := os.Open(self.absPath)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	header := make([]byte, len(jmodMagic))
+	if _, err := io.ReadFull(file, header); err != nil || !bytes.Equal(header, jmodMagic) {
+		file.Close()
+		return errors.New(self.absPath + ": not a jmod file")
+	}
+	size := int64(len(jmodMagic))
+	r, err := zip.NewReader(io.NewSectionReader(file, size, info.Size()-size), info.Size()-size)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	files := map[string]*zip.File{}
+	for _, f := range r.File {
+		if !strings.HasPrefix(f.Name, jmodClassesPrefix) {
+			continue // bin/、conf/、lib/ 等目录下是命令、配置文件和本地库
+		}
+		name := f.Name[len(jmodClassesPrefix):]
+		if _, ok := files[name]; !ok {
+			files[name] = f
+		}
+	}
+	self.file, self.files = file, files
+	return nil
+}
+
+// 返回 classes/ 下所有 class 文件所在的包
+func (self *JmodEntry) packages() ([]string, bool) {
+	if err := self.open(); err != nil {
+		return nil, false
+	}
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+	if self.file == nil {
+		return nil, false
+	}
+	seen := map[string]bool{}
+	pkgs := []string{}
+	for name := range self.files {
+		if !strings.HasSuffix(name, ".class") {
+			continue
+		}
+		if pkg := packageOf(name); !seen[pkg] {
+			seen[pkg] = true
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	return pkgs, true
+}
+
+// 遍历 classes/ 下的文件，文件名不含 classes/ 前缀
+func (self *JmodEntry) Walk(fn func(name string) error) error {
+	for {
+		self.mutex.RLock()
+		if self.file != nil {
+			break
+		}
+		self.mutex.RUnlock()
+		if err := self.open(); err != nil {
+			return err
+		}
+	}
+	names := make([]string, 0, len(self.files))
+	for name, f := range self.files {
+		if !f.FileInfo().IsDir() {
+			names = append(names, name)
+		}
+	}
+	self.mutex.RUnlock()
+	return walkNames(names, fn)
+}
+
+func (self *JmodEntry) Close() error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if self.file == nil {
+		return nil
+	}
+	err := self.file.Close()
+	self.file, self.files = nil, nil
+	return err
+}
+
+func (self *JmodEntry) String() string {
+	return self.absPath
+}
@@ -13,7 +13,8 @@ import (
 //
 // 对于带有通配符 `*` 的路径，首先需要去除末尾星号，然后通过 filepath.Walk() 对目录遍历
 // filepath.Walk() 方法支持自定义遍历方法
-func newWildcardEntry(path string) CompositeEntry {
+// 遍历目录时遇到的错误会记录在 diag 中
+func newWildcardEntry(path string, diag *diagnostics) CompositeEntry {
 	baseDir := path[:len(path)-1] // 去除 `*`
 	compositeEntry := []Entry{}
 
@@ -21,13 +22,18 @@ func newWildcardEntry(path string) CompositeEntry {
 	// `type WalkFunc func(path string, info os.FileInfo, err error) error`
 	findClassFiles := func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			return err
+			diag.add(path, "%v", err)
+			return nil // 继续遍历其余文件
 		}
 		if info.IsDir() && path != baseDir {
 			return filepath.SkipDir // 如果当前遍历文件为目录则跳过，因为通配符路径不能递归
 		}
 		if strings.HasSuffix(path, ".jar") || strings.HasSuffix(path, ".JAR") {
-			jarEntry := newZipEntry(path) // 如果当前文件为 jar 文件，则为其建立 ZipEntry
+			jarEntry, err := newZipEntry(path) // 如果当前文件为 jar 文件，则为其建立 ZipEntry
+			if err != nil {
+				diag.add(path, "%v", err)
+				return nil
+			}
 			compositeEntry = append(compositeEntry, jarEntry)
 		}
 		return nil
@@ -5,48 +5,214 @@ import (
 	"errors"
 	"io/ioutil"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 )
 
+// ZipEntry 在第一次读取 class 时打开 zip 文件，并以文件名为 key 建立索引，之后一直保持打开状态，
+// 避免每次寻找 class 文件都重新打开并遍历整个 zip 文件（rt.jar 有 60MB，上万个文件）
+//
+// 多个 goroutine 可以同时调用 readClass()：zip.File.Open() 通过 ReadAt 读取数据，本身是并发安全的，
+// 这里的读写锁只用于保护 zip 文件的打开和关闭。Close() 之后再次读取会重新打开 zip 文件
+//
+// 多版本 jar（Multi-Release: true）的 META-INF/versions/N/ 下是针对 Java N 及以上版本的 class，
+// release 不小于 9 时，建立索引时用版本号不超过 release 的最高版本覆盖根目录下的同名文件
 type ZipEntry struct {
-	absPath string
+	absPath  string
+	release  int // 目标 Java 版本，必须在第一次打开之前设置
+	mutex    sync.RWMutex
+	reader   *zip.ReadCloser
+	files    map[string]*zip.File // 文件名 -> zip 文件中的文件
+	manifest *Manifest            // 没有 META-INF/MANIFEST.MF 时为 nil
 }
 
-func newZipEntry(path string) *ZipEntry {
+const multiReleasePrefix = "META-INF/versions/"
+
+func newZipEntry(path string) (*ZipEntry, error) {
 	absPath, err := filepath.Abs(path)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	return &ZipEntry{absPath: absPath}
+	return &ZipEntry{absPath: absPath}, nil
 }
 
 // ZipEntry 结构体实现 Entry 接口 readClass() 方法
-// 从 zip 文件进行遍历并提取与 class Name 同名的 class 文件
-// 这里可以看到，目前每一次寻找 class 文件时都需要遍历
-// TODO: 可以优化
+// 通过索引找到与 className 同名的 class 文件并读取
 func (self *ZipEntry) readClass(className string) ([]byte, Entry, error) {
-	r, err := zip.OpenReader(self.absPath) // 尝试打开 zip 文件，如果出错则直接返回
+	// 持有读锁期间 zip 文件不会被关闭；如果尚未打开（或已被关闭），先释放读锁并打开
+	for {
+		self.mutex.RLock()
+		if self.reader != nil {
+			break
+		}
+		self.mutex.RUnlock()
+		if err := self.open(); err != nil {
+			return nil, nil, err
+		}
+	}
+	defer self.mutex.RUnlock()
+
+	f, ok := self.files[className]
+	if !ok {
+		return nil, nil, errors.New("class not found: " + className)
+	}
+	data, err := readZipFile(f)
 	if err != nil {
 		return nil, nil, err
 	}
-	defer r.Close()
+	return data, self, nil
+}
+
+// jar 文件中的 class 和资源文件没有区别，多版本 jar 同样优先使用对应版本的资源
+func (self *ZipEntry) readResource(name string) ([]byte, Entry, error) {
+	return self.readClass(name)
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open() // 尝试打开文件，若打开失败则直接返回
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(rc) // 若文件打开成功，则尝试读取文件内容
+}
+
+// 打开 zip 文件并建立索引，zip 文件已经打开时什么也不做
+func (self *ZipEntry) open() error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if self.reader != nil {
+		return nil
+	}
+
+	r, err := zip.OpenReader(self.absPath) // 尝试打开 zip 文件，如果出错则直接返回
+	if err != nil {
+		return err
+	}
+	files := make(map[string]*zip.File, len(r.File))
+	for _, f := range r.File {
+		if _, ok := files[f.Name]; !ok { // zip 文件中有同名文件时，和遍历查找一样以第一个为准
+			files[f.Name] = f
+		}
+	}
+
+	var manifest *Manifest
+	if f, ok := files["META-INF/MANIFEST.MF"]; ok {
+		if data, err := readZipFile(f); err == nil {
+			manifest = parseManifest(data)
+		}
+	}
+	if manifest != nil && manifest.IsMultiRelease() && self.release >= 9 {
+		overlayVersionedFiles(r.File, files, self.release)
+	}
+	self.reader, self.files, self.manifest = r, files, manifest
+	return nil
+}
 
-	for _, f := range r.File { // 若 zip 文件打开成功，则遍历并寻找 class 文件
-		if f.Name == className {
-			rc, err := f.Open() // 若文件名为 className 则尝试打开当前遍历的文件，若打开失败则直接返回
-			if err != nil {
-				return nil, nil, err
-			}
-			defer rc.Close()
+// 对每个文件，用 META-INF/versions/N/ 下 9 <= N <= release 的最高版本覆盖根目录下的同名文件
+func overlayVersionedFiles(zipFiles []*zip.File, files map[string]*zip.File, release int) {
+	versions := map[string]int{}
+	for _, f := range zipFiles {
+		if !strings.HasPrefix(f.Name, multiReleasePrefix) {
+			continue
+		}
+		rest := f.Name[len(multiReleasePrefix):]
+		i := strings.IndexByte(rest, '/')
+		if i < 0 {
+			continue
+		}
+		version, err := strconv.Atoi(rest[:i])
+		if err != nil || version < 9 || version > release {
+			continue
+		}
+		if name := rest[i+1:]; name != "" && version > versions[name] {
+			files[name] = f
+			versions[name] = version
+		}
+	}
+}
+
+// 返回 jar 文件的 manifest，没有 manifest 或者 jar 文件无法打开时返回 nil
+func (self *ZipEntry) Manifest() *Manifest {
+	if err := self.open(); err != nil {
+		return nil
+	}
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+	return self.manifest
+}
+
+// 打开 zip 文件，从中央目录中得到所有 class 文件所在的包
+// zip 文件无法打开时返回 false，此时 classpath 会对任何包都尝试这个 zip 文件，并在读取时报告错误
+func (self *ZipEntry) packages() ([]string, bool) {
+	if err := self.open(); err != nil {
+		return nil, false
+	}
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+	if self.reader == nil { // 刚打开就被 Close() 了
+		return nil, false
+	}
+	seen := map[string]bool{}
+	pkgs := []string{}
+	for name := range self.files {
+		if !strings.HasSuffix(name, ".class") || strings.HasPrefix(name, multiReleasePrefix) {
+			continue
+		}
+		if pkg := packageOf(name); !seen[pkg] {
+			seen[pkg] = true
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	return pkgs, true
+}
 
-			data, err := ioutil.ReadAll(rc) // 若文件打开成功，则尝试读取文件内容
-			if err != nil {
-				return nil, nil, err
-			}
+// 遍历 zip 文件的中央目录，多版本 jar 中 META-INF/versions/ 下的文件以覆盖后的名称出现，不会单独列出
+func (self *ZipEntry) Walk(fn func(name string) error) error {
+	// 与 readClass() 相同，在读锁内确认 zip 文件已经打开，防止并发的 Close() 把索引置为 nil
+	for {
+		self.mutex.RLock()
+		if self.reader != nil {
+			break
+		}
+		self.mutex.RUnlock()
+		if err := self.open(); err != nil {
+			return err
+		}
+	}
+	names := make([]string, 0, len(self.files))
+	for name, f := range self.files {
+		if !strings.HasPrefix(name, multiReleasePrefix) && !f.FileInfo().IsDir() {
+			names = append(names, name)
+		}
+	}
+	self.mutex.RUnlock()
+	return walkNames(names, fn)
+}
 
-			return data, self, nil
+// 按名称顺序对每个文件调用 fn
+func walkNames(names []string, fn func(name string) error) error {
+	sort.Strings(names)
+	for _, name := range names {
+		if err := fn(name); err != nil {
+			return err
 		}
 	}
-	return nil, nil, errors.New(" class not found: " + className)
+	return nil
+}
+
+// 关闭 zip 文件并释放索引，会等待正在进行的读取完成
+func (self *ZipEntry) Close() error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if self.reader == nil {
+		return nil
+	}
+	err := self.reader.Close()
+	self.reader, self.files, self.manifest = nil, nil, nil
+	return err
 }
 
 func (self *ZipEntry) String() string {
@@ -0,0 +1,153 @@
+package classpath
+
+import (
+	"archive/zip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 生成一个与 rt.jar 规模相近的 jar（20000 个 class 文件，分布在 200 个包中），
+// 测量 ZipEntry 打开并建立索引之后读取单个 class 的开销
+func BenchmarkZipEntryReadClass(b *testing.B) {
+	dir, err := ioutil.TempDir("", "jvmgo-bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	jar := filepath.Join(dir, "bench.jar")
+	names := writeBenchJar(b, jar, 200, 100)
+
+	entry, err := newZipEntry(jar)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer entry.Close()
+	if _, _, err := entry.readClass(names[0]); err != nil { // 打开 zip 文件并建立索引不计入结果
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := entry.readClass(names[i%len(names)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// 写出 packages 个包、每个包 classes 个 class 文件的 jar，返回所有 class 文件名
+func writeBenchJar(tb testing.TB, path string, packages, classes int) []string {
+	f, err := os.Create(path)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer f.Close()
+	w := zip.NewWriter(f)
+	data := make([]byte, 2048)
+	copy(data, []byte{0xCA, 0xFE, 0xBA, 0xBE})
+	var names []string
+	for p := 0; p < packages; p++ {
+		for c := 0; c < classes; c++ {
+			name := fmt.Sprintf("bench/p%d/C%d.class", p, c)
+			fw, err := w.Create(name)
+			if err != nil {
+				tb.Fatal(err)
+			}
+			if _, err := fw.Write(data); err != nil {
+				tb.Fatal(err)
+			}
+			names = append(names, name)
+		}
+	}
+	if err := w.Close(); err != nil {
+		tb.Fatal(err)
+	}
+	return names
+}
+
+// 多版本 jar：根目录、versions/9 和 versions/11 下各有一个 p/A.class，内容分别为 "8"、"9" 和 "11"
+func writeMultiReleaseJar(t *testing.T, multiRelease bool) string {
+	manifest := "Manifest-Version: 1.0\r\n"
+	if multiRelease {
+		manifest += "Multi-Release: true\r\n"
+	}
+	path := filepath.Join(t.TempDir(), "mr.jar")
+	writeTestFile(t, path, zipBytes(t, map[string][]byte{
+		"META-INF/MANIFEST.MF":                 []byte(manifest),
+		"p/A.class":                            []byte("8"),
+		"p/B.class":                            []byte("8"),
+		"META-INF/versions/9/p/A.class":        []byte("9"),
+		"META-INF/versions/11/p/A.class":       []byte("11"),
+		"META-INF/versions/11/p/Only11.class":  []byte("11"),
+		"META-INF/versions/x/p/B.class":        []byte("x"),
+		"META-INF/versions/8/p/B.class":        []byte("versions/8"),
+		"META-INF/versions/17/q/Later17.class": []byte("17"),
+	}))
+	return path
+}
+
+func TestZipEntryMultiRelease(t *testing.T) {
+	tests := []struct {
+		multiRelease bool
+		release      int
+		want         map[string]string // class 文件名 -> 内容，空字符串表示找不到
+	}{
+		{true, 8, map[string]string{"p/A.class": "8", "p/B.class": "8", "p/Only11.class": ""}},
+		{true, 9, map[string]string{"p/A.class": "9", "p/B.class": "8", "p/Only11.class": ""}},
+		{true, 10, map[string]string{"p/A.class": "9", "p/Only11.class": ""}},
+		{true, 11, map[string]string{"p/A.class": "11", "p/B.class": "8", "p/Only11.class": "11", "q/Later17.class": ""}},
+		{true, 17, map[string]string{"p/A.class": "11", "p/Only11.class": "11", "q/Later17.class": "17"}},
+		{false, 17, map[string]string{"p/A.class": "8", "p/Only11.class": "", "q/Later17.class": ""}},
+	}
+	for _, test := range tests {
+		entry, err := newZipEntry(writeMultiReleaseJar(t, test.multiRelease))
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry.release = test.release
+		for className, want := range test.want {
+			data, _, err := entry.readClass(className)
+			if want == "" {
+				if err == nil {
+					t.Errorf("multi-release %v, release %d: %s found (%q)", test.multiRelease, test.release, className, data)
+				}
+				continue
+			}
+			if err != nil || string(data) != want {
+				t.Errorf("multi-release %v, release %d: %s = %q, %v; want %q", test.multiRelease, test.release, className, data, err, want)
+			}
+		}
+		if got := entry.Manifest().IsMultiRelease(); got != test.multiRelease {
+			t.Errorf("IsMultiRelease() = %v, want %v", got, test.multiRelease)
+		}
+		entry.Close()
+	}
+}
+
+func TestDetectRelease(t *testing.T) {
+	tests := []struct {
+		release string // JDK 根目录下 release 文件的内容，为空表示没有这个文件
+		jreDir  string // 相对于 JDK 根目录
+		want    int
+	}{
+		{`JAVA_VERSION="1.8.0_202"`, "jre", 8},
+		{"IMPLEMENTOR=\"x\"\nJAVA_VERSION=\"11.0.2\"\n", "", 11},
+		{`JAVA_VERSION="17"`, "", 17},
+		{`JAVA_VERSION="21-ea"`, "", 21},
+		{`JAVA_VERSION="bogus"`, "", 7},
+		{"", "", 7},
+	}
+	for _, test := range tests {
+		jdk := t.TempDir()
+		if test.release != "" {
+			writeTestFile(t, filepath.Join(jdk, "release"), []byte(test.release))
+		}
+		jreDir := filepath.Join(jdk, test.jreDir)
+		os.MkdirAll(jreDir, 0755)
+		if got := detectRelease(jreDir, 7); got != test.want {
+			t.Errorf("release file %q: got %d, want %d", test.release, got, test.want)
+		}
+	}
+}
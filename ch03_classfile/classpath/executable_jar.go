@@ -0,0 +1,76 @@
+package classpath
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 以 -jar 方式启动时，用户类路径由 jar 文件本身和 manifest 中 Class-Path 属性列出的路径组成，-classpath 选项被忽略
+// 返回 manifest 中 Main-Class 属性给出的主类名
+//
+// Class-Path 是以空格分隔的相对 URL，相对于 jar 文件所在的目录，以 "/" 结尾的表示目录，其余都作为 jar 文件
+// 与 -classpath 不同，其中的 "*" 不是通配符；不存在的路径会被忽略，并记录在 Diagnostics() 中
+func (self *Classpath) UseJar(jarPath string) (string, error) {
+	jarEntry, err := newZipEntry(jarPath)
+	if err != nil {
+		return "", err
+	}
+	jarEntry.release = self.release
+	manifest := jarEntry.Manifest()
+	if manifest == nil {
+		if !exists(jarPath) {
+			return "", errors.New("Unable to access jarfile " + jarPath)
+		}
+		return "", errors.New("no main manifest attribute, in " + jarPath)
+	}
+	mainClass := manifest.MainAttribute("Main-Class")
+	if mainClass == "" {
+		return "", errors.New("no main manifest attribute, in " + jarPath)
+	}
+
+	userClasspath := CompositeEntry{jarEntry}
+	for _, path := range manifestClassPath(manifest, filepath.Dir(jarEntry.absPath)) {
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			self.diagnostics.add(path, "Class-Path entry of %s: no such file or directory", jarPath)
+			continue
+		} else if err != nil {
+			self.diagnostics.add(path, "Class-Path entry of %s: %v", jarPath, err)
+			continue
+		}
+		if info.IsDir() {
+			if entry, err := newDirEntry(path); err == nil {
+				userClasspath = append(userClasspath, entry)
+			}
+		} else if entry, err := newZipEntry(path); err == nil {
+			entry.release = self.release
+			userClasspath = append(userClasspath, entry)
+		}
+	}
+	self.diagnostics.checkEntry(userClasspath)
+
+	self.userClasspath.Close()
+	self.userClasspath = userClasspath
+	self.buildIndex()
+	return mainClass, nil
+}
+
+// 把 Class-Path 属性中的相对 URL 转换为本地路径，只支持相对路径和 file: URL
+func manifestClassPath(manifest *Manifest, baseDir string) []string {
+	var paths []string
+	for _, field := range strings.Fields(manifest.MainAttribute("Class-Path")) {
+		u, err := url.Parse(field)
+		if err != nil || (u.Scheme != "" && u.Scheme != "file") {
+			continue
+		}
+		path := filepath.FromSlash(u.Path)
+		if u.Scheme == "" && !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
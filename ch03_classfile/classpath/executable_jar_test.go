@@ -0,0 +1,137 @@
+package classpath
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// app/app.jar 的 Class-Path 引用了 app/lib 下的 jar、app/classes/ 目录和 app 之外的 shared.jar，
+// 其中 missing.jar、http URL 和 "*" 都会被跳过
+func writeExecutableJar(t *testing.T, manifest string) (dir, jar string) {
+	dir = t.TempDir()
+	jar = filepath.Join(dir, "app", "app.jar")
+	writeTestFile(t, jar, zipBytes(t, map[string][]byte{
+		"META-INF/MANIFEST.MF": []byte(manifest),
+		"com/app/Main.class":   testClass,
+	}))
+	writeTestFile(t, filepath.Join(dir, "app", "lib", "a.jar"), zipBytes(t, map[string][]byte{"a/A.class": testClass}))
+	writeTestFile(t, filepath.Join(dir, "app", "lib dir", "b.jar"), zipBytes(t, map[string][]byte{"b/B.class": testClass}))
+	writeTestFile(t, filepath.Join(dir, "app", "classes", "c", "C.class"), testClass)
+	writeTestFile(t, filepath.Join(dir, "shared.jar"), zipBytes(t, map[string][]byte{"s/S.class": testClass}))
+	return dir, jar
+}
+
+func TestUseJar(t *testing.T) {
+	tests := []struct {
+		name      string
+		manifest  string
+		mainClass string
+		found     []string // 应当能找到的类
+		missing   []string // 不应当找到的类
+	}{
+		{
+			name:      "Main-Class only",
+			manifest:  "Manifest-Version: 1.0\nMain-Class: com.app.Main\n",
+			mainClass: "com.app.Main",
+			found:     []string{"com/app/Main"},
+			missing:   []string{"a/A", "c/C", "old/Old"},
+		},
+		{
+			name: "Class-Path relative to the jar",
+			manifest: "Manifest-Version: 1.0\nMain-Class: com.app.Main\n" +
+				"Class-Path: lib/a.jar lib%20dir/b.jar classes/ ../shared.jar\n",
+			mainClass: "com.app.Main",
+			found:     []string{"com/app/Main", "a/A", "b/B", "c/C", "s/S"},
+			missing:   []string{"old/Old"},
+		},
+		{
+			name: "Class-Path with continuation lines",
+			manifest: "Manifest-Version: 1.0\r\nMain-Class: com.app.Main\r\n" +
+				"Class-Path: lib/a.j\r\n ar classes/\r\n",
+			mainClass: "com.app.Main",
+			found:     []string{"a/A", "c/C"},
+		},
+		{
+			name: "missing and unsupported Class-Path entries are skipped",
+			manifest: "Manifest-Version: 1.0\nMain-Class: com.app.Main\n" +
+				"Class-Path: missing.jar http://example.com/x.jar lib/* nothere/ lib/a.jar\n",
+			mainClass: "com.app.Main",
+			found:     []string{"com/app/Main", "a/A"},
+			missing:   []string{"b/B"},
+		},
+	}
+	for _, test := range tests {
+		_, jar := writeExecutableJar(t, test.manifest)
+		// -classpath 指定的目录被忽略
+		classes := t.TempDir()
+		writeTestFile(t, filepath.Join(classes, "old", "Old.class"), testClass)
+		cp := newModuleTestClasspath()
+		dirEntry, err := newDirEntry(classes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cp.userClasspath = CompositeEntry{dirEntry}
+		cp.buildIndex()
+
+		mainClass, err := cp.UseJar(jar)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if mainClass != test.mainClass {
+			t.Errorf("%s: main class = %q, want %q", test.name, mainClass, test.mainClass)
+		}
+		for _, className := range test.found {
+			if _, _, err := cp.ReadClass(className); err != nil {
+				t.Errorf("%s: %s not found on %s", test.name, className, cp)
+			}
+		}
+		for _, className := range test.missing {
+			if _, _, err := cp.ReadClass(className); err == nil {
+				t.Errorf("%s: %s found on %s", test.name, className, cp)
+			}
+		}
+		cp.Close()
+	}
+}
+
+func TestUseJarFileURL(t *testing.T) {
+	dir := t.TempDir()
+	shared := filepath.Join(dir, "shared.jar")
+	writeTestFile(t, shared, zipBytes(t, map[string][]byte{"s/S.class": testClass}))
+	_, jar := writeExecutableJar(t, "Main-Class: com.app.Main\nClass-Path: file://"+filepath.ToSlash(shared)+"\n")
+
+	cp := newModuleTestClasspath()
+	if _, err := cp.UseJar(jar); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := cp.ReadClass("s/S"); err != nil {
+		t.Errorf("s/S not found on %s", cp)
+	}
+}
+
+func TestUseJarErrors(t *testing.T) {
+	_, noMain := writeExecutableJar(t, "Manifest-Version: 1.0\n")
+	noManifest := filepath.Join(t.TempDir(), "plain.jar")
+	writeTestFile(t, noManifest, zipBytes(t, map[string][]byte{"p/A.class": testClass}))
+	missing := filepath.Join(t.TempDir(), "missing.jar")
+
+	tests := []struct {
+		jar  string
+		want string
+	}{
+		{noMain, "no main manifest attribute, in " + noMain},
+		{noManifest, "no main manifest attribute, in " + noManifest},
+		{missing, "Unable to access jarfile " + missing},
+	}
+	for _, test := range tests {
+		cp := newModuleTestClasspath()
+		if _, err := cp.UseJar(test.jar); err == nil || err.Error() != test.want {
+			t.Errorf("UseJar(%s): got error %v, want %q", test.jar, err, test.want)
+		}
+		if strings.Contains(cp.String(), test.jar) {
+			t.Errorf("UseJar(%s) changed the user classpath after an error", test.jar)
+		}
+	}
+}
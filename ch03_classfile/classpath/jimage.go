@@ -0,0 +1,456 @@
+package classpath
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// JDK 9 开始不再提供 rt.jar，而是把所有模块的 class 和资源文件打包为 lib/modules 文件，其格式为 jimage
+// jimage 文件由索引和资源两部分组成，索引部分的结构如下：
+// jimage {
+//     u4 magic;                 // 0xCAFEDADA
+//     u4 version;               // 高 16 位为主版本号，低 16 位为次版本号，目前为 1.0
+//     u4 flags;
+//     u4 resource_count;
+//     u4 table_length;
+//     u4 locations_size;
+//     u4 strings_size;
+//     s4 redirect[table_length]; // 哈希表的重定向表
+//     u4 offsets[table_length];  // 每个资源的 location 在 locations 中的偏移
+//     u1 locations[locations_size];
+//     u1 strings[strings_size];  // 以 0 结尾的 MUTF-8 字符串
+// }
+//
+// 与 class 文件不同，jimage 文件中的整数使用生成该文件的平台的字节序，需要根据 magic 判断
+// 资源的完整名称为 /模块名/包路径/类名.扩展名，例如 /java.base/java/lang/Object.class
+const (
+	jimageMagic          = 0xCAFEDADA
+	jimageMajorVersion   = 1
+	jimageMinorVersion   = 0
+	jimageHeaderSize     = 7 * 4
+	jimageHashMultiplier = 0x01000193
+)
+
+// location 由一系列属性组成，每个属性的第一个字节高 5 位是属性类型，低 3 位是属性值的字节数减 1，
+// 随后是大端序存放的属性值，以 ATTRIBUTE_END 结束
+// 模块名、包路径、类名和扩展名都是 strings 中的偏移，资源内容的偏移则相对于索引部分的末尾
+const (
+	jimageAttrEnd          = 0
+	jimageAttrModule       = 1
+	jimageAttrParent       = 2
+	jimageAttrBase         = 3
+	jimageAttrExtension    = 4
+	jimageAttrOffset       = 5
+	jimageAttrCompressed   = 6 // 压缩后的大小，为 0 表示没有压缩
+	jimageAttrUncompressed = 7
+	jimageAttrCount        = 8
+)
+
+type jimageLocation [jimageAttrCount]uint64
+
+type jimage struct {
+	file      *os.File
+	order     binary.ByteOrder
+	redirect  []int32
+	offsets   []uint32
+	locations []byte
+	strings   []byte
+	indexSize int64             // 资源部分的起始位置
+	modules   map[string]string // 包名 -> 模块名
+	names     map[string]bool   // 所有模块名
+}
+
+func openJImage(path string) (*jimage, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	image := &jimage{file: file}
+	if err := image.readIndex(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	image.indexPackages()
+	return image, nil
+}
+
+func (self *jimage) close() error {
+	return self.file.Close()
+}
+
+// 读取 jimage 的索引部分
+func (self *jimage) readIndex() error {
+	header := make([]byte, jimageHeaderSize)
+	if _, err := self.file.ReadAt(header, 0); err != nil {
+		return errors.New("not a jimage file")
+	}
+	switch {
+	case binary.LittleEndian.Uint32(header) == jimageMagic:
+		self.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(header) == jimageMagic:
+		self.order = binary.BigEndian
+	default:
+		return errors.New("not a jimage file")
+	}
+	version := self.order.Uint32(header[4:])
+	if version>>16 != jimageMajorVersion || version&0xFFFF != jimageMinorVersion {
+		return fmt.Errorf("unsupported jimage version %d.%d", version>>16, version&0xFFFF)
+	}
+
+	tableLength := int64(self.order.Uint32(header[16:]))
+	locationsSize := int64(self.order.Uint32(header[20:]))
+	stringsSize := int64(self.order.Uint32(header[24:]))
+	self.indexSize = jimageHeaderSize + tableLength*8 + locationsSize + stringsSize
+	info, err := self.file.Stat()
+	if err != nil {
+		return err
+	}
+	if self.indexSize > info.Size() {
+		return errors.New("truncated jimage file")
+	}
+
+	index := make([]byte, self.indexSize-jimageHeaderSize)
+	if _, err := self.file.ReadAt(index, jimageHeaderSize); err != nil {
+		return err
+	}
+	self.redirect = make([]int32, tableLength)
+	self.offsets = make([]uint32, tableLength)
+	for i := int64(0); i < tableLength; i++ {
+		self.redirect[i] = int32(self.order.Uint32(index[i*4:]))
+		self.offsets[i] = self.order.Uint32(index[(tableLength+i)*4:])
+	}
+	self.locations = index[tableLength*8 : tableLength*8+locationsSize]
+	self.strings = index[tableLength*8+locationsSize:]
+	return nil
+}
+
+// 遍历所有 location，记录所有模块名以及每个包属于哪个模块，查找 class 时需要先根据包名得到模块名才能拼出完整的资源名称
+// 每个模块根目录下的 module-info.class 不属于任何包，所以不会被记录，以免遮住 classpath 中的 module-info.class
+func (self *jimage) indexPackages() {
+	self.modules = map[string]string{}
+	self.names = map[string]bool{}
+	for _, offset := range self.offsets {
+		loc, err := self.location(offset)
+		if err != nil || string(self.getString(loc[jimageAttrExtension])) != "class" {
+			continue
+		}
+		module := string(self.getString(loc[jimageAttrModule]))
+		pkg := string(self.getString(loc[jimageAttrParent]))
+		if module == "" || module == "modules" || module == "packages" {
+			continue
+		}
+		self.names[module] = true
+		if pkg == "" {
+			continue
+		}
+		if _, ok := self.modules[pkg]; !ok {
+			self.modules[pkg] = module
+		}
+	}
+}
+
+// 返回所有模块中的资源名称（不含模块名），去掉重复的名称
+func (self *jimage) resourceNames() []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, offset := range self.offsets {
+		loc, err := self.location(offset)
+		if err != nil {
+			continue
+		}
+		module := string(self.getString(loc[jimageAttrModule]))
+		if module == "" || module == "modules" || module == "packages" {
+			continue
+		}
+		name := self.locationName(loc)[len(module)+2:] // 去掉 /模块名/
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// 返回 strings 中 offset 处以 0 结尾的字符串
+func (self *jimage) getString(offset uint64) []byte {
+	if offset >= uint64(len(self.strings)) {
+		return nil
+	}
+	str := self.strings[offset:]
+	if end := bytes.IndexByte(str, 0); end >= 0 {
+		return str[:end]
+	}
+	return str
+}
+
+// 解码 locations 中 offset 处的 location 属性
+func (self *jimage) location(offset uint32) (*jimageLocation, error) {
+	loc := &jimageLocation{}
+	for i := int(offset); i < len(self.locations); {
+		kind := self.locations[i] >> 3
+		if kind == jimageAttrEnd {
+			return loc, nil
+		}
+		if kind >= jimageAttrCount {
+			return nil, fmt.Errorf("invalid jimage location attribute kind %d", kind)
+		}
+		length := int(self.locations[i]&7) + 1
+		if i+1+length > len(self.locations) {
+			break
+		}
+		var value uint64
+		for _, b := range self.locations[i+1 : i+1+length] {
+			value = value<<8 | uint64(b)
+		}
+		loc[kind] = value
+		i += 1 + length
+	}
+	return nil, errors.New("truncated jimage location attributes")
+}
+
+// location 对应的完整资源名称：/模块名/包路径/类名.扩展名，为空的部分连同分隔符一起省略
+func (self *jimage) locationName(loc *jimageLocation) string {
+	var name []byte
+	if module := self.getString(loc[jimageAttrModule]); len(module) > 0 {
+		name = append(append(append(name, '/'), module...), '/')
+	}
+	if parent := self.getString(loc[jimageAttrParent]); len(parent) > 0 {
+		name = append(append(name, parent...), '/')
+	}
+	name = append(name, self.getString(loc[jimageAttrBase])...)
+	if extension := self.getString(loc[jimageAttrExtension]); len(extension) > 0 {
+		name = append(append(name, '.'), extension...)
+	}
+	return string(name)
+}
+
+// 通过哈希表查找资源：先用默认种子计算哈希得到 redirect 中的位置，
+// redirect 的值为负数时直接给出 offsets 中的位置（-1 - value），为正数时则作为新的种子重新计算哈希，为 0 表示不存在
+// 哈希冲突时得到的可能是其他资源，所以最后需要比较完整名称
+func (self *jimage) findLocation(name string) (*jimageLocation, bool) {
+	length := int32(len(self.redirect))
+	if length == 0 {
+		return nil, false
+	}
+	index := jimageHashCode(name, jimageHashMultiplier) % length
+	switch value := self.redirect[index]; {
+	case value < 0:
+		index = -1 - value
+	case value > 0:
+		index = jimageHashCode(name, value) % length
+	default:
+		return nil, false
+	}
+	if index >= length {
+		return nil, false
+	}
+	loc, err := self.location(self.offsets[index])
+	if err != nil || self.locationName(loc) != name {
+		return nil, false
+	}
+	return loc, true
+}
+
+// 与 jdk.internal.jimage.ImageStringsReader.hashCode() 相同的 FNV 哈希
+func jimageHashCode(name string, seed int32) int32 {
+	hash := seed
+	for i := 0; i < len(name); i++ {
+		hash = hash*jimageHashMultiplier ^ int32(name[i])
+	}
+	return hash & 0x7FFFFFFF
+}
+
+// 读取资源内容，必要时解压
+func (self *jimage) readResource(loc *jimageLocation) ([]byte, error) {
+	size := loc[jimageAttrUncompressed]
+	if loc[jimageAttrCompressed] != 0 {
+		size = loc[jimageAttrCompressed]
+	}
+	offset := uint64(self.indexSize) + loc[jimageAttrOffset]
+	info, err := self.file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if offset+size > uint64(info.Size()) {
+		return nil, errors.New("jimage resource out of range")
+	}
+	data := make([]byte, size)
+	if _, err := self.file.ReadAt(data, int64(offset)); err != nil {
+		return nil, err
+	}
+	if loc[jimageAttrCompressed] == 0 {
+		return data, nil
+	}
+	return self.decompress(data)
+}
+
+// 压缩过的资源以下面的头开始，整数同样使用 jimage 的字节序：
+// compressed_resource_header {
+//     u4 magic;                       // 0xCAFEFAFA
+//     u8 compressed_size;
+//     u8 uncompressed_size;
+//     u4 decompressor_name_offset;    // 解压器名称在 strings 中的偏移，"zip" 或 "compact-cp"
+//     u4 content_offset;
+//     u1 is_terminal;
+// }
+// jlink 可以叠加多种压缩方式，所以需要反复解压，直到内容不再以这个头开始
+const (
+	compressedResourceMagic      = 0xCAFEFAFA
+	compressedResourceHeaderSize = 29
+)
+
+func (self *jimage) decompress(data []byte) ([]byte, error) {
+	for len(data) >= compressedResourceHeaderSize && self.order.Uint32(data) == compressedResourceMagic {
+		compressedSize := self.order.Uint64(data[4:])
+		uncompressedSize := self.order.Uint64(data[12:])
+		decompressor := string(self.getString(uint64(self.order.Uint32(data[20:]))))
+		content := data[compressedResourceHeaderSize:]
+		if compressedSize < uint64(len(content)) {
+			content = content[:compressedSize]
+		}
+
+		var err error
+		switch decompressor {
+		case "zip":
+			data, err = inflate(content)
+		case "compact-cp":
+			data, err = self.expandSharedStrings(content)
+		default:
+			err = fmt.Errorf("unsupported jimage decompressor %q", decompressor)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if uint64(len(data)) != uncompressedSize {
+			return nil, errors.New("jimage resource size mismatch after decompression")
+		}
+	}
+	return data, nil
+}
+
+// "zip" 解压器使用 java.util.zip.Inflater 的默认格式，即 zlib
+func inflate(content []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// "compact-cp" 解压器：jlink 把 class 文件常量池中的字符串移到 jimage 的 strings 中共享，
+// 常量池中对应的 CONSTANT_Utf8_info 被替换成下面两种常量，解压时需要还原为 CONSTANT_Utf8_info：
+// - EXTERNALIZED_STRING(23)：           后跟字符串在 strings 中的偏移
+// - EXTERNALIZED_STRING_DESCRIPTOR(25)：后跟去掉了类名的描述符的偏移，以及描述符中每个类的包名和类名的偏移
+// 偏移使用压缩整数存放，见 readCompressedInt()
+// 常量池之后的内容原样保留
+const (
+	externalizedString           = 23
+	externalizedStringDescriptor = 25
+)
+
+// 其余常量的 tag 与 info 的字节数
+var constantInfoSizes = map[uint8]int{
+	3: 4, 4: 4, 5: 8, 6: 8, // Integer, Float, Long, Double
+	7: 2, 8: 2, // Class, String
+	9: 4, 10: 4, 11: 4, 12: 4, // Fieldref, Methodref, InterfaceMethodref, NameAndType
+	15: 3, 16: 2, 17: 4, 18: 4, // MethodHandle, MethodType, Dynamic, InvokeDynamic
+	19: 2, 20: 2, // Module, Package
+}
+
+func (self *jimage) expandSharedStrings(content []byte) (data []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New("malformed compact-cp jimage resource")
+		}
+	}()
+
+	pos := 0
+	read := func(n int) []byte {
+		b := content[pos : pos+n]
+		pos += n
+		return b
+	}
+	readInt := func() uint64 {
+		value, size := readCompressedInt(content[pos:])
+		pos += size
+		return value
+	}
+	appendUtf8 := func(str []byte) {
+		if len(str) > 0xFFFF {
+			panic("string too long")
+		}
+		data = append(data, 1, byte(len(str)>>8), byte(len(str)))
+		data = append(data, str...)
+	}
+
+	data = append(data, read(8)...) // magic, minor_version, major_version
+	countBytes := read(2)
+	data = append(data, countBytes...)
+	count := int(binary.BigEndian.Uint16(countBytes))
+	for i := 1; i < count; i++ {
+		tag := read(1)[0]
+		switch tag {
+		case 1: // CONSTANT_Utf8
+			appendUtf8(read(int(binary.BigEndian.Uint16(read(2)))))
+		case externalizedString:
+			appendUtf8(self.getString(readInt()))
+		case externalizedStringDescriptor:
+			appendUtf8(self.reconstructDescriptor(readInt, read))
+		default:
+			size, ok := constantInfoSizes[tag]
+			if !ok {
+				panic("invalid constant pool tag")
+			}
+			if tag == 5 || tag == 6 { // long 和 double 占两个位置
+				i++
+			}
+			data = append(data, tag)
+			data = append(data, read(size)...)
+		}
+	}
+	return append(data, content[pos:]...), nil
+}
+
+// 还原描述符：共享的描述符中每个 'L' 之后的类名被去掉了，依次用包名和类名补上
+func (self *jimage) reconstructDescriptor(readInt func() uint64, read func(int) []byte) []byte {
+	descriptor := self.getString(readInt())
+	indexes := read(int(readInt()))
+	var str []byte
+	for _, c := range descriptor {
+		str = append(str, c)
+		if c != 'L' {
+			continue
+		}
+		pkg, size := readCompressedInt(indexes)
+		indexes = indexes[size:]
+		class, size := readCompressedInt(indexes)
+		indexes = indexes[size:]
+		if p := self.getString(pkg); len(p) > 0 {
+			str = append(append(str, p...), '/')
+		}
+		str = append(str, self.getString(class)...)
+	}
+	return str
+}
+
+// 压缩整数：第一个字节最高位为 1 时，第 5、6 位是整数占用的字节数，低 5 位是整数的最高位部分；
+// 最高位为 0 时整数占用 4 个字节。返回整数值和占用的字节数
+func readCompressedInt(b []byte) (uint64, int) {
+	size, value := 4, uint64(b[0])
+	if b[0]&0x80 != 0 {
+		size, value = int(b[0]>>5&3), uint64(b[0]&0x1F)
+		if size == 0 {
+			panic("invalid compressed int")
+		}
+	}
+	for _, c := range b[1:size] {
+		value = value<<8 | uint64(c)
+	}
+	return value, size
+}
@@ -0,0 +1,470 @@
+package classpath
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 测试用的最小 class 文件，常量池中有普通字符串、带类名的方法描述符和 long 常量，
+// 足以覆盖 compact-cp 的 EXTERNALIZED_STRING、EXTERNALIZED_STRING_DESCRIPTOR 和占两个位置的常量
+func testClassData() []byte {
+	var cp [][]byte
+	utf8 := func(s string) []byte {
+		b := []byte{1, byte(len(s) >> 8), byte(len(s))}
+		return append(b, s...)
+	}
+	cp = append(cp,
+		utf8("p/Hello"),                       // #1
+		[]byte{7, 0, 1},                       // #2 Class p/Hello
+		utf8("java/lang/Object"),              // #3
+		[]byte{7, 0, 3},                       // #4 Class java/lang/Object
+		utf8("main"),                          // #5
+		utf8("([Ljava/lang/String;LHello;)V"), // #6 含默认包中的类
+		[]byte{5, 0, 0, 0, 0, 0, 0, 0, 42},    // #7 Long，占 #7 和 #8
+		utf8("Code"),                          // #9
+	)
+	data := []byte{0xCA, 0xFE, 0xBA, 0xBE, 0, 0, 0, 52, 0, 10}
+	for _, c := range cp {
+		data = append(data, c...)
+	}
+	// access_flags, this_class, super_class, interfaces_count, fields_count, methods_count, attributes_count
+	return append(data, 0, 0x21, 0, 2, 0, 4, 0, 0, 0, 0, 0, 0, 0, 0)
+}
+
+// jimageBuilder 按照 JDK 的格式生成 jimage 文件，与 jimage.go 中的读取代码相互独立
+type jimageBuilder struct {
+	order     binary.ByteOrder
+	strings   []byte
+	stringOff map[string]int
+	resources []jimageTestResource
+	content   []byte
+}
+
+type jimageTestResource struct {
+	module, parent, base, extension  string
+	offset, compressed, uncompressed int
+}
+
+func newJImageBuilder(order binary.ByteOrder) *jimageBuilder {
+	return &jimageBuilder{order: order, strings: []byte{0}, stringOff: map[string]int{"": 0}}
+}
+
+func (self *jimageBuilder) str(s string) int {
+	if off, ok := self.stringOff[s]; ok {
+		return off
+	}
+	off := len(self.strings)
+	self.stringOff[s] = off
+	self.strings = append(append(self.strings, s...), 0)
+	return off
+}
+
+// 压缩整数，格式见 readCompressedInt()
+func compressedInt(v int) []byte {
+	switch {
+	case v < 0x20:
+		return []byte{0xA0 | byte(v)}
+	case v < 0x2000:
+		return []byte{0xC0 | byte(v>>8), byte(v)}
+	case v < 0x200000:
+		return []byte{0xE0 | byte(v>>16), byte(v >> 8), byte(v)}
+	}
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(v))
+	return b
+}
+
+// 模拟 jlink 的 compact-cp 插件：方法描述符改为 EXTERNALIZED_STRING_DESCRIPTOR，
+// 其余 Utf8 常量隔一个改为 EXTERNALIZED_STRING
+func (self *jimageBuilder) compactCp(data []byte) []byte {
+	out := append([]byte{}, data[:10]...)
+	count := int(binary.BigEndian.Uint16(data[8:]))
+	p := 10
+	for i := 1; i < count; i++ {
+		tag := data[p]
+		if tag != 1 {
+			size := constantInfoSizes[tag]
+			out = append(out, data[p:p+1+size]...)
+			p += 1 + size
+			if tag == 5 || tag == 6 {
+				i++
+			}
+			continue
+		}
+		n := int(binary.BigEndian.Uint16(data[p+1:]))
+		s := string(data[p+3 : p+3+n])
+		switch {
+		case strings.HasPrefix(s, "(") && strings.Contains(s, "L"):
+			var desc []byte
+			var indexes []byte
+			for j := 0; j < len(s); j++ {
+				desc = append(desc, s[j])
+				if s[j] != 'L' {
+					continue
+				}
+				end := j + strings.IndexByte(s[j:], ';')
+				name := s[j+1 : end]
+				pkg, class := "", name
+				if slash := strings.LastIndexByte(name, '/'); slash >= 0 {
+					pkg, class = name[:slash], name[slash+1:]
+				}
+				indexes = append(indexes, compressedInt(self.str(pkg))...)
+				indexes = append(indexes, compressedInt(self.str(class))...)
+				j = end - 1
+			}
+			out = append(out, externalizedStringDescriptor)
+			out = append(out, compressedInt(self.str(string(desc)))...)
+			out = append(out, compressedInt(len(indexes))...)
+			out = append(out, indexes...)
+		case i%2 == 1:
+			out = append(out, externalizedString)
+			out = append(out, compressedInt(self.str(s))...)
+		default:
+			out = append(out, data[p:p+3+n]...)
+		}
+		p += 3 + n
+	}
+	return append(out, data[p:]...)
+}
+
+func (self *jimageBuilder) compressedHeader(payload []byte, uncompressedSize int, decompressor string) []byte {
+	header := make([]byte, compressedResourceHeaderSize)
+	self.order.PutUint32(header, compressedResourceMagic)
+	self.order.PutUint64(header[4:], uint64(len(payload)))
+	self.order.PutUint64(header[12:], uint64(uncompressedSize))
+	self.order.PutUint32(header[20:], uint32(self.str(decompressor)))
+	header[28] = 1
+	return append(header, payload...)
+}
+
+func zlibCompress(data []byte) []byte {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+// compression 为 ""、"zip" 或 "compact-cp+zip"
+func (self *jimageBuilder) add(module, parent, base, extension string, data []byte, compression string) {
+	stored, compressed := data, 0
+	switch compression {
+	case "zip":
+		stored = self.compressedHeader(zlibCompress(data), len(data), "zip")
+		compressed = len(stored)
+	case "compact-cp+zip":
+		compact := self.compressedHeader(self.compactCp(data), len(data), "compact-cp")
+		stored = self.compressedHeader(zlibCompress(compact), len(compact), "zip")
+		compressed = len(stored)
+	}
+	self.resources = append(self.resources, jimageTestResource{
+		module: module, parent: parent, base: base, extension: extension,
+		offset: len(self.content), compressed: compressed, uncompressed: len(data),
+	})
+	self.content = append(self.content, stored...)
+}
+
+func (self jimageTestResource) name() string {
+	var name string
+	if self.module != "" {
+		name += "/" + self.module + "/"
+	}
+	if self.parent != "" {
+		name += self.parent + "/"
+	}
+	name += self.base
+	if self.extension != "" {
+		name += "." + self.extension
+	}
+	return name
+}
+
+func testJImageHash(name string, seed uint32) uint32 {
+	for i := 0; i < len(name); i++ {
+		seed = seed*0x01000193 ^ uint32(name[i])
+	}
+	return seed & 0x7FFFFFFF
+}
+
+func locationAttribute(kind uint8, value int) []byte {
+	var b []byte
+	for v := value; v > 0 || len(b) == 0; v >>= 8 {
+		b = append([]byte{byte(v)}, b...)
+	}
+	return append([]byte{kind<<3 | byte(len(b)-1)}, b...)
+}
+
+// 生成完整的 jimage 文件，返回文件内容以及 redirect 表中正数（重新计算哈希）和负数（直接定位）的个数
+func (self *jimageBuilder) bytes() (image []byte, reseeded, direct int) {
+	var locations []byte
+	locationOffsets := make([]int, len(self.resources))
+	for i, r := range self.resources {
+		locationOffsets[i] = len(locations)
+		for _, attr := range []struct {
+			kind  uint8
+			value int
+		}{
+			{jimageAttrModule, self.str(r.module)},
+			{jimageAttrParent, self.str(r.parent)},
+			{jimageAttrBase, self.str(r.base)},
+			{jimageAttrExtension, self.str(r.extension)},
+			{jimageAttrOffset, r.offset},
+			{jimageAttrCompressed, r.compressed},
+			{jimageAttrUncompressed, r.uncompressed},
+		} {
+			locations = append(locations, locationAttribute(attr.kind, attr.value)...)
+		}
+		locations = append(locations, jimageAttrEnd)
+	}
+
+	// 与 jdk.tools.jlink.internal.PerfectHashBuilder 相同的思路：冲突的桶换种子重新散列，只有一项的桶直接给出位置
+	n := uint32(len(self.resources))
+	buckets := make([][]int, n)
+	for i, r := range self.resources {
+		b := testJImageHash(r.name(), jimageHashMultiplier) % n
+		buckets[b] = append(buckets[b], i)
+	}
+	redirect := make([]int32, n)
+	slots := make([]int, n)
+	for i := range slots {
+		slots[i] = -1
+	}
+	for size := len(self.resources); size > 1; size-- {
+		for b, items := range buckets {
+			if len(items) != size {
+				continue
+			}
+			for seed := uint32(1); ; seed++ {
+				positions := map[uint32]bool{}
+				for _, i := range items {
+					p := testJImageHash(self.resources[i].name(), seed) % n
+					if slots[p] >= 0 || positions[p] {
+						break
+					}
+					positions[p] = true
+				}
+				if len(positions) == len(items) {
+					for _, i := range items {
+						slots[testJImageHash(self.resources[i].name(), seed)%n] = i
+					}
+					redirect[b] = int32(seed)
+					reseeded++
+					break
+				}
+			}
+		}
+	}
+	free := 0
+	for b, items := range buckets {
+		if len(items) != 1 {
+			continue
+		}
+		for slots[free] >= 0 {
+			free++
+		}
+		slots[free] = items[0]
+		redirect[b] = int32(-1 - free)
+		direct++
+	}
+
+	u4 := func(v uint32) {
+		b := make([]byte, 4)
+		self.order.PutUint32(b, v)
+		image = append(image, b...)
+	}
+	for _, v := range []uint32{jimageMagic, jimageMajorVersion<<16 | jimageMinorVersion, 0, n, n,
+		uint32(len(locations)), uint32(len(self.strings))} {
+		u4(v)
+	}
+	for _, v := range redirect {
+		u4(uint32(v))
+	}
+	for _, i := range slots {
+		u4(uint32(locationOffsets[i]))
+	}
+	image = append(image, locations...)
+	image = append(image, self.strings...)
+	return append(image, self.content...), reseeded, direct
+}
+
+func writeTestJImage(t *testing.T, order binary.ByteOrder) (path string, reseeded, direct int) {
+	class := testClassData()
+	builder := newJImageBuilder(order)
+	builder.add("java.base", "java/lang", "Object", "class", class, "")
+	builder.add("java.base", "java/util", "List", "class", class, "zip")
+	builder.add("java.base", "java/lang", "String", "class", class, "compact-cp+zip")
+	builder.add("java.sql", "java/sql", "Driver", "class", class, "")
+	builder.add("java.base", "", "module-info", "class", []byte("MODINFO"), "")
+	builder.add("java.sql", "META-INF/services", "java.sql", "Driver", []byte("org.Driver"), "")
+	builder.add("java.sql", "java/sql", "driver", "properties", []byte("sql"), "zip")
+	builder.add("packages", "", "java.lang", "", make([]byte, 8), "")
+	for i := 0; i < 200; i++ { // 足够多的资源才会出现哈希冲突
+		builder.add("java.base", "java/lang", fmt.Sprintf("Fill%d", i), "class", class[:8], "")
+	}
+	image, reseeded, direct := builder.bytes()
+
+	dir, err := ioutil.TempDir("", "jvmgo-jimage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path = filepath.Join(dir, "modules")
+	if err := ioutil.WriteFile(path, image, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path, reseeded, direct
+}
+
+func TestJImageReadClass(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		path, reseeded, direct := writeTestJImage(t, order)
+		defer os.RemoveAll(filepath.Dir(path))
+		if reseeded == 0 || direct == 0 {
+			t.Fatalf("%v: generated image has %d reseeded and %d direct redirect entries, want both", order, reseeded, direct)
+		}
+		entry, err := newJImageEntry(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, className := range []string{"java/lang/Object", "java/util/List", "java/lang/String", "java/sql/Driver"} {
+			data, from, err := entry.readClass(className + ".class")
+			if err != nil {
+				t.Errorf("%v: %s: %v", order, className, err)
+				continue
+			}
+			if from != entry {
+				t.Errorf("%v: %s: read from %v", order, className, from)
+			}
+			if !bytes.Equal(data, testClassData()) {
+				t.Errorf("%v: %s: got % x, want % x", order, className, data, testClassData())
+			}
+		}
+		// 通过 redirect 表能找到每一个填充资源，其中有些经过重新散列
+		for i := 0; i < 200; i++ {
+			className := fmt.Sprintf("java/lang/Fill%d.class", i)
+			if data, _, err := entry.readClass(className); err != nil || len(data) != 8 {
+				t.Errorf("%v: %s: got %d bytes, %v", order, className, len(data), err)
+			}
+		}
+		for _, className := range []string{"java/lang/Missing.class", "javax/Missing.class", "module-info.class"} {
+			if _, _, err := entry.readClass(className); err == nil {
+				t.Errorf("%v: found %s", order, className)
+			}
+		}
+
+		pkgs, ok := entry.packages()
+		if !ok || len(pkgs) != 3 {
+			t.Errorf("%v: packages() = %v, %v; want java/lang, java/util and java/sql", order, pkgs, ok)
+		}
+		entry.Close()
+	}
+}
+
+// 包中的资源在包所属的模块中查找，不属于任何包的资源依次查找每个模块
+func TestJImageReadResource(t *testing.T) {
+	path, _, _ := writeTestJImage(t, binary.LittleEndian)
+	defer os.RemoveAll(filepath.Dir(path))
+	entry, err := newJImageEntry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer entry.Close()
+
+	tests := []struct {
+		name string
+		want string // 为空表示找不到
+	}{
+		{"java/sql/driver.properties", "sql"},
+		{"META-INF/services/java.sql.Driver", "org.Driver"},
+		{"module-info.class", "MODINFO"},
+		{"java/lang/driver.properties", ""},
+		{"META-INF/services/missing", ""},
+	}
+	for _, test := range tests {
+		data, from, err := entry.readResource(test.name)
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("%s: found %q, want an error", test.name, data)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if string(data) != test.want || from != entry {
+			t.Errorf("%s: read %q from %v, want %q", test.name, data, from, test.want)
+		}
+	}
+}
+
+func TestJImageLocationAttributes(t *testing.T) {
+	path, _, _ := writeTestJImage(t, binary.BigEndian)
+	defer os.RemoveAll(filepath.Dir(path))
+	image, err := openJImage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer image.close()
+
+	loc, ok := image.findLocation("/java.sql/java/sql/Driver.class")
+	if !ok {
+		t.Fatal("/java.sql/java/sql/Driver.class not found")
+	}
+	for kind, want := range map[int]string{
+		jimageAttrModule: "java.sql", jimageAttrParent: "java/sql", jimageAttrBase: "Driver", jimageAttrExtension: "class",
+	} {
+		if got := string(image.getString(loc[kind])); got != want {
+			t.Errorf("attribute %d = %q, want %q", kind, got, want)
+		}
+	}
+	if loc[jimageAttrCompressed] != 0 || loc[jimageAttrUncompressed] != uint64(len(testClassData())) {
+		t.Errorf("compressed = %d, uncompressed = %d", loc[jimageAttrCompressed], loc[jimageAttrUncompressed])
+	}
+
+	loc, ok = image.findLocation("/java.base/java/util/List.class")
+	if !ok {
+		t.Fatal("/java.base/java/util/List.class not found")
+	}
+	if loc[jimageAttrCompressed] == 0 {
+		t.Error("java/util/List is stored compressed but has no compressed size")
+	}
+	if got := image.modules["java/lang"]; got != "java.base" {
+		t.Errorf("module of java/lang = %q, want java.base", got)
+	}
+}
+
+func TestJImageBadHeader(t *testing.T) {
+	path, _, _ := writeTestJImage(t, binary.LittleEndian)
+	defer os.RemoveAll(filepath.Dir(path))
+	image, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		mutate func(b []byte) []byte
+		want   string
+	}{
+		{"bad magic", func(b []byte) []byte { b[0] = 0; return b }, "not a jimage file"},
+		{"short file", func(b []byte) []byte { return b[:10] }, "not a jimage file"},
+		{"version", func(b []byte) []byte { binary.LittleEndian.PutUint32(b[4:], 2<<16); return b }, "unsupported jimage version 2.0"},
+		{"truncated index", func(b []byte) []byte { return b[:jimageHeaderSize+16] }, "truncated jimage file"},
+	}
+	for _, test := range tests {
+		bad := test.mutate(append([]byte{}, image...))
+		if err := ioutil.WriteFile(path, bad, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := openJImage(path); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: got error %v, want %q", test.name, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package classpath
+
+import (
+	"sort"
+	"strings"
+)
+
+// ClassLocation 说明一个 class 出现在哪些 Entry 中，entries 按照搜索顺序排列，
+// 第一个是 ReadClass() 实际读取的 Entry，其余的被它遮蔽（shadowed），永远不会被加载
+// 同一个 class 出现在多个 jar 中通常意味着同一个库的多个版本同时位于 classpath 上
+type ClassLocation struct {
+	name    string  // 类名，例如 java/lang/Object
+	entries []Entry // 包含该 class 的目录、jar 文件或模块
+}
+
+// getter 方法
+func (self *ClassLocation) Name() string {
+	return self.name
+}
+func (self *ClassLocation) Entry() Entry {
+	return self.entries[0]
+}
+func (self *ClassLocation) Shadowed() []Entry {
+	return self.entries[1:]
+}
+
+// 是否出现在多个 Entry 中
+func (self *ClassLocation) IsDuplicate() bool {
+	return len(self.entries) > 1
+}
+
+// 按照 ReadClass() 的搜索顺序遍历 classpath 中每个目录、jar 文件和模块里的所有文件
+// includeJre 为 false 时跳过启动类路径和扩展类路径，fn 返回错误时停止遍历并返回该错误
+func (self *Classpath) Walk(includeJre bool, fn func(name string, entry Entry) error) error {
+	entries := self.getIndex().entries
+	if !includeJre {
+		entries = entries[len(leafEntries(self.bootClasspath))+len(leafEntries(self.extClasspath)):]
+	}
+	for _, entry := range entries {
+		entry := entry
+		if err := entry.Walk(func(name string) error { return fn(name, entry) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 列出 classpath 中的所有 class，按类名排序，每个 class 附带包含它的所有 Entry
+// module-info.class 不是普通的类，每个模块化的 jar 中都有一个，所以不列出
+func (self *Classpath) ListClasses(includeJre bool) ([]*ClassLocation, error) {
+	locations := map[string]*ClassLocation{}
+	err := self.Walk(includeJre, func(name string, entry Entry) error {
+		if !strings.HasSuffix(name, ".class") || strings.HasSuffix(name, "module-info.class") {
+			return nil
+		}
+		name = strings.TrimSuffix(name, ".class")
+		location, ok := locations[name]
+		if !ok {
+			location = &ClassLocation{name: name}
+			locations[name] = location
+		}
+		if n := len(location.entries); n == 0 || location.entries[n-1] != entry {
+			location.entries = append(location.entries, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*ClassLocation, 0, len(locations))
+	for _, location := range locations {
+		result = append(result, location)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].name < result[j].name })
+	return result, nil
+}
+
+// 列出 classpath 中的所有包，按包名排序，默认包为空字符串
+func (self *Classpath) ListPackages(includeJre bool) ([]string, error) {
+	classes, err := self.ListClasses(includeJre)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	pkgs := []string{}
+	for _, class := range classes {
+		if pkg := packageOf(class.name + ".class"); !seen[pkg] {
+			seen[pkg] = true
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	sort.Strings(pkgs)
+	return pkgs, nil
+}
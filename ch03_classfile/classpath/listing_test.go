@@ -0,0 +1,186 @@
+package classpath
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// boot: rt.jar；user: classes 目录、v1.jar、v2.jar，其中 a/A 和 b/C 出现在多个位置
+func newListingTestClasspath(t *testing.T) (cp *Classpath, rt, classes, v1, v2 string) {
+	dir := t.TempDir()
+	rt = filepath.Join(dir, "rt.jar")
+	writeTestFile(t, rt, zipBytes(t, map[string][]byte{
+		"java/lang/Object.class": testClass,
+		"a/A.class":              testClass, // 启动类路径中的类遮蔽 user classpath 中的同名类
+	}))
+	classes = filepath.Join(dir, "classes")
+	writeTestFile(t, filepath.Join(classes, "a", "A.class"), testClass)
+	writeTestFile(t, filepath.Join(classes, "a", "B.class"), testClass)
+	writeTestFile(t, filepath.Join(classes, "Main.class"), testClass)
+	writeTestFile(t, filepath.Join(classes, "module-info.class"), testClass)
+	v1 = filepath.Join(dir, "v1.jar")
+	writeTestFile(t, v1, zipBytes(t, map[string][]byte{
+		"a/A.class":            testClass,
+		"b/C.class":            testClass,
+		"b/res.txt":            []byte("not a class"),
+		"META-INF/MANIFEST.MF": []byte("Manifest-Version: 1.0\n"),
+		"module-info.class":    testClass,
+	}))
+	v2 = filepath.Join(dir, "v2.jar")
+	writeTestFile(t, v2, zipBytes(t, map[string][]byte{
+		"a/A.class": testClass,
+		"b/C.class": testClass,
+		"d/D.class": testClass,
+	}))
+
+	cp = newModuleTestClasspath()
+	cp.bootClasspath = CompositeEntry{newEntry(rt, &cp.diagnostics)}
+	user := CompositeEntry{}
+	for _, path := range []string{classes, v1, v2} {
+		user = append(user, newEntry(path, &cp.diagnostics))
+	}
+	cp.userClasspath = user
+	cp.buildIndex()
+	return cp, rt, classes, v1, v2
+}
+
+func entryNames(entries []Entry) []string {
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.String())
+	}
+	return names
+}
+
+func TestListClasses(t *testing.T) {
+	cp, rt, classes, v1, v2 := newListingTestClasspath(t)
+	defer cp.Close()
+
+	tests := []struct {
+		includeJre bool
+		want       map[string][]string // 类名 -> 包含它的 Entry，第一个是实际加载的位置
+	}{
+		{false, map[string][]string{
+			"Main": {classes},
+			"a/A":  {classes, v1, v2},
+			"a/B":  {classes},
+			"b/C":  {v1, v2},
+			"d/D":  {v2},
+		}},
+		{true, map[string][]string{
+			"java/lang/Object": {rt},
+			"Main":             {classes},
+			"a/A":              {rt, classes, v1, v2},
+			"a/B":              {classes},
+			"b/C":              {v1, v2},
+			"d/D":              {v2},
+		}},
+	}
+	for _, test := range tests {
+		locations, err := cp.ListClasses(test.includeJre)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := map[string][]string{}
+		var names []string
+		for _, location := range locations {
+			entries := append([]Entry{location.Entry()}, location.Shadowed()...)
+			got[location.Name()] = entryNames(entries)
+			names = append(names, location.Name())
+			if location.IsDuplicate() != (len(entries) > 1) {
+				t.Errorf("jre %v: %s: IsDuplicate() = %v with %d entries", test.includeJre, location.Name(), location.IsDuplicate(), len(entries))
+			}
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("jre %v: ListClasses() = %v, want %v", test.includeJre, got, test.want)
+		}
+		if !sort.StringsAreSorted(names) {
+			t.Errorf("jre %v: classes not sorted: %v", test.includeJre, names)
+		}
+	}
+
+	// 列出的第一个位置就是 ReadClass() 实际读取的位置
+	for _, className := range []string{"a/A", "b/C"} {
+		_, from, err := cp.ReadClass(className)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]string{"a/A": rt, "b/C": v1}[className]
+		if from.String() != want {
+			t.Errorf("ReadClass(%s) read from %v, want %s", className, from, want)
+		}
+	}
+}
+
+func TestListPackages(t *testing.T) {
+	cp, _, _, _, _ := newListingTestClasspath(t)
+	defer cp.Close()
+
+	for includeJre, want := range map[bool][]string{
+		false: {"", "a", "b", "d"},
+		true:  {"", "a", "b", "d", "java/lang"},
+	} {
+		pkgs, err := cp.ListPackages(includeJre)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(pkgs, want) {
+			t.Errorf("jre %v: ListPackages() = %q, want %q", includeJre, pkgs, want)
+		}
+	}
+}
+
+// 遍历时 jar 文件或 jimage 被并发关闭，Walk() 会重新打开而不是读到已经释放的索引
+func TestWalkConcurrentClose(t *testing.T) {
+	path, _, _ := writeTestJImage(t, binary.LittleEndian)
+	defer os.RemoveAll(filepath.Dir(path))
+	image, err := newJImageEntry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jar := filepath.Join(t.TempDir(), "a.jar")
+	writeTestFile(t, jar, zipBytes(t, map[string][]byte{"a/A.class": testClass}))
+	zipEntry, err := newZipEntry(jar)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entry := range []Entry{image, zipEntry} {
+		var wg sync.WaitGroup
+		done := make(chan bool)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					entry.Close()
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			defer close(done)
+			for i := 0; i < 1000; i++ {
+				n := 0
+				if err := entry.Walk(func(name string) error { n++; return nil }); err != nil {
+					t.Error(err)
+					return
+				}
+				if n == 0 {
+					t.Errorf("%v: Walk() found no files", entry)
+					return
+				}
+			}
+		}()
+		wg.Wait()
+		entry.Close()
+	}
+}
@@ -0,0 +1,72 @@
+package classpath
+
+import (
+	"strings"
+)
+
+// jar 文件的 META-INF/MANIFEST.MF 由若干节（section）组成，节之间用空行分隔，每一行是一个 "名称: 值" 形式的属性
+// 第一节是主属性（main attributes），之后每一节以 "Name: 路径" 开始，给出某个文件或包的属性
+// 每行最多 72 个字节，更长的值会被拆成多行，后续行以一个空格开始
+// 属性名不区分大小写
+type Manifest struct {
+	main     map[string]string
+	sections map[string]map[string]string // Name 属性 -> 该节的属性
+}
+
+func parseManifest(data []byte) *Manifest {
+	manifest := &Manifest{main: map[string]string{}, sections: map[string]map[string]string{}}
+	text := strings.Replace(strings.Replace(string(data), "\r\n", "\n", -1), "\r", "\n", -1)
+
+	// 先把续行拼接到上一行
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(line, " ") && len(lines) > 0 && lines[len(lines)-1] != "" {
+			lines[len(lines)-1] += line[1:]
+		} else {
+			lines = append(lines, line)
+		}
+	}
+
+	attrs := manifest.main
+	inSection := false // 主属性之后的空行开始一个新的节
+	for _, line := range lines {
+		if line == "" {
+			inSection = true
+			attrs = nil
+			continue
+		}
+		i := strings.Index(line, ":")
+		if i <= 0 {
+			continue // 格式错误的行直接忽略
+		}
+		name := strings.ToLower(strings.TrimSpace(line[:i]))
+		value := strings.TrimSpace(line[i+1:])
+		if inSection && attrs == nil {
+			if name != "name" {
+				continue // 节必须以 Name 属性开始
+			}
+			attrs = map[string]string{}
+			manifest.sections[value] = attrs
+			continue
+		}
+		if attrs != nil {
+			attrs[name] = value
+		}
+	}
+	return manifest
+}
+
+// 返回主属性，不存在时返回空字符串
+func (self *Manifest) MainAttribute(name string) string {
+	return self.main[strings.ToLower(name)]
+}
+
+// 返回某一节的属性，不存在时返回空字符串
+func (self *Manifest) Attribute(section, name string) string {
+	return self.sections[section][strings.ToLower(name)]
+}
+
+// Multi-Release: true 表示 jar 文件是多版本 jar，META-INF/versions/N/ 下是针对 Java N 及以上版本的 class
+func (self *Manifest) IsMultiRelease() bool {
+	return strings.EqualFold(self.MainAttribute("Multi-Release"), "true")
+}
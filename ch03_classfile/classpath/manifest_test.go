@@ -0,0 +1,42 @@
+package classpath
+
+import (
+	"testing"
+)
+
+func TestParseManifest(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		section  string // 为空时检查主属性
+		attr     string
+		want     string
+	}{
+		{"main attribute", "Manifest-Version: 1.0\nMain-Class: com.app.Main\n", "", "Main-Class", "com.app.Main"},
+		{"case-insensitive name", "MAIN-CLASS: com.app.Main\n", "", "main-class", "com.app.Main"},
+		{"CRLF", "Manifest-Version: 1.0\r\nMain-Class: com.app.Main\r\n", "", "Main-Class", "com.app.Main"},
+		{"CR", "Manifest-Version: 1.0\rMain-Class: com.app.Main\r", "", "Main-Class", "com.app.Main"},
+		{"continuation line", "Class-Path: lib/a.jar lib/b\n .jar lib/c.jar\n", "", "Class-Path", "lib/a.jar lib/b.jar lib/c.jar"},
+		{"continuation in the middle of a word", "Main-Class: com.exa\r\n mple.Main\r\n", "", "Main-Class", "com.example.Main"},
+		{"several continuation lines", "Class-Path: a\n b\n c\n", "", "Class-Path", "abc"},
+		{"value without trailing newline", "Main-Class: com.app.Main", "", "Main-Class", "com.app.Main"},
+		{"missing attribute", "Manifest-Version: 1.0\n", "", "Main-Class", ""},
+		{"malformed line ignored", "garbage\nMain-Class: com.app.Main\n", "", "Main-Class", "com.app.Main"},
+		{"section attribute", "Manifest-Version: 1.0\n\nName: com/app/\nSealed: true\n", "com/app/", "Sealed", "true"},
+		{"section does not leak into main", "Manifest-Version: 1.0\n\nName: com/app/\nMain-Class: Wrong\n", "", "Main-Class", ""},
+		{"section without Name is ignored", "Manifest-Version: 1.0\n\nSealed: true\n\nName: p/\nSealed: false\n", "p/", "Sealed", "false"},
+		{"continuation of a section name", "Manifest-Version: 1.0\n\nName: com/a\n pp/\nSealed: true\n", "com/app/", "Sealed", "true"},
+	}
+	for _, test := range tests {
+		manifest := parseManifest([]byte(test.manifest))
+		var got string
+		if test.section == "" {
+			got = manifest.MainAttribute(test.attr)
+		} else {
+			got = manifest.Attribute(test.section, test.attr)
+		}
+		if got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,437 @@
+package classpath
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"jvmgo/ch03_classfile/classfile"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Module 表示模块路径（--module-path）上的一个模块，可以是以下三种形式之一：
+// - 展开的模块目录：目录下直接有 module-info.class
+// - 模块化 jar：jar 文件根目录下有 module-info.class；没有的话作为自动模块（automatic module）
+// - jmod 文件：classes/ 目录下有 module-info.class
+//
+// Module 同样实现了 Entry 接口，但只会读取属于本模块的包中的 class
+type Module struct {
+	name       string
+	descriptor *classfile.ModuleDescriptor // 自动模块为 nil
+	entry      Entry                       // 读取模块内容的 DirEntry、ZipEntry 或 JmodEntry
+	pkgs       map[string]bool             // 模块包含的包
+	reads      map[string]bool             // 解析之后得到的可读模块
+}
+
+// 根据路径创建模块，路径不是模块时返回 nil
+func newModule(path string, release int) (*Module, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	var entry Entry
+	switch {
+	case info.IsDir():
+		if !exists(filepath.Join(path, "module-info.class")) {
+			return nil, nil // 没有 module-info.class 的目录不是模块
+		}
+		entry, err = newDirEntry(path)
+	case strings.HasSuffix(path, ".jar") || strings.HasSuffix(path, ".JAR"):
+		var zipEntry *ZipEntry
+		if zipEntry, err = newZipEntry(path); err == nil {
+			zipEntry.release = release // 多版本的模块化 jar 可以在 META-INF/versions/N/ 下提供 module-info.class
+			entry = zipEntry
+		}
+	case strings.HasSuffix(path, ".jmod"):
+		entry, err = newJmodEntry(path)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	module := &Module{entry: entry, pkgs: map[string]bool{}}
+	data, _, err := entry.readClass("module-info.class")
+	if err != nil {
+		if _, ok := entry.(*ZipEntry); !ok {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		module.name = automaticModuleName(path)
+		if module.name == "" {
+			return nil, fmt.Errorf("%s: unable to derive module name", path)
+		}
+	} else {
+		cf, err := classfile.Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		if module.descriptor, err = cf.ModuleDescriptor(); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		if module.descriptor == nil {
+			return nil, fmt.Errorf("%s: module-info.class has no Module attribute", path)
+		}
+		module.name = module.descriptor.Name()
+	}
+
+	// 优先使用 ModulePackages 属性，javac 生成的 module-info.class 通常没有这个属性，此时遍历模块内容
+	pkgs := []string(nil)
+	if module.descriptor != nil {
+		pkgs = module.descriptor.Packages()
+	}
+	if len(pkgs) == 0 {
+		if lister, ok := entry.(packageLister); ok {
+			pkgs, _ = lister.packages()
+		}
+	}
+	for _, pkg := range pkgs {
+		if pkg != "" { // 命名模块中不能有默认包
+			module.pkgs[pkg] = true
+		}
+	}
+	return module, nil
+}
+
+var (
+	automaticModuleVersion = regexp.MustCompile(`-(\d+(\.|$))`)
+	nonAlphanumeric        = regexp.MustCompile(`[^A-Za-z0-9]`)
+	repeatingDots          = regexp.MustCompile(`\.{2,}`)
+)
+
+// 按照 java.lang.module.ModuleFinder 的规则，根据 jar 文件名生成自动模块名：
+// 去掉扩展名和版本号（第一个 "-数字" 之后的部分），非字母数字的字符替换为 "."，合并连续的 "." 并去掉首尾的 "."
+// 例如 foo-bar-1.2.3.jar 的模块名为 foo.bar
+func automaticModuleName(path string) string {
+	name := filepath.Base(path)
+	name = name[:len(name)-len(filepath.Ext(name))]
+	if loc := automaticModuleVersion.FindStringIndex(name); loc != nil {
+		name = name[:loc[0]]
+	}
+	name = nonAlphanumeric.ReplaceAllString(name, ".")
+	name = repeatingDots.ReplaceAllString(name, ".")
+	return strings.Trim(name, ".")
+}
+
+// 在模块路径中查找所有模块，模块路径中的每一项可以是模块本身，也可以是包含多个模块的目录
+// 同名模块以模块路径中先出现的为准，但同一个目录中出现同名模块是错误
+func findModules(modulePath string, release int) (map[string]*Module, error) {
+	found := map[string]*Module{}
+	for _, path := range strings.Split(modulePath, pathListSeparator) {
+		if path == "" {
+			continue
+		}
+		module, err := newModule(path, release)
+		if err != nil {
+			return nil, err
+		}
+		if module != nil {
+			if _, ok := found[module.name]; !ok {
+				found[module.name] = module
+			}
+			continue
+		}
+
+		infos, err := ioutil.ReadDir(path)
+		if err != nil {
+			continue // 与 -classpath 一样忽略不存在的路径
+		}
+		inDir := map[string]*Module{}
+		for _, info := range infos {
+			module, err := newModule(filepath.Join(path, info.Name()), release)
+			if err != nil {
+				return nil, err
+			}
+			if module == nil {
+				continue
+			}
+			if other, ok := inDir[module.name]; ok {
+				return nil, fmt.Errorf("two versions of module %s found in %s (%s and %s)",
+					module.name, path, filepath.Base(other.entry.String()), info.Name())
+			}
+			inDir[module.name] = module
+			if _, ok := found[module.name]; !ok {
+				found[module.name] = module
+			}
+		}
+	}
+	return found, nil
+}
+
+// moduleGraph 是从根模块开始解析得到的模块图，只包含模块路径上的模块，
+// 系统模块（启动类路径中的模块）由 isSystem 判断，不需要解析
+type moduleGraph struct {
+	modules  map[string]*Module
+	order    []*Module // 解析顺序，根模块在最前面
+	isSystem func(name string) bool
+}
+
+// 从根模块开始，沿着 requires 解析所有依赖的模块，并计算可读关系
+// 缺少依赖、循环依赖以及多个模块包含同一个包都是解析错误，所有错误会一起返回
+func resolveModules(root string, found map[string]*Module, isSystem func(string) bool) (*moduleGraph, error) {
+	graph := &moduleGraph{modules: map[string]*Module{}, isSystem: isSystem}
+	var errs []string
+
+	rootModule, ok := found[root]
+	if !ok {
+		return nil, fmt.Errorf("module %s not found", root)
+	}
+	graph.add(rootModule)
+	for i := 0; i < len(graph.order); i++ {
+		module := graph.order[i]
+		if module.descriptor == nil {
+			// 自动模块没有声明依赖，解析到任何一个自动模块时，模块路径上的所有自动模块都会被解析
+			for _, name := range sortedModuleNames(found) {
+				if found[name].descriptor == nil {
+					graph.add(found[name])
+				}
+			}
+			continue
+		}
+		for _, requires := range module.descriptor.Requires() {
+			if requires.IsStatic() || isSystem(requires.Name()) {
+				continue // requires static 只是编译期依赖，运行时不需要解析
+			}
+			if dep, ok := found[requires.Name()]; ok {
+				graph.add(dep)
+			} else {
+				errs = append(errs, fmt.Sprintf("module %s not found, required by %s", requires.Name(), module.name))
+			}
+		}
+	}
+
+	errs = append(errs, graph.checkCycles()...)
+	errs = append(errs, graph.checkSplitPackages()...)
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "\n"))
+	}
+	graph.computeReads()
+	return graph, nil
+}
+
+func (self *moduleGraph) add(module *Module) {
+	if _, ok := self.modules[module.name]; !ok {
+		self.modules[module.name] = module
+		self.order = append(self.order, module)
+	}
+}
+
+// 模块之间的 requires 不能形成环
+func (self *moduleGraph) checkCycles() []string {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := map[string]int{}
+	var errs []string
+	var path []string
+	var visit func(module *Module)
+	visit = func(module *Module) {
+		state[module.name] = visiting
+		path = append(path, module.name)
+		if module.descriptor != nil {
+			for _, requires := range module.descriptor.Requires() {
+				dep, ok := self.modules[requires.Name()]
+				if !ok {
+					continue
+				}
+				switch state[dep.name] {
+				case visiting:
+					for i, name := range path {
+						if name == dep.name {
+							cycle := append(append([]string{}, path[i:]...), dep.name)
+							errs = append(errs, "cycle detected: "+strings.Join(cycle, " -> "))
+						}
+					}
+				case 0:
+					visit(dep)
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[module.name] = visited
+	}
+	for _, module := range self.order {
+		if state[module.name] == 0 {
+			visit(module)
+		}
+	}
+	return errs
+}
+
+// 同一个包不能出现在多个模块中
+func (self *moduleGraph) checkSplitPackages() []string {
+	owners := map[string]string{}
+	var errs []string
+	for _, module := range self.order {
+		for _, pkg := range sortedPackages(module.pkgs) {
+			if owner, ok := owners[pkg]; ok {
+				errs = append(errs, fmt.Sprintf("package %s in both module %s and module %s",
+					strings.Replace(pkg, "/", ".", -1), owner, module.name))
+			} else {
+				owners[pkg] = module.name
+			}
+		}
+	}
+	return errs
+}
+
+// 计算可读关系：模块可以读取它 requires 的模块，以及这些模块 requires transitive 的模块（递归）；
+// 读取了任何一个自动模块，就可以读取所有自动模块；自动模块可以读取所有模块；所有模块都隐式读取 java.base
+// requires static 的模块只有在被解析时才可读
+func (self *moduleGraph) computeReads() {
+	for _, module := range self.order {
+		module.reads = map[string]bool{"java.base": true}
+	}
+	for _, module := range self.order {
+		if module.descriptor == nil {
+			for _, other := range self.order {
+				if other != module {
+					module.reads[other.name] = true
+				}
+			}
+			continue
+		}
+		for _, requires := range module.descriptor.Requires() {
+			if requires.IsStatic() {
+				if _, ok := self.modules[requires.Name()]; !ok && !self.isSystem(requires.Name()) {
+					continue
+				}
+			}
+			self.addReads(module, requires.Name())
+		}
+	}
+}
+
+func (self *moduleGraph) addReads(module *Module, name string) {
+	if module.reads[name] {
+		return
+	}
+	module.reads[name] = true
+	dep, ok := self.modules[name]
+	if !ok {
+		return // 系统模块之间的可读关系由启动类路径负责
+	}
+	if dep.descriptor == nil {
+		for _, other := range self.order {
+			if other.descriptor == nil {
+				module.reads[other.name] = true
+			}
+		}
+		return
+	}
+	for _, requires := range dep.descriptor.Requires() {
+		if requires.IsTransitive() {
+			self.addReads(module, requires.Name())
+		}
+	}
+}
+
+// 按照解析顺序组成一个 CompositeEntry，用于建立索引和查找 class
+func (self *moduleGraph) entry() CompositeEntry {
+	entries := make(CompositeEntry, len(self.order))
+	for i, module := range self.order {
+		entries[i] = module
+	}
+	return entries
+}
+
+func sortedModuleNames(modules map[string]*Module) []string {
+	names := make([]string, 0, len(modules))
+	for name := range modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedPackages(packages map[string]bool) []string {
+	pkgs := make([]string, 0, len(packages))
+	for pkg := range packages {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+	return pkgs
+}
+
+// Module 结构体实现 Entry 接口 readClass() 方法，只读取属于本模块的包中的 class
+func (self *Module) readClass(className string) ([]byte, Entry, error) {
+	if !self.pkgs[packageOf(className)] {
+		return nil, nil, errors.New("class not found: " + className)
+	}
+	data, _, err := self.entry.readClass(className)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, self, nil
+}
+
+// 按照模块的封装规则读取资源：class 文件和不属于任何包的资源（如 META-INF/ 下的文件）总是可以读取，
+// 包中的其他资源只有在包对所有模块开放（opens 或者 open module）时才能读取；自动模块的所有包都是开放的
+func (self *Module) readResource(name string) ([]byte, Entry, error) {
+	pkg := packageOf(name)
+	if self.pkgs[pkg] && !strings.HasSuffix(name, ".class") && !self.isOpen(pkg) {
+		return nil, nil, errors.New("resource not found: " + name)
+	}
+	data, _, err := self.entry.readResource(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, self, nil
+}
+
+// 包是否无条件地对所有模块开放
+func (self *Module) isOpen(pkg string) bool {
+	if self.descriptor == nil || self.descriptor.IsOpen() {
+		return true
+	}
+	for _, opens := range self.descriptor.Opens() {
+		if opens.Package() == pkg && !opens.IsQualified() {
+			return true
+		}
+	}
+	return false
+}
+
+func (self *Module) packages() ([]string, bool) {
+	return sortedPackages(self.pkgs), true
+}
+
+// 遍历模块中的所有文件
+func (self *Module) Walk(fn func(name string) error) error {
+	return self.entry.Walk(fn)
+}
+
+func (self *Module) Close() error {
+	return self.entry.Close()
+}
+
+func (self *Module) String() string {
+	return self.name + "@" + self.entry.String()
+}
+
+// getter 方法
+func (self *Module) Name() string {
+	return self.name
+}
+
+// 自动模块返回 nil
+func (self *Module) Descriptor() *classfile.ModuleDescriptor {
+	return self.descriptor
+}
+func (self *Module) IsAutomatic() bool {
+	return self.descriptor == nil
+}
+
+// 判断本模块能否读取另一个模块，模块总是可以读取自身
+func (self *Module) CanRead(name string) bool {
+	return name == self.name || self.reads[name]
+}
+
+// 模块所在的路径
+func (self *Module) Location() string {
+	return self.entry.String()
+}
@@ -0,0 +1,389 @@
+package classpath
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// 测试用的 module-info.class 生成器，只写入解析模块图需要的内容
+type moduleInfo struct {
+	name      string
+	open      bool // open module，所有包都开放
+	requires  []moduleRequires
+	opens     []moduleOpens
+	packages  []string // 非空时写入 ModulePackages 属性
+	mainClass string   // 非空时写入 ModuleMainClass 属性
+}
+
+type moduleRequires struct {
+	name  string
+	flags uint16
+}
+
+type moduleOpens struct {
+	pkg string
+	to  []string // 非空时为只对这些模块开放的 qualified opens
+}
+
+const (
+	testRequiresTransitive = 0x0020
+	testRequiresStatic     = 0x0040
+)
+
+func (self moduleInfo) bytes() []byte {
+	var cp []byte
+	count := uint16(1)
+	u2 := func(b []byte, v uint16) []byte { return append(b, byte(v>>8), byte(v)) }
+	add := func(tag byte, info []byte) uint16 {
+		cp = append(append(cp, tag), info...)
+		count++
+		return count - 1
+	}
+	utf8 := func(s string) uint16 { return add(1, append(u2(nil, uint16(len(s))), s...)) }
+	ref := func(tag byte, name string) uint16 { return add(tag, u2(nil, utf8(name))) }
+
+	this := ref(7, "module-info")
+	flags := uint16(0)
+	if self.open {
+		flags = 0x0020 // ACC_OPEN
+	}
+	body := u2(u2(u2(nil, ref(19, self.name)), flags), 0)
+	requires := append([]moduleRequires{{"java.base", 0x8000}}, self.requires...)
+	body = u2(body, uint16(len(requires)))
+	for _, r := range requires {
+		body = u2(u2(u2(body, ref(19, r.name)), r.flags), 0)
+	}
+	body = u2(u2(body, 0), uint16(len(self.opens))) // exports, opens
+	for _, o := range self.opens {
+		body = u2(u2(u2(body, ref(20, o.pkg)), 0), uint16(len(o.to)))
+		for _, to := range o.to {
+			body = u2(body, ref(19, to))
+		}
+	}
+	body = u2(u2(body, 0), 0) // uses, provides
+
+	var attrs [][]byte
+	attr := func(name string, info []byte) {
+		b := u2(nil, utf8(name))
+		b = append(b, byte(len(info)>>24), byte(len(info)>>16), byte(len(info)>>8), byte(len(info)))
+		attrs = append(attrs, append(b, info...))
+	}
+	attr("Module", body)
+	if len(self.packages) > 0 {
+		info := u2(nil, uint16(len(self.packages)))
+		for _, pkg := range self.packages {
+			info = u2(info, ref(20, pkg))
+		}
+		attr("ModulePackages", info)
+	}
+	if self.mainClass != "" {
+		attr("ModuleMainClass", u2(nil, ref(7, self.mainClass)))
+	}
+
+	data := []byte{0xCA, 0xFE, 0xBA, 0xBE, 0, 0, 0, 53}
+	data = append(u2(data, count), cp...)
+	data = u2(u2(u2(u2(u2(u2(data, 0x8000), this), 0), 0), 0), 0) // access_flags 到 methods_count
+	data = u2(data, uint16(len(attrs)))
+	for _, a := range attrs {
+		data = append(data, a...)
+	}
+	return data
+}
+
+var testClass = []byte{0xCA, 0xFE, 0xBA, 0xBE}
+
+func writeTestFile(t *testing.T, path string, data []byte) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func zipBytes(t *testing.T, files map[string][]byte) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, name := range sortedKeys(files) {
+		fw, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(files[name])
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func sortedKeys(files map[string][]byte) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func newModuleTestClasspath() *Classpath {
+	cp := &Classpath{bootClasspath: CompositeEntry{}, extClasspath: CompositeEntry{}, userClasspath: CompositeEntry{}}
+	cp.buildIndex()
+	return cp
+}
+
+// 模块路径 mods 目录中的模块：
+//
+//	app          展开的目录，没有 ModulePackages 属性，requires lib、foo.bar、java.sql 和 static opt.missing
+//	lib.jar      模块化 jar，requires transitive util
+//	util.jmod    jmod 文件，带 ModulePackages 属性
+//	foo-bar-1.2.3.jar  自动模块 foo.bar
+//	other.jar    依赖不存在的模块，但不会被解析
+func writeTestModulePath(t *testing.T) string {
+	mods := filepath.Join(t.TempDir(), "mods")
+	writeTestFile(t, filepath.Join(mods, "app", "module-info.class"), moduleInfo{
+		name: "app",
+		requires: []moduleRequires{
+			{"lib", 0}, {"foo.bar", 0}, {"java.sql", 0}, {"opt.missing", testRequiresStatic},
+		},
+		mainClass: "com/app/Main",
+	}.bytes())
+	writeTestFile(t, filepath.Join(mods, "app", "com", "app", "Main.class"), testClass)
+	writeTestFile(t, filepath.Join(mods, "app", "com", "app", "impl", "Impl.class"), testClass)
+
+	writeTestFile(t, filepath.Join(mods, "lib.jar"), zipBytes(t, map[string][]byte{
+		"module-info.class":     moduleInfo{name: "lib", requires: []moduleRequires{{"util", testRequiresTransitive}}}.bytes(),
+		"com/lib/Lib.class":     testClass,
+		"com/lib/res/data.txt":  []byte("not a class"),
+		"META-INF/MANIFEST.MF":  []byte("Manifest-Version: 1.0\n"),
+		"com/lib/inner/X.class": testClass,
+	}))
+	writeTestFile(t, filepath.Join(mods, "util.jmod"), append(append([]byte{}, jmodMagic...), zipBytes(t, map[string][]byte{
+		"classes/module-info.class":   moduleInfo{name: "util", packages: []string{"com/util"}}.bytes(),
+		"classes/com/util/Util.class": testClass,
+		"bin/tool":                    []byte("x"),
+	})...))
+	writeTestFile(t, filepath.Join(mods, "foo-bar-1.2.3.jar"), zipBytes(t, map[string][]byte{
+		"org/foo/Foo.class": testClass,
+	}))
+	writeTestFile(t, filepath.Join(mods, "other.jar"), zipBytes(t, map[string][]byte{
+		"module-info.class": moduleInfo{name: "other", requires: []moduleRequires{{"nothere", 0}}}.bytes(),
+		"org/other/O.class": testClass,
+	}))
+	return mods
+}
+
+func TestResolveModules(t *testing.T) {
+	cp := newModuleTestClasspath()
+	if err := cp.ResolveModules(writeTestModulePath(t), "app"); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, module := range cp.Modules() {
+		names = append(names, module.Name())
+	}
+	if got, want := strings.Join(names, " "), "app lib foo.bar util"; got != want {
+		t.Errorf("resolved modules = %s, want %s", got, want)
+	}
+	if cp.Module("other") != nil {
+		t.Error("module other was resolved although nothing requires it")
+	}
+	if md := cp.Module("app").Descriptor(); md == nil || md.MainClass() != "com/app/Main" {
+		t.Errorf("app descriptor = %v", md)
+	}
+	if !cp.Module("foo.bar").IsAutomatic() || cp.Module("lib").IsAutomatic() {
+		t.Error("only foo.bar should be an automatic module")
+	}
+
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{"app", "app", true},
+		{"app", "lib", true},
+		{"app", "util", true}, // lib requires transitive util
+		{"app", "foo.bar", true},
+		{"app", "java.base", true},
+		{"app", "java.sql", true},
+		{"app", "other", false},
+		{"lib", "util", true},
+		{"lib", "app", false},
+		{"util", "lib", false},
+		{"util", "java.base", true},
+		{"foo.bar", "app", true}, // 自动模块可以读取所有模块
+		{"foo.bar", "util", true},
+	}
+	for _, test := range tests {
+		if got := cp.Module(test.from).CanRead(test.to); got != test.want {
+			t.Errorf("%s.CanRead(%s) = %v, want %v", test.from, test.to, got, test.want)
+		}
+	}
+}
+
+// 展开的模块没有 ModulePackages 属性时遍历目录得到包；jmod 使用 ModulePackages 属性，并且跳过 JM 文件头和 classes/ 前缀
+func TestModuleLayouts(t *testing.T) {
+	mods := writeTestModulePath(t)
+	tests := []struct {
+		path     string
+		name     string
+		packages string
+	}{
+		{"app", "app", "com/app com/app/impl"},
+		{"lib.jar", "lib", "com/lib com/lib/inner"},
+		{"util.jmod", "util", "com/util"},
+		{"foo-bar-1.2.3.jar", "foo.bar", "org/foo"},
+	}
+	for _, test := range tests {
+		module, err := newModule(filepath.Join(mods, test.path), 8)
+		if err != nil {
+			t.Errorf("%s: %v", test.path, err)
+			continue
+		}
+		if module == nil {
+			t.Errorf("%s: not a module", test.path)
+			continue
+		}
+		pkgs, _ := module.packages()
+		if module.Name() != test.name || strings.Join(pkgs, " ") != test.packages {
+			t.Errorf("%s: got module %s with packages %v, want %s with %s", test.path, module.Name(), pkgs, test.name, test.packages)
+		}
+		if _, _, err := module.readClass("module-info.class"); err == nil {
+			t.Errorf("%s: module-info.class is readable as a class of the module", test.path)
+		}
+		module.Close()
+	}
+
+	// 没有 module-info.class 的目录和其他文件不是模块
+	for _, path := range []string{"", "app/com"} {
+		if module, err := newModule(filepath.Join(mods, path), 8); module != nil || err != nil {
+			t.Errorf("%q: got %v, %v; want not a module", path, module, err)
+		}
+	}
+}
+
+func TestReadClassFromModule(t *testing.T) {
+	cp := newModuleTestClasspath()
+	if err := cp.ResolveModules(writeTestModulePath(t), "app"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		from, className string
+		found           bool
+	}{
+		{"app", "com/app/Main", true},
+		{"app", "com/lib/Lib", true},
+		{"app", "com/util/Util", true},
+		{"app", "org/foo/Foo", true},
+		{"app", "org/other/O", false}, // 未解析的模块不可见
+		{"lib", "com/util/Util", true},
+		{"lib", "com/app/Main", false},
+		{"util", "com/lib/Lib", false},
+		{"nothere", "com/app/Main", false},
+	}
+	for _, test := range tests {
+		_, entry, err := cp.ReadClassFromModule(test.from, test.className)
+		if (err == nil) != test.found {
+			t.Errorf("ReadClassFromModule(%s, %s): got %v, %v; want found = %v", test.from, test.className, entry, err, test.found)
+		}
+	}
+	if _, _, err := cp.ReadClass("org/other/O"); err == nil {
+		t.Error("ReadClass found a class of an unresolved module")
+	}
+	if _, _, err := cp.ReadClass("com/util/Util"); err != nil {
+		t.Errorf("ReadClass(com/util/Util): %v", err)
+	}
+}
+
+// 缺少依赖、循环依赖和包冲突一起报告
+func TestResolveModulesErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "a.jar"), zipBytes(t, map[string][]byte{
+		"module-info.class": moduleInfo{name: "a", requires: []moduleRequires{{"b", 0}, {"nothere", 0}}}.bytes(),
+		"p/A.class":         testClass,
+	}))
+	writeTestFile(t, filepath.Join(dir, "b.jar"), zipBytes(t, map[string][]byte{
+		"module-info.class": moduleInfo{name: "b", requires: []moduleRequires{{"c", 0}}}.bytes(),
+		"p/B.class":         testClass,
+	}))
+	writeTestFile(t, filepath.Join(dir, "c.jar"), zipBytes(t, map[string][]byte{
+		"module-info.class": moduleInfo{name: "c", requires: []moduleRequires{{"a", 0}}}.bytes(),
+		"q/C.class":         testClass,
+	}))
+
+	cp := newModuleTestClasspath()
+	err := cp.ResolveModules(dir, "a")
+	if err == nil {
+		t.Fatal("ResolveModules succeeded")
+	}
+	for _, want := range []string{
+		"module nothere not found, required by a",
+		"cycle detected: a -> b -> c -> a",
+		"package p in both module a and module b",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+	if cp.Modules() != nil {
+		t.Error("a failed resolution changed the classpath")
+	}
+
+	if err := cp.ResolveModules(dir, "missing"); err == nil || err.Error() != "module missing not found" {
+		t.Errorf("got error %v for a missing root module", err)
+	}
+}
+
+func TestDuplicateModulesInDirectory(t *testing.T) {
+	dir := t.TempDir()
+	for _, jar := range []string{"x-1.0.jar", "x-2.0.jar"} {
+		writeTestFile(t, filepath.Join(dir, jar), zipBytes(t, map[string][]byte{"x/X.class": testClass}))
+	}
+	if _, err := findModules(dir, 8); err == nil || !strings.Contains(err.Error(), "two versions of module x") {
+		t.Errorf("got error %v, want two versions of module x", err)
+	}
+
+	// 模块路径中靠前的同名模块优先
+	first, second := filepath.Join(dir, "first"), filepath.Join(dir, "second")
+	writeTestFile(t, filepath.Join(first, "y.jar"), zipBytes(t, map[string][]byte{"y/A.class": testClass}))
+	writeTestFile(t, filepath.Join(second, "y.jar"), zipBytes(t, map[string][]byte{"y/B.class": testClass}))
+	found, err := findModules(first+pathListSeparator+second, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found["y"] == nil || !found["y"].pkgs["y"] || !strings.HasPrefix(found["y"].Location(), first) {
+		t.Errorf("module y = %v, want the one in %s", found["y"], first)
+	}
+}
+
+func TestAutomaticModuleName(t *testing.T) {
+	tests := []struct{ jar, name string }{
+		{"foo-bar-1.2.3.jar", "foo.bar"},
+		{"guava-31.1-jre.jar", "guava"},
+		{"commons_io.jar", "commons.io"},
+		{"a..b--c.jar", "a.b.c"},
+		{"x-1.jar", "x"},
+		{"jetty-util-9.4.jar", "jetty.util"},
+		{"hello.world.jar", "hello.world"},
+		{"-1.0.jar", ""},
+	}
+	for _, test := range tests {
+		if got := automaticModuleName(filepath.Join("lib", test.jar)); got != test.name {
+			t.Errorf("automaticModuleName(%s) = %q, want %q", test.jar, got, test.name)
+		}
+	}
+}
+
+func TestJmodHeader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.jmod")
+	writeTestFile(t, path, zipBytes(t, map[string][]byte{"classes/module-info.class": moduleInfo{name: "bad"}.bytes()}))
+	if _, err := newModule(path, 8); err == nil {
+		t.Error("a jmod without the JM header was accepted")
+	}
+}
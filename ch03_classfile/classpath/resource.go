@@ -0,0 +1,81 @@
+package classpath
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+// Resource 是在 classpath 中找到的一个资源文件
+type Resource struct {
+	name  string
+	data  []byte
+	entry Entry // 资源所在的目录、jar 文件或模块
+}
+
+// getter 方法
+func (self *Resource) Name() string {
+	return self.name
+}
+func (self *Resource) Data() []byte {
+	return self.data
+}
+func (self *Resource) Entry() Entry {
+	return self.entry
+}
+
+// 按照 boot -> ext -> 模块路径 -> user 的顺序查找资源，返回第一个找到的资源，对应 ClassLoader.getResource()
+// 资源名是以 "/" 分隔的相对路径，例如 META-INF/services/java.sql.Driver，开头的 "/" 会被忽略
+// 资源不一定与 class 文件位于同一个包中，所以不使用包索引，而是依次查找每个目录和 jar 文件
+func (self *Classpath) GetResource(name string) (*Resource, error) {
+	name = strings.TrimPrefix(name, "/")
+	if !isValidResourceName(name) {
+		return nil, errors.New("invalid resource name: " + name)
+	}
+	for _, entry := range self.getIndex().entries {
+		if data, from, err := entry.readResource(name); err == nil {
+			return &Resource{name: name, data: data, entry: from}, nil
+		}
+	}
+	return nil, errors.New("resource not found: " + name)
+}
+
+// 与 GetResource() 相同，但返回可以读取资源内容的 io.ReadCloser，对应 ClassLoader.getResourceAsStream()
+func (self *Classpath) GetResourceAsStream(name string) (io.ReadCloser, error) {
+	resource, err := self.GetResource(name)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(resource.data)), nil
+}
+
+// 返回所有目录和 jar 文件中名为 name 的资源，顺序与查找顺序相同，对应 ClassLoader.getResources()
+// ServiceLoader 通过它读取所有 META-INF/services/ 下的服务配置文件
+func (self *Classpath) GetResources(name string) ([]*Resource, error) {
+	name = strings.TrimPrefix(name, "/")
+	if !isValidResourceName(name) {
+		return nil, errors.New("invalid resource name: " + name)
+	}
+	var resources []*Resource
+	for _, entry := range self.getIndex().entries {
+		if data, from, err := entry.readResource(name); err == nil {
+			resources = append(resources, &Resource{name: name, data: data, entry: from})
+		}
+	}
+	return resources, nil
+}
+
+// 资源名必须是不以 "/" 开始、不含 "." 和 ".." 的规范相对路径，防止读取到 classpath 之外的文件
+func isValidResourceName(name string) bool {
+	if name == "" || strings.HasPrefix(name, "/") || strings.Contains(name, "\\") {
+		return false
+	}
+	clean := path.Clean(name)
+	if clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return false
+	}
+	return clean == strings.TrimSuffix(name, "/")
+}
@@ -0,0 +1,157 @@
+package classpath
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// user classpath 依次为 classes 目录、lib.jar 和 more 目录，三者都有同名的服务配置文件
+func newResourceTestClasspath(t *testing.T) (cp *Classpath, classes, jar, more string) {
+	dir := t.TempDir()
+	classes = filepath.Join(dir, "classes")
+	writeTestFile(t, filepath.Join(classes, "META-INF", "services", "p.Service"), []byte("classes"))
+	writeTestFile(t, filepath.Join(classes, "p", "config.properties"), []byte("config"))
+	writeTestFile(t, filepath.Join(dir, "secret.txt"), []byte("outside the classpath"))
+	jar = filepath.Join(dir, "lib.jar")
+	writeTestFile(t, jar, zipBytes(t, map[string][]byte{
+		"META-INF/services/p.Service": []byte("jar"),
+		"q/data.bin":                  []byte("data"),
+	}))
+	more = filepath.Join(dir, "more")
+	writeTestFile(t, filepath.Join(more, "META-INF", "services", "p.Service"), []byte("more"))
+
+	cp = newModuleTestClasspath()
+	user := CompositeEntry{}
+	for _, path := range []string{classes, jar, more} {
+		user = append(user, newEntry(path, &cp.diagnostics))
+	}
+	cp.userClasspath = user
+	cp.buildIndex()
+	return cp, classes, jar, more
+}
+
+func TestGetResources(t *testing.T) {
+	cp, classes, jar, more := newResourceTestClasspath(t)
+	defer cp.Close()
+
+	resources, err := cp.GetResources("META-INF/services/p.Service")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ entry, data string }{{classes, "classes"}, {jar, "jar"}, {more, "more"}}
+	if len(resources) != len(want) {
+		t.Fatalf("found %d resources, want %d", len(resources), len(want))
+	}
+	for i, resource := range resources {
+		if resource.Entry().String() != want[i].entry || string(resource.Data()) != want[i].data {
+			t.Errorf("resource %d = %q from %v, want %q from %s", i, resource.Data(), resource.Entry(), want[i].data, want[i].entry)
+		}
+	}
+
+	// GetResource() 返回查找顺序中的第一个
+	resource, err := cp.GetResource("/META-INF/services/p.Service")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resource.Data()) != "classes" || resource.Name() != "META-INF/services/p.Service" {
+		t.Errorf("GetResource() = %s %q, want the one in classes", resource.Name(), resource.Data())
+	}
+
+	stream, err := cp.GetResourceAsStream("q/data.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(stream)
+	stream.Close()
+	if err != nil || string(data) != "data" {
+		t.Errorf("GetResourceAsStream() read %q, %v", data, err)
+	}
+
+	if resources, err := cp.GetResources("no/such/resource"); err != nil || len(resources) != 0 {
+		t.Errorf("GetResources() of a missing resource = %v, %v; want none", resources, err)
+	}
+	if _, err := cp.GetResource("no/such/resource"); err == nil {
+		t.Error("GetResource() found a missing resource")
+	}
+}
+
+// 资源名不能逃出 classpath 中的目录
+func TestGetResourceInvalidNames(t *testing.T) {
+	cp, _, _, _ := newResourceTestClasspath(t)
+	defer cp.Close()
+
+	for _, name := range []string{"", "../secret.txt", "p/../../secret.txt", "/../secret.txt", "p/./config.properties", "p\\config.properties", "p//config.properties"} {
+		if resource, err := cp.GetResource(name); err == nil {
+			t.Errorf("GetResource(%q) = %q, want an error", name, resource.Data())
+		}
+		if _, err := cp.GetResources(name); err == nil {
+			t.Errorf("GetResources(%q) accepted an invalid name", name)
+		}
+	}
+	if _, err := cp.GetResource("p/config.properties"); err != nil {
+		t.Error(err)
+	}
+}
+
+// 命名模块中的资源受模块封装的限制：class 文件和不属于任何包的资源总是可见，
+// 包中的其他资源只有在包无条件开放时才可见
+func TestModuleResourceEncapsulation(t *testing.T) {
+	mods := filepath.Join(t.TempDir(), "mods")
+	writeTestFile(t, filepath.Join(mods, "enc.jar"), zipBytes(t, map[string][]byte{
+		"module-info.class": moduleInfo{
+			name:     "enc",
+			requires: []moduleRequires{{"opener", 0}, {"openmod", 0}},
+			opens:    []moduleOpens{{"com/enc/open", nil}, {"com/enc/friends", []string{"opener"}}},
+		}.bytes(),
+		"com/enc/C.class":             testClass,
+		"com/enc/data.txt":            []byte("encapsulated"),
+		"com/enc/open/O.class":        testClass,
+		"com/enc/open/data.txt":       []byte("opened"),
+		"com/enc/friends/F.class":     testClass,
+		"com/enc/friends/data.txt":    []byte("qualified"),
+		"com/enc/res/data.txt":        []byte("not a package"),
+		"META-INF/services/p.Service": []byte("service"),
+		"readme.txt":                  []byte("top level"),
+	}))
+	writeTestFile(t, filepath.Join(mods, "opener", "module-info.class"), moduleInfo{name: "opener"}.bytes())
+	writeTestFile(t, filepath.Join(mods, "opener", "com", "opener", "Opener.class"), testClass)
+	writeTestFile(t, filepath.Join(mods, "openmod.jar"), zipBytes(t, map[string][]byte{
+		"module-info.class":      moduleInfo{name: "openmod", open: true}.bytes(),
+		"org/openmod/M.class":    testClass,
+		"org/openmod/config.xml": []byte("open module"),
+	}))
+
+	cp := newModuleTestClasspath()
+	if err := cp.ResolveModules(mods, "enc"); err != nil {
+		t.Fatal(err)
+	}
+	defer cp.Close()
+
+	tests := []struct {
+		name    string
+		visible bool
+	}{
+		{"com/enc/C.class", true},
+		{"com/enc/data.txt", false},
+		{"com/enc/open/data.txt", true},
+		{"com/enc/friends/data.txt", false}, // 只对 opener 开放
+		{"com/enc/res/data.txt", true},
+		{"META-INF/services/p.Service", true},
+		{"readme.txt", true},
+		{"org/openmod/config.xml", true},
+		{"module-info.class", true},
+	}
+	for _, test := range tests {
+		resource, err := cp.GetResource(test.name)
+		if visible := err == nil; visible != test.visible {
+			t.Errorf("%s: visible = %v, want %v (%v)", test.name, visible, test.visible, err)
+			continue
+		}
+		if err == nil {
+			if _, ok := resource.Entry().(*Module); !ok {
+				t.Errorf("%s: read from %v, want a module", test.name, resource.Entry())
+			}
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package classpath
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Entry 的方法都是未导出的，其他包无法实现新的 Entry
+// ClassSource 是可以由其他包实现的 class 来源，例如构建时生成、只存在于内存中的 class，或者远程服务器上的 class
+//
+// 实现了 Walk(fn func(name string) error) error 方法的 ClassSource 可以被枚举，用于建立包索引和 Classpath.ListClasses()，
+// 否则任何包都需要在其中查找；实现了 Close() error 方法的 ClassSource 在 Classpath.Close() 时释放资源
+type ClassSource interface {
+	ReadFile(name string) ([]byte, error) // name 是以 "/" 分隔的相对路径，例如 java/lang/Object.class
+	String() string
+}
+
+type walkableSource interface {
+	Walk(fn func(name string) error) error
+}
+
+// SourceOpener 根据 URL 创建 ClassSource，classpath 中以已注册的 scheme 开头的路径（如 http://host/classes/）由它打开
+type SourceOpener func(url string) (ClassSource, error)
+
+var (
+	openersMutex sync.RWMutex
+	openers      = map[string]SourceOpener{}
+)
+
+func init() {
+	RegisterScheme("http", openHTTPSource)
+	RegisterScheme("https", openHTTPSource)
+}
+
+// 为 URL scheme 注册 SourceOpener，scheme 不区分大小写
+// 为了不与 Windows 的盘符混淆，scheme 至少需要两个字符；重复注册同一个 scheme 会 panic
+func RegisterScheme(scheme string, opener SourceOpener) {
+	scheme = strings.ToLower(scheme)
+	if len(scheme) < 2 || opener == nil {
+		panic("classpath: invalid scheme registration: " + scheme)
+	}
+	openersMutex.Lock()
+	defer openersMutex.Unlock()
+	if _, ok := openers[scheme]; ok {
+		panic("classpath: scheme registered twice: " + scheme)
+	}
+	openers[scheme] = opener
+}
+
+// 如果 path 以已注册的 scheme 开头，返回 scheme 和对应的 SourceOpener
+func schemeOf(path string) (string, SourceOpener) {
+	i := strings.Index(path, ":")
+	if i < 2 {
+		return "", nil
+	}
+	scheme := strings.ToLower(path[:i])
+	openersMutex.RLock()
+	defer openersMutex.RUnlock()
+	if opener, ok := openers[scheme]; ok {
+		return scheme, opener
+	}
+	return "", nil
+}
+
+// 用系统分隔符切分路径列表，但不切分 URL 中的分隔符
+// 类 UNIX 系统的分隔符是 ":"，所以 http://host:8080/classes/ 中的 ":" 不能作为分隔符：
+// URL 中 "scheme:" 和 "//host:port" 部分的 ":" 都会被跳过，之后遇到的第一个分隔符才结束这个路径
+func splitPathList(pathList string) []string {
+	var list []string
+	for {
+		skip := 0
+		if scheme, _ := schemeOf(pathList); scheme != "" {
+			skip = len(scheme) + 1
+			if strings.HasPrefix(pathList[skip:], "//") {
+				if i := strings.Index(pathList[skip+2:], "/"); i >= 0 {
+					skip += 2 + i
+				} else {
+					return append(list, pathList) // 只有主机名的 URL 一直延续到结尾
+				}
+			}
+		}
+		i := strings.Index(pathList[skip:], pathListSeparator)
+		if i < 0 {
+			return append(list, pathList)
+		}
+		list = append(list, pathList[:skip+i])
+		pathList = pathList[skip+i+len(pathListSeparator):]
+	}
+}
+
+// SourceEntry 把 ClassSource 适配为 Entry
+type SourceEntry struct {
+	source ClassSource
+}
+
+func newSourceEntry(source ClassSource) *SourceEntry {
+	return &SourceEntry{source: source}
+}
+
+// getter 方法
+func (self *SourceEntry) Source() ClassSource {
+	return self.source
+}
+
+// SourceEntry 结构体实现 Entry 接口 readClass() 方法
+func (self *SourceEntry) readClass(className string) ([]byte, Entry, error) {
+	data, err := self.source.ReadFile(className)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, self, nil
+}
+
+func (self *SourceEntry) readResource(name string) ([]byte, Entry, error) {
+	return self.readClass(name)
+}
+
+// 可以枚举的 ClassSource 返回其中所有 class 文件所在的包
+func (self *SourceEntry) packages() ([]string, bool) {
+	if _, ok := self.source.(walkableSource); !ok {
+		return nil, false
+	}
+	seen := map[string]bool{}
+	pkgs := []string{}
+	err := self.Walk(func(name string) error {
+		if pkg := packageOf(name); strings.HasSuffix(name, ".class") && !seen[pkg] {
+			seen[pkg] = true
+			pkgs = append(pkgs, pkg)
+		}
+		return nil
+	})
+	return pkgs, err == nil
+}
+
+// 无法枚举的 ClassSource 不包含任何文件
+// 需要下载的 ClassSource（远程 jar 文件）在第一次读取时才下载，classIndex 在此之前不枚举其中的包
+func (self *SourceEntry) fetched() bool {
+	if f, ok := self.source.(fetcher); ok {
+		return f.fetched()
+	}
+	return true
+}
+
+func (self *SourceEntry) Walk(fn func(name string) error) error {
+	if walkable, ok := self.source.(walkableSource); ok {
+		return walkable.Walk(fn)
+	}
+	return nil
+}
+
+func (self *SourceEntry) Close() error {
+	if closer, ok := self.source.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (self *SourceEntry) String() string {
+	return self.source.String()
+}
+
+// 把 ClassSource 追加到用户类路径的末尾
+// 可以与 ReadClass() 等查找并发调用，正在进行的查找使用原来的索引
+func (self *Classpath) AppendSource(source ClassSource) {
+	self.mutex.Lock()
+	self.userClasspath = append(CompositeEntry{self.userClasspath}, newSourceEntry(source))
+	self.mutex.Unlock()
+	self.buildIndex()
+}
+
+// MemorySource 是只存在于内存中的 ClassSource，文件在创建时确定，之后不能修改
+type MemorySource struct {
+	name  string
+	files map[string][]byte // 以 "/" 分隔的文件名 -> 文件内容
+}
+
+// 创建 MemorySource，name 只用于显示，files 会被复制
+func NewMemorySource(name string, files map[string][]byte) *MemorySource {
+	source := &MemorySource{name: name, files: make(map[string][]byte, len(files))}
+	for file, data := range files {
+		source.files[strings.TrimPrefix(file, "/")] = data
+	}
+	return source
+}
+
+func (self *MemorySource) ReadFile(name string) ([]byte, error) {
+	if data, ok := self.files[name]; ok {
+		return data, nil
+	}
+	return nil, errors.New("file not found: " + name)
+}
+
+func (self *MemorySource) Walk(fn func(name string) error) error {
+	names := make([]string, 0, len(self.files))
+	for name := range self.files {
+		names = append(names, name)
+	}
+	return walkNames(names, fn)
+}
+
+func (self *MemorySource) String() string {
+	return self.name
+}
@@ -0,0 +1,149 @@
+package classpath
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 与 URLClassLoader 一样，以 "/" 结尾的 URL 是目录，每个 class 单独下载；其余的 URL 是 jar 文件，第一次读取时整个下载到内存中
+func openHTTPSource(rawURL string) (ClassSource, error) {
+	base, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if base.Host == "" {
+		return nil, errors.New("missing host in URL: " + rawURL)
+	}
+	source := &HTTPSource{base: base, client: &http.Client{Timeout: 30 * time.Second}}
+	if strings.HasSuffix(base.Path, "/") {
+		return source, nil
+	}
+	return &httpJarSource{source: source}, nil
+}
+
+// HTTPSource 从 HTTP 服务器上的目录读取文件
+type HTTPSource struct {
+	base   *url.URL
+	client *http.Client
+}
+
+// 下载 base 目录下的文件，服务器返回 404 表示文件不存在
+func (self *HTTPSource) ReadFile(name string) ([]byte, error) {
+	return self.get(self.base.ResolveReference(&url.URL{Path: name}))
+}
+
+func (self *HTTPSource) get(u *url.URL) ([]byte, error) {
+	resp, err := self.client.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", u, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+func (self *HTTPSource) String() string {
+	return self.base.String()
+}
+
+// 远程 jar 文件，下载成功后的内容保存在 MemorySource 中
+// 下载失败时不缓存结果，但在等待时间内直接返回上次的错误，避免每次查找 class 都请求服务器；
+// 等待时间从 httpRetryMinDelay 开始，每次失败后加倍，最长为 httpRetryMaxDelay
+type httpJarSource struct {
+	source  *HTTPSource
+	mutex   sync.Mutex
+	files   *MemorySource
+	err     error         // 上一次下载的错误
+	retryAt time.Time     // 在此之前不再重试
+	delay   time.Duration // 下一次失败后的等待时间
+}
+
+const (
+	httpRetryMinDelay = time.Second
+	httpRetryMaxDelay = time.Minute
+)
+
+func (self *httpJarSource) open() error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if self.files != nil {
+		return nil
+	}
+	if self.err != nil && time.Now().Before(self.retryAt) {
+		return self.err
+	}
+	data, err := self.source.get(self.source.base)
+	if err == nil {
+		self.files, err = unzipToMemory(self.String(), data)
+	}
+	if err != nil {
+		if self.delay == 0 {
+			self.delay = httpRetryMinDelay
+		}
+		self.err, self.retryAt = err, time.Now().Add(self.delay)
+		if self.delay *= 2; self.delay > httpRetryMaxDelay {
+			self.delay = httpRetryMaxDelay
+		}
+		return err
+	}
+	self.err, self.delay = nil, 0
+	return nil
+}
+
+func (self *httpJarSource) fetched() bool {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return self.files != nil
+}
+
+func (self *httpJarSource) ReadFile(name string) ([]byte, error) {
+	if err := self.open(); err != nil {
+		return nil, err
+	}
+	return self.files.ReadFile(name)
+}
+
+func (self *httpJarSource) Walk(fn func(name string) error) error {
+	if err := self.open(); err != nil {
+		return err
+	}
+	return self.files.Walk(fn)
+}
+
+func (self *httpJarSource) String() string {
+	return self.source.String()
+}
+
+// 把 zip 文件中的所有文件解压到内存中
+func unzipToMemory(name string, data []byte) (*MemorySource, error) {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	files := map[string][]byte{}
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		files[f.Name], err = ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return NewMemorySource(name, files), nil
+}
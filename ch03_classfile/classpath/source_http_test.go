@@ -0,0 +1,129 @@
+package classpath
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPSourceReadsDirectory(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/classes/p/A.class" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte{0xCA, 0xFE, 0xBA, 0xBE})
+	}))
+	defer server.Close()
+
+	source, err := openHTTPSource(server.URL + "/classes/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := source.ReadFile("p/A.class"); err != nil || !bytes.Equal(data, []byte{0xCA, 0xFE, 0xBA, 0xBE}) {
+		t.Errorf("ReadFile(p/A.class) = %x, %v", data, err)
+	}
+	if _, err := source.ReadFile("p/B.class"); err == nil {
+		t.Error("ReadFile(p/B.class) succeeded on a 404 response")
+	}
+}
+
+// 下载 jar 失败后，等待时间内返回上次的错误，等待时间过后重新下载
+func TestHTTPJarSourceRetriesFailedDownload(t *testing.T) {
+	var requests, failures int32 = 0, 1
+	jar := zipBytes(t, map[string][]byte{"p/A.class": {0xCA, 0xFE, 0xBA, 0xBE}})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if atomic.AddInt32(&failures, -1) >= 0 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(jar)
+	}))
+	defer server.Close()
+
+	opened, err := openHTTPSource(server.URL + "/lib/a.jar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := opened.(*httpJarSource)
+
+	if _, err := source.ReadFile("p/A.class"); err == nil {
+		t.Fatal("ReadFile() succeeded while the server is failing")
+	}
+	if _, err := source.ReadFile("p/A.class"); err == nil {
+		t.Fatal("ReadFile() succeeded within the retry delay")
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("%d requests within the retry delay, want 1", n)
+	}
+	if source.delay != 2*httpRetryMinDelay {
+		t.Errorf("next retry delay = %v, want %v", source.delay, 2*httpRetryMinDelay)
+	}
+
+	source.retryAt = time.Now() // 跳过等待
+	if data, err := source.ReadFile("p/A.class"); err != nil || len(data) != 4 {
+		t.Fatalf("ReadFile() after the retry delay = %x, %v", data, err)
+	}
+	if _, err := source.ReadFile("p/A.class"); err != nil {
+		t.Error(err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("%d requests, want 2", n)
+	}
+}
+
+// 远程 jar 文件不会在建立索引时下载，第一次查找时才下载，之后为它建立包索引
+func TestHTTPJarSourceIsFetchedLazily(t *testing.T) {
+	var requests, failures int32 = 0, 1
+	jar := zipBytes(t, map[string][]byte{"p/A.class": {0xCA, 0xFE, 0xBA, 0xBE}})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if atomic.AddInt32(&failures, -1) >= 0 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(jar)
+	}))
+	defer server.Close()
+
+	cp := newModuleTestClasspath()
+	cp.userClasspath = newEntry(server.URL+"/lib/a.jar", &cp.diagnostics)
+	cp.buildIndex()
+	if n := atomic.LoadInt32(&requests); n != 0 {
+		t.Fatalf("%d requests while building the index, want 0", n)
+	}
+
+	// 下载失败时找不到的 class 不缓存
+	if _, _, err := cp.ReadClass("p/A"); err == nil {
+		t.Fatal("found p/A while the server is failing")
+	}
+	if cp.getIndex().missing["p"]["p/A.class"] {
+		t.Error("a miss was cached while the jar could not be downloaded")
+	}
+
+	cp.userClasspath.(*SourceEntry).source.(*httpJarSource).retryAt = time.Now() // 跳过等待
+	if _, _, err := cp.ReadClass("p/A"); err != nil {
+		t.Fatalf("p/A not found after the jar was downloaded: %v", err)
+	}
+	index := cp.getIndex()
+	if len(index.unfetched) != 0 || !index.packages[0]["p"] {
+		t.Errorf("jar not indexed after download: packages %v, unfetched %v", index.packages[0], index.unfetched)
+	}
+
+	// 下载完成之后其他包中的 class 不再查找这个 jar，找不到的 class 会被缓存
+	for i := 0; i < 2; i++ {
+		if _, _, err := cp.ReadClass("q/B"); err == nil {
+			t.Fatal("found q/B")
+		}
+	}
+	if n := cp.Stats().NegativeCacheHits(); n != 1 {
+		t.Errorf("negative cache hits = %d, want 1", n)
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("%d requests, want 2", n)
+	}
+}
@@ -0,0 +1,148 @@
+package classpath
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSplitPathList(t *testing.T) {
+	sep := pathListSeparator
+	tests := []struct {
+		pathList string
+		want     []string
+	}{
+		{"a" + sep + "b", []string{"a", "b"}},
+		{"http://host:8080/classes/" + sep + "lib/a.jar", []string{"http://host:8080/classes/", "lib/a.jar"}},
+		{"a.jar" + sep + "https://host/lib/b.jar" + sep + "c", []string{"a.jar", "https://host/lib/b.jar", "c"}},
+		{"http://host:8080", []string{"http://host:8080"}},
+		{"HTTP://host/x/", []string{"HTTP://host/x/"}},
+	}
+	for _, test := range tests {
+		if got := splitPathList(test.pathList); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("splitPathList(%q) = %q, want %q", test.pathList, got, test.want)
+		}
+	}
+}
+
+// 测试用的 ClassSource，不能枚举
+type testSource struct {
+	files map[string][]byte
+}
+
+func (self *testSource) ReadFile(name string) ([]byte, error) {
+	if data, ok := self.files[name]; ok {
+		return data, nil
+	}
+	return nil, errors.New("file not found: " + name)
+}
+
+func (self *testSource) String() string {
+	return "test:source"
+}
+
+func TestRegisterScheme(t *testing.T) {
+	var opened []string
+	RegisterScheme("TestScheme", func(url string) (ClassSource, error) {
+		opened = append(opened, url)
+		if strings.HasSuffix(url, "bad") {
+			return nil, errors.New("cannot open " + url)
+		}
+		return &testSource{files: map[string][]byte{"p/A.class": testClass}}, nil
+	})
+
+	cp := newModuleTestClasspath()
+	cp.userClasspath = newEntry("testscheme://x/good"+pathListSeparator+"testscheme://x/bad", &cp.diagnostics)
+	cp.buildIndex()
+	if !reflect.DeepEqual(opened, []string{"testscheme://x/good", "testscheme://x/bad"}) {
+		t.Errorf("opened %q", opened)
+	}
+	if len(cp.diagnostics) != 1 || cp.diagnostics[0].Path() != "testscheme://x/bad" {
+		t.Errorf("diagnostics = %v, want one for the bad URL", cp.diagnostics)
+	}
+	_, from, err := cp.ReadClass("p/A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from.String() != "test:source" {
+		t.Errorf("read from %v", from)
+	}
+
+	for _, scheme := range []string{"testscheme", "TESTSCHEME", "x", ""} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterScheme(%q) did not panic", scheme)
+				}
+			}()
+			RegisterScheme(scheme, openHTTPSource)
+		}()
+	}
+}
+
+func TestMemorySource(t *testing.T) {
+	files := map[string][]byte{"/gen/A.class": testClass, "gen/res.txt": []byte("res")}
+	source := NewMemorySource("generated", files)
+	files["gen/B.class"] = testClass // 创建之后修改 map 不影响 MemorySource
+
+	var names []string
+	source.Walk(func(name string) error {
+		names = append(names, name)
+		return nil
+	})
+	if want := []string{"gen/A.class", "gen/res.txt"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Walk() = %q, want %q", names, want)
+	}
+	if _, err := source.ReadFile("gen/B.class"); err == nil {
+		t.Error("ReadFile() found a file added after creation")
+	}
+
+	cp := newModuleTestClasspath()
+	cp.AppendSource(source)
+	cp.AppendSource(&testSource{files: map[string][]byte{"other/C.class": testClass, "gen/A.class": []byte("shadowed")}})
+	data, from, err := cp.ReadClass("gen/A")
+	if err != nil || from.String() != "generated" || string(data) != string(testClass) {
+		t.Errorf("ReadClass(gen/A) = %q from %v, %v", data, from, err)
+	}
+	if _, from, err := cp.ReadClass("other/C"); err != nil || from.String() != "test:source" {
+		t.Errorf("ReadClass(other/C) from %v, %v", from, err)
+	}
+	if resource, err := cp.GetResource("gen/res.txt"); err != nil || string(resource.Data()) != "res" {
+		t.Errorf("GetResource(gen/res.txt) = %v, %v", resource, err)
+	}
+	classes, err := cp.ListClasses(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(classes) != 1 || classes[0].Name() != "gen/A" { // testSource 不能枚举
+		t.Errorf("ListClasses() = %v", classes)
+	}
+}
+
+// AppendSource() 可以与查找并发执行
+func TestAppendSourceConcurrently(t *testing.T) {
+	cp := newModuleTestClasspath()
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		name := string(rune('a'+i)) + "/A"
+		go func() {
+			defer wg.Done()
+			cp.AppendSource(NewMemorySource(name, map[string][]byte{name + ".class": testClass}))
+		}()
+		go func() {
+			defer wg.Done()
+			cp.ReadClass(name)
+			_ = cp.String()
+		}()
+	}
+	wg.Wait()
+	for i := 0; i < 20; i++ {
+		name := string(rune('a'+i)) + "/A"
+		if _, _, err := cp.ReadClass(name); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
@@ -7,16 +7,28 @@ import (
 )
 
 type Cmd struct {
-	helpFlag    bool
-	versionFlag bool
+	helpFlag           bool
+	versionFlag        bool
+	describeModuleFlag bool // 输出 classpath 中 module-info.class 的模块描述符
 
-	cpOption   string
-	XjreOption string // -Xjre 选项
+	cpOption         string
+	jarOption        string // -jar 选项，可执行 jar 文件
+	modulePathOption string // --module-path/-p 选项，模块路径
+	moduleOption     string // --module/-m 选项，格式为 模块名[/主类名]
+	XjreOption       string // -Xjre 选项
 
+	XstatsClasspathFlag bool // -Xstats:classpath 选项，退出前输出 classpath 查找的统计信息
+	XlintClasspathFlag  bool // -Xlint:classpath 选项，输出 JRE 目录的选择过程以及 classpath 中有问题的路径
+
+	tool  string   // 子命令名，例如 classpath、javap，为空时启动主类
 	class string   // java 主类名
-	args  []string // 主类参数
+	args  []string // 主类参数，或者由子命令自己解析的参数
 }
 
+// 子命令的名称，第一个参数是其中之一时运行对应的工具而不是启动主类；
+// 主类与工具同名时（例如默认包中的 javap 类）写在 "--" 之后，例如 jvmgo -cp . -- javap
+var toolNames = map[string]bool{"classpath": true, "javap": true, "asm": true, "diff": true, "deps": true}
+
 func parseCmd() *Cmd {
 	cmd := &Cmd{}
 	flag.Usage = printUsage
@@ -24,20 +36,74 @@ func parseCmd() *Cmd {
 	flag.BoolVar(&cmd.helpFlag, "help", false, "print help message")           // -help
 	flag.BoolVar(&cmd.helpFlag, "?", false, "print help message")              // -?
 	flag.BoolVar(&cmd.versionFlag, "version", false, "print version and exit") // -version
+	flag.BoolVar(&cmd.describeModuleFlag, "describe-module", false,
+		"print the descriptor of module-info.class found on the classpath") // -describe-module
 
-	flag.StringVar(&cmd.cpOption, "classpath", "", "classpath") // -classpath
-	flag.StringVar(&cmd.cpOption, "cp", "", "classpath")        // -cp
-	flag.StringVar(&cmd.XjreOption, "Xjre", "", "path to jre")  // -Xjre
+	flag.StringVar(&cmd.cpOption, "classpath", "", "classpath")                 // -classpath
+	flag.StringVar(&cmd.cpOption, "cp", "", "classpath")                        // -cp
+	flag.StringVar(&cmd.jarOption, "jar", "", "executable jar")                 // -jar
+	flag.StringVar(&cmd.XjreOption, "Xjre", "", "path to jre")                  // -Xjre
+	flag.StringVar(&cmd.modulePathOption, "module-path", "", "module path")     // --module-path
+	flag.StringVar(&cmd.modulePathOption, "p", "", "module path")               // -p
+	flag.StringVar(&cmd.moduleOption, "module", "", "main module[/main class]") // --module
+	flag.StringVar(&cmd.moduleOption, "m", "", "main module[/main class]")      // -m
+	flag.BoolVar(&cmd.XstatsClasspathFlag, "Xstats:classpath", false,
+		"print classpath lookup statistics before exit") // -Xstats:classpath
+	flag.BoolVar(&cmd.XlintClasspathFlag, "Xlint:classpath", false,
+		"warn about missing or unreadable classpath entries") // -Xlint:classpath
 
 	flag.Parse()
 	args := flag.Args()
-	if len(args) > 0 {
+	if cmd.moduleOption != "" || cmd.jarOption != "" {
+		cmd.args = args // 主类由 -m 选项或 jar 文件的 manifest 给出，剩余参数都是主类参数
+	} else if len(args) > 0 && toolNames[args[0]] && !afterDashes(args) {
+		cmd.tool = args[0]
+		cmd.args = args[1:]
+	} else if len(args) > 0 {
 		cmd.class = args[0] // 第一个参数为主类名
 		cmd.args = args[1:] // 随后为主类的参数
 	}
 	return cmd
 }
 
+// flag 包遇到 "--" 时停止解析选项，并把它从剩余参数中去掉，只能从 os.Args 中判断 args 之前是否是 "--"
+func afterDashes(args []string) bool {
+	i := len(os.Args) - len(args) - 1
+	return i > 0 && os.Args[i] == "--"
+}
+
 func printUsage() {
 	fmt.Printf("Usage: %s [-options] class [args...]\n", os.Args[0])
+	fmt.Printf("   or  %s [-options] -jar jarfile [args...]\n", os.Args[0])
+	fmt.Printf("   or  %s [-options] -m module[/class] [args...]\n", os.Args[0])
+	fmt.Printf("   or  %s [-options] -- class [args...]\n", os.Args[0])
+	fmt.Printf("   or  %s [-options] classpath [-list | -packages | -duplicates] [-jre]\n", os.Args[0])
+	fmt.Printf("   or  %s [-options] javap [-v] [-c] [-l] [-s] [-p] [-format=text|json] class...\n", os.Args[0])
+	fmt.Printf("   or  %s asm [-d dir] file.j...\n", os.Args[0])
+	fmt.Printf("   or  %s diff [-breaking] old new\n", os.Args[0])
+	fmt.Printf("   or  %s deps [-level class|package|archive] [-format text|dot|json] [-external] [-cycles] path...\n", os.Args[0])
+}
+
+// 各个工具统一的退出状态：参数错误，或者无法读取、解析类时为 exitError；
+// exitFailure 只用来报告检查的结果，例如 diff 发现不兼容的变化、deps -cycles 发现循环依赖
+const (
+	exitFailure = 1
+	exitError   = 2
+)
+
+// 工具的错误信息统一以 "Error: " 开头写到标准错误输出，不会和标准输出中的结果混在一起
+func printError(err error) {
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+}
+
+// 输出错误信息并以 exitError 退出
+func exitWithError(err error) {
+	printError(err)
+	os.Exit(exitError)
+}
+
+// 子命令的参数错误时输出用法说明并以 exitError 退出，与 flag 包处理无法识别的选项时相同
+func exitWithUsage(flags *flag.FlagSet) {
+	flags.Usage()
+	os.Exit(exitError)
 }
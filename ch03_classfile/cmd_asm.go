@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"jvmgo/ch03_classfile/asm"
+	"jvmgo/ch03_classfile/classfile"
+	"os"
+	"path/filepath"
+)
+
+// jvmgo asm [-d dir] file.j...
+// 汇编 Jasmin 格式的源文件，生成的 class 文件按照类名写入 dir 下对应的目录，例如 dir/java/lang/Foo.class
+// 某个文件有错误时报告错误并继续汇编其余的文件，最后以 exitError 退出
+func runAsmCommand(cmd *Cmd) {
+	flags := flag.NewFlagSet("asm", flag.ExitOnError)
+	dir := flags.String("d", ".", "destination directory for class files")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s asm [-d dir] file.j...\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(cmd.args)
+	if flags.NArg() == 0 {
+		exitWithUsage(flags)
+	}
+
+	failed := false
+	for _, file := range flags.Args() {
+		if err := assembleFile(file, *dir); err != nil {
+			printError(err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(exitError)
+	}
+}
+
+func assembleFile(file, dir string) error {
+	source, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	cf, err := asm.Assemble(file, string(source))
+	if err != nil {
+		return err
+	}
+	classData, err := classfile.Serialize(cf)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, filepath.FromSlash(cf.ClassName())+".class")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, classData, 0644)
+}
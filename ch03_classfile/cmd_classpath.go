@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"jvmgo/ch03_classfile/classpath"
+	"os"
+	"sort"
+	"strings"
+)
+
+// jvmgo [-Xjre jre] [-cp classpath] classpath [-options]
+// 列出 classpath 中的类和包，或者找出出现在多个 jar 中的类（通常是同一个库的多个版本）
+func runClasspathCommand(cmd *Cmd) {
+	flags := flag.NewFlagSet("classpath", flag.ExitOnError)
+	flags.StringVar(&cmd.cpOption, "classpath", cmd.cpOption, "classpath")
+	flags.StringVar(&cmd.cpOption, "cp", cmd.cpOption, "classpath")
+	flags.StringVar(&cmd.XjreOption, "Xjre", cmd.XjreOption, "path to jre")
+	listFlag := flags.Bool("list", false, "list all classes and the entry each one is loaded from")
+	packagesFlag := flags.Bool("packages", false, "list all packages")
+	duplicatesFlag := flags.Bool("duplicates", false, "list classes found in more than one entry")
+	jreFlag := flags.Bool("jre", false, "include the boot and extension classpath")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s [-options] classpath [-list | -packages | -duplicates] [-jre]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(cmd.args)
+	if !*listFlag && !*packagesFlag && !*duplicatesFlag {
+		exitWithUsage(flags)
+	}
+
+	cp, err := classpath.Parse(cmd.XjreOption, cmd.cpOption)
+	if err != nil {
+		exitWithError(err)
+	}
+	defer cp.Close()
+
+	if *packagesFlag {
+		pkgs, err := cp.ListPackages(*jreFlag)
+		if err != nil {
+			exitWithError(err)
+		}
+		for _, pkg := range pkgs {
+			if pkg == "" {
+				pkg = "(default package)"
+			}
+			fmt.Println(strings.Replace(pkg, "/", ".", -1))
+		}
+		return
+	}
+
+	classes, err := cp.ListClasses(*jreFlag)
+	if err != nil {
+		exitWithError(err)
+	}
+	if *listFlag {
+		for _, class := range classes {
+			fmt.Printf("%s %v\n", strings.Replace(class.Name(), "/", ".", -1), class.Entry())
+			for _, entry := range class.Shadowed() {
+				fmt.Printf("    shadows %v\n", entry)
+			}
+		}
+		return
+	}
+	printDuplicates(classes)
+}
+
+// 按照包含重复类的 Entry 组合分组输出，同一组通常是同一个库的不同版本
+func printDuplicates(classes []*classpath.ClassLocation) {
+	groups := map[string][]string{}
+	var keys []string
+	for _, class := range classes {
+		if !class.IsDuplicate() {
+			continue
+		}
+		key := fmt.Sprintf("%v", class.Entry())
+		for _, entry := range class.Shadowed() {
+			key += "\n" + fmt.Sprintf("%v", entry)
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], strings.Replace(class.Name(), "/", ".", -1))
+	}
+	if len(keys) == 0 {
+		fmt.Println("no duplicate classes found")
+		return
+	}
+
+	sort.Strings(keys)
+	for _, key := range keys {
+		entries := strings.Split(key, "\n")
+		fmt.Printf("%d classes in %s shadow:\n", len(groups[key]), entries[0])
+		for _, entry := range entries[1:] {
+			fmt.Printf("    %s\n", entry)
+		}
+		for _, name := range groups[key] {
+			fmt.Printf("  %s\n", name)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"jvmgo/ch03_classfile/classdeps"
+	"os"
+	"strings"
+)
+
+// jvmgo deps [-level class|package|archive] [-format text|dot|json] [-external] [-cycles] path...
+// 分析 class 文件、jar 文件或目录中的类之间的依赖，类似 jdeps；同名的类以靠前的 path 中的为准
+// 使用 -cycles 时只输出循环依赖，发现循环依赖时以 exitFailure 退出，方便在检查分层规则的脚本中使用
+func runDepsCommand(cmd *Cmd) {
+	flags := flag.NewFlagSet("deps", flag.ExitOnError)
+	level := flags.String("level", "package", "aggregate dependencies by class, package or archive")
+	format := flags.String("format", "text", "output format: text, dot or json")
+	externalFlag := flags.Bool("external", false, "include dependencies on classes outside the given paths")
+	cyclesFlag := flags.Bool("cycles", false, "only report dependency cycles")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s deps [-level class|package|archive] [-format text|dot|json] [-external] [-cycles] path...\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(cmd.args)
+	if flags.NArg() == 0 {
+		exitWithUsage(flags)
+	}
+	switch classdeps.Level(*level) {
+	case classdeps.ClassLevel, classdeps.PackageLevel, classdeps.ArchiveLevel:
+	default:
+		exitWithError(fmt.Errorf("unknown level %q", *level))
+	}
+	switch *format {
+	case "text", "dot", "json":
+	default:
+		exitWithError(fmt.Errorf("unknown format %q", *format))
+	}
+
+	analyzer := classdeps.NewAnalyzer()
+	for _, path := range flags.Args() {
+		classes, err := loadClasses(path)
+		if err != nil {
+			exitWithError(err)
+		}
+		for _, cf := range classes {
+			if err := analyzer.AddClass(path, cf); err != nil {
+				exitWithError(err)
+			}
+		}
+	}
+	// 警告输出到标准错误，不影响 dot 和 json 格式的输出
+	for _, warning := range analyzer.Warnings() {
+		fmt.Fprintf(os.Stderr, "warning: [deps] %v\n", warning)
+	}
+	graph := analyzer.Graph(classdeps.Level(*level), *externalFlag)
+
+	if *cyclesFlag {
+		if printCycles(graph) > 0 {
+			os.Exit(exitFailure)
+		}
+		return
+	}
+	var err error
+	switch *format {
+	case "text":
+		printDependencies(graph)
+	case "dot":
+		err = graph.WriteDOT(os.Stdout)
+	case "json":
+		err = graph.WriteJSON(os.Stdout, classdeps.Level(*level))
+	}
+	if err != nil {
+		exitWithError(err)
+	}
+}
+
+// 与 jdeps 的输出类似，每个节点后面列出它依赖的节点，最后列出循环依赖；没有依赖的节点（包括 -external 时的外部类）不输出
+func printDependencies(graph *classdeps.Graph) {
+	printed := false
+	for _, node := range graph.Nodes() {
+		if len(graph.Dependencies(node)) == 0 {
+			continue
+		}
+		printed = true
+		fmt.Println(node)
+		for _, dep := range graph.Dependencies(node) {
+			fmt.Printf("   -> %s\n", dep)
+		}
+	}
+	if !printed {
+		fmt.Println("no dependencies")
+	}
+	for _, cycle := range graph.Cycles() {
+		fmt.Printf("cycle: %s\n", strings.Join(cycle, ", "))
+	}
+}
+
+func printCycles(graph *classdeps.Graph) int {
+	cycles := graph.Cycles()
+	for _, cycle := range cycles {
+		fmt.Printf("cycle: %s\n", strings.Join(cycle, ", "))
+	}
+	if len(cycles) == 0 {
+		fmt.Println("no dependency cycles")
+	}
+	return len(cycles)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"archive/zip"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"jvmgo/ch03_classfile/classdiff"
+	"jvmgo/ch03_classfile/classfile"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// jvmgo diff [-breaking] old new
+// 比较两个版本的类的 API，old 和 new 可以是 class 文件、jar 文件或者存放 class 文件的目录
+// 发现不兼容的变化时以 exitFailure 退出，参数错误或者无法读取、解析类时以 exitError 退出，
+// 方便在发布检查的脚本中区分这两种情况
+func runDiffCommand(cmd *Cmd) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	breakingFlag := flags.Bool("breaking", false, "only report changes that break binary compatibility")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s diff [-breaking] old.class|old.jar|olddir new.class|new.jar|newdir\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(cmd.args)
+	if flags.NArg() != 2 {
+		exitWithUsage(flags)
+	}
+
+	changes, err := diffClasses(flags.Arg(0), flags.Arg(1))
+	if err != nil {
+		exitWithError(err)
+	}
+	if printChanges(changes, *breakingFlag) > 0 {
+		os.Exit(exitFailure)
+	}
+}
+
+// 两个参数都是 class 文件时直接比较这两个类，不要求类名相同，例如比较重命名前后的类
+func diffClasses(oldPath, newPath string) ([]*classdiff.Change, error) {
+	oldClasses, err := loadClasses(oldPath)
+	if err != nil {
+		return nil, err
+	}
+	newClasses, err := loadClasses(newPath)
+	if err != nil {
+		return nil, err
+	}
+	if isClassFile(oldPath) && isClassFile(newPath) {
+		return classdiff.CompareClasses(oldClasses[0], newClasses[0])
+	}
+	return classdiff.Compare(oldClasses, newClasses)
+}
+
+func isClassFile(path string) bool {
+	return strings.HasSuffix(strings.ToLower(path), ".class")
+}
+
+// 按变化所在的类分组输出，最后输出统计，返回不兼容的变化的数量
+func printChanges(changes []*classdiff.Change, breakingOnly bool) int {
+	className, total, breaking := "", 0, 0
+	for _, change := range changes {
+		if breakingOnly && !change.IsBreaking() {
+			continue
+		}
+		if change.ClassName() != className {
+			className = change.ClassName()
+			fmt.Println(className)
+		}
+		total++
+		line := "  " + string(change.Kind())
+		if change.Member() != "" {
+			line += " " + change.Member()
+		}
+		if change.Detail() != "" {
+			line += ": " + change.Detail()
+		}
+		if change.IsBreaking() {
+			breaking++
+			line += " [breaking]"
+		}
+		fmt.Println(line)
+	}
+	if total == 0 {
+		fmt.Println("no API changes")
+	} else {
+		fmt.Printf("%d changes, %d breaking\n", total, breaking)
+	}
+	return breaking
+}
+
+// 读取并解析 path 中的所有类，jar 中 META-INF/versions 下的多版本类不参与比较
+func loadClasses(path string) ([]*classfile.ClassFile, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return loadClassesFromDir(path)
+	}
+	if isClassFile(path) {
+		cf, err := parseClass(path, ioutil.ReadFile)
+		if err != nil {
+			return nil, err
+		}
+		return []*classfile.ClassFile{cf}, nil
+	}
+	return loadClassesFromJar(path)
+}
+
+func loadClassesFromDir(dir string) ([]*classfile.ClassFile, error) {
+	var classes []*classfile.ClassFile
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !isClassFile(path) {
+			return nil
+		}
+		cf, err := parseClass(path, ioutil.ReadFile)
+		if err != nil {
+			return err
+		}
+		classes = append(classes, cf)
+		return nil
+	})
+	return classes, err
+}
+
+func loadClassesFromJar(jar string) ([]*classfile.ClassFile, error) {
+	r, err := zip.OpenReader(jar)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var classes []*classfile.ClassFile
+	for _, file := range r.File {
+		if !isClassFile(file.Name) || strings.HasPrefix(file.Name, "META-INF/") {
+			continue
+		}
+		cf, err := parseClass(jar+"!/"+file.Name, func(string) ([]byte, error) {
+			rc, err := file.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer rc.Close()
+			return ioutil.ReadAll(rc)
+		})
+		if err != nil {
+			return nil, err
+		}
+		classes = append(classes, cf)
+	}
+	return classes, nil
+}
+
+// 解析出错时在错误信息中带上文件的位置
+func parseClass(location string, read func(string) ([]byte, error)) (*classfile.ClassFile, error) {
+	data, err := read(location)
+	if err != nil {
+		return nil, err
+	}
+	cf, err := classfile.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", location, err)
+	}
+	return cf, nil
+}
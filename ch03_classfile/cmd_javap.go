@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"jvmgo/ch03_classfile/classfile"
+	"jvmgo/ch03_classfile/classjson"
+	"jvmgo/ch03_classfile/classpath"
+	"jvmgo/ch03_classfile/javap"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// jvmgo [-Xjre jre] [-cp classpath] javap [-v] [-c] [-l] [-s] [-p] [-format=text|json] class...
+// 按照 JDK javap 的格式输出 class 文件的内容，class 可以是 class 文件路径，也可以是 classpath 中的类名
+// -format=json 时忽略其它选项，每个类输出一个完整的 JSON 文档，格式参考 classjson 包
+// 某个类无法读取或解析时报告错误并继续处理其余的类，最后以 exitError 退出
+func runJavapCommand(cmd *Cmd) {
+	flags := flag.NewFlagSet("javap", flag.ExitOnError)
+	flags.StringVar(&cmd.cpOption, "classpath", cmd.cpOption, "classpath")
+	flags.StringVar(&cmd.cpOption, "cp", cmd.cpOption, "classpath")
+	flags.StringVar(&cmd.XjreOption, "Xjre", cmd.XjreOption, "path to jre")
+	var options javap.Options
+	flags.BoolVar(&options.Verbose, "v", false, "print additional information")
+	flags.BoolVar(&options.Code, "c", false, "disassemble the code")
+	flags.BoolVar(&options.Lines, "l", false, "print line number and local variable tables")
+	flags.BoolVar(&options.Descriptors, "s", false, "print internal type signatures")
+	flags.BoolVar(&options.Private, "p", false, "show all classes and members")
+	format := flags.String("format", "text", "output format: text or json")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s [-options] javap [-v] [-c] [-l] [-s] [-p] [-format=text|json] class...\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(cmd.args)
+	if flags.NArg() == 0 || (*format != "text" && *format != "json") {
+		exitWithUsage(flags)
+	}
+
+	var cp *classpath.Classpath // 只有按类名查找时才需要 classpath
+	failed := false
+	for _, arg := range flags.Args() {
+		var source *javap.Source
+		var err error
+		if strings.HasSuffix(arg, ".class") {
+			source, err = readClassFile(arg)
+		} else {
+			if cp == nil {
+				if cp, err = classpath.Parse(cmd.XjreOption, cmd.cpOption); err != nil {
+					exitWithError(err)
+				}
+				defer cp.Close()
+			}
+			source, err = readClassFromClasspath(cp, arg)
+		}
+		if err != nil {
+			printError(err)
+			failed = true
+			continue
+		}
+		cf, err := classfile.Parse(source.Data)
+		if err == nil && *format == "json" {
+			err = classjson.Write(os.Stdout, cf)
+		} else if err == nil {
+			err = javap.Write(os.Stdout, cf, source, options)
+		}
+		if err != nil {
+			printError(fmt.Errorf("%s: %v", arg, err))
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(exitError)
+	}
+}
+
+func readClassFile(path string) (*javap.Source, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(absPath)
+	if err != nil {
+		return nil, err
+	}
+	source := &javap.Source{Location: absPath, Data: data}
+	if info, err := os.Stat(absPath); err == nil {
+		source.ModTime = info.ModTime()
+	}
+	return source, nil
+}
+
+// 与 javap 一样，目录中的类输出文件路径，jar 中的类输出 jar:file: 形式的 URL
+func readClassFromClasspath(cp *classpath.Classpath, className string) (*javap.Source, error) {
+	name := strings.Replace(className, ".", "/", -1)
+	data, entry, err := cp.ReadClass(name)
+	if err != nil {
+		return nil, err
+	}
+	source := &javap.Source{Data: data}
+	switch entry := entry.(type) {
+	case *classpath.DirEntry:
+		source.Location = filepath.Join(entry.String(), filepath.FromSlash(name)+".class")
+		if info, err := os.Stat(source.Location); err == nil {
+			source.ModTime = info.ModTime()
+		}
+	case *classpath.ZipEntry:
+		source.Location = "jar:file:" + filepath.ToSlash(entry.String()) + "!/" + name + ".class"
+		if info, err := os.Stat(entry.String()); err == nil {
+			source.ModTime = info.ModTime()
+		}
+	default:
+		source.Location = fmt.Sprintf("%v!/%s.class", entry, name)
+	}
+	return source, nil
+}
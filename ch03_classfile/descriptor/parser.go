@@ -0,0 +1,299 @@
+package descriptor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 描述符和泛型签名的语法见 JVM 规范 4.3 和 4.7.9.1 节，下面的解析函数都要求整个字符串恰好是一个完整的描述符或签名
+// 描述符中的类名按 4.2.1 节检查：以 "/" 分隔的每一部分都不能为空，也不能包含 "."、";"、"[" 和 "/"
+// 签名中的标识符还不能包含 "<"、">" 和 ":"
+
+type parser struct {
+	kind string // "field descriptor"、"method signature" 等，用于错误信息
+	s    string
+	pos  int
+}
+
+// 解析字段描述符，例如 "[Ljava/lang/String;"
+func ParseFieldDescriptor(descriptor string) (t Type, err error) {
+	self := &parser{kind: "field descriptor", s: descriptor}
+	defer self.handleError(&err, func() { t = nil })
+	t = self.fieldType()
+	self.expectEnd()
+	return t, nil
+}
+
+// 解析方法描述符，例如 "(I[Ljava/lang/String;J)V"
+func ParseMethodDescriptor(descriptor string) (m *MethodType, err error) {
+	self := &parser{kind: "method descriptor", s: descriptor}
+	defer self.handleError(&err, func() { m = nil })
+	m = &MethodType{params: []Type{}}
+	self.expect('(')
+	for self.peek() != ')' {
+		m.params = append(m.params, self.fieldType())
+	}
+	self.expect(')')
+	m.ret = self.returnType(self.fieldType)
+	self.expectEnd()
+	return m, nil
+}
+
+// 解析类的 Signature 属性，例如 "<T:Ljava/lang/Object;>Ljava/util/AbstractList<TT;>;Ljava/util/List<TT;>;"
+func ParseClassSignature(signature string) (c *ClassSignature, err error) {
+	self := &parser{kind: "class signature", s: signature}
+	defer self.handleError(&err, func() { c = nil })
+	c = &ClassSignature{typeParams: self.typeParameters(), interfaces: []*ClassType{}}
+	c.superclass = self.classTypeSignature()
+	for self.pos < len(self.s) {
+		c.interfaces = append(c.interfaces, self.classTypeSignature())
+	}
+	return c, nil
+}
+
+// 解析方法的 Signature 属性，例如 "<T:Ljava/lang/Object;>(Ljava/util/List<TT;>;)TT;^TE;"
+func ParseMethodSignature(signature string) (m *MethodType, err error) {
+	self := &parser{kind: "method signature", s: signature}
+	defer self.handleError(&err, func() { m = nil })
+	m = &MethodType{typeParams: self.typeParameters(), params: []Type{}, throws: []Type{}}
+	self.expect('(')
+	for self.peek() != ')' {
+		m.params = append(m.params, self.javaTypeSignature())
+	}
+	self.expect(')')
+	m.ret = self.returnType(self.javaTypeSignature)
+	for self.peek() == '^' {
+		self.pos++
+		if self.peek() == 'T' {
+			m.throws = append(m.throws, self.typeVariable())
+		} else {
+			m.throws = append(m.throws, self.classTypeSignature())
+		}
+	}
+	self.expectEnd()
+	return m, nil
+}
+
+// 解析字段的 Signature 属性，字段签名只能是引用类型，例如 "Ljava/util/List<Ljava/lang/String;>;"
+func ParseFieldSignature(signature string) (t Type, err error) {
+	self := &parser{kind: "field signature", s: signature}
+	defer self.handleError(&err, func() { t = nil })
+	t = self.referenceTypeSignature()
+	self.expectEnd()
+	return t, nil
+}
+
+// 解析过程中的错误以 panic 的形式抛出，在这里转换为 error，并由 reset 把已经解析了一部分的结果置为 nil
+func (self *parser) handleError(err *error, reset func()) {
+	if r := recover(); r != nil {
+		msg, ok := r.(parseError)
+		if !ok {
+			panic(r)
+		}
+		reset()
+		*err = fmt.Errorf("invalid %s %q: %s at offset %d", self.kind, self.s, string(msg), self.pos)
+	}
+}
+
+type parseError string
+
+func (self *parser) fail(format string, args ...interface{}) {
+	panic(parseError(fmt.Sprintf(format, args...)))
+}
+
+// 返回当前字符，已到末尾时返回 0
+func (self *parser) peek() byte {
+	if self.pos < len(self.s) {
+		return self.s[self.pos]
+	}
+	return 0
+}
+
+func (self *parser) expect(c byte) {
+	if self.peek() != c {
+		self.unexpected(fmt.Sprintf("%q", c))
+	}
+	self.pos++
+}
+
+func (self *parser) expectEnd() {
+	if self.pos != len(self.s) {
+		self.fail("unexpected %q after the end", self.s[self.pos:])
+	}
+}
+
+func (self *parser) unexpected(expected string) {
+	if self.pos >= len(self.s) {
+		self.fail("expected %s, got end of string", expected)
+	}
+	self.fail("expected %s, got %q", expected, self.s[self.pos])
+}
+
+// FieldType: BaseType | ObjectType | ArrayType
+func (self *parser) fieldType() Type {
+	switch c := self.peek(); c {
+	case 'B', 'C', 'D', 'F', 'I', 'J', 'S', 'Z':
+		self.pos++
+		return &BaseType{code: c}
+	case 'L':
+		self.pos++
+		end := strings.IndexByte(self.s[self.pos:], ';')
+		if end < 0 {
+			self.fail("missing ';' after class name")
+		}
+		name := self.s[self.pos : self.pos+end]
+		self.checkClassName(name)
+		self.pos += end + 1
+		return &ClassType{name: name, simpleName: name}
+	case '[':
+		dims := self.dimensions()
+		return &ArrayType{dims: dims, elem: self.fieldType()}
+	}
+	self.unexpected("a field type")
+	return nil
+}
+
+// 数组最多有 255 维（JVM 规范 4.3.2 节）
+func (self *parser) dimensions() int {
+	dims := 0
+	for self.peek() == '[' {
+		dims++
+		self.pos++
+	}
+	if dims > 255 {
+		self.fail("array type has %d dimensions, more than 255", dims)
+	}
+	return dims
+}
+
+func (self *parser) checkClassName(name string) {
+	for _, part := range strings.Split(name, "/") {
+		if part == "" || strings.ContainsAny(part, ".;[") {
+			self.fail("invalid class name %q", name)
+		}
+	}
+}
+
+// 返回值可以是 void，其余与参数类型相同
+func (self *parser) returnType(parseType func() Type) Type {
+	if self.peek() == 'V' {
+		self.pos++
+		return &BaseType{code: 'V'}
+	}
+	return parseType()
+}
+
+// JavaTypeSignature: ReferenceTypeSignature | BaseType
+func (self *parser) javaTypeSignature() Type {
+	switch c := self.peek(); c {
+	case 'B', 'C', 'D', 'F', 'I', 'J', 'S', 'Z':
+		self.pos++
+		return &BaseType{code: c}
+	}
+	return self.referenceTypeSignature()
+}
+
+// ReferenceTypeSignature: ClassTypeSignature | TypeVariableSignature | ArrayTypeSignature
+func (self *parser) referenceTypeSignature() Type {
+	switch self.peek() {
+	case 'L':
+		return self.classTypeSignature()
+	case 'T':
+		return self.typeVariable()
+	case '[':
+		dims := self.dimensions()
+		return &ArrayType{dims: dims, elem: self.javaTypeSignature()}
+	}
+	self.unexpected("a reference type")
+	return nil
+}
+
+// ClassTypeSignature: L [PackageSpecifier] SimpleClassTypeSignature {. SimpleClassTypeSignature} ;
+func (self *parser) classTypeSignature() *ClassType {
+	self.expect('L')
+	name := self.identifier()
+	for self.peek() == '/' {
+		self.pos++
+		name += "/" + self.identifier()
+	}
+	t := &ClassType{name: name, simpleName: name, typeArgs: self.typeArguments()}
+	for self.peek() == '.' {
+		self.pos++
+		simpleName := self.identifier()
+		t = &ClassType{outer: t, name: t.name + "$" + simpleName, simpleName: simpleName, typeArgs: self.typeArguments()}
+	}
+	self.expect(';')
+	return t
+}
+
+// TypeVariableSignature: T Identifier ;
+func (self *parser) typeVariable() *TypeVariable {
+	self.expect('T')
+	name := self.identifier()
+	self.expect(';')
+	return &TypeVariable{name: name}
+}
+
+// TypeArguments: < TypeArgument {TypeArgument} >，没有类型实参时返回 nil
+func (self *parser) typeArguments() []*TypeArgument {
+	if self.peek() != '<' {
+		return nil
+	}
+	self.pos++
+	var args []*TypeArgument
+	for {
+		switch c := self.peek(); c {
+		case '*':
+			self.pos++
+			args = append(args, &TypeArgument{wildcard: '*'})
+		case '+', '-':
+			self.pos++
+			args = append(args, &TypeArgument{wildcard: c, typ: self.referenceTypeSignature()})
+		default:
+			args = append(args, &TypeArgument{typ: self.referenceTypeSignature()})
+		}
+		if self.peek() == '>' {
+			self.pos++
+			return args
+		}
+	}
+}
+
+// TypeParameters: < TypeParameter {TypeParameter} >，没有类型形参时返回空切片
+// TypeParameter: Identifier : [ReferenceTypeSignature] {: ReferenceTypeSignature}
+func (self *parser) typeParameters() []*TypeParameter {
+	params := []*TypeParameter{}
+	if self.peek() != '<' {
+		return params
+	}
+	self.pos++
+	for {
+		param := &TypeParameter{name: self.identifier(), interfaceBounds: []Type{}}
+		self.expect(':')
+		switch self.peek() {
+		case 'L', 'T', '[':
+			param.classBound = self.referenceTypeSignature()
+		}
+		for self.peek() == ':' {
+			self.pos++
+			param.interfaceBounds = append(param.interfaceBounds, self.referenceTypeSignature())
+		}
+		params = append(params, param)
+		if self.peek() == '>' {
+			self.pos++
+			return params
+		}
+	}
+}
+
+// 签名中的标识符不能为空，也不能包含 . ; [ / < > :
+func (self *parser) identifier() string {
+	start := self.pos
+	for self.pos < len(self.s) && !strings.ContainsRune(".;[/<>:", rune(self.s[self.pos])) {
+		self.pos++
+	}
+	if self.pos == start {
+		self.unexpected("an identifier")
+	}
+	return self.s[start:self.pos]
+}
@@ -0,0 +1,94 @@
+package descriptor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseWellFormed(t *testing.T) {
+	fields := []string{"I", "[[J", "Ljava/lang/String;", "[Ljava/util/Map$Entry;"}
+	for _, s := range fields {
+		if typ, err := ParseFieldDescriptor(s); err != nil || typ.Signature() != s {
+			t.Errorf("ParseFieldDescriptor(%q) = %v, %v", s, typ, err)
+		}
+	}
+	methods := []string{"()V", "(I[Ljava/lang/String;J)V", "(DF)[Ljava/lang/Object;"}
+	for _, s := range methods {
+		if m, err := ParseMethodDescriptor(s); err != nil || m.Signature() != s {
+			t.Errorf("ParseMethodDescriptor(%q) = %v, %v", s, m, err)
+		}
+	}
+	classes := []string{"Ljava/lang/Object;", "<T:Ljava/lang/Object;>Ljava/util/AbstractList<TT;>;Ljava/util/List<TT;>;"}
+	for _, s := range classes {
+		if c, err := ParseClassSignature(s); err != nil || c.Signature() != s {
+			t.Errorf("ParseClassSignature(%q) = %v, %v", s, c, err)
+		}
+	}
+	methodSignatures := []string{"<T:Ljava/lang/Object;>(Ljava/util/List<TT;>;)TT;^TE;", "(Ljava/util/Map<*+TK;>.Entry<-TV;>;)V"}
+	for _, s := range methodSignatures {
+		if m, err := ParseMethodSignature(s); err != nil || m.Signature() != s {
+			t.Errorf("ParseMethodSignature(%q) = %v, %v", s, m, err)
+		}
+	}
+	if typ, err := ParseFieldSignature("Ljava/util/List<Ljava/lang/String;>;"); err != nil || typ.Signature() != "Ljava/util/List<Ljava/lang/String;>;" {
+		t.Errorf("ParseFieldSignature() = %v, %v", typ, err)
+	}
+}
+
+// 格式错误时返回 nil 和 error，不返回解析了一部分的结果
+func TestParseMalformed(t *testing.T) {
+	fields := []string{"", "V", "Q", "II", "L;", "Ljava/lang/String", "Ljava//String;", "Ljava.lang.String;",
+		"L[I;", "[", "[V", strings.Repeat("[", 256) + "I"}
+	for _, s := range fields {
+		if typ, err := ParseFieldDescriptor(s); err == nil || typ != nil {
+			t.Errorf("ParseFieldDescriptor(%q) = %v, %v, want an error", s, typ, err)
+		}
+	}
+	methods := []string{"", "V", "()", "(V)V", "(I", "(I)", "(I)VV", "I)V", "(Ljava/lang/String)V", "(I)Q"}
+	for _, s := range methods {
+		if m, err := ParseMethodDescriptor(s); err == nil || m != nil {
+			t.Errorf("ParseMethodDescriptor(%q) = %v, %v, want an error", s, m, err)
+		}
+	}
+	classes := []string{"", "<>Ljava/lang/Object;", "<T>Ljava/lang/Object;", "<T:Ljava/lang/Object;",
+		"Ljava/lang/Object", "Ljava/util/List<>;", "Ljava/util/List<I>;", "Ljava/lang/Object;I"}
+	for _, s := range classes {
+		if c, err := ParseClassSignature(s); err == nil || c != nil {
+			t.Errorf("ParseClassSignature(%q) = %v, %v, want an error", s, c, err)
+		}
+	}
+	methodSignatures := []string{"", "()", "(TT)V", "(Ljava/util/List<TT;>)V", "()V^", "()V^I", "<:Ljava/lang/Object;>()V", "()TT;x"}
+	for _, s := range methodSignatures {
+		if m, err := ParseMethodSignature(s); err == nil || m != nil {
+			t.Errorf("ParseMethodSignature(%q) = %v, %v, want an error", s, m, err)
+		}
+	}
+	fieldSignatures := []string{"", "I", "TT", "Ljava/util/List<TT;>", "Ljava/util/List<TT;>;;", "L.;"}
+	for _, s := range fieldSignatures {
+		if typ, err := ParseFieldSignature(s); err == nil || typ != nil {
+			t.Errorf("ParseFieldSignature(%q) = %v, %v, want an error", s, typ, err)
+		}
+	}
+}
+
+// 合法描述符的每个真前缀都不是合法的描述符
+func TestParseTruncated(t *testing.T) {
+	field := "[[Ljava/util/Map$Entry;"
+	for i := 0; i < len(field); i++ {
+		if typ, err := ParseFieldDescriptor(field[:i]); err == nil || typ != nil {
+			t.Errorf("ParseFieldDescriptor(%q) = %v, %v, want an error", field[:i], typ, err)
+		}
+	}
+	method := "(I[JLjava/lang/String;)[Ljava/lang/Object;"
+	for i := 0; i < len(method); i++ {
+		if m, err := ParseMethodDescriptor(method[:i]); err == nil || m != nil {
+			t.Errorf("ParseMethodDescriptor(%q) = %v, %v, want an error", method[:i], m, err)
+		}
+	}
+	signature := "<T:Ljava/lang/Object;>(Ljava/util/List<+TT;>;)TT;"
+	for i := 0; i < len(signature); i++ {
+		if m, err := ParseMethodSignature(signature[:i]); err == nil || m != nil {
+			t.Errorf("ParseMethodSignature(%q) = %v, %v, want an error", signature[:i], m, err)
+		}
+	}
+}
@@ -0,0 +1,385 @@
+package descriptor
+
+import (
+	"strings"
+)
+
+// Type 是描述符或泛型签名中的一个类型
+// 描述符只会产生 BaseType、ClassType（没有类型参数）和 ArrayType，泛型签名还会产生 TypeVariable
+type Type interface {
+	Signature() string // 描述符或签名形式，例如 "Ljava/util/List<TT;>;"
+	String() string    // Java 源码形式，例如 "java.util.List<T>"
+	Slots() int        // 作为参数或局部变量时占用的 slot 数，long 和 double 为 2，void 为 0
+}
+
+// 基本类型，包括只能作为返回值出现的 void
+type BaseType struct {
+	code byte // B、C、D、F、I、J、S、Z 或 V
+}
+
+// 类或接口类型，泛型签名中的内部类用 outer 指向外部类，例如 Ljava/util/Map<TK;TV;>.Entry<TK;TV;>;
+type ClassType struct {
+	outer      *ClassType
+	name       string // 内部形式的完整类名，内部类为 "外部类$简单名"，例如 java/util/Map$Entry
+	simpleName string // 内部类的简单名，顶层类与 name 相同
+	typeArgs   []*TypeArgument
+}
+
+// 数组类型，elem 是去掉所有维度之后的元素类型，不会是 ArrayType
+type ArrayType struct {
+	dims int
+	elem Type
+}
+
+// 类型变量，例如 TT;
+type TypeVariable struct {
+	name string
+}
+
+// 类型实参，wildcard 为 0 表示普通的类型，'*' 表示 ?，'+' 表示 ? extends，'-' 表示 ? super
+type TypeArgument struct {
+	wildcard byte
+	typ      Type // wildcard 为 '*' 时为 nil
+}
+
+// 类型形参，classBound 为 nil 表示只有接口上界，例如 <T::Ljava/lang/Comparable<TT;>;>
+type TypeParameter struct {
+	name            string
+	classBound      Type
+	interfaceBounds []Type
+}
+
+// 类的泛型签名
+type ClassSignature struct {
+	typeParams []*TypeParameter
+	superclass *ClassType
+	interfaces []*ClassType
+}
+
+// 方法描述符或方法的泛型签名，描述符没有类型形参和 throws
+type MethodType struct {
+	typeParams []*TypeParameter
+	params     []Type
+	ret        Type
+	throws     []Type
+}
+
+var baseTypeNames = map[byte]string{
+	'B': "byte", 'C': "char", 'D': "double", 'F': "float",
+	'I': "int", 'J': "long", 'S': "short", 'Z': "boolean", 'V': "void",
+}
+
+// 把 class 文件内部的 "/" 分隔名称转换为 Java 源码中的 "." 分隔名称
+func JavaName(internalName string) string {
+	return strings.Replace(internalName, "/", ".", -1)
+}
+
+// getter 方法
+func (self *BaseType) Code() byte {
+	return self.code
+}
+func (self *BaseType) IsVoid() bool {
+	return self.code == 'V'
+}
+
+func (self *BaseType) Signature() string {
+	return string(self.code)
+}
+func (self *BaseType) String() string {
+	return baseTypeNames[self.code]
+}
+func (self *BaseType) Slots() int {
+	switch self.code {
+	case 'J', 'D':
+		return 2
+	case 'V':
+		return 0
+	}
+	return 1
+}
+
+// getter 方法
+func (self *ClassType) Name() string {
+	return self.name
+}
+func (self *ClassType) SimpleName() string {
+	return self.simpleName
+}
+func (self *ClassType) Outer() *ClassType {
+	return self.outer
+}
+func (self *ClassType) TypeArguments() []*TypeArgument {
+	return self.typeArgs
+}
+
+func (self *ClassType) Signature() string {
+	return "L" + self.signatureBody() + ";"
+}
+
+// 不含开头的 L 和结尾的 ;
+func (self *ClassType) signatureBody() string {
+	var s string
+	if self.outer != nil {
+		s = self.outer.signatureBody() + "." + self.simpleName
+	} else {
+		s = self.name
+	}
+	if len(self.typeArgs) > 0 {
+		args := make([]string, len(self.typeArgs))
+		for i, arg := range self.typeArgs {
+			args[i] = arg.Signature()
+		}
+		s += "<" + strings.Join(args, "") + ">"
+	}
+	return s
+}
+
+func (self *ClassType) String() string {
+	var s string
+	if self.outer != nil {
+		s = self.outer.String() + "." + self.simpleName
+	} else {
+		s = JavaName(self.name)
+	}
+	if len(self.typeArgs) > 0 {
+		args := make([]string, len(self.typeArgs))
+		for i, arg := range self.typeArgs {
+			args[i] = arg.String()
+		}
+		s += "<" + strings.Join(args, ", ") + ">"
+	}
+	return s
+}
+func (self *ClassType) Slots() int {
+	return 1
+}
+
+// 是否为没有类型实参的 java/lang/Object
+func (self *ClassType) IsObject() bool {
+	return self.name == "java/lang/Object" && self.outer == nil && len(self.typeArgs) == 0
+}
+
+// getter 方法
+func (self *ArrayType) Dimensions() int {
+	return self.dims
+}
+func (self *ArrayType) ElementType() Type {
+	return self.elem
+}
+
+func (self *ArrayType) Signature() string {
+	return strings.Repeat("[", self.dims) + self.elem.Signature()
+}
+func (self *ArrayType) String() string {
+	return self.elem.String() + strings.Repeat("[]", self.dims)
+}
+func (self *ArrayType) Slots() int {
+	return 1
+}
+
+func (self *TypeVariable) Name() string {
+	return self.name
+}
+func (self *TypeVariable) Signature() string {
+	return "T" + self.name + ";"
+}
+func (self *TypeVariable) String() string {
+	return self.name
+}
+func (self *TypeVariable) Slots() int {
+	return 1
+}
+
+// getter 方法
+func (self *TypeArgument) Wildcard() byte {
+	return self.wildcard
+}
+func (self *TypeArgument) Type() Type {
+	return self.typ
+}
+
+func (self *TypeArgument) Signature() string {
+	switch self.wildcard {
+	case '*':
+		return "*"
+	case '+', '-':
+		return string(self.wildcard) + self.typ.Signature()
+	}
+	return self.typ.Signature()
+}
+func (self *TypeArgument) String() string {
+	switch self.wildcard {
+	case '*':
+		return "?"
+	case '+':
+		return "? extends " + self.typ.String()
+	case '-':
+		return "? super " + self.typ.String()
+	}
+	return self.typ.String()
+}
+
+// getter 方法
+func (self *TypeParameter) Name() string {
+	return self.name
+}
+func (self *TypeParameter) ClassBound() Type {
+	return self.classBound
+}
+func (self *TypeParameter) InterfaceBounds() []Type {
+	return self.interfaceBounds
+}
+
+func (self *TypeParameter) Signature() string {
+	s := self.name + ":"
+	if self.classBound != nil {
+		s += self.classBound.Signature()
+	}
+	for _, bound := range self.interfaceBounds {
+		s += ":" + bound.Signature()
+	}
+	return s
+}
+
+// 与 Java 源码相同，只有 java.lang.Object 一个上界时省略 extends
+func (self *TypeParameter) String() string {
+	var bounds []string
+	if classBound, ok := self.classBound.(*ClassType); !ok || !classBound.IsObject() || len(self.interfaceBounds) > 0 {
+		if self.classBound != nil {
+			bounds = append(bounds, self.classBound.String())
+		}
+	}
+	for _, bound := range self.interfaceBounds {
+		bounds = append(bounds, bound.String())
+	}
+	if len(bounds) == 0 {
+		return self.name
+	}
+	return self.name + " extends " + strings.Join(bounds, " & ")
+}
+
+// getter 方法
+func (self *ClassSignature) TypeParameters() []*TypeParameter {
+	return self.typeParams
+}
+func (self *ClassSignature) Superclass() *ClassType {
+	return self.superclass
+}
+func (self *ClassSignature) Interfaces() []*ClassType {
+	return self.interfaces
+}
+
+func (self *ClassSignature) Signature() string {
+	s := typeParamsSignature(self.typeParams) + self.superclass.Signature()
+	for _, iface := range self.interfaces {
+		s += iface.Signature()
+	}
+	return s
+}
+
+// 类声明中类名之后的部分，例如 "<T> extends java.util.AbstractList<T> implements java.util.List<T>"
+// 超类为 java.lang.Object 时省略 extends
+func (self *ClassSignature) String() string {
+	var parts []string
+	if len(self.typeParams) > 0 {
+		parts = append(parts, typeParamsString(self.typeParams))
+	}
+	if !self.superclass.IsObject() {
+		parts = append(parts, "extends "+self.superclass.String())
+	}
+	if len(self.interfaces) > 0 {
+		names := make([]string, len(self.interfaces))
+		for i, iface := range self.interfaces {
+			names[i] = iface.String()
+		}
+		parts = append(parts, "implements "+strings.Join(names, ", "))
+	}
+	return strings.Join(parts, " ")
+}
+
+// getter 方法
+func (self *MethodType) TypeParameters() []*TypeParameter {
+	return self.typeParams
+}
+func (self *MethodType) ParameterTypes() []Type {
+	return self.params
+}
+func (self *MethodType) ReturnType() Type {
+	return self.ret
+}
+func (self *MethodType) ExceptionTypes() []Type {
+	return self.throws
+}
+
+// 参数占用的 slot 数，不包括实例方法的 this
+func (self *MethodType) ArgSlots() int {
+	slots := 0
+	for _, param := range self.params {
+		slots += param.Slots()
+	}
+	return slots
+}
+
+// 返回值占用的 slot 数，void 为 0
+func (self *MethodType) ReturnSlots() int {
+	return self.ret.Slots()
+}
+
+func (self *MethodType) Signature() string {
+	s := typeParamsSignature(self.typeParams) + "("
+	for _, param := range self.params {
+		s += param.Signature()
+	}
+	s += ")" + self.ret.Signature()
+	for _, t := range self.throws {
+		s += "^" + t.Signature()
+	}
+	return s
+}
+
+// 方法类型的 Java 源码形式，例如 "<T> T (java.util.List<T>) throws E"
+func (self *MethodType) String() string {
+	return self.Declaration("")
+}
+
+// 以 name 为方法名的 Java 声明，例如 Declaration("get") 返回 "<T> T get(java.util.List<T>) throws E"
+// name 为空时返回类型和参数列表之间以空格分隔
+func (self *MethodType) Declaration(name string) string {
+	s := ""
+	if len(self.typeParams) > 0 {
+		s = typeParamsString(self.typeParams) + " "
+	}
+	s += self.ret.String() + " "
+	params := make([]string, len(self.params))
+	for i, param := range self.params {
+		params[i] = param.String()
+	}
+	s += name + "(" + strings.Join(params, ", ") + ")"
+	if len(self.throws) > 0 {
+		names := make([]string, len(self.throws))
+		for i, t := range self.throws {
+			names[i] = t.String()
+		}
+		s += " throws " + strings.Join(names, ", ")
+	}
+	return s
+}
+
+func typeParamsSignature(typeParams []*TypeParameter) string {
+	if len(typeParams) == 0 {
+		return ""
+	}
+	s := "<"
+	for _, param := range typeParams {
+		s += param.Signature()
+	}
+	return s + ">"
+}
+
+func typeParamsString(typeParams []*TypeParameter) string {
+	params := make([]string, len(typeParams))
+	for i, param := range typeParams {
+		params[i] = param.String()
+	}
+	return "<" + strings.Join(params, ", ") + ">"
+}
@@ -0,0 +1,315 @@
+package disasm
+
+import (
+	"fmt"
+	"jvmgo/ch03_classfile/classfile"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Constants 按照 javap 的格式把常量池中的常量转换为可读形式
+// 常量池索引无效或者常量类型不符时不会 panic，而是像 javap 一样在结果中给出 "#索引"，这样反汇编有问题的 class 文件时仍然能输出其余部分
+// 引用其它常量时只接受规范规定的类型（例如 Fieldref 的 class_index 必须是 CONSTANT_Class），所以自引用或循环引用不会导致无限递归
+type Constants struct {
+	cf *classfile.ClassFile
+	cp classfile.ConstantPool
+}
+
+func NewConstants(cf *classfile.ClassFile) *Constants {
+	return &Constants{cf: cf, cp: cf.ConstantPool()}
+}
+
+// 返回索引处的常量，索引无效时返回 nil
+func (self *Constants) Constant(index uint16) classfile.ConstantInfo {
+	c, _ := self.cp.GetConstantInfo(index)
+	return c
+}
+
+func invalid(index uint16) string {
+	return fmt.Sprintf("#%d", index)
+}
+
+// 常量池列表中的类型名，例如 Methodref、NameAndType
+func ConstantTypeName(c classfile.ConstantInfo) string {
+	switch c.(type) {
+	case *classfile.ConstantClassInfo:
+		return "Class"
+	case *classfile.ConstantFieldrefInfo:
+		return "Fieldref"
+	case *classfile.ConstantMethodrefInfo:
+		return "Methodref"
+	case *classfile.ConstantInterfaceMethodrefInfo:
+		return "InterfaceMethodref"
+	case *classfile.ConstantStringInfo:
+		return "String"
+	case *classfile.ConstantIntegerInfo:
+		return "Integer"
+	case *classfile.ConstantFloatInfo:
+		return "Float"
+	case *classfile.ConstantLongInfo:
+		return "Long"
+	case *classfile.ConstantDoubleInfo:
+		return "Double"
+	case *classfile.ConstantNameAndTypeInfo:
+		return "NameAndType"
+	case *classfile.ConstantUtf8Info:
+		return "Utf8"
+	case *classfile.ConstantMethodHandleInfo:
+		return "MethodHandle"
+	case *classfile.ConstantMethodTypeInfo:
+		return "MethodType"
+	case *classfile.ConstantDynamicInfo:
+		return "Dynamic"
+	case *classfile.ConstantInvokeDynamicInfo:
+		return "InvokeDynamic"
+	case *classfile.ConstantModuleInfo:
+		return "Module"
+	case *classfile.ConstantPackageInfo:
+		return "Package"
+	}
+	return "(unknown)"
+}
+
+// 字节码注释和 ConstantValue 等属性中引用常量时使用的类型名
+func constantKindName(c classfile.ConstantInfo) string {
+	switch c.(type) {
+	case *classfile.ConstantClassInfo:
+		return "class"
+	case *classfile.ConstantFieldrefInfo:
+		return "Field"
+	case *classfile.ConstantMethodrefInfo:
+		return "Method"
+	case *classfile.ConstantInterfaceMethodrefInfo:
+		return "InterfaceMethod"
+	case *classfile.ConstantIntegerInfo:
+		return "int"
+	case *classfile.ConstantFloatInfo:
+		return "float"
+	case *classfile.ConstantLongInfo:
+		return "long"
+	case *classfile.ConstantDoubleInfo:
+		return "double"
+	}
+	return ConstantTypeName(c)
+}
+
+// 返回 "类型 值" 形式的常量引用，例如 "Method java/io/PrintStream.println:(Ljava/lang/String;)V"
+// 引用当前类的字段和方法时省略类名，索引为 0 时返回 "#0"
+func (self *Constants) Describe(index uint16) string {
+	if index == 0 {
+		return "#0"
+	}
+	c := self.Constant(index)
+	if c == nil {
+		return invalid(index)
+	}
+	value := self.StringValue(index)
+	switch ref := c.(type) {
+	case *classfile.ConstantFieldrefInfo:
+		value = self.memberrefValue(&ref.ConstantMemberrefInfo)
+	case *classfile.ConstantMethodrefInfo:
+		value = self.memberrefValue(&ref.ConstantMemberrefInfo)
+	case *classfile.ConstantInterfaceMethodrefInfo:
+		value = self.memberrefValue(&ref.ConstantMemberrefInfo)
+	}
+	return constantKindName(c) + " " + value
+}
+
+func (self *Constants) memberrefValue(ref *classfile.ConstantMemberrefInfo) string {
+	if ref.ClassIndex() == self.cf.ThisClass() {
+		return self.nameAndTypeValue(ref.NameAndTypeIndex())
+	}
+	return self.classValue(ref.ClassIndex()) + "." + self.nameAndTypeValue(ref.NameAndTypeIndex())
+}
+
+// 返回常量的可读形式，例如 CONSTANT_NameAndType 返回 "<init>":()V，CONSTANT_Long 返回 10l
+func (self *Constants) StringValue(index uint16) string {
+	switch c := self.Constant(index).(type) {
+	case *classfile.ConstantClassInfo:
+		return checkName(self.Utf8(c.NameIndex()))
+	case *classfile.ConstantFieldrefInfo:
+		return self.classValue(c.ClassIndex()) + "." + self.nameAndTypeValue(c.NameAndTypeIndex())
+	case *classfile.ConstantMethodrefInfo:
+		return self.classValue(c.ClassIndex()) + "." + self.nameAndTypeValue(c.NameAndTypeIndex())
+	case *classfile.ConstantInterfaceMethodrefInfo:
+		return self.classValue(c.ClassIndex()) + "." + self.nameAndTypeValue(c.NameAndTypeIndex())
+	case *classfile.ConstantStringInfo:
+		return escapeString(self.Utf8(c.StringIndex()))
+	case *classfile.ConstantIntegerInfo:
+		return strconv.Itoa(int(c.Value()))
+	case *classfile.ConstantFloatInfo:
+		return javaFloat(float64(c.Value()), 32) + "f"
+	case *classfile.ConstantLongInfo:
+		return strconv.FormatInt(c.Value(), 10) + "l"
+	case *classfile.ConstantDoubleInfo:
+		return javaFloat(c.Value(), 64) + "d"
+	case *classfile.ConstantNameAndTypeInfo:
+		return checkName(self.Utf8(c.NameIndex())) + ":" + self.Utf8(c.DescriptorIndex())
+	case *classfile.ConstantUtf8Info:
+		return escapeString(c.Value())
+	case *classfile.ConstantMethodHandleInfo:
+		return referenceKindName(c.ReferenceKind()) + " " + self.memberrefStringValue(c.ReferenceIndex())
+	case *classfile.ConstantMethodTypeInfo:
+		return self.Utf8(c.DescriptorIndex())
+	case *classfile.ConstantDynamicInfo:
+		return fmt.Sprintf("#%d:%s", c.BootstrapMethodAttrIndex(), self.nameAndTypeValue(c.NameAndTypeIndex()))
+	case *classfile.ConstantInvokeDynamicInfo:
+		return fmt.Sprintf("#%d:%s", c.BootstrapMethodAttrIndex(), self.nameAndTypeValue(c.NameAndTypeIndex()))
+	case *classfile.ConstantModuleInfo:
+		return checkName(self.Utf8(c.NameIndex()))
+	case *classfile.ConstantPackageInfo:
+		return checkName(self.Utf8(c.NameIndex()))
+	}
+	return invalid(index)
+}
+
+// 下面几个方法只接受指定类型的常量，其它类型和无效索引都返回 "#索引"
+func (self *Constants) classValue(index uint16) string {
+	if _, ok := self.Constant(index).(*classfile.ConstantClassInfo); ok {
+		return self.StringValue(index)
+	}
+	return invalid(index)
+}
+
+func (self *Constants) nameAndTypeValue(index uint16) string {
+	if _, ok := self.Constant(index).(*classfile.ConstantNameAndTypeInfo); ok {
+		return self.StringValue(index)
+	}
+	return invalid(index)
+}
+
+// CONSTANT_MethodHandle 的 reference_index 指向字段或方法引用
+func (self *Constants) memberrefStringValue(index uint16) string {
+	switch self.Constant(index).(type) {
+	case *classfile.ConstantFieldrefInfo, *classfile.ConstantMethodrefInfo, *classfile.ConstantInterfaceMethodrefInfo:
+		return self.StringValue(index)
+	}
+	return invalid(index)
+}
+
+// 返回 CONSTANT_Utf8 常量的字符串，不做任何转义
+func (self *Constants) Utf8(index uint16) string {
+	if c, ok := self.Constant(index).(*classfile.ConstantUtf8Info); ok {
+		return c.Value()
+	}
+	return invalid(index)
+}
+
+// 返回 CONSTANT_Class 常量的类名，使用 "/" 分隔的内部形式
+func (self *Constants) ClassName(index uint16) string {
+	if c, ok := self.Constant(index).(*classfile.ConstantClassInfo); ok {
+		return self.Utf8(c.NameIndex())
+	}
+	return invalid(index)
+}
+
+func referenceKindName(kind uint8) string {
+	names := map[uint8]string{
+		classfile.REF_getField:         "REF_getField",
+		classfile.REF_getStatic:        "REF_getStatic",
+		classfile.REF_putField:         "REF_putField",
+		classfile.REF_putStatic:        "REF_putStatic",
+		classfile.REF_invokeVirtual:    "REF_invokeVirtual",
+		classfile.REF_invokeStatic:     "REF_invokeStatic",
+		classfile.REF_invokeSpecial:    "REF_invokeSpecial",
+		classfile.REF_newInvokeSpecial: "REF_newInvokeSpecial",
+		classfile.REF_invokeInterface:  "REF_invokeInterface",
+	}
+	if name, ok := names[kind]; ok {
+		return name
+	}
+	return fmt.Sprintf("(unknown reference kind %d)", kind)
+}
+
+// 不是合法标识符（"/" 分隔）的名称加上引号，例如 "<init>" 和数组类名 "[I"
+func checkName(name string) string {
+	if name == "" {
+		return `""`
+	}
+	prev := '/'
+	for _, r := range name {
+		start := unicode.IsLetter(r) || r == '_' || r == '$'
+		part := start || unicode.IsDigit(r)
+		if (prev == '/' && !start) || (r != '/' && !part) {
+			return `"` + escapeName(name) + `"`
+		}
+		prev = r
+	}
+	return name
+}
+
+func escapeName(name string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`).Replace(name)
+}
+
+// 字符串常量中的控制字符和引号使用转义序列输出
+func escapeString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\'':
+			b.WriteString(`\'`)
+		case '\\':
+			b.WriteString(`\\`)
+		default:
+			if unicode.IsControl(r) {
+				fmt.Fprintf(&b, `\u%04x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	return b.String()
+}
+
+// 按照 Java 的 Float.toString() 和 Double.toString() 格式化浮点数：
+// 绝对值在 10^-3 到 10^7 之间时使用小数形式（至少一位小数），否则使用 1.0E10 形式的科学计数法
+func javaFloat(f float64, bitSize int) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	case f == 0:
+		if math.Signbit(f) {
+			return "-0.0"
+		}
+		return "0.0"
+	}
+	if abs := math.Abs(f); abs >= 1e-3 && abs < 1e7 {
+		s := strconv.FormatFloat(f, 'f', -1, bitSize)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		return s
+	}
+	s := strconv.FormatFloat(f, 'e', -1, bitSize)
+	i := strings.IndexByte(s, 'e')
+	mantissa, exponent := s[:i], s[i+1:]
+	if !strings.Contains(mantissa, ".") {
+		mantissa += ".0"
+	}
+	exponent = strings.TrimPrefix(exponent, "+")
+	negative := strings.HasPrefix(exponent, "-")
+	exponent = strings.TrimLeft(strings.TrimPrefix(exponent, "-"), "0")
+	if negative {
+		exponent = "-" + exponent
+	}
+	return mantissa + "E" + exponent
+}
@@ -0,0 +1,56 @@
+package disasm
+
+import (
+	"encoding/binary"
+	"jvmgo/ch03_classfile/classfile"
+	"testing"
+)
+
+// 生成只有常量池的 class 文件，this_class 为 #1
+func parseConstantPool(t *testing.T, constants ...[]byte) *classfile.ClassFile {
+	data := []byte{0xCA, 0xFE, 0xBA, 0xBE, 0, 0, 0, 52}
+	data = binary.BigEndian.AppendUint16(data, uint16(len(constants)+1))
+	for _, c := range constants {
+		data = append(data, c...)
+	}
+	data = append(data, 0, 0x21, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+	cf, err := classfile.Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cf
+}
+
+func ref(tag uint8, a, b uint16) []byte {
+	return []byte{tag, byte(a >> 8), byte(a), byte(b >> 8), byte(b)}
+}
+
+// 自引用、循环引用和类型不符的引用输出 "#索引"，不会无限递归
+func TestStringValueOfInvalidReferences(t *testing.T) {
+	cf := parseConstantPool(t,
+		ref(classfile.CONSTANT_Fieldref, 1, 1),           // #1 引用自身
+		ref(classfile.CONSTANT_Methodref, 3, 3),          // #2
+		ref(classfile.CONSTANT_InterfaceMethodref, 2, 2), // #3 与 #2 互相引用
+		[]byte{classfile.CONSTANT_MethodHandle, 5, 0, 4}, // #4 引用自身
+		ref(classfile.CONSTANT_InvokeDynamic, 0, 5),      // #5 引用自身
+		ref(classfile.CONSTANT_Dynamic, 0, 99),           // #6 索引越界
+	)
+	constants := NewConstants(cf)
+	tests := map[uint16]string{
+		1:  "#1.#1",
+		2:  "#3.#3",
+		3:  "#2.#2",
+		4:  "REF_invokeVirtual #4",
+		5:  "#0:#5",
+		6:  "#0:#99",
+		99: "#99",
+	}
+	for index, want := range tests {
+		if got := constants.StringValue(index); got != want {
+			t.Errorf("StringValue(%d) = %q, want %q", index, got, want)
+		}
+	}
+	if got, want := constants.Describe(2), "Method #3.#3"; got != want {
+		t.Errorf("Describe(2) = %q, want %q", got, want)
+	}
+}
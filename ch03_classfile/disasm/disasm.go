@@ -0,0 +1,258 @@
+package disasm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"jvmgo/ch03_classfile/classfile"
+	"strings"
+)
+
+// Code 是一个方法的字节码反汇编后得到的指令列表，javap 命令用它输出 Code 属性，
+// 解释器的跟踪模式则可以先反汇编整个方法，再用 Code.At(pc) 取出将要执行的指令
+//
+// 与 javap 相同，引用常量池的指令在注释中给出常量的内容，wide 指令的助记符加上 _w 后缀，
+// 另外每条指令还记录了它对应的源码行号以及从这里开始、结束的异常处理范围，便于跟踪时显示
+type Code struct {
+	code         *classfile.CodeAttribute
+	constants    *Constants
+	instructions []*Instruction
+	byPc         map[int]*Instruction
+	tryEnds      []*classfile.ExceptionTableEntry // 结束于字节码末尾的异常处理范围
+}
+
+// Instruction 是反汇编后的一条指令
+type Instruction struct {
+	pc            int
+	opcode        uint8
+	mnemonic      string
+	operands      string                           // 操作数，例如 "#7"、"1, 1"、"44"，switch 指令为空
+	comment       string                           // 常量池操作数的内容，例如 "Method java/lang/Object."<init>":()V"
+	cases         []*SwitchCase                    // switch 的各个分支，按 case 值的顺序排列
+	defaultTarget int                              // switch 的默认目标
+	line          int                              // 从这条指令开始的源码行号，没有时为 0
+	tryStarts     []*classfile.ExceptionTableEntry // 从这条指令开始的异常处理范围
+	tryEnds       []*classfile.ExceptionTableEntry // 在这条指令之前结束的异常处理范围
+	handlers      []*classfile.ExceptionTableEntry // 以这条指令为入口的异常处理
+}
+
+type SwitchCase struct {
+	key    int32
+	target int
+}
+
+// newarray 指令的 atype 操作数对应的基本类型
+var arrayTypes = map[uint8]string{
+	4: "boolean", 5: "char", 6: "float", 7: "double",
+	8: "byte", 9: "short", 10: "int", 11: "long",
+}
+
+// 反汇编方法的字节码，抽象方法和本地方法没有 Code 属性，返回错误
+// 常量池中有问题的常量不会导致失败，而是在注释中标出，参考 Constants
+func Disassemble(cf *classfile.ClassFile, method *classfile.MemberInfo) (*Code, error) {
+	list, err := classfile.NewInstructionList(cf, method)
+	if err != nil {
+		return nil, err
+	}
+	constants := NewConstants(cf)
+	code := &Code{code: method.CodeAttribute(), constants: constants, byPc: map[int]*Instruction{}}
+	for insn := list.First(); insn != nil; insn = insn.Next() {
+		decoded := decode(insn, constants)
+		code.instructions = append(code.instructions, decoded)
+		code.byPc[decoded.pc] = decoded
+	}
+	code.annotate()
+	return code, nil
+}
+
+func decode(insn *classfile.Instruction, constants *Constants) *Instruction {
+	decoded := &Instruction{pc: insn.Pc(), opcode: insn.Opcode(), mnemonic: insn.Name()}
+	if insn.IsWide() {
+		decoded.mnemonic += "_w"
+	}
+
+	operands := insn.Operands()
+	switch op := insn.Opcode(); {
+	case op == classfile.OP_tableswitch || op == classfile.OP_lookupswitch:
+		keys := insn.SwitchKeys()
+		for i, target := range insn.SwitchTargets() {
+			decoded.cases = append(decoded.cases, &SwitchCase{key: keys[i], target: target.Pc()})
+		}
+		decoded.defaultTarget = insn.DefaultTarget().Pc()
+	case insn.Target() != nil:
+		decoded.operands = fmt.Sprintf("%d", insn.Target().Pc())
+	case insn.CpIndex() != 0:
+		decoded.operands = fmt.Sprintf("#%d", insn.CpIndex())
+		switch op {
+		case classfile.OP_invokeinterface, classfile.OP_invokedynamic, classfile.OP_multianewarray:
+			decoded.operands += fmt.Sprintf(",  %d", operands[2])
+		}
+		decoded.comment = constants.Describe(insn.CpIndex())
+	case op == classfile.OP_iinc:
+		if insn.IsWide() {
+			decoded.operands = fmt.Sprintf("%d, %d", insn.LocalIndex(), int16(binary.BigEndian.Uint16(operands[2:])))
+		} else {
+			decoded.operands = fmt.Sprintf("%d, %d", insn.LocalIndex(), int8(operands[1]))
+		}
+	case insn.LocalIndex() >= 0 && len(operands) > 0:
+		decoded.operands = fmt.Sprintf("%d", insn.LocalIndex())
+	case op == classfile.OP_bipush:
+		decoded.operands = fmt.Sprintf("%d", int8(operands[0]))
+	case op == classfile.OP_sipush:
+		decoded.operands = fmt.Sprintf("%d", int16(binary.BigEndian.Uint16(operands)))
+	case op == classfile.OP_newarray:
+		if name, ok := arrayTypes[operands[0]]; ok {
+			decoded.operands = name
+		} else {
+			decoded.operands = fmt.Sprintf("%d", operands[0])
+		}
+	}
+	return decoded
+}
+
+// 把行号表和异常处理表中的信息记录到对应的指令上
+func (self *Code) annotate() {
+	if lines := self.code.LineNumberTableAttribute(); lines != nil {
+		for _, entry := range lines.LineNumberTable() {
+			if insn := self.byPc[int(entry.StartPc())]; insn != nil && insn.line == 0 {
+				insn.line = int(entry.LineNumber())
+			}
+		}
+	}
+	for _, entry := range self.code.ExceptionTable() {
+		if insn := self.byPc[int(entry.StartPc())]; insn != nil {
+			insn.tryStarts = append(insn.tryStarts, entry)
+		}
+		if insn := self.byPc[int(entry.EndPc())]; insn != nil {
+			insn.tryEnds = append(insn.tryEnds, entry)
+		} else {
+			self.tryEnds = append(self.tryEnds, entry)
+		}
+		if insn := self.byPc[int(entry.HandlerPc())]; insn != nil {
+			insn.handlers = append(insn.handlers, entry)
+		}
+	}
+}
+
+// getter 方法
+func (self *Code) Instructions() []*Instruction {
+	return self.instructions
+}
+func (self *Code) CodeAttribute() *classfile.CodeAttribute {
+	return self.code
+}
+
+// 返回位于 pc 处的指令，pc 不是指令的起始位置时返回 nil
+func (self *Code) At(pc int) *Instruction {
+	return self.byPc[pc]
+}
+
+// 输出带有行号和异常处理范围注释的指令列表，每条指令一行，注释行以 "// " 开头并写在对应的指令之前，
+// 例如 "// line 6"、"// try start (50 to 58, handler 61)"、"// catch java/lang/Exception (50 to 58, handler 61)"
+func (self *Code) Write(w io.Writer) error {
+	var b strings.Builder
+	for _, insn := range self.instructions {
+		for _, entry := range insn.tryEnds {
+			fmt.Fprintf(&b, "// try end (%s)\n", describeRange(entry))
+		}
+		if insn.line != 0 {
+			fmt.Fprintf(&b, "// line %d\n", insn.line)
+		}
+		for _, entry := range insn.handlers {
+			fmt.Fprintf(&b, "// %s (%s)\n", describeCatch(entry, self.constants), describeRange(entry))
+		}
+		for _, entry := range insn.tryStarts {
+			fmt.Fprintf(&b, "// try start (%s)\n", describeRange(entry))
+		}
+		fmt.Fprintf(&b, "%5d: %s\n", insn.pc, insn.text())
+	}
+	for _, entry := range self.tryEnds {
+		fmt.Fprintf(&b, "// try end (%s)\n", describeRange(entry))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func describeRange(entry *classfile.ExceptionTableEntry) string {
+	return fmt.Sprintf("%d to %d, handler %d", entry.StartPc(), entry.EndPc(), entry.HandlerPc())
+}
+
+// catch_type 为 0 的异常处理用于实现 finally
+func describeCatch(entry *classfile.ExceptionTableEntry, constants *Constants) string {
+	if entry.CatchType() == 0 {
+		return "finally"
+	}
+	return "catch " + constants.ClassName(entry.CatchType())
+}
+
+// getter 方法
+func (self *Instruction) Pc() int {
+	return self.pc
+}
+func (self *Instruction) Opcode() uint8 {
+	return self.opcode
+}
+func (self *Instruction) Mnemonic() string {
+	return self.mnemonic
+}
+func (self *Instruction) Operands() string {
+	return self.operands
+}
+func (self *Instruction) Comment() string {
+	return self.comment
+}
+func (self *Instruction) Cases() []*SwitchCase {
+	return self.cases
+}
+func (self *Instruction) DefaultTarget() int {
+	return self.defaultTarget
+}
+func (self *Instruction) Line() int {
+	return self.line
+}
+func (self *Instruction) TryStarts() []*classfile.ExceptionTableEntry {
+	return self.tryStarts
+}
+func (self *Instruction) TryEnds() []*classfile.ExceptionTableEntry {
+	return self.tryEnds
+}
+func (self *Instruction) Handlers() []*classfile.ExceptionTableEntry {
+	return self.handlers
+}
+
+func (self *SwitchCase) Key() int32 {
+	return self.key
+}
+func (self *SwitchCase) Target() int {
+	return self.target
+}
+
+func (self *Instruction) IsSwitch() bool {
+	return self.opcode == classfile.OP_tableswitch || self.opcode == classfile.OP_lookupswitch
+}
+
+// 单行形式，例如 "1: invokespecial #1 // Method java/lang/Object."<init>":()V"，
+// switch 指令的跳转表也写在同一行：20: tableswitch { 0: 44, 1: 47, default: 50 }
+func (self *Instruction) String() string {
+	return fmt.Sprintf("%d: %s", self.pc, self.text())
+}
+
+// 不含 pc 的部分
+func (self *Instruction) text() string {
+	s := self.mnemonic
+	if self.IsSwitch() {
+		cases := make([]string, 0, len(self.cases)+1)
+		for _, c := range self.cases {
+			cases = append(cases, fmt.Sprintf("%d: %d", c.key, c.target))
+		}
+		cases = append(cases, fmt.Sprintf("default: %d", self.defaultTarget))
+		return s + " { " + strings.Join(cases, ", ") + " }"
+	}
+	if self.operands != "" {
+		s += " " + self.operands
+	}
+	if self.comment != "" {
+		s += " // " + self.comment
+	}
+	return s
+}
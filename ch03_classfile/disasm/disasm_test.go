@@ -0,0 +1,113 @@
+package disasm
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"jvmgo/ch03_classfile/classfile"
+)
+
+// ../javap/testdata/Demo.class 的 main 方法包含 tableswitch、lookupswitch、iinc_w、
+// 两个覆盖同一范围的异常处理以及行号表
+func disassembleDemoMain(t *testing.T) *Code {
+	data, err := ioutil.ReadFile("../javap/testdata/Demo.class")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err := classfile.Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, method := range cf.Methods() {
+		if method.Name() == "main" {
+			code, err := Disassemble(cf, method)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return code
+		}
+	}
+	t.Fatal("Demo.class has no main method")
+	return nil
+}
+
+func TestDisassemble(t *testing.T) {
+	code := disassembleDemoMain(t)
+
+	tests := []struct {
+		pc       int
+		mnemonic string
+		operands string
+		comment  string
+		line     int
+	}{
+		{0, "iconst_1", "", "", 3},
+		{24, "getstatic", "#38", "Field java/lang/System.out:Ljava/io/PrintStream;", 0},
+		{32, "goto", "64", "", 0},
+		{35, "iconst_2", "", "", 5},
+		{64, "ldc2_w", "#47", "long 123456789012l", 0},
+		{77, "iinc_w", "1, 1000", "", 0},
+		{83, "iinc", "1, 1", "", 0},
+		{89, "invokeinterface", "#55,  1", "InterfaceMethod java/lang/Runnable.run:()V", 0},
+	}
+	for _, test := range tests {
+		insn := code.At(test.pc)
+		if insn == nil {
+			t.Errorf("At(%d) = nil", test.pc)
+			continue
+		}
+		if insn.Mnemonic() != test.mnemonic || insn.Operands() != test.operands || insn.Comment() != test.comment || insn.Line() != test.line {
+			t.Errorf("At(%d) = %s %q // %q line %d, want %s %q // %q line %d", test.pc,
+				insn.Mnemonic(), insn.Operands(), insn.Comment(), insn.Line(),
+				test.mnemonic, test.operands, test.comment, test.line)
+		}
+	}
+	if insn := code.At(25); insn != nil {
+		t.Errorf("At(25) = %v, want nil inside getstatic", insn)
+	}
+
+	// switch 的跳转表
+	tableswitch := code.At(1)
+	if !tableswitch.IsSwitch() || tableswitch.DefaultTarget() != 64 || len(tableswitch.Cases()) != 2 ||
+		tableswitch.Cases()[0].Key() != 0 || tableswitch.Cases()[0].Target() != 24 ||
+		tableswitch.Cases()[1].Key() != 1 || tableswitch.Cases()[1].Target() != 35 {
+		t.Errorf("tableswitch = %v", tableswitch)
+	}
+	lookupswitch := code.At(36)
+	if !lookupswitch.IsSwitch() || lookupswitch.DefaultTarget() != 64 || len(lookupswitch.Cases()) != 2 ||
+		lookupswitch.Cases()[0].Key() != -1 || lookupswitch.Cases()[1].Key() != 10 {
+		t.Errorf("lookupswitch = %v", lookupswitch)
+	}
+
+	// 异常处理范围 64 到 94，入口为 95
+	if n := len(code.At(64).TryStarts()); n != 2 {
+		t.Errorf("%d try ranges start at 64, want 2", n)
+	}
+	if n := len(code.At(94).TryEnds()); n != 2 {
+		t.Errorf("%d try ranges end before 94, want 2", n)
+	}
+	if n := len(code.At(95).Handlers()); n != 2 {
+		t.Errorf("95 is the handler of %d ranges, want 2", n)
+	}
+}
+
+func TestCodeWrite(t *testing.T) {
+	var buf bytes.Buffer
+	if err := disassembleDemoMain(t).Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"// line 3\n    0: iconst_1\n",
+		"    1: tableswitch { 0: 24, 1: 35, default: 64 }\n",
+		"// try start (64 to 94, handler 95)\n// try start (64 to 94, handler 95)\n   64: ldc2_w #47 // long 123456789012l\n",
+		"// try end (64 to 94, handler 95)\n",
+		"// catch java/lang/Exception (64 to 94, handler 95)\n// finally (64 to 94, handler 95)\n   95: astore_2\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Write() output does not contain %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,387 @@
+package javap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"jvmgo/ch03_classfile/classfile"
+	"strings"
+)
+
+func (self *classPrinter) writeAttributes(attributes []classfile.AttributeInfo) {
+	for _, attr := range attributes {
+		self.section(func() { self.writeAttribute(attr) })
+	}
+}
+
+// 按照 javap 的格式输出属性，classfile 包没有解析的属性中，常见的几种在这里解码，其余的以十六进制输出
+func (self *classPrinter) writeAttribute(attrInfo classfile.AttributeInfo) {
+	switch attr := attrInfo.(type) {
+	case *classfile.CodeAttribute:
+		self.writeCode(attr)
+	case *classfile.ConstantValueAttribute:
+		self.print("ConstantValue: ")
+		self.writeConstant(attr.ConstantValueIndex())
+		self.println("")
+	case *classfile.DeprecatedAttribute:
+		self.println("Deprecated: true")
+	case *classfile.SyntheticAttribute:
+		self.println("Synthetic: true")
+	case *classfile.SourceFileAttribute:
+		self.println(fmt.Sprintf("SourceFile: \"%s\"", attr.FileName()))
+	case *classfile.ExceptionsAttribute:
+		self.println("Exceptions:")
+		self.indent++
+		names := make([]string, len(attr.ExceptionIndexTable()))
+		for i, index := range attr.ExceptionIndexTable() {
+			names[i] = self.className(index)
+		}
+		self.println("throws " + strings.Join(names, ", "))
+		self.indent--
+	case *classfile.LineNumberTableAttribute:
+		self.println("LineNumberTable:")
+		self.indent++
+		for _, entry := range attr.LineNumberTable() {
+			self.println(fmt.Sprintf("line %d: %d", entry.LineNumber(), entry.StartPc()))
+		}
+		self.indent--
+	case *classfile.LocalVariableTableAttribute:
+		self.println("LocalVariableTable:")
+		self.indent++
+		self.println("Start  Length  Slot  Name   Signature")
+		for _, entry := range attr.LocalVariableTable() {
+			self.println(fmt.Sprintf("%5d %7d %5d %5s   %s",
+				entry.StartPc(), entry.Length(), entry.Index(), entry.Name(), entry.Descriptor()))
+		}
+		self.indent--
+	case *classfile.LocalVariableTypeTableAttribute:
+		self.println("LocalVariableTypeTable:")
+		self.indent++
+		self.println("Start  Length  Slot  Name   Signature")
+		for _, entry := range attr.LocalVariableTypeTable() {
+			self.println(fmt.Sprintf("%5d %7d %5d %5s   %s",
+				entry.StartPc(), entry.Length(), entry.Index(), entry.Name(), entry.Signature()))
+		}
+		self.indent--
+	case *classfile.StackMapTableAttribute:
+		self.writeStackMapTable(attr)
+	case *classfile.MethodParametersAttribute:
+		self.println("MethodParameters:")
+		self.indent++
+		self.println(fmt.Sprintf("%-31s%s", "Name", "Flags"))
+		for _, param := range attr.Parameters() {
+			name := param.Name()
+			if name == "" {
+				name = "<no name>"
+			}
+			flags := flagNames(param.AccessFlags(), []flagName{
+				{classfile.ACC_FINAL, "final"}, {classfile.ACC_SYNTHETIC, "synthetic"}, {classfile.ACC_MANDATED, "mandated"}})
+			self.println(fmt.Sprintf("%-31s%s", name, strings.Join(flags, " ")))
+		}
+		self.indent--
+	case *classfile.BootstrapMethodsAttribute:
+		self.println("BootstrapMethods:")
+		self.indent++
+		for i, bm := range attr.BootstrapMethods() {
+			self.println(fmt.Sprintf("%d: #%d %s", i, bm.BootstrapMethodRef(), self.stringValue(bm.BootstrapMethodRef())))
+			self.indent++
+			self.println("Method arguments:")
+			self.indent++
+			for _, arg := range bm.BootstrapArguments() {
+				self.println(fmt.Sprintf("#%d %s", arg, self.stringValue(arg)))
+			}
+			self.indent -= 2
+		}
+		self.indent--
+	case *classfile.ModuleAttribute:
+		self.writeModule(attr)
+	case *classfile.ModulePackagesAttribute:
+		self.println("ModulePackages:")
+		self.indent++
+		for _, index := range attr.PackageIndex() {
+			self.writeIndex(index)
+		}
+		self.indent--
+	case *classfile.ModuleMainClassAttribute:
+		self.print(fmt.Sprintf("ModuleMainClass: #%d", attr.MainClassIndex()))
+		self.tab()
+		self.println("// " + self.stringValue(attr.MainClassIndex()))
+	case *classfile.UnparsedAttribute:
+		self.writeUnparsed(attr)
+	}
+}
+
+// 输出 "#索引 // 内容" 形式的一行
+func (self *classPrinter) writeIndex(index uint16) {
+	self.print(fmt.Sprintf("#%d", index))
+	self.tab()
+	self.println("// " + self.stringValue(index))
+}
+
+func (self *classPrinter) writeStackMapTable(attr *classfile.StackMapTableAttribute) {
+	self.println(fmt.Sprintf("StackMapTable: number_of_entries = %d", len(attr.Entries())))
+	self.indent++
+	for _, frame := range attr.Entries() {
+		frameType := frame.FrameType()
+		switch {
+		case frameType < 64:
+			self.println(fmt.Sprintf("frame_type = %d /* same */", frameType))
+		case frameType < 128:
+			self.println(fmt.Sprintf("frame_type = %d /* same_locals_1_stack_item */", frameType))
+			self.indent++
+			self.writeVerificationTypes("stack", frame.Stack())
+			self.indent--
+		case frameType == 247:
+			self.println(fmt.Sprintf("frame_type = %d /* same_locals_1_stack_item_frame_extended */", frameType))
+			self.indent++
+			self.println(fmt.Sprintf("offset_delta = %d", frame.OffsetDelta()))
+			self.writeVerificationTypes("stack", frame.Stack())
+			self.indent--
+		case frameType >= 248 && frameType <= 251:
+			kind := "chop"
+			if frameType == 251 {
+				kind = "same_frame_extended"
+			}
+			self.println(fmt.Sprintf("frame_type = %d /* %s */", frameType, kind))
+			self.indent++
+			self.println(fmt.Sprintf("offset_delta = %d", frame.OffsetDelta()))
+			self.indent--
+		case frameType >= 252 && frameType <= 254:
+			self.println(fmt.Sprintf("frame_type = %d /* append */", frameType))
+			self.indent++
+			self.println(fmt.Sprintf("offset_delta = %d", frame.OffsetDelta()))
+			self.writeVerificationTypes("locals", frame.Locals())
+			self.indent--
+		case frameType == 255:
+			self.println(fmt.Sprintf("frame_type = %d /* full_frame */", frameType))
+			self.indent++
+			self.println(fmt.Sprintf("offset_delta = %d", frame.OffsetDelta()))
+			self.writeVerificationTypes("locals", frame.Locals())
+			self.writeVerificationTypes("stack", frame.Stack())
+			self.indent--
+		default:
+			self.println(fmt.Sprintf("frame_type = %d /* unknown */", frameType))
+		}
+	}
+	self.indent--
+}
+
+func (self *classPrinter) writeVerificationTypes(name string, infos []*classfile.VerificationTypeInfo) {
+	self.print(name + " = [")
+	for i, info := range infos {
+		if i == 0 {
+			self.print(" ")
+		} else {
+			self.print(", ")
+		}
+		switch info.Tag() {
+		case classfile.ITEM_Top:
+			self.print("top")
+		case classfile.ITEM_Integer:
+			self.print("int")
+		case classfile.ITEM_Float:
+			self.print("float")
+		case classfile.ITEM_Double:
+			self.print("double")
+		case classfile.ITEM_Long:
+			self.print("long")
+		case classfile.ITEM_Null:
+			self.print("null")
+		case classfile.ITEM_UninitializedThis:
+			self.print("uninitialized_this")
+		case classfile.ITEM_Object:
+			self.writeConstant(info.CpoolIndex())
+		case classfile.ITEM_Uninitialized:
+			self.print(fmt.Sprintf("uninitialized %d", info.Offset()))
+		}
+	}
+	if len(infos) == 0 {
+		self.println("]")
+	} else {
+		self.println(" ]")
+	}
+}
+
+// Module 属性中的每一项都输出为 "#索引,标志 // 内容" 的形式
+func (self *classPrinter) writeModule(attr *classfile.ModuleAttribute) {
+	self.println("Module:")
+	self.indent++
+	self.writeModuleEntry(attr.ModuleNameIndex(), attr.ModuleFlags(), true, []flagName{
+		{classfile.ACC_OPEN, "ACC_OPEN"}, {classfile.ACC_SYNTHETIC, "ACC_SYNTHETIC"}, {classfile.ACC_MANDATED, "ACC_MANDATED"}})
+	self.writeOptionalIndex(attr.ModuleVersionIndex())
+
+	self.writeCount(len(attr.Requires()), "requires")
+	self.indent++
+	for _, e := range attr.Requires() {
+		self.writeModuleEntry(e.RequiresIndex(), e.RequiresFlags(), true, []flagName{
+			{classfile.ACC_TRANSITIVE, "ACC_TRANSITIVE"}, {classfile.ACC_STATIC_PHASE, "ACC_STATIC_PHASE"},
+			{classfile.ACC_SYNTHETIC, "ACC_SYNTHETIC"}, {classfile.ACC_MANDATED, "ACC_MANDATED"}})
+		self.writeOptionalIndex(e.RequiresVersionIndex())
+	}
+	self.indent--
+
+	self.writeModuleExports("exports", attr.Exports())
+	self.writeModuleExports("opens", attr.Opens())
+
+	self.writeCount(len(attr.UsesIndex()), "uses")
+	self.indent++
+	for _, index := range attr.UsesIndex() {
+		self.writeIndex(index)
+	}
+	self.indent--
+
+	self.writeCount(len(attr.Provides()), "provides")
+	self.indent++
+	for _, e := range attr.Provides() {
+		self.writeModuleEntry(e.ProvidesIndex(), uint16(len(e.ProvidesWithIndex())), false, nil)
+		self.indent++
+		for _, index := range e.ProvidesWithIndex() {
+			self.print(fmt.Sprintf("#%d", index))
+			self.tab()
+			self.println("// ... with " + self.stringValue(index))
+		}
+		self.indent--
+	}
+	self.indent--
+	self.indent--
+}
+
+// hex 为 true 时以十六进制输出 value（标志），否则以十进制输出（数量）
+func (self *classPrinter) writeModuleEntry(index, value uint16, hex bool, flags []flagName) {
+	if hex {
+		self.print(fmt.Sprintf("#%d,%x", index, value))
+	} else {
+		self.print(fmt.Sprintf("#%d,%d", index, value))
+	}
+	self.tab()
+	self.print("// " + self.stringValue(index))
+	for _, name := range flagNames(value, flags) {
+		self.print(" " + name)
+	}
+	self.println("")
+}
+
+func (self *classPrinter) writeOptionalIndex(index uint16) {
+	if index == 0 {
+		self.println("#0")
+	} else {
+		self.writeIndex(index)
+	}
+}
+
+func (self *classPrinter) writeCount(n int, comment string) {
+	self.print(fmt.Sprintf("%d", n))
+	self.tab()
+	self.println("// " + comment)
+}
+
+func (self *classPrinter) writeModuleExports(keyword string, entries []*classfile.ModuleExportsEntry) {
+	self.writeCount(len(entries), keyword)
+	self.indent++
+	for _, e := range entries {
+		self.writeModuleEntry(e.Index(), e.Flags(), true, []flagName{
+			{classfile.ACC_SYNTHETIC, "ACC_SYNTHETIC"}, {classfile.ACC_MANDATED, "ACC_MANDATED"}})
+		if len(e.ToIndex()) == 0 {
+			continue
+		}
+		self.indent++
+		self.writeCount(len(e.ToIndex()), keyword+" to")
+		for _, index := range e.ToIndex() {
+			self.print(fmt.Sprintf("#%d", index))
+			self.tab()
+			self.println("// ... to " + self.stringValue(index))
+		}
+		self.indent--
+	}
+	self.indent--
+}
+
+// classfile 包没有解析的属性，javap 常见的几种在这里按照 JVM 规范解码
+func (self *classPrinter) writeUnparsed(attr *classfile.UnparsedAttribute) {
+	info := attr.Info()
+	u2 := func(offset int) uint16 {
+		if offset+2 > len(info) {
+			panic(fmt.Errorf("truncated %s attribute", attr.Name()))
+		}
+		return binary.BigEndian.Uint16(info[offset:])
+	}
+
+	switch attr.Name() {
+	case "Signature":
+		self.print(fmt.Sprintf("Signature: #%d", u2(0)))
+		self.tab()
+		self.println("// " + self.stringValue(u2(0)))
+	case "NestHost":
+		self.print("NestHost: ")
+		self.writeConstant(u2(0))
+		self.println("")
+	case "NestMembers", "PermittedSubclasses":
+		self.println(attr.Name() + ":")
+		self.indent++
+		for i := 0; i < int(u2(0)); i++ {
+			self.println(self.stringValue(u2(2 + 2*i)))
+		}
+		self.indent--
+	case "EnclosingMethod":
+		classIndex, methodIndex := u2(0), u2(2)
+		self.print(fmt.Sprintf("EnclosingMethod: #%d.#%d", classIndex, methodIndex))
+		self.tab()
+		comment := "// " + self.className(classIndex)
+		if methodIndex != 0 {
+			comment += "." + self.stringValue(methodIndex)
+		}
+		self.println(comment)
+	case "InnerClasses":
+		self.println("InnerClasses:")
+		self.indent++
+		for i := 0; i < int(u2(0)); i++ {
+			offset := 2 + 8*i
+			self.writeInnerClass(u2(offset), u2(offset+2), u2(offset+4), u2(offset+6))
+		}
+		self.indent--
+	default:
+		self.println(fmt.Sprintf("%s: length = 0x%x (unknown attribute)", attr.Name(), len(info)))
+		self.indent++
+		for i := 0; i < len(info); i += 16 {
+			end := i + 16
+			if end > len(info) {
+				end = len(info)
+			}
+			hex := make([]string, end-i)
+			for j, b := range info[i:end] {
+				hex[j] = fmt.Sprintf("%02x", b)
+			}
+			self.println(strings.Join(hex, " "))
+		}
+		self.indent--
+	}
+}
+
+// 例如 public static #7= #2 of #4;   // Inner=class Outer$Inner of class Outer
+func (self *classPrinter) writeInnerClass(innerIndex, outerIndex, nameIndex, flags uint16) {
+	modifiers := []flagName{{classfile.ACC_PUBLIC, "public"}, {classfile.ACC_PRIVATE, "private"},
+		{classfile.ACC_PROTECTED, "protected"}, {classfile.ACC_STATIC, "static"}, {classfile.ACC_FINAL, "final"},
+		{classfile.ACC_ABSTRACT, "abstract"}}
+	if flags&classfile.ACC_INTERFACE != 0 {
+		modifiers = modifiers[:5] // 接口总是 abstract 的，不重复输出
+	}
+	self.writeModifiers(flags, modifiers)
+	if nameIndex != 0 {
+		self.print(fmt.Sprintf("#%d= ", nameIndex))
+	}
+	self.print(fmt.Sprintf("#%d", innerIndex))
+	if outerIndex != 0 {
+		self.print(fmt.Sprintf(" of #%d", outerIndex))
+	}
+	self.print(";")
+	self.tab()
+	self.print("// ")
+	if nameIndex != 0 {
+		self.print(self.utf8(nameIndex) + "=")
+	}
+	self.writeConstant(innerIndex)
+	if outerIndex != 0 {
+		self.print(" of ")
+		self.writeConstant(outerIndex)
+	}
+	self.println("")
+}
@@ -0,0 +1,92 @@
+package javap
+
+import (
+	"fmt"
+	"jvmgo/ch03_classfile/classfile"
+	"jvmgo/ch03_classfile/disasm"
+)
+
+// Code 属性：-v 时先输出 stack、locals 和 args_size，再输出字节码、异常处理表和 Code 属性自己的属性
+func (self *classPrinter) writeCode(code *classfile.CodeAttribute) {
+	self.println("Code:")
+	self.indent++
+	argsSize := len(javaMethodParams(self.method.Descriptor()))
+	if self.method.AccessFlags()&classfile.ACC_STATIC == 0 {
+		argsSize++
+	}
+	self.println(fmt.Sprintf("stack=%d, locals=%d, args_size=%d", code.MaxStack(), code.MaxLocals(), argsSize))
+	self.writeInstructions()
+	self.writeExceptionTable(code)
+	self.writeAttributes(code.Attributes())
+	self.indent--
+}
+
+func javaMethodParams(descriptor string) []string {
+	params, _ := javaMethodTypes(descriptor)
+	return params
+}
+
+// 每条指令输出为 "pc: 助记符 操作数"，引用常量池的指令在注释中给出常量的内容
+func (self *classPrinter) writeInstructions() {
+	code, err := disasm.Disassemble(self.cf, self.method)
+	if err != nil {
+		panic(err)
+	}
+	for _, insn := range code.Instructions() {
+		self.printf("%4d: %-13s ", insn.Pc(), insn.Mnemonic())
+		switch {
+		case insn.IsSwitch():
+			self.writeSwitch(insn)
+		case insn.Opcode() == classfile.OP_newarray:
+			self.print(" " + insn.Operands()) // 与 javap 一样，newarray 的类型前多一个空格
+		default:
+			self.print(insn.Operands())
+		}
+		if insn.Comment() != "" {
+			self.tab()
+			self.print("// " + insn.Comment())
+		}
+		self.println("")
+	}
+}
+
+// switch 指令的跳转表缩进输出，每个分支一行
+func (self *classPrinter) writeSwitch(insn *disasm.Instruction) {
+	cases := insn.Cases()
+	if insn.Opcode() == classfile.OP_tableswitch {
+		low, high := int32(0), int32(-1)
+		if len(cases) > 0 {
+			low, high = cases[0].Key(), cases[len(cases)-1].Key()
+		}
+		self.printf("{ // %d to %d", low, high)
+	} else {
+		self.printf("{ // %d", len(cases))
+	}
+	self.println("")
+	self.indent += 3
+	for _, c := range cases {
+		self.println(fmt.Sprintf("%12d: %d", c.Key(), c.Target()))
+	}
+	self.println(fmt.Sprintf("     default: %d", insn.DefaultTarget()))
+	self.print("}")
+	self.indent -= 3
+}
+
+func (self *classPrinter) writeExceptionTable(code *classfile.CodeAttribute) {
+	table := code.ExceptionTable()
+	if len(table) == 0 {
+		return
+	}
+	self.println("Exception table:")
+	self.indent++
+	self.println(" from    to  target type")
+	for _, entry := range table {
+		self.printf(" %5d %5d %5d   ", entry.StartPc(), entry.EndPc(), entry.HandlerPc())
+		if entry.CatchType() == 0 {
+			self.println("any")
+		} else {
+			self.println("Class " + self.stringValue(entry.CatchType()))
+		}
+	}
+	self.indent--
+}
@@ -0,0 +1,76 @@
+package javap
+
+import (
+	"fmt"
+	"jvmgo/ch03_classfile/classfile"
+	"jvmgo/ch03_classfile/disasm"
+	"strconv"
+)
+
+// 常量池中的每一项都以 "#索引 = 类型 内容 // 注释" 的格式输出，注释是常量的可读形式
+func (self *classPrinter) writeConstantPool() {
+	self.println("Constant pool:")
+	self.indent++
+	width := len(strconv.Itoa(len(self.cp))) + 1
+	for i := 1; i < len(self.cp); i++ {
+		c := self.cp[i]
+		if c == nil {
+			continue // long 和 double 之后的第二个位置
+		}
+		self.printf("%*s = %-18s ", width, "#"+strconv.Itoa(i), disasm.ConstantTypeName(c))
+		switch c := c.(type) {
+		case *classfile.ConstantClassInfo:
+			self.printRef(fmt.Sprintf("#%d", c.NameIndex()), uint16(i))
+		case *classfile.ConstantFieldrefInfo:
+			self.printRef(fmt.Sprintf("#%d.#%d", c.ClassIndex(), c.NameAndTypeIndex()), uint16(i))
+		case *classfile.ConstantMethodrefInfo:
+			self.printRef(fmt.Sprintf("#%d.#%d", c.ClassIndex(), c.NameAndTypeIndex()), uint16(i))
+		case *classfile.ConstantInterfaceMethodrefInfo:
+			self.printRef(fmt.Sprintf("#%d.#%d", c.ClassIndex(), c.NameAndTypeIndex()), uint16(i))
+		case *classfile.ConstantStringInfo:
+			self.printRef(fmt.Sprintf("#%d", c.StringIndex()), uint16(i))
+		case *classfile.ConstantNameAndTypeInfo:
+			self.printRef(fmt.Sprintf("#%d:#%d", c.NameIndex(), c.DescriptorIndex()), uint16(i))
+		case *classfile.ConstantMethodHandleInfo:
+			self.printRef(fmt.Sprintf("%d:#%d", c.ReferenceKind(), c.ReferenceIndex()), uint16(i))
+		case *classfile.ConstantMethodTypeInfo:
+			self.printRef(fmt.Sprintf("#%d", c.DescriptorIndex()), uint16(i))
+		case *classfile.ConstantDynamicInfo:
+			self.printRef(fmt.Sprintf("#%d:#%d", c.BootstrapMethodAttrIndex(), c.NameAndTypeIndex()), uint16(i))
+		case *classfile.ConstantInvokeDynamicInfo:
+			self.printRef(fmt.Sprintf("#%d:#%d", c.BootstrapMethodAttrIndex(), c.NameAndTypeIndex()), uint16(i))
+		case *classfile.ConstantModuleInfo:
+			self.printRef(fmt.Sprintf("#%d", c.NameIndex()), uint16(i))
+		case *classfile.ConstantPackageInfo:
+			self.printRef(fmt.Sprintf("#%d", c.NameIndex()), uint16(i))
+		default:
+			// 数字常量和 Utf8 直接输出值
+			self.println(self.stringValue(uint16(i)))
+		}
+	}
+	self.indent--
+}
+
+func (self *classPrinter) printRef(ref string, index uint16) {
+	self.print(ref)
+	self.tab()
+	self.println("// " + self.stringValue(index))
+}
+
+// 以下方法按照 javap 的格式输出常量，具体的转换由 disasm.Constants 完成
+func (self *classPrinter) writeConstant(index uint16) {
+	self.print(self.constants.Describe(index))
+}
+
+func (self *classPrinter) stringValue(index uint16) string {
+	return self.constants.StringValue(index)
+}
+
+func (self *classPrinter) utf8(index uint16) string {
+	return self.constants.Utf8(index)
+}
+
+// CONSTANT_Class 常量的类名，以 Java 源码形式返回
+func (self *classPrinter) className(index uint16) string {
+	return javaName(self.constants.ClassName(index))
+}
@@ -0,0 +1,34 @@
+package javap
+
+import (
+	"jvmgo/ch03_classfile/descriptor"
+)
+
+// 描述符的解析由 descriptor 包完成，这里只转换为 javap 输出用的字符串
+// 格式错误的描述符直接 panic，由 Write() 转换为错误
+
+// 把字段描述符转换为 Java 源码中的类型名，例如 [Ljava/lang/String; 转换为 java.lang.String[]
+func javaType(fieldDescriptor string) string {
+	t, err := descriptor.ParseFieldDescriptor(fieldDescriptor)
+	if err != nil {
+		panic(err)
+	}
+	return t.String()
+}
+
+// 把方法描述符转换为参数类型列表和返回值类型
+func javaMethodTypes(methodDescriptor string) ([]string, string) {
+	m, err := descriptor.ParseMethodDescriptor(methodDescriptor)
+	if err != nil {
+		panic(err)
+	}
+	params := make([]string, len(m.ParameterTypes()))
+	for i, param := range m.ParameterTypes() {
+		params[i] = param.String()
+	}
+	return params, m.ReturnType().String()
+}
+
+func javaName(internalName string) string {
+	return descriptor.JavaName(internalName)
+}
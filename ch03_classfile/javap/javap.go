@@ -0,0 +1,336 @@
+package javap
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"jvmgo/ch03_classfile/classfile"
+	"jvmgo/ch03_classfile/disasm"
+	"strings"
+	"time"
+)
+
+// Options 对应 JDK javap 工具的命令行选项，输出格式与 javap 相同，便于与真实的 javap 输出逐行对比
+// 不带任何选项时只输出类和非私有成员的声明，-v 包含了 -c、-l、-s 和 -p
+type Options struct {
+	Verbose     bool // -v：输出常量池和所有属性，同时输出私有成员
+	Code        bool // -c：输出字节码和异常处理表
+	Lines       bool // -l：输出行号表和局部变量表
+	Descriptors bool // -s：输出字段和方法的描述符
+	Private     bool // -p：输出私有成员
+}
+
+// Source 说明 class 文件来自哪里，用于 -v 模式下输出文件位置、修改时间、大小和校验和
+type Source struct {
+	Location string    // 文件路径或 jar:file:...!/... 形式的 URL
+	ModTime  time.Time // 修改时间，未知时为零值
+	Data     []byte    // class 文件数据
+}
+
+type flagName struct {
+	flag uint16
+	name string
+}
+
+// 声明中使用的修饰符，顺序与 javap 相同
+var (
+	classModifiers = []flagName{{classfile.ACC_PUBLIC, "public"}, {classfile.ACC_FINAL, "final"},
+		{classfile.ACC_ABSTRACT, "abstract"}}
+	fieldModifiers = []flagName{{classfile.ACC_PUBLIC, "public"}, {classfile.ACC_PRIVATE, "private"},
+		{classfile.ACC_PROTECTED, "protected"}, {classfile.ACC_STATIC, "static"}, {classfile.ACC_FINAL, "final"},
+		{classfile.ACC_VOLATILE, "volatile"}, {classfile.ACC_TRANSIENT, "transient"}}
+	methodModifiers = []flagName{{classfile.ACC_PUBLIC, "public"}, {classfile.ACC_PRIVATE, "private"},
+		{classfile.ACC_PROTECTED, "protected"}, {classfile.ACC_STATIC, "static"}, {classfile.ACC_FINAL, "final"},
+		{classfile.ACC_SYNCHRONIZED, "synchronized"}, {classfile.ACC_NATIVE, "native"},
+		{classfile.ACC_ABSTRACT, "abstract"}, {classfile.ACC_STRICT, "strictfp"}}
+)
+
+func flagNames(flags uint16, names []flagName) []string {
+	result := []string{}
+	for _, n := range names {
+		if flags&n.flag != 0 {
+			result = append(result, n.name)
+		}
+	}
+	return result
+}
+
+type classPrinter struct {
+	*printer
+	cf        *classfile.ClassFile
+	cp        classfile.ConstantPool
+	constants *disasm.Constants
+	options   Options
+	method    *classfile.MemberInfo // 正在输出的方法，用于反汇编和计算 args_size
+	formatErr error                 // 第一个格式错误
+}
+
+// 按照 javap 的格式把 class 文件的内容输出到 w，source 为 nil 时不输出文件信息
+// 描述符或字节码格式错误等问题只影响所在的声明、成员或属性，这一部分输出为 "Error: ..." 行，其余部分照常输出，
+// 最后返回遇到的第一个格式错误
+func Write(w io.Writer, cf *classfile.ClassFile, source *Source, options Options) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			var ok bool
+			err, ok = r.(error)
+			if !ok {
+				err = fmt.Errorf("%v", r)
+			}
+		}
+	}()
+
+	if options.Verbose {
+		options.Code, options.Lines, options.Descriptors, options.Private = true, true, true, true
+	}
+	p := &classPrinter{printer: newPrinter(w), cf: cf, cp: cf.ConstantPool(), constants: disasm.NewConstants(cf), options: options}
+	p.writeClass(source)
+	if p.err != nil {
+		return p.err
+	}
+	return p.formatErr
+}
+
+// 输出 class 文件的一部分（类的声明、一个成员或一个属性），出错时与 javap 一样输出 "Error: ..." 并继续
+func (self *classPrinter) section(fn func()) {
+	indent := self.indent
+	defer func() {
+		if r := recover(); r != nil {
+			err, ok := r.(error)
+			if !ok {
+				err = fmt.Errorf("%v", r)
+			}
+			if self.formatErr == nil {
+				self.formatErr = err
+			}
+			self.endLine()
+			self.indent = indent
+			self.println("Error: " + err.Error())
+		}
+	}()
+	fn()
+}
+
+func (self *classPrinter) writeClass(source *Source) {
+	// -v 时 Compiled from 与文件信息一起缩进输出
+	header := self.options.Verbose && source != nil
+	if header {
+		self.writeSource(source)
+		self.indent++
+	}
+	if sourceFile := self.sourceFile(); sourceFile != nil {
+		self.println(fmt.Sprintf("Compiled from \"%s\"", sourceFile.FileName()))
+	}
+	if header {
+		self.indent--
+	}
+
+	self.section(self.writeDeclaration)
+	if self.options.Verbose {
+		self.endLine()
+		self.indent++
+		self.println(fmt.Sprintf("minor version: %d", self.cf.MinorVersion()))
+		self.println(fmt.Sprintf("major version: %d", self.cf.MajorVersion()))
+		self.writeFlags(self.cf.AccessFlags(), classfile.ClassAccessFlags(self.cf.AccessFlags()).Names())
+		self.writeClassIndex("this_class", self.cf.ThisClass())
+		self.writeClassIndex("super_class", self.cf.SuperClass())
+		self.println(fmt.Sprintf("interfaces: %d, fields: %d, methods: %d, attributes: %d",
+			len(self.cf.Interfaces()), len(self.cf.Fileds()), len(self.cf.Methods()), len(self.cf.Attributes())))
+		self.indent--
+		self.writeConstantPool()
+	} else if self.lineIndent >= 0 {
+		self.print(" ")
+	}
+
+	self.println("{")
+	self.indent++
+	separate := false // 成员之间是否空一行
+	for _, field := range self.cf.Fileds() {
+		self.section(func() { separate = self.writeField(field, separate) })
+	}
+	for _, method := range self.cf.Methods() {
+		self.section(func() { separate = self.writeMethod(method, separate) })
+	}
+	self.indent--
+	self.println("}")
+
+	if self.options.Verbose {
+		self.writeAttributes(self.cf.Attributes())
+	}
+}
+
+// 输出文件位置、修改时间、大小和 SHA-256 校验和
+func (self *classPrinter) writeSource(source *Source) {
+	self.println("Classfile " + source.Location)
+	self.indent++
+	if !source.ModTime.IsZero() {
+		self.println(fmt.Sprintf("Last modified %s; size %d bytes", source.ModTime.Format("Jan 2, 2006"), len(source.Data)))
+	} else {
+		self.println(fmt.Sprintf("Size %d bytes", len(source.Data)))
+	}
+	self.println(fmt.Sprintf("SHA-256 checksum %x", sha256.Sum256(source.Data)))
+	self.indent--
+}
+
+func (self *classPrinter) sourceFile() *classfile.SourceFileAttribute {
+	for _, attr := range self.cf.Attributes() {
+		if sourceFile, ok := attr.(*classfile.SourceFileAttribute); ok {
+			return sourceFile
+		}
+	}
+	return nil
+}
+
+// 类的声明，例如 public class Hello extends Base implements java.lang.Runnable,java.io.Serializable
+func (self *classPrinter) writeDeclaration() {
+	flags := self.cf.AccessFlags()
+	if flags&classfile.ACC_MODULE != 0 {
+		self.print("module " + self.moduleName())
+		return
+	}
+	modifiers := classModifiers
+	if flags&classfile.ACC_INTERFACE != 0 {
+		modifiers = classModifiers[:2] // 接口声明中省略 abstract
+	}
+	self.writeModifiers(flags, modifiers)
+	if flags&classfile.ACC_INTERFACE != 0 {
+		self.print("interface ")
+	} else {
+		self.print("class ")
+	}
+	self.print(javaName(self.cf.ClassName()))
+
+	if flags&classfile.ACC_INTERFACE == 0 && self.cf.SuperClass() != 0 {
+		if super := self.className(self.cf.SuperClass()); super != "java.lang.Object" {
+			self.print(" extends " + super)
+		}
+	}
+	for i, index := range self.cf.Interfaces() {
+		switch {
+		case i > 0:
+			self.print(",")
+		case flags&classfile.ACC_INTERFACE != 0:
+			self.print(" extends ")
+		default:
+			self.print(" implements ")
+		}
+		self.print(self.className(index))
+	}
+}
+
+func (self *classPrinter) moduleName() string {
+	if md, err := self.cf.ModuleDescriptor(); err == nil && md != nil {
+		if md.Version() != "" {
+			return md.Name() + "@" + md.Version()
+		}
+		return md.Name()
+	}
+	return javaName(self.cf.ClassName())
+}
+
+func (self *classPrinter) writeModifiers(flags uint16, modifiers []flagName) {
+	for _, name := range flagNames(flags, modifiers) {
+		self.print(name + " ")
+	}
+}
+
+func (self *classPrinter) writeFlags(flags uint16, names []string) {
+	self.println(fmt.Sprintf("flags: (0x%04x) %s", flags, strings.Join(names, ", ")))
+}
+
+func (self *classPrinter) writeClassIndex(name string, index uint16) {
+	self.print(fmt.Sprintf("%s: #%d", name, index))
+	if index != 0 {
+		self.tab()
+		self.print("// " + self.stringValue(index))
+	}
+	self.println("")
+}
+
+// 私有成员只有在 -p 或 -v 时输出
+func (self *classPrinter) accessible(flags uint16) bool {
+	return self.options.Private || flags&classfile.ACC_PRIVATE == 0
+}
+
+// 输出字段，返回下一个成员之前是否需要空一行
+func (self *classPrinter) writeField(field *classfile.MemberInfo, separate bool) bool {
+	if !self.accessible(field.AccessFlags()) {
+		return separate
+	}
+	if separate {
+		self.println("")
+	}
+	fieldType := javaType(field.Descriptor())
+	self.writeModifiers(field.AccessFlags(), fieldModifiers)
+	self.println(fieldType + " " + field.Name() + ";")
+
+	self.indent++
+	if self.options.Descriptors {
+		self.println("descriptor: " + field.Descriptor())
+	}
+	if self.options.Verbose {
+		self.writeFlags(field.AccessFlags(), classfile.FieldAccessFlags(field.AccessFlags()).Names())
+		self.writeAttributes(field.Attributes())
+	}
+	self.indent--
+	return self.options.Descriptors || self.options.Code || self.options.Lines
+}
+
+// 输出方法，返回下一个成员之前是否需要空一行
+func (self *classPrinter) writeMethod(method *classfile.MemberInfo, separate bool) bool {
+	flags := method.AccessFlags()
+	if !self.accessible(flags) {
+		return separate
+	}
+	if separate {
+		self.println("")
+	}
+	self.method = method
+
+	params, ret := javaMethodTypes(method.Descriptor())
+	self.writeModifiers(flags, methodModifiers)
+	if flags&classfile.ACC_VARARGS != 0 && len(params) > 0 {
+		last := len(params) - 1
+		params[last] = strings.TrimSuffix(params[last], "[]") + "..."
+	}
+	switch method.Name() {
+	case "<init>":
+		self.print(javaName(self.cf.ClassName()) + "(" + strings.Join(params, ", ") + ")")
+	case "<clinit>":
+		self.print("{}")
+	default:
+		self.print(ret + " " + method.Name() + "(" + strings.Join(params, ", ") + ")")
+	}
+	if exceptions := method.ExceptionsAttribute(); exceptions != nil {
+		names := make([]string, len(exceptions.ExceptionIndexTable()))
+		for i, index := range exceptions.ExceptionIndexTable() {
+			names[i] = self.className(index)
+		}
+		self.print(" throws " + strings.Join(names, ", "))
+	}
+	self.println(";")
+
+	self.indent++
+	if self.options.Descriptors {
+		self.println("descriptor: " + method.Descriptor())
+	}
+	if self.options.Verbose {
+		self.writeFlags(flags, classfile.MethodAccessFlags(flags).Names())
+		self.writeAttributes(method.Attributes())
+	} else if code := method.CodeAttribute(); code != nil {
+		if self.options.Code {
+			self.println("Code:")
+			self.writeInstructions()
+			self.writeExceptionTable(code)
+		}
+		if self.options.Lines {
+			if lines := code.LineNumberTableAttribute(); lines != nil {
+				self.writeAttribute(lines)
+			}
+			if vars := code.LocalVariableTableAttribute(); vars != nil {
+				self.writeAttribute(vars)
+			}
+		}
+	}
+	self.indent--
+	return self.options.Descriptors || self.options.Code || self.options.Lines
+}
@@ -0,0 +1,62 @@
+package javap
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"jvmgo/ch03_classfile/classfile"
+)
+
+var update = flag.Bool("update", false, "重新生成 testdata 中的 .txt 文件")
+
+// testdata 中每个 .class 文件和同名的 .txt 文件是一对，.txt 是本包以 -v 选项输出的结果，
+// 由 go test -update 生成并经人工检查，没有与 JDK 的 javap 逐字比较，只用来发现输出的意外变化
+// Malformed.class 中有循环引用的常量、错误的描述符和跳转到无效 pc 的指令，
+// 输出中对应的部分为 "Error: ..." 行，其余部分照常输出
+func TestWriteGolden(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.class"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no class files in testdata")
+	}
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".class")
+		t.Run(name, func(t *testing.T) {
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cf, err := classfile.Parse(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			source := &Source{Location: filepath.Base(file), Data: data}
+			err = Write(&buf, cf, source, Options{Verbose: true})
+			if wantErr := strings.HasPrefix(name, "Malformed"); (err != nil) != wantErr {
+				t.Errorf("Write() error = %v, want error: %v", err, wantErr)
+			}
+
+			golden := strings.TrimSuffix(file, ".class") + ".txt"
+			if *update {
+				if err := ioutil.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != string(want) {
+				t.Errorf("output differs from %s:\n%s", golden, got)
+			}
+		})
+	}
+}
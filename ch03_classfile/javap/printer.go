@@ -0,0 +1,84 @@
+package javap
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// javap 的输出规则：
+// 1. 每一级缩进为 2 个空格，缩进在一行中第一个非空白字符输出时确定，之后改变缩进只影响下一行
+// 2. tab() 把当前行（不含缩进）补齐到第 40 列，用于对齐 "// " 开头的注释，超过 40 列时只补一个空格
+// 3. 行尾的空白字符会被丢弃
+const (
+	indentWidth = 2
+	tabColumn   = 40
+)
+
+type printer struct {
+	w          io.Writer
+	indent     int
+	line       strings.Builder // 当前行，不含缩进
+	lineIndent int             // 当前行的缩进级别，-1 表示当前行还没有非空白字符
+	err        error
+}
+
+func newPrinter(w io.Writer) *printer {
+	return &printer{w: w, lineIndent: -1}
+}
+
+// 输出字符串，字符串中的 "\n" 会结束当前行
+func (self *printer) print(s string) {
+	for {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			self.append(s)
+			return
+		}
+		self.append(s[:i])
+		self.println("")
+		s = s[i+1:]
+	}
+}
+
+func (self *printer) append(s string) {
+	if self.lineIndent < 0 && strings.TrimLeft(s, " ") != "" {
+		self.lineIndent = self.indent
+	}
+	self.line.WriteString(s)
+}
+
+func (self *printer) printf(format string, args ...interface{}) {
+	self.print(fmt.Sprintf(format, args...))
+}
+
+func (self *printer) println(s string) {
+	self.print(s)
+	if self.err != nil {
+		return
+	}
+	line := strings.TrimRight(self.line.String(), " ")
+	if line != "" {
+		line = strings.Repeat(" ", self.lineIndent*indentWidth) + line
+	}
+	_, self.err = io.WriteString(self.w, line+"\n")
+	self.line.Reset()
+	self.lineIndent = -1
+}
+
+// 结束已经输出了内容的当前行，当前行为空时什么也不做
+func (self *printer) endLine() {
+	if self.lineIndent >= 0 {
+		self.println("")
+	} else {
+		self.line.Reset()
+	}
+}
+
+func (self *printer) tab() {
+	n := tabColumn - self.line.Len()
+	if n < 1 {
+		n = 1
+	}
+	self.line.WriteString(strings.Repeat(" ", n))
+}
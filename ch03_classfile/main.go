@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"jvmgo/ch03_classfile/classfile"
 	"jvmgo/ch03_classfile/classpath"
 	"strings"
 )
@@ -11,7 +12,19 @@ func main() {
 
 	if cmd.versionFlag {
 		fmt.Println("version 0.0.1")
-	} else if cmd.helpFlag || cmd.class == "" {
+	} else if cmd.tool == "classpath" {
+		runClasspathCommand(cmd) // 子命令，剩余参数由子命令自己解析
+	} else if cmd.tool == "javap" {
+		runJavapCommand(cmd)
+	} else if cmd.tool == "asm" {
+		runAsmCommand(cmd)
+	} else if cmd.tool == "diff" {
+		runDiffCommand(cmd)
+	} else if cmd.tool == "deps" {
+		runDepsCommand(cmd)
+	} else if cmd.describeModuleFlag {
+		describeModule(cmd)
+	} else if cmd.helpFlag || (cmd.class == "" && cmd.moduleOption == "" && cmd.jarOption == "") {
 		printUsage()
 	} else {
 		startJVM(cmd)
@@ -19,14 +32,106 @@ func main() {
 }
 
 func startJVM(cmd *Cmd) {
-	cp := classpath.Parse(cmd.XjreOption, cmd.cpOption) // 制作 classpath
+	cp, err := classpath.Parse(cmd.XjreOption, cmd.cpOption) // 制作 classpath
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if cmd.XstatsClasspathFlag {
+		defer fmt.Printf("classpath stats: %v\n", cp.Stats())
+	}
+	if cmd.jarOption != "" {
+		mainClass, err := cp.UseJar(cmd.jarOption)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		cmd.class = mainClass
+	}
+	if cmd.moduleOption != "" && !resolveMainModule(cmd, cp) {
+		return
+	}
+	if cmd.XlintClasspathFlag {
+		printClasspathWarnings(cp)
+	}
 	fmt.Printf("classpath:%v mainclass:%v args:%v\n", cp, cmd.class, cmd.args)
 
 	className := strings.Replace(cmd.class, ".", "/", -1) // 根据主类名制作 main class 路径
-	classData, _, err := cp.ReadClass(className)          // 读取主类文件
+	var classData []byte
+	if cmd.moduleOption != "" { // 以主模块的视角读取主类，只能看到启动类路径和主模块可以读取的模块
+		moduleName, _ := splitModuleOption(cmd.moduleOption)
+		classData, _, err = cp.ReadClassFromModule(moduleName, className)
+	} else {
+		classData, _, err = cp.ReadClass(className) // 读取主类文件
+	}
 	if err != nil {
 		fmt.Printf("Cannot find or load main class %s\n", cmd.class)
 		return
 	}
 	fmt.Printf("class data:%v\n", classData)
 }
+
+// -Xlint:classpath 模式下输出选择 JRE 目录的过程和 classpath 中有问题的路径
+func printClasspathWarnings(cp *classpath.Classpath) {
+	fmt.Println(cp.Jre())
+	for _, diag := range cp.Diagnostics() {
+		fmt.Printf("warning: [classpath] %v\n", diag)
+	}
+}
+
+// 从模块路径解析 -m 选项指定的主模块，主类未指定时使用主模块 ModuleMainClass 属性给出的主类
+func resolveMainModule(cmd *Cmd, cp *classpath.Classpath) bool {
+	moduleName, mainClass := splitModuleOption(cmd.moduleOption)
+	if err := cp.ResolveModules(cmd.modulePathOption, moduleName); err != nil {
+		fmt.Printf("Error occurred during initialization of boot layer\n%v\n", err)
+		return false
+	}
+	if mainClass == "" {
+		if md := cp.Module(moduleName).Descriptor(); md != nil {
+			mainClass = strings.Replace(md.MainClass(), "/", ".", -1)
+		}
+	}
+	if mainClass == "" {
+		fmt.Printf("module %s does not have a ModuleMainClass attribute, use -m <module>/<main-class>\n", moduleName)
+		return false
+	}
+	cmd.class = mainClass
+	return true
+}
+
+// -m 选项的格式为 module[/main-class]
+func splitModuleOption(option string) (moduleName, mainClass string) {
+	if i := strings.Index(option, "/"); i >= 0 {
+		return option[:i], option[i+1:]
+	}
+	return option, ""
+}
+
+// 从 classpath 中读取并解析 module-info.class，输出其模块描述符，类似 `java --describe-module`
+func describeModule(cmd *Cmd) {
+	cp, err := classpath.Parse(cmd.XjreOption, cmd.cpOption)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	classData, _, err := cp.ReadClass("module-info")
+	if err != nil {
+		fmt.Printf("Cannot find module-info.class in classpath %s\n", cp)
+		return
+	}
+	cf, err := classfile.Parse(classData)
+	if err != nil {
+		fmt.Printf("Cannot parse module-info.class: %v\n", err)
+		return
+	}
+	md, err := cf.ModuleDescriptor()
+	if err != nil {
+		fmt.Printf("Cannot parse module-info.class: %v\n", err)
+		return
+	}
+	if md == nil {
+		fmt.Println("module-info.class has no Module attribute")
+		return
+	}
+	fmt.Println(md)
+}